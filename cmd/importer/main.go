@@ -0,0 +1,67 @@
+package main
+
+import (
+	"chatapp/internal/clients"
+	"chatapp/internal/config"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"chatapp/internal/services/hash"
+	"chatapp/internal/services/slackimport"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// importer loads a Slack workspace export into the database:
+//
+//	go run ./cmd/importer -file slack-export.zip
+//
+// Imports are idempotent, an interrupted import is resumed by running it again.
+func main() {
+	file := flag.String("file", "", "path to the Slack export zip")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logger := logger.NewLogger()
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	//parse config
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logger.Fatal(ctx, fmt.Errorf("failed to load config: %w", err))
+	}
+	//run clients
+	cls, err := clients.NewClients(ctx, cfg, logger)
+	if err != nil {
+		logger.Fatal(ctx, err)
+	}
+	defer func() {
+		if err := cls.Postgres.Close(); err != nil {
+			logger.Error(ctx, fmt.Errorf("failed to close Database connection: %w", err))
+		}
+	}()
+
+	rps := repositories.NewRepositories(cls)
+	importer := slackimport.NewService(cfg, logger, rps, hash.NewService(cfg))
+
+	report, err := importer.Import(ctx, *file)
+	if report != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	}
+	if err != nil {
+		logger.Error(ctx, fmt.Errorf("slack import failed: %w", err))
+		stop()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	chat "chatapp/internal/entities/chat"
+	"chatapp/internal/logger"
+	"chatapp/internal/services"
+	"chatapp/internal/services/chat/utils"
+	"chatapp/internal/services/retention"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	srvs   *services.Services
+	logger logger.Logger
+}
+
+func NewHandler(srvs *services.Services, logger logger.Logger) *Handler {
+	return &Handler{
+		srvs:   srvs,
+		logger: logger,
+	}
+}
+
+// SetLegalHoldRequestPayload represents the request payload for a legal hold.
+// swagger:model
+type SetLegalHoldRequestPayload struct {
+	// Suspends purging of the conversation when true
+	LegalHold bool `json:"legal_hold"`
+}
+
+// SetLegalHoldResp200Body represents the legal hold of a conversation.
+// swagger:model
+type SetLegalHoldResp200Body struct {
+	// required: true
+	ConversationID int64 `json:"conversation_id"`
+	// required: true
+	LegalHold bool `json:"legal_hold"`
+}
+
+// @Summary      Set legal hold
+// @Description  Suspends or resumes purging of the conversation by retention policies. Participants are not notified. Only system administrators can place legal holds.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        payload  body      SetLegalHoldRequestPayload  true  "Set Legal Hold Payload"
+// @Success      200      {object}  SetLegalHoldResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/admin/conversations/{conversationId}/legal-hold [put]
+func (h *Handler) SetLegalHold(ctx *fiber.Ctx) error {
+	reqBody := &SetLegalHoldRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	u := auth.MustGetUser(ctx)
+
+	cnv, err := h.srvs.RetentionService.SetLegalHold(ctx.Context(), u, cnvId, reqBody.LegalHold)
+	if err != nil {
+		return adminError(err)
+	}
+	return ctx.JSON(&SetLegalHoldResp200Body{
+		ConversationID: cnv.ID,
+		LegalHold:      cnv.LegalHold,
+	})
+}
+
+// RetentionRunsResp200Body represents the audit log of retention purges.
+// swagger:model
+type RetentionRunsResp200Body struct {
+	// required: true
+	Runs []*chat.RetentionRun `json:"runs"`
+}
+
+// @Summary      Retention audit log
+// @Description  Lists purge runs of retention policies, newest first, with the number of messages purged per conversation.
+// @Tags         admin
+// @Produce      json
+// @Param        limit  query int   false "Number of runs to retrieve" default(20)
+// @Param        lastID query int64 false "ID of the last run received"
+// @Success      200      {object}  RetentionRunsResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/admin/retention-runs [get]
+func (h *Handler) GetRetentionRuns(ctx *fiber.Ctx) error {
+	limit, err := strconv.Atoi(ctx.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		return fiber.ErrBadRequest
+	}
+	var lastID *int64
+	if lastIDStr := ctx.Query("lastID", ""); lastIDStr != "" {
+		id, err := strconv.ParseInt(lastIDStr, 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+		lastID = &id
+	}
+	u := auth.MustGetUser(ctx)
+
+	runs, err := h.srvs.RetentionService.GetRuns(ctx.Context(), u, lastID, limit)
+	if err != nil {
+		return adminError(err)
+	}
+	return ctx.JSON(&RetentionRunsResp200Body{
+		Runs: runs,
+	})
+}
+
+func adminError(err error) error {
+	switch {
+	case errors.Is(err, utils.ErrConversationNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, retention.ErrAdminRequired):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return fiber.ErrInternalServerError
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"bufio"
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	"chatapp/internal/services/account"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary      Export personal data
+// @Description  Downloads a zip archive of everything tied to the account: profile, identities, sessions,
+// @Description  API keys, bots, conversations and sent messages.
+// @Tags         authentication
+// @Produce      application/zip
+// @Success      200
+// @Security     UserTokenAuth
+// @Router       /api/v1/profile/export [get]
+func (h *Handler) ExportPersonalData(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+
+	ctx.Set(fiber.HeaderContentType, "application/zip")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="personal-data-%d.zip"`, u.ID))
+	// the writer runs after the handler returns, so it can't use the request context
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := h.srvs.AccountService.WriteExport(context.Background(), u.ID, w); err != nil {
+			h.logger.Error(context.Background(), fmt.Errorf("failed to stream personal data export: %w", err), slog.Int64("userId", u.ID))
+		}
+	})
+	return nil
+}
+
+// DeleteAccountRequestPayload confirms the account deletion.
+// swagger:model
+type DeleteAccountRequestPayload struct {
+	// Current password of the account
+	// required: true
+	Password string `json:"password" validate:"required,max=128"`
+}
+
+// @Summary      Delete account
+// @Description  Anonymizes or deletes the account depending on the server policy and signs it out everywhere.
+// @Description  Sent messages are kept in their conversations with a deleted user as sender.
+// @Tags         authentication
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      DeleteAccountRequestPayload  true  "Delete Account Request Payload"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/profile [delete]
+func (h *Handler) DeleteAccount(ctx *fiber.Ctx) error {
+	reqBody := &DeleteAccountRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.AccountService.DeleteAccount(ctx.Context(), u, reqBody.Password); err != nil {
+		switch {
+		case errors.Is(err, account.ErrInvalidPassword):
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		case errors.Is(err, account.ErrAccountDeleted):
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		default:
+			return fiber.ErrInternalServerError
+		}
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/services/block"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary      Block user
+// @Description  Hides messages of the user from you. The user can no longer start direct conversations with you or add you to groups.
+// @Tags         users
+// @Produce      json
+// @Param        userId path int64 true "User ID"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/users/{userId}/block [post]
+func (h *Handler) BlockUser(ctx *fiber.Ctx) error {
+	usrId, err := strconv.ParseInt(ctx.Params("userId"), 10, 64)
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.BlockService.Block(ctx.Context(), u.ID, usrId); err != nil {
+		return blockError(err)
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+// @Summary      Unblock user
+// @Tags         users
+// @Produce      json
+// @Param        userId path int64 true "User ID"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/users/{userId}/block [delete]
+func (h *Handler) UnblockUser(ctx *fiber.Ctx) error {
+	usrId, err := strconv.ParseInt(ctx.Params("userId"), 10, 64)
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.BlockService.Unblock(ctx.Context(), u.ID, usrId); err != nil {
+		return blockError(err)
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+// BlocksResp200Body represents the users blocked by the user.
+// swagger:model
+type BlocksResp200Body struct {
+	// required: true
+	Blocks []*users.Block `json:"blocks"`
+}
+
+// @Summary      Get blocked users
+// @Tags         users
+// @Produce      json
+// @Success      200      {object}  BlocksResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/blocks [get]
+func (h *Handler) GetBlocks(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+
+	blocks, err := h.srvs.BlockService.GetBlocks(ctx.Context(), u.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&BlocksResp200Body{
+		Blocks: blocks,
+	})
+}
+
+func blockError(err error) error {
+	switch {
+	case errors.Is(err, block.ErrUserNotFound), errors.Is(err, block.ErrNotBlocked):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, block.ErrCannotBlockSelf):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return fiber.ErrInternalServerError
+}
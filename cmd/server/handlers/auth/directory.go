@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	user_dto "chatapp/internal/dto/user"
+	"chatapp/internal/entities/users"
+	authServ "chatapp/internal/services/auth"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SearchUsersResp200Body represents a page of the user directory.
+// swagger:model
+type SearchUsersResp200Body struct {
+	// required: true
+	Users []*users.PublicUser `json:"users"`
+}
+
+// @Summary      Search users
+// @Description  Finds users by the start of their user name or display name.
+// @Description  Users hidden from the directory, bots and deleted accounts are not listed.
+// @Tags         users
+// @Produce      json
+// @Param        q      query string false "User name or display name prefix"
+// @Param        limit  query int    false "Number of users to retrieve" default(20)
+// @Param        lastID query int64  false "ID of the last user received"
+// @Success      200      {object}  SearchUsersResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/users [get]
+func (h *Handler) SearchUsers(ctx *fiber.Ctx) error {
+	limit, err := strconv.Atoi(ctx.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		return fiber.ErrBadRequest
+	}
+	var lastID *int64
+	if lastIDStr := ctx.Query("lastID", ""); lastIDStr != "" {
+		id, err := strconv.ParseInt(lastIDStr, 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+		lastID = &id
+	}
+
+	found, err := h.srvs.UserService.SearchUsers(ctx.Context(), ctx.Query("q", ""), lastID, limit)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&SearchUsersResp200Body{
+		Users: found,
+	})
+}
+
+// PublicProfileResp200Body represents the profile of another user.
+// swagger:model
+type PublicProfileResp200Body struct {
+	// required: true
+	User *users.PublicUser `json:"user"`
+}
+
+// @Summary      Get user
+// @Description  Returns the public profile of a user, emails are never included.
+// @Tags         users
+// @Produce      json
+// @Param        userId path int64 true "User ID"
+// @Success      200      {object}  PublicProfileResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/users/{userId} [get]
+func (h *Handler) GetUser(ctx *fiber.Ctx) error {
+	usrId, err := strconv.ParseInt(ctx.Params("userId"), 10, 64)
+	if err != nil {
+		return fiber.ErrBadRequest
+	}
+	u := auth.MustGetUser(ctx)
+
+	profile, err := h.srvs.UserService.GetPublicProfile(ctx.Context(), u, usrId)
+	if err != nil {
+		if errors.Is(err, authServ.ErrUserNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&PublicProfileResp200Body{
+		User: profile,
+	})
+}
+
+// UpdatePrivacyRequestPayload represents the privacy settings of the user.
+// swagger:model
+type UpdatePrivacyRequestPayload struct {
+	// "everyone" lists the user in the directory, "conversations" shows the profile
+	// only to people sharing a conversation
+	Discoverability *string `json:"discoverability" validate:"omitempty,oneof=everyone conversations"`
+	// "contacts" lets only contacts start direct conversations with the user
+	DMPolicy *string `json:"dm_policy" validate:"omitempty,oneof=everyone contacts"`
+}
+
+// @Summary      Update privacy settings
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      UpdatePrivacyRequestPayload  true  "Update Privacy Request Payload"
+// @Success      200      {object}  ProfileResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/profile/privacy [put]
+func (h *Handler) UpdatePrivacy(ctx *fiber.Ctx) error {
+	reqBody := &UpdatePrivacyRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	user, err := h.srvs.UserService.UpdatePrivacy(ctx.Context(), u, &user_dto.UpdatePrivacyDTO{
+		Discoverability: reqBody.Discoverability,
+		DMPolicy:        reqBody.DMPolicy,
+	})
+	if err != nil {
+		if errors.Is(err, authServ.ErrInvalidDiscoverability) || errors.Is(err, authServ.ErrInvalidDMPolicy) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(ProfileResp200Body{
+		User: user,
+	})
+}
+
+// UpdateNotificationsRequestPayload represents the notification settings of the user.
+// swagger:model
+type UpdateNotificationsRequestPayload struct {
+	// "all" pushes every message, "mentions" only mentions and direct messages, "none" nothing
+	PushLevel *string `json:"push_level" validate:"omitempty,oneof=all mentions none"`
+	// DigestFrequency is how often unread messages are emailed while you are away
+	DigestFrequency *string `json:"digest_frequency" validate:"omitempty,oneof=daily weekly off"`
+	// QuietHours pause push notifications and digests, null start and end turn them off
+	QuietHours *QuietHoursPayload `json:"quiet_hours"`
+}
+
+// QuietHoursPayload is a range of hours in your timezone, it may span midnight like 22 to 7.
+// swagger:model
+type QuietHoursPayload struct {
+	Start *int `json:"start" validate:"omitempty,min=0,max=23"`
+	End   *int `json:"end" validate:"omitempty,min=0,max=23"`
+}
+
+// @Summary      Update notification settings
+// @Description  Push notifications are sent only while the user is not in the conversation and didn't mute it.
+// @Description  Unread messages are emailed as digests while the user is away. Quiet hours pause both.
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      UpdateNotificationsRequestPayload  true  "Update Notifications Request Payload"
+// @Success      200      {object}  ProfileResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/profile/notifications [put]
+func (h *Handler) UpdateNotificationSettings(ctx *fiber.Ctx) error {
+	reqBody := &UpdateNotificationsRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	dto := &user_dto.NotificationSettingsDTO{
+		PushLevel:       reqBody.PushLevel,
+		DigestFrequency: reqBody.DigestFrequency,
+	}
+	if reqBody.QuietHours != nil {
+		dto.QuietHours = &user_dto.QuietHoursDTO{
+			Start: reqBody.QuietHours.Start,
+			End:   reqBody.QuietHours.End,
+		}
+	}
+	user, err := h.srvs.UserService.UpdateNotificationSettings(ctx.Context(), u, dto)
+	if err != nil {
+		if errors.Is(err, authServ.ErrInvalidPushLevel) ||
+			errors.Is(err, authServ.ErrInvalidDigestFrequency) ||
+			errors.Is(err, authServ.ErrInvalidQuietHours) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(ProfileResp200Body{
+		User: user,
+	})
+}
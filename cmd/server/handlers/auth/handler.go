@@ -3,15 +3,22 @@ package auth
 import (
 	"chatapp/cmd/server/middlewares/auth"
 	reqparser "chatapp/cmd/server/utils/req_parser"
+	session_dto "chatapp/internal/dto/session"
 	user_dto "chatapp/internal/dto/user"
 	"chatapp/internal/entities/users"
 	"chatapp/internal/logger"
 	"chatapp/internal/repositories"
 	"chatapp/internal/services"
 	authServ "chatapp/internal/services/auth"
+	"chatapp/internal/services/auth/password"
+	"chatapp/internal/services/auth/session"
+	"chatapp/internal/services/auth/throttle"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -39,7 +46,7 @@ type RegisterRequestPayload struct {
 	UserName string `json:"name" validate:"required,min=3,max=20,alphanum"`
 	// Password of the user
 	// required: true
-	Password string `json:"password" validate:"required,min=8,max=20,alphanum"`
+	Password string `json:"password" validate:"required,max=128"`
 	// Email address of the user
 	// required: true
 	Email string `json:"email" validate:"required,email"`
@@ -74,6 +81,9 @@ func (h *Handler) Register(ctx *fiber.Ctx) error {
 		Password: reqBody.Password,
 	})
 	if err != nil {
+		if errors.Is(err, password.ErrWeakPassword) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
 		return errors.Join(fiber.ErrInternalServerError, err)
 	}
 	return ctx.JSON(&RegisterResponse200Payload{
@@ -87,22 +97,42 @@ func (h *Handler) Register(ctx *fiber.Ctx) error {
 type LoginRequestPayload struct {
 	// Password of the user
 	// required: true
-	Password string `json:"password" validate:"required,min=8,max=20,alphanum"`
+	Password string `json:"password" validate:"required,max=128"`
 	// Email address of the user
 	// required: true
 	Email string `json:"email" validate:"required,email"`
 }
 
 // LoginResp200Body represents a successful login response containing a JWT token.
+// When two-factor authentication is enabled only MFARequired and MFAToken are set.
 // swagger:model
 type LoginResp200Body struct {
 	// JWT token for authenticated requests
+	Token string `json:"token,omitempty"`
+	// Access token expiration time
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Refresh token used to obtain a new token pair
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// Refresh token expiration time
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
+	// Indicates that the login must be finished at /auth/signin/mfa
 	// required: true
-	Token string `json:"token"`
+	MFARequired bool `json:"mfa_required"`
+	// Short-lived challenge token for /auth/signin/mfa
+	MFAToken string `json:"mfa_token,omitempty"`
+}
+
+func newLoginResp200Body(pair *session_dto.TokenPair) *LoginResp200Body {
+	return &LoginResp200Body{
+		Token:            pair.AccessToken,
+		ExpiresAt:        &pair.AccessExpiresAt,
+		RefreshToken:     pair.RefreshToken,
+		RefreshExpiresAt: &pair.RefreshExpiresAt,
+	}
 }
 
 // @Summary      User login
-// @Description  Authenticates a user and returns a JWT token.
+// @Description  Authenticates a user and returns a JWT token, or an MFA challenge when two-factor authentication is enabled.
 // @Tags         authentication
 // @Accept       json
 // @Produce      json
@@ -114,22 +144,332 @@ func (h *Handler) Login(ctx *fiber.Ctx) error {
 	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
 		return errors.Join(fiber.ErrBadRequest, err)
 	}
-	user, err := h.srvs.UserService.Login(ctx.Context(), reqBody.Email, reqBody.Password)
+	res, err := h.srvs.UserService.Login(ctx.Context(), &user_dto.LoginDTO{
+		Email:     reqBody.Email,
+		Password:  reqBody.Password,
+		IP:        ctx.IP(),
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+	})
 	if err != nil {
-		if errors.Is(err, authServ.ErrUnAutorize) {
+		// a locked account looks like a wrong password, only its owner learns about the lock by email
+		if errors.Is(err, authServ.ErrUnAutorize) || errors.Is(err, authServ.ErrAccountLocked) {
 			return fiber.ErrForbidden
 		}
+		var throttled *throttle.ThrottledError
+		if errors.As(err, &throttled) {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			return fiber.ErrTooManyRequests
+		}
+		if errors.Is(err, authServ.ErrEmailNotVerified) {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
 		return errors.Join(fiber.ErrInternalServerError, err)
 	}
-	token, err := h.srvs.JwtService.CreateToken(ctx.Context(), user.ID)
+	if res.MFAToken != "" {
+		return ctx.JSON(&LoginResp200Body{
+			MFARequired: true,
+			MFAToken:    res.MFAToken,
+		})
+	}
+	return h.startSession(ctx, res.User)
+}
+
+// LoginMFARequestPayload represents the request payload for the second login step.
+// swagger:model
+type LoginMFARequestPayload struct {
+	// Challenge token returned by /auth/signin
+	// required: true
+	MFAToken string `json:"mfa_token" validate:"required"`
+	// Code from the authenticator app
+	Code string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	// One of the recovery codes, used instead of Code
+	RecoveryCode string `json:"recovery_code" validate:"omitempty,max=32"`
+}
+
+// @Summary      Finish login with two-factor code
+// @Description  Exchanges an MFA challenge token and a TOTP or recovery code for a JWT token.
+// @Tags         authentication
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      LoginMFARequestPayload  true  "Login MFA Request Payload"
+// @Success      200      {object}  LoginResp200Body
+// @Router       /api/v1/auth/signin/mfa [post]
+func (h *Handler) LoginMFA(ctx *fiber.Ctx) error {
+	reqBody := &LoginMFARequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	user, err := h.srvs.UserService.VerifyMFALogin(ctx.Context(), &user_dto.MFALoginDTO{
+		Token:        reqBody.MFAToken,
+		Code:         reqBody.Code,
+		RecoveryCode: reqBody.RecoveryCode,
+		IP:           ctx.IP(),
+		UserAgent:    ctx.Get(fiber.HeaderUserAgent),
+	})
+	if err != nil {
+		if errors.Is(err, authServ.ErrInvalidToken) || errors.Is(err, authServ.ErrInvalidMFACode) {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
+		if errors.Is(err, authServ.ErrAccountLocked) {
+			return fiber.NewError(fiber.StatusForbidden, authServ.ErrInvalidMFACode.Error())
+		}
+		return fiber.ErrInternalServerError
+	}
+	return h.startSession(ctx, user)
+}
+
+func (h *Handler) startSession(ctx *fiber.Ctx, user *users.User) error {
+	pair, err := h.srvs.SessionService.CreateSession(ctx.Context(), &session_dto.CreateSessionDTO{
+		UserID:    user.ID,
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+		IP:        ctx.IP(),
+	})
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	return ctx.JSON(newLoginResp200Body(pair))
+}
+
+// refresh endpoint
+// RefreshRequestPayload represents the request payload for token refresh.
+// swagger:model
+type RefreshRequestPayload struct {
+	// Refresh token received on sign in or previous refresh
+	// required: true
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// @Summary      Refresh tokens
+// @Description  Rotates the refresh token and returns a new token pair.
+// @Tags         authentication
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      RefreshRequestPayload  true  "Refresh Request Payload"
+// @Success      200      {object}  LoginResp200Body
+// @Router       /api/v1/auth/refresh [post]
+func (h *Handler) Refresh(ctx *fiber.Ctx) error {
+	reqBody := &RefreshRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	pair, err := h.srvs.SessionService.Refresh(ctx.Context(), reqBody.RefreshToken)
 	if err != nil {
+		if errors.Is(err, session.ErrInvalidRefreshToken) {
+			return fiber.ErrUnauthorized
+		}
 		return fiber.ErrInternalServerError
 	}
+	return ctx.JSON(newLoginResp200Body(pair))
+}
 
-	resp := &LoginResp200Body{
-		Token: token,
+// LogoutResp200Body represents a successful logout response.
+// swagger:model
+type LogoutResp200Body struct {
+	// Indicates whether the operation was successful.
+	// required: true
+	Success bool `json:"success"`
+}
+
+// @Summary      Logout
+// @Description  Revokes the current session.
+// @Tags         authentication
+// @Produce      json
+// @Success      200      {object}  LogoutResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/auth/logout [post]
+func (h *Handler) Logout(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+	sessionId := auth.MustGetSessionID(ctx)
+
+	if err := h.srvs.SessionService.RevokeSession(ctx.Context(), u.ID, sessionId); err != nil && !errors.Is(err, session.ErrSessionNotFound) {
+		return fiber.ErrInternalServerError
 	}
-	return ctx.JSON(resp)
+	return ctx.JSON(&LogoutResp200Body{
+		Success: true,
+	})
+}
+
+// SessionsResp200Body represents a list of user's active sessions.
+// swagger:model
+type SessionsResp200Body struct {
+	// Active sessions of the user
+	// required: true
+	Sessions []*users.Session `json:"sessions"`
+	// ID of the session used for this request
+	// required: true
+	CurrentSessionID int64 `json:"current_session_id"`
+}
+
+// @Summary      List sessions
+// @Description  Lists devices with an active session.
+// @Tags         authentication
+// @Produce      json
+// @Success      200      {object}  SessionsResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/auth/sessions [get]
+func (h *Handler) GetSessions(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+
+	sessions, err := h.srvs.SessionService.GetSessions(ctx.Context(), u.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&SessionsResp200Body{
+		Sessions:         sessions,
+		CurrentSessionID: auth.MustGetSessionID(ctx),
+	})
+}
+
+// @Summary      Revoke session
+// @Description  Revokes one of the user's sessions, access tokens of the session stop working immediately.
+// @Tags         authentication
+// @Produce      json
+// @Param        sessionId path int64 true "Session ID"
+// @Success      200      {object}  LogoutResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/auth/sessions/{sessionId} [delete]
+func (h *Handler) RevokeSession(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+	sessionId, err := strconv.ParseInt(ctx.Params("sessionId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	if err := h.srvs.SessionService.RevokeSession(ctx.Context(), u.ID, sessionId); err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&LogoutResp200Body{
+		Success: true,
+	})
+}
+
+// SuccessResp200Body represents a successful response without data.
+// swagger:model
+type SuccessResp200Body struct {
+	// Indicates whether the operation was successful.
+	// required: true
+	Success bool `json:"success"`
+}
+
+// VerifyEmailRequestPayload represents the request payload for email verification.
+// swagger:model
+type VerifyEmailRequestPayload struct {
+	// Token from the verification email
+	// required: true
+	Token string `json:"token" validate:"required"`
+}
+
+// @Summary      Verify email
+// @Description  Confirms ownership of the email with a token sent on sign up.
+// @Tags         authentication
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      VerifyEmailRequestPayload  true  "Verify Email Request Payload"
+// @Success      200      {object}  SuccessResp200Body
+// @Router       /api/v1/auth/verify-email [post]
+func (h *Handler) VerifyEmail(ctx *fiber.Ctx) error {
+	reqBody := &VerifyEmailRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	if err := h.srvs.UserService.VerifyEmail(ctx.Context(), reqBody.Token); err != nil {
+		if errors.Is(err, authServ.ErrInvalidToken) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+// EmailRequestPayload represents a request payload containing only an email.
+// swagger:model
+type EmailRequestPayload struct {
+	// Email address of the user
+	// required: true
+	Email string `json:"email" validate:"required,email"`
+}
+
+// @Summary      Resend verification email
+// @Description  Sends a new verification email. The response is the same whether the email is registered or not.
+// @Tags         authentication
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      EmailRequestPayload  true  "Email Request Payload"
+// @Success      200      {object}  SuccessResp200Body
+// @Router       /api/v1/auth/verify-email/resend [post]
+func (h *Handler) ResendVerificationEmail(ctx *fiber.Ctx) error {
+	reqBody := &EmailRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	if err := h.srvs.UserService.ResendVerificationEmail(ctx.Context(), reqBody.Email); err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("resend verification endpoint error: %w", err))
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+// @Summary      Forgot password
+// @Description  Sends a password reset link. The response is the same whether the email is registered or not.
+// @Tags         authentication
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      EmailRequestPayload  true  "Email Request Payload"
+// @Success      200      {object}  SuccessResp200Body
+// @Router       /api/v1/auth/forgot-password [post]
+func (h *Handler) ForgotPassword(ctx *fiber.Ctx) error {
+	reqBody := &EmailRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	if err := h.srvs.UserService.ForgotPassword(ctx.Context(), reqBody.Email); err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("forgot password endpoint error: %w", err))
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+// ResetPasswordRequestPayload represents the request payload for password reset.
+// swagger:model
+type ResetPasswordRequestPayload struct {
+	// Token from the password reset email
+	// required: true
+	Token string `json:"token" validate:"required"`
+	// New password
+	// required: true
+	Password string `json:"password" validate:"required,max=128"`
+}
+
+// @Summary      Reset password
+// @Description  Sets a new password using a token from the reset email, all sessions are signed out.
+// @Tags         authentication
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      ResetPasswordRequestPayload  true  "Reset Password Request Payload"
+// @Success      200      {object}  SuccessResp200Body
+// @Router       /api/v1/auth/reset-password [post]
+func (h *Handler) ResetPassword(ctx *fiber.Ctx) error {
+	reqBody := &ResetPasswordRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	if err := h.srvs.UserService.ResetPassword(ctx.Context(), reqBody.Token, reqBody.Password); err != nil {
+		if errors.Is(err, authServ.ErrInvalidToken) || errors.Is(err, password.ErrWeakPassword) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
 }
 
 // profile endpoint
@@ -147,6 +487,7 @@ type ProfileResp200Body struct {
 // @Produce      json
 // @Success      200      {object}  ProfileResp200Body
 // @Security     UserTokenAuth
+// @Security     APIKeyAuth
 // @Router       /api/v1/auth/profile [get]
 func (h *Handler) GetProfile(ctx *fiber.Ctx) error {
 	u := auth.MustGetUser(ctx)
@@ -163,3 +504,14 @@ func (h *Handler) GetProfile(ctx *fiber.Ctx) error {
 		User: user,
 	})
 }
+
+// @Summary      JSON Web Key Set
+// @Description  Public keys used to verify tokens issued by the chat service.
+// @Tags         authentication
+// @Produce      json
+// @Success      200      {object}  map[string]interface{}
+// @Router       /.well-known/jwks.json [get]
+func (h *Handler) GetJWKS(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return ctx.JSON(h.srvs.JwtService.GetJWKS())
+}
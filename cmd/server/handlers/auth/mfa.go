@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	authServ "chatapp/internal/services/auth"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EnrollTOTPResp200Body represents a started TOTP enrollment.
+// swagger:model
+type EnrollTOTPResp200Body struct {
+	// Base32 secret for manual entry in an authenticator app
+	// required: true
+	Secret string `json:"secret"`
+	// otpauth:// URI to be shown as QR code
+	// required: true
+	URI string `json:"uri"`
+}
+
+// @Summary      Start TOTP enrollment
+// @Description  Generates a TOTP secret. Two-factor authentication is enabled after confirming it with a code.
+// @Tags         two-factor
+// @Produce      json
+// @Success      200      {object}  EnrollTOTPResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/auth/2fa/enroll [post]
+func (h *Handler) EnrollTOTP(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+
+	enrollment, err := h.srvs.UserService.EnrollTOTP(ctx.Context(), u)
+	if err != nil {
+		if errors.Is(err, authServ.ErrMFAAlreadyEnabled) {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&EnrollTOTPResp200Body{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	})
+}
+
+// TOTPCodeRequestPayload represents a request payload containing a TOTP code.
+// swagger:model
+type TOTPCodeRequestPayload struct {
+	// Code from the authenticator app
+	// required: true
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// RecoveryCodesResp200Body represents freshly generated recovery codes, they are shown only once.
+// swagger:model
+type RecoveryCodesResp200Body struct {
+	// One-time recovery codes
+	// required: true
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// @Summary      Confirm TOTP enrollment
+// @Description  Enables two-factor authentication and returns one-time recovery codes.
+// @Tags         two-factor
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      TOTPCodeRequestPayload  true  "TOTP Code Payload"
+// @Success      200      {object}  RecoveryCodesResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/auth/2fa/confirm [post]
+func (h *Handler) ConfirmTOTP(ctx *fiber.Ctx) error {
+	reqBody := &TOTPCodeRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	codes, err := h.srvs.UserService.ConfirmTOTP(ctx.Context(), u, reqBody.Code)
+	if err != nil {
+		return mfaError(err)
+	}
+	return ctx.JSON(&RecoveryCodesResp200Body{
+		RecoveryCodes: codes,
+	})
+}
+
+// @Summary      Regenerate recovery codes
+// @Description  Replaces all recovery codes with new ones.
+// @Tags         two-factor
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      TOTPCodeRequestPayload  true  "TOTP Code Payload"
+// @Success      200      {object}  RecoveryCodesResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/auth/2fa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(ctx *fiber.Ctx) error {
+	reqBody := &TOTPCodeRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	codes, err := h.srvs.UserService.RegenerateRecoveryCodes(ctx.Context(), u, reqBody.Code)
+	if err != nil {
+		return mfaError(err)
+	}
+	return ctx.JSON(&RecoveryCodesResp200Body{
+		RecoveryCodes: codes,
+	})
+}
+
+// @Summary      Disable TOTP
+// @Description  Disables two-factor authentication, not allowed when it is required for all users.
+// @Tags         two-factor
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      TOTPCodeRequestPayload  true  "TOTP Code Payload"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/auth/2fa/disable [post]
+func (h *Handler) DisableTOTP(ctx *fiber.Ctx) error {
+	reqBody := &TOTPCodeRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.UserService.DisableTOTP(ctx.Context(), u, reqBody.Code); err != nil {
+		return mfaError(err)
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+func mfaError(err error) error {
+	switch {
+	case errors.Is(err, authServ.ErrInvalidMFACode):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, authServ.ErrMFAAlreadyEnabled),
+		errors.Is(err, authServ.ErrMFANotEnrolled),
+		errors.Is(err, authServ.ErrMFANotEnabled),
+		errors.Is(err, authServ.ErrMFAEnrollmentNeeded):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	default:
+		return fiber.ErrInternalServerError
+	}
+}
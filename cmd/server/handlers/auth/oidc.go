@@ -0,0 +1,146 @@
+package auth
+
+import (
+	session_dto "chatapp/internal/dto/session"
+	authServ "chatapp/internal/services/auth"
+	"chatapp/internal/services/auth/oidc"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	oidcStateCookie     = "oidc_state"
+	oidcStateCookiePath = "/api/v1/auth/oidc"
+)
+
+// OIDCProvidersResp200Body represents the list of configured identity providers.
+// swagger:model
+type OIDCProvidersResp200Body struct {
+	// Providers available for single sign-on
+	// required: true
+	Providers []oidc.ProviderInfo `json:"providers"`
+}
+
+// @Summary      List SSO providers
+// @Description  Lists configured OpenID Connect identity providers.
+// @Tags         authentication
+// @Produce      json
+// @Success      200      {object}  OIDCProvidersResp200Body
+// @Router       /api/v1/auth/oidc/providers [get]
+func (h *Handler) GetOIDCProviders(ctx *fiber.Ctx) error {
+	return ctx.JSON(&OIDCProvidersResp200Body{
+		Providers: h.srvs.OIDCService.Providers(),
+	})
+}
+
+// @Summary      Start SSO login
+// @Description  Redirects the browser to the identity provider.
+// @Tags         authentication
+// @Param        provider query string true "Provider name"
+// @Success      302
+// @Router       /api/v1/auth/oidc/login [get]
+func (h *Handler) BeginOIDCLogin(ctx *fiber.Ctx) error {
+	req, err := h.srvs.OIDCService.BeginLogin(ctx.Context(), ctx.Query("provider"))
+	if err != nil {
+		if errors.Is(err, oidc.ErrUnknownProvider) {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.ErrBadGateway
+	}
+	h.setOIDCStateCookie(ctx, req.State, time.Now().Add(oidc.StateTTL))
+	return ctx.Redirect(req.URL, fiber.StatusFound)
+}
+
+// @Summary      Finish SSO login
+// @Description  Callback of the identity provider. Signs in the linked user, links a verified email to an existing account or creates a new one.
+// @Description  Responds with tokens, or redirects to the configured success URL with tokens in the fragment.
+// @Tags         authentication
+// @Produce      json
+// @Param        code  query string true "Authorization code"
+// @Param        state query string true "State"
+// @Success      200      {object}  LoginResp200Body
+// @Router       /api/v1/auth/oidc/callback [get]
+func (h *Handler) FinishOIDCLogin(ctx *fiber.Ctx) error {
+	if errMsg := ctx.Query("error"); errMsg != "" {
+		return fiber.NewError(fiber.StatusUnauthorized, errMsg)
+	}
+	sealed := ctx.Cookies(oidcStateCookie)
+	h.setOIDCStateCookie(ctx, "", time.Unix(0, 0))
+
+	identity, err := h.srvs.OIDCService.FinishLogin(ctx.Context(), sealed, ctx.Query("state"), ctx.Query("code"))
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidState) || errors.Is(err, oidc.ErrUnknownProvider) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if errors.Is(err, oidc.ErrDomainNotAllowed) || errors.Is(err, oidc.ErrEmailNotVerified) {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
+		return fiber.NewError(fiber.StatusUnauthorized, "identity provider login failed")
+	}
+
+	res, err := h.srvs.UserService.LoginWithIdentity(ctx.Context(), identity)
+	if err != nil {
+		if errors.Is(err, authServ.ErrIdentityEmailConflict) || errors.Is(err, authServ.ErrIdentityEmailMissing) {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		if errors.Is(err, authServ.ErrAccountLocked) {
+			return fiber.ErrTooManyRequests
+		}
+		if errors.Is(err, authServ.ErrUnAutorize) {
+			return fiber.ErrForbidden
+		}
+		return fiber.ErrInternalServerError
+	}
+
+	body := &LoginResp200Body{
+		MFARequired: true,
+		MFAToken:    res.MFAToken,
+	}
+	if res.MFAToken == "" {
+		pair, err := h.srvs.SessionService.CreateSession(ctx.Context(), &session_dto.CreateSessionDTO{
+			UserID:    res.User.ID,
+			UserAgent: ctx.Get(fiber.HeaderUserAgent),
+			IP:        ctx.IP(),
+		})
+		if err != nil {
+			return fiber.ErrInternalServerError
+		}
+		body = newLoginResp200Body(pair)
+	}
+
+	if redirect := h.srvs.OIDCService.SuccessRedirectURL(); redirect != "" {
+		return ctx.Redirect(redirect+"#"+loginFragment(body), fiber.StatusFound)
+	}
+	return ctx.JSON(body)
+}
+
+func (h *Handler) setOIDCStateCookie(ctx *fiber.Ctx, value string, expires time.Time) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     oidcStateCookiePath,
+		Expires:  expires,
+		Secure:   ctx.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// loginFragment encodes tokens for the URL fragment, which browsers never send to servers.
+func loginFragment(body *LoginResp200Body) string {
+	v := url.Values{}
+	if body.MFARequired {
+		v.Set("mfa_required", "true")
+		v.Set("mfa_token", body.MFAToken)
+		return v.Encode()
+	}
+	v.Set("token", body.Token)
+	v.Set("expires_at", strconv.FormatInt(body.ExpiresAt.Unix(), 10))
+	v.Set("refresh_token", body.RefreshToken)
+	v.Set("refresh_expires_at", strconv.FormatInt(body.RefreshExpiresAt.Unix(), 10))
+	return v.Encode()
+}
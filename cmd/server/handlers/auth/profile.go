@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	user_dto "chatapp/internal/dto/user"
+	authServ "chatapp/internal/services/auth"
+	"chatapp/internal/services/auth/password"
+	"chatapp/internal/services/avatar"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UpdateProfileRequestPayload changes the fields which are present.
+// swagger:model
+type UpdateProfileRequestPayload struct {
+	// Name shown instead of the user name, empty clears it
+	DisplayName *string `json:"display_name" validate:"omitempty,max=50"`
+	Bio         *string `json:"bio" validate:"omitempty,max=500"`
+	// Status text like "on vacation"
+	Status *string `json:"status" validate:"omitempty,max=100"`
+	// IANA timezone like Europe/Berlin, empty clears it
+	Timezone *string `json:"timezone" validate:"omitempty,max=64"`
+}
+
+// @Summary      Update profile
+// @Description  Changes the display name, bio, status or timezone. Conversations of the user receive a user_updated event.
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      UpdateProfileRequestPayload  true  "Update Profile Request Payload"
+// @Success      200      {object}  ProfileResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/profile [patch]
+func (h *Handler) UpdateProfile(ctx *fiber.Ctx) error {
+	reqBody := &UpdateProfileRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	user, err := h.srvs.UserService.UpdateProfile(ctx.Context(), u, &user_dto.UpdateProfileDTO{
+		DisplayName: reqBody.DisplayName,
+		Bio:         reqBody.Bio,
+		Status:      reqBody.Status,
+		Timezone:    reqBody.Timezone,
+	})
+	if err != nil {
+		return profileError(err)
+	}
+	return ctx.JSON(ProfileResp200Body{
+		User: user,
+	})
+}
+
+// @Summary      Upload avatar
+// @Description  Sets the avatar from a PNG, JPEG or GIF image in the "avatar" form field.
+// @Description  The image is cropped to a square and scaled down.
+// @Tags         profile
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        avatar  formData  file  true  "Avatar image"
+// @Success      200      {object}  ProfileResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/profile/avatar [put]
+func (h *Handler) UploadAvatar(ctx *fiber.Ctx) error {
+	fh, err := ctx.FormFile("avatar")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "avatar file is required")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "avatar file is required")
+	}
+	defer f.Close()
+	u := auth.MustGetUser(ctx)
+
+	user, err := h.srvs.UserService.SetAvatar(ctx.Context(), u, f)
+	if err != nil {
+		return profileError(err)
+	}
+	return ctx.JSON(ProfileResp200Body{
+		User: user,
+	})
+}
+
+// @Summary      Remove avatar
+// @Tags         profile
+// @Produce      json
+// @Success      200      {object}  ProfileResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/profile/avatar [delete]
+func (h *Handler) RemoveAvatar(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+
+	user, err := h.srvs.UserService.RemoveAvatar(ctx.Context(), u)
+	if err != nil {
+		return profileError(err)
+	}
+	return ctx.JSON(ProfileResp200Body{
+		User: user,
+	})
+}
+
+// @Summary      Get avatar
+// @Description  Serves an avatar image, avatar URLs change on upload so they are cached forever.
+// @Tags         profile
+// @Produce      png
+// @Param        name  path  string  true  "Avatar file name"
+// @Success      200
+// @Router       /api/v1/avatars/{name} [get]
+func (h *Handler) GetAvatar(ctx *fiber.Ctx) error {
+	path, err := h.srvs.UserService.AvatarPath(ctx.Params("name"))
+	if err != nil {
+		return fiber.ErrNotFound
+	}
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	return ctx.SendFile(path)
+}
+
+// ChangeEmailRequestPayload requests a change of the account email.
+// swagger:model
+type ChangeEmailRequestPayload struct {
+	// New email address, it must be confirmed from its inbox
+	// required: true
+	Email string `json:"email" validate:"required,email,max=100"`
+	// Current password
+	// required: true
+	Password string `json:"password" validate:"required,max=128"`
+}
+
+// @Summary      Change email
+// @Description  Sends a confirmation link to the new address, the email changes once it is followed.
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      ChangeEmailRequestPayload  true  "Change Email Request Payload"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/profile/email [post]
+func (h *Handler) ChangeEmail(ctx *fiber.Ctx) error {
+	reqBody := &ChangeEmailRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.UserService.RequestEmailChange(ctx.Context(), u, reqBody.Email, reqBody.Password); err != nil {
+		return profileError(err)
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+// @Summary      Confirm email change
+// @Description  Replaces the email with the new address the token was sent to.
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      VerifyEmailRequestPayload  true  "Confirm Email Request Payload"
+// @Success      200      {object}  SuccessResp200Body
+// @Router       /api/v1/auth/confirm-email [post]
+func (h *Handler) ConfirmEmailChange(ctx *fiber.Ctx) error {
+	reqBody := &VerifyEmailRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	if err := h.srvs.UserService.ConfirmEmailChange(ctx.Context(), reqBody.Token); err != nil {
+		return profileError(err)
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+// ChangePasswordRequestPayload represents the request payload for a password change.
+// swagger:model
+type ChangePasswordRequestPayload struct {
+	// required: true
+	CurrentPassword string `json:"current_password" validate:"required,max=128"`
+	// required: true
+	NewPassword string `json:"new_password" validate:"required,max=128"`
+}
+
+// @Summary      Change password
+// @Description  Sets a new password after checking the current one, other sessions are signed out.
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      ChangePasswordRequestPayload  true  "Change Password Request Payload"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/profile/password [post]
+func (h *Handler) ChangePassword(ctx *fiber.Ctx) error {
+	reqBody := &ChangePasswordRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.UserService.ChangePassword(ctx.Context(), u, auth.MustGetSessionID(ctx), reqBody.CurrentPassword, reqBody.NewPassword); err != nil {
+		return profileError(err)
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+func profileError(err error) error {
+	switch {
+	case errors.Is(err, authServ.ErrInvalidPassword):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, authServ.ErrEmailTaken):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, avatar.ErrImageTooLarge):
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, authServ.ErrInvalidTimezone),
+		errors.Is(err, authServ.ErrSameEmail),
+		errors.Is(err, authServ.ErrInvalidToken),
+		errors.Is(err, avatar.ErrInvalidImage),
+		errors.Is(err, password.ErrWeakPassword):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		return fiber.ErrInternalServerError
+	}
+}
@@ -0,0 +1,152 @@
+package conversation
+
+import (
+	"bufio"
+	"chatapp/cmd/server/middlewares/auth"
+	chat "chatapp/internal/entities/chat"
+	"chatapp/internal/services/chat/utils"
+	"chatapp/internal/services/export"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportResponse202Payload represents an export queued for a large conversation.
+// swagger:model
+type ExportResponse202Payload struct {
+	// required: true
+	Export *chat.Export `json:"export"`
+}
+
+// @Summary      Export conversation
+// @Description  Streams the history with participants in json, html or txt. Large conversations are exported by a background job instead: 202 is returned with the export, an export_finished websocket event is sent to the requester when it is done and the file can be downloaded until expires_at.
+// @Tags         conversations
+// @Produce      json
+// @Produce      html
+// @Produce      plain
+// @Param        conversationId path  int64  true  "Conversation ID"
+// @Param        format         query string false "Export format: json, html or txt" default(json)
+// @Success      200
+// @Success      202            {object}  ExportResponse202Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/export [get]
+func (h *Handler) ExportConversation(ctx *fiber.Ctx) error {
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	format, err := export.ParseFormat(ctx.Query("format", string(chat.ExportFormatJSON)))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	user := auth.MustGetUser(ctx)
+
+	exp, err := h.srvs.ExportService.RequestExport(ctx.Context(), user.ID, cnvId, format)
+	if err != nil {
+		return exportError(err)
+	}
+	if exp != nil {
+		return ctx.Status(fiber.StatusAccepted).JSON(&ExportResponse202Payload{
+			Export: exp,
+		})
+	}
+
+	ctx.Set(fiber.HeaderContentType, export.ContentType(format))
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, export.FileName(cnvId, format)))
+	// the writer runs after the handler returns, so it can't use the request context
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := h.srvs.ExportService.WriteExport(context.Background(), cnvId, format, w); err != nil {
+			h.logger.Error(context.Background(), fmt.Errorf("failed to stream export: %w", err), slog.Int64("conversation", cnvId))
+		}
+	})
+	return nil
+}
+
+// GetExportResponse200Payload represents an export.
+// swagger:model
+type GetExportResponse200Payload struct {
+	// required: true
+	Export *chat.Export `json:"export"`
+}
+
+// @Summary      Get export status
+// @Description  Only the requester can see the export.
+// @Tags         conversations
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        exportId       path int64 true "Export ID"
+// @Success      200            {object}  GetExportResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/exports/{exportId} [get]
+func (h *Handler) GetExport(ctx *fiber.Ctx) error {
+	cnvId, exportId, err := h.parseExportParams(ctx)
+	if err != nil {
+		return err
+	}
+	user := auth.MustGetUser(ctx)
+
+	exp, err := h.srvs.ExportService.GetExport(ctx.Context(), user.ID, cnvId, exportId)
+	if err != nil {
+		return exportError(err)
+	}
+	return ctx.JSON(&GetExportResponse200Payload{
+		Export: exp,
+	})
+}
+
+// @Summary      Download export
+// @Description  Downloads the file of a ready export, only the requester can download it.
+// @Tags         conversations
+// @Produce      octet-stream
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        exportId       path int64 true "Export ID"
+// @Success      200
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/exports/{exportId}/download [get]
+func (h *Handler) DownloadExport(ctx *fiber.Ctx) error {
+	cnvId, exportId, err := h.parseExportParams(ctx)
+	if err != nil {
+		return err
+	}
+	user := auth.MustGetUser(ctx)
+
+	exp, err := h.srvs.ExportService.OpenExport(ctx.Context(), user.ID, cnvId, exportId)
+	if err != nil {
+		return exportError(err)
+	}
+	return ctx.Download(*exp.FilePath, export.FileName(cnvId, exp.Format))
+}
+
+func (h *Handler) parseExportParams(ctx *fiber.Ctx) (int64, int64, error) {
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.String("fail_on", "conversationId"), slog.Any("params", ctx.AllParams()))
+		return 0, 0, fiber.ErrBadRequest
+	}
+	exportId, err := strconv.ParseInt(ctx.Params("exportId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.String("fail_on", "exportId"), slog.Any("params", ctx.AllParams()))
+		return 0, 0, fiber.ErrBadRequest
+	}
+	return cnvId, exportId, nil
+}
+
+func exportError(err error) error {
+	switch {
+	case errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound):
+		return errors.Join(fiber.ErrBadRequest, err)
+	case errors.Is(err, export.ErrExportNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, export.ErrExportNotReady):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	return fiber.ErrInternalServerError
+}
@@ -4,17 +4,21 @@ import (
 	"chatapp/cmd/server/middlewares/auth"
 	reqparser "chatapp/cmd/server/utils/req_parser"
 	chat "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
 	eventlisteners "chatapp/internal/eventListeners"
 	"chatapp/internal/logger"
 	"chatapp/internal/services"
+	"chatapp/internal/services/chat/conversation"
 	"chatapp/internal/services/chat/utils"
 	"chatapp/internal/services/events"
+	"chatapp/internal/services/retention"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"slices"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
@@ -41,8 +45,7 @@ type CreateConversationRequestPayload struct {
 	// required: true
 	Name string `json:"name" validate:"required,min=3,max=50"`
 	// Indicates if the conversation is a group chat
-	// required: true
-	IsGroup bool `json:"is_group" validate:"required,boolean"`
+	IsGroup bool `json:"is_group" validate:"boolean"`
 	// IDs of participants to be added to the conversation
 	// required: true
 	ParticipantIDs []int64 `json:"participant_ids" validate:"required"`
@@ -62,6 +65,7 @@ type CreateConversationResponse200Payload struct {
 // @Success      200      {object}  CreateConversationResponse200Payload
 // @Router       /conversations [post]
 // @Security UserTokenAuth
+// @Security APIKeyAuth
 func (h *Handler) CreateConversation(ctx *fiber.Ctx) error {
 	reqBody := &CreateConversationRequestPayload{}
 	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
@@ -73,8 +77,11 @@ func (h *Handler) CreateConversation(ctx *fiber.Ctx) error {
 		reqBody.ParticipantIDs = append(reqBody.ParticipantIDs, u.ID)
 	}
 
-	conv, err := h.srvs.ConversationService.CreateConversation(ctx.Context(), reqBody.Name, reqBody.IsGroup, reqBody.ParticipantIDs)
+	conv, err := h.srvs.ConversationService.CreateConversation(ctx.Context(), u.ID, reqBody.Name, reqBody.IsGroup, reqBody.ParticipantIDs)
 	if err != nil {
+		if errors.Is(err, conversation.ErrBlocked) || errors.Is(err, conversation.ErrContactsOnly) {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
 		return errors.Join(fiber.ErrInternalServerError, err)
 	}
 
@@ -83,6 +90,9 @@ func (h *Handler) CreateConversation(ctx *fiber.Ctx) error {
 	})
 }
 
+// seenInterval throttles recording delivered messages as seen by long-lived connections
+const seenInterval = time.Minute
+
 // listen ws with conversation messages
 func (h *Handler) ListenConversation(conn *websocket.Conn) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -107,17 +117,101 @@ func (h *Handler) ListenConversation(conn *websocket.Conn) {
 		return
 	}
 
+	usr, _ := conn.Locals(auth.CtxKey).(*users.User)
+	seenAt := time.Now()
+	if usr != nil {
+		h.evls.ChatEventListener.SetOnline(convId, usr.ID)
+		defer h.evls.ChatEventListener.SetOffline(convId, usr.ID)
+		// messages delivered while connected are seen, the context is cancelled once the connection closes
+		h.srvs.ConversationService.MarkSeen(ctx, convId, usr.ID)
+		defer h.srvs.ConversationService.MarkSeen(context.WithoutCancel(ctx), convId, usr.ID)
+	}
+
 	ch := make(chan events.Event)
 	h.evls.ChatEventListener.SubscribeChannel(convId, ch)
-	defer h.evls.ChatEventListener.UnsubscribeChannel(convId, ch)
+	defer unsubscribe(ch, func() { h.evls.ChatEventListener.UnsubscribeChannel(convId, ch) })
+
+	// blocks are loaded once and kept up to date by the block events of the user,
+	// subscribing first makes sure no change made while loading is missed
+	var blocked map[int64]struct{}
+	var uch chan events.Event
+	if usr != nil {
+		uch = make(chan events.Event)
+		h.evls.ChatEventListener.SubscribeUser(usr.ID, uch)
+		defer unsubscribe(uch, func() { h.evls.ChatEventListener.UnsubscribeUser(usr.ID, uch) })
 
-	for msg := range ch {
-		if err := conn.WriteJSON(msg); err != nil {
-			h.logger.Error(ctx, fmt.Errorf("cannot write websocket message: %w", err))
+		ids, err := h.srvs.BlockService.GetBlockedIds(ctx, usr.ID)
+		if err != nil {
+			h.logger.Error(ctx, fmt.Errorf("failed to get blocked users: %w", err), slog.Int64("userId", usr.ID))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"internal server error"}`))
 			return
 		}
+		blocked = make(map[int64]struct{}, len(ids))
+		for _, id := range ids {
+			blocked[id] = struct{}{}
+		}
 	}
 
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-uch:
+			if d, ok := ev.Data.(map[string]int64); ok {
+				switch ev.Type {
+				case events.EventTypeUserBlocked:
+					blocked[d["user_id"]] = struct{}{}
+				case events.EventTypeUserUnblocked:
+					delete(blocked, d["user_id"])
+				}
+			}
+		case msg := <-ch:
+			// ephemeral command responses are shown only to the invoker
+			if msg.Recipient != 0 && (usr == nil || usr.ID != msg.Recipient) {
+				continue
+			}
+			if isFromBlocked(blocked, msg) {
+				continue
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				h.logger.Error(ctx, fmt.Errorf("cannot write websocket message: %w", err))
+				return
+			}
+			if usr != nil && time.Since(seenAt) >= seenInterval {
+				h.srvs.ConversationService.MarkSeen(ctx, convId, usr.ID)
+				seenAt = time.Now()
+			}
+		}
+	}
+}
+
+// unsubscribe keeps receiving while unsubscribing, the channel may be blocked delivering an event to us.
+func unsubscribe(ch <-chan events.Event, fn func()) {
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ch:
+			case <-done:
+				return
+			}
+		}
+	}()
+	fn()
+	close(done)
+}
+
+// isFromBlocked reports whether the event was caused by a user the listener blocked.
+func isFromBlocked(blocked map[int64]struct{}, ev events.Event) bool {
+	var senderId int64
+	switch d := ev.Data.(type) {
+	case *chat.Message:
+		senderId = d.SenderID
+	case map[string]int64:
+		senderId = d["user_id"]
+	}
+	_, ok := blocked[senderId]
+	return ok
 }
 
 // ShowUserTypingResponse200Payload represents a successful response for showing user typing status.
@@ -135,6 +229,7 @@ type ShowUserTypingResponse200Payload struct {
 // @Produce      json
 // @Success      200      {object}  ShowUserTypingResponse200Payload
 // @Security     UserTokenAuth
+// @Security     APIKeyAuth
 // @Router       /api/v1/conversations/{conversationId}/show-user-typing [post]
 func (h *Handler) ShowUserTyping(ctx *fiber.Ctx) error {
 	user := auth.MustGetUser(ctx)
@@ -155,3 +250,168 @@ func (h *Handler) ShowUserTyping(ctx *fiber.Ctx) error {
 		Success: true,
 	})
 }
+
+// SetMessageTTLRequestPayload represents the request payload for changing the message lifetime of a conversation.
+// swagger:model
+type SetMessageTTLRequestPayload struct {
+	// Lifetime of new messages in seconds, messages are kept forever when null
+	TTLSeconds *int `json:"ttl_seconds" validate:"omitempty,min=5,max=31536000"`
+}
+
+// SetMessageTTLResponse200Payload represents the updated conversation.
+// swagger:model
+type SetMessageTTLResponse200Payload struct {
+	// required: true
+	Conversation *chat.Conversation `json:"conversation"`
+}
+
+// @Summary      Set disappearing messages
+// @Description  Deletes new messages of the conversation after the TTL, a message_expired event is published for every deleted message. Existing messages keep their expiry. Only conversation admins can change it.
+// @Tags         conversations
+// @Accept       json
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        payload  body      SetMessageTTLRequestPayload  true  "Set Message TTL Payload"
+// @Success      200      {object}  SetMessageTTLResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/message-ttl [put]
+func (h *Handler) SetMessageTTL(ctx *fiber.Ctx) error {
+	reqBody := &SetMessageTTLRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	user := auth.MustGetUser(ctx)
+
+	var ttl *time.Duration
+	if reqBody.TTLSeconds != nil {
+		d := time.Duration(*reqBody.TTLSeconds) * time.Second
+		ttl = &d
+	}
+	cnv, err := h.srvs.ConversationService.SetMessageTTL(ctx.Context(), user.ID, cnvId, ttl)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound):
+			return errors.Join(fiber.ErrBadRequest, err)
+		case errors.Is(err, utils.ErrIsNotConversationAdmin):
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&SetMessageTTLResponse200Payload{
+		Conversation: cnv,
+	})
+}
+
+// SetMutedRequestPayload represents the notification settings of the user in a conversation.
+// swagger:model
+type SetMutedRequestPayload struct {
+	// required: true
+	Muted bool `json:"muted"`
+	// Notifications resume at this time, the conversation stays muted until unmuted when null
+	Until *time.Time `json:"until"`
+}
+
+// SetMutedResponse200Payload represents the participation of the user in the conversation.
+// swagger:model
+type SetMutedResponse200Payload struct {
+	// required: true
+	Participant *chat.ConversationParticipant `json:"participant"`
+}
+
+// @Summary      Mute a conversation
+// @Description  Muted conversations don't notify the user, mentions included. Messages are still delivered.
+// @Tags         conversations
+// @Accept       json
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        payload  body      SetMutedRequestPayload  true  "Set Muted Payload"
+// @Success      200      {object}  SetMutedResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/mute [put]
+func (h *Handler) SetMuted(ctx *fiber.Ctx) error {
+	reqBody := &SetMutedRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	if reqBody.Until != nil && !reqBody.Until.After(time.Now()) {
+		return fiber.NewError(fiber.StatusBadRequest, "until must be in the future")
+	}
+	user := auth.MustGetUser(ctx)
+
+	p, err := h.srvs.ConversationService.SetMuted(ctx.Context(), user.ID, cnvId, reqBody.Muted, reqBody.Until)
+	if err != nil {
+		if errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound) {
+			return errors.Join(fiber.ErrBadRequest, err)
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&SetMutedResponse200Payload{
+		Participant: p,
+	})
+}
+
+// SetRetentionRequestPayload represents the request payload for the retention of a conversation.
+// swagger:model
+type SetRetentionRequestPayload struct {
+	// Days to keep messages, 0 keeps them forever and null restores the server default
+	RetentionDays *int `json:"retention_days" validate:"omitempty,min=0,max=36500"`
+}
+
+// SetRetentionResponse200Payload represents the updated conversation.
+// swagger:model
+type SetRetentionResponse200Payload struct {
+	// required: true
+	Conversation *chat.Conversation `json:"conversation"`
+}
+
+// @Summary      Set message retention
+// @Description  Overrides the server retention of the conversation, older messages are purged periodically. Conversation admins can only shorten the server default, system administrators can set any retention.
+// @Tags         conversations
+// @Accept       json
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        payload  body      SetRetentionRequestPayload  true  "Set Retention Payload"
+// @Success      200      {object}  SetRetentionResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/retention [put]
+func (h *Handler) SetRetention(ctx *fiber.Ctx) error {
+	reqBody := &SetRetentionRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	user := auth.MustGetUser(ctx)
+
+	cnv, err := h.srvs.RetentionService.SetRetention(ctx.Context(), user, cnvId, reqBody.RetentionDays)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound):
+			return errors.Join(fiber.ErrBadRequest, err)
+		case errors.Is(err, utils.ErrIsNotConversationAdmin):
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		case errors.Is(err, retention.ErrRetentionExceedsDefault):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&SetRetentionResponse200Payload{
+		Conversation: cnv,
+	})
+}
@@ -7,11 +7,14 @@ import (
 	chat "chatapp/internal/entities/chat"
 	"chatapp/internal/logger"
 	"chatapp/internal/services"
+	message_service "chatapp/internal/services/chat/message"
 	"chatapp/internal/services/chat/utils"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -34,6 +37,8 @@ type SendMessageRequestPayload struct {
 	// Content of the message
 	// required: true
 	Content string `json:"content" validate:"required,min=3,max=250"`
+	// Seconds after which the message is deleted, the conversation TTL applies when it is shorter
+	TTLSeconds *int `json:"ttl_seconds" validate:"omitempty,min=5,max=31536000"`
 }
 
 // SendMessageResponse200Payload represents a successful response containing the sent message.
@@ -47,7 +52,7 @@ type SendMessageResponse200Payload struct {
 
 // SendMessage sends a new message in a conversation.
 // @Summary      Send a new message
-// @Description  Sends a new message within a specified conversation.
+// @Description  Sends a new message within a specified conversation. "@name" mentions participants, "@here" and "@all" are allowed only for conversation admins. Messages starting with "/" run slash commands like /me, /topic, /invite and /mute, type /help for the list. Ephemeral command responses are returned with "ephemeral": true and are never stored. Messages with ttl_seconds or sent to a conversation with a message TTL are deleted at expires_at.
 // @Tags         messages
 // @Accept       json
 // @Produce      json
@@ -55,6 +60,7 @@ type SendMessageResponse200Payload struct {
 // @Param        payload        body      SendMessageRequestPayload true "Send Message Payload"
 // @Success      200            {object}  SendMessageResponse200Payload
 // @Security     UserTokenAuth
+// @Security     APIKeyAuth
 // @Router       /api/v1/conversations/{conversationId}/messages [post]
 func (h *Handler) SendMessage(ctx *fiber.Ctx) error {
 	reqBody := &SendMessageRequestPayload{}
@@ -69,12 +75,25 @@ func (h *Handler) SendMessage(ctx *fiber.Ctx) error {
 		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse conversation id param: %w", err), slog.Any("params", ctx.Queries()))
 		return errors.Join(fiber.ErrBadRequest, err)
 	}
+	var ttl *time.Duration
+	if reqBody.TTLSeconds != nil {
+		d := time.Duration(*reqBody.TTLSeconds) * time.Second
+		ttl = &d
+	}
 	user := auth.MustGetUser(ctx)
-	message, err := h.srvs.MessageService.SendMessage(ctx.Context(), cnvId, user.ID, reqBody.Content)
+	message, err := h.srvs.MessageService.SendMessage(ctx.Context(), &messages_dto.SendMessageDTO{
+		ConversationID: cnvId,
+		SenderID:       user.ID,
+		Content:        reqBody.Content,
+		TTL:            ttl,
+	})
 	if err != nil {
 		if errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound) {
 			return errors.Join(fiber.ErrBadRequest, err)
 		}
+		if errors.Is(err, message_service.ErrBroadcastMentionNotAllowed) {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
 
 		return fiber.ErrInternalServerError
 	}
@@ -102,6 +121,7 @@ type GetMessagesResponse200Payload struct {
 // @Param        lastID         query    int64  false "ID of the last message received"
 // @Success      200            {object}  GetMessagesResponse200Payload
 // @Security     UserTokenAuth
+// @Security     APIKeyAuth
 // @Router       /api/v1/conversations/{conversationId}/messages [get]
 func (h *Handler) GetMessages(ctx *fiber.Ctx) error {
 	user := auth.MustGetUser(ctx)
@@ -171,6 +191,7 @@ type MessageUpdateResponse200Payload struct {
 // @Param        payload        body     MessageUpdateRequestPayload true "Update Message Payload"
 // @Success      200            {object}  MessageUpdateResponse200Payload
 // @Security     UserTokenAuth
+// @Security     APIKeyAuth
 // @Router       /api/v1/conversations/{conversationId}/messages/{messageId} [post]
 func (h *Handler) UpdateMessage(ctx *fiber.Ctx) error {
 	user := auth.MustGetUser(ctx)
@@ -193,6 +214,9 @@ func (h *Handler) UpdateMessage(ctx *fiber.Ctx) error {
 		if errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound) {
 			return errors.Join(fiber.ErrBadRequest, err)
 		}
+		if errors.Is(err, message_service.ErrBroadcastMentionNotAllowed) {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
 		h.logger.Error(ctx.Context(), fmt.Errorf("update message endpoint error: %w", err))
 		return fiber.ErrInternalServerError
 	}
@@ -200,3 +224,136 @@ func (h *Handler) UpdateMessage(ctx *fiber.Ctx) error {
 		Message: message,
 	})
 }
+
+// GetMentionsResponse200Payload represents messages with unread mentions of the user.
+// swagger:model
+type GetMentionsResponse200Payload struct {
+	// Messages mentioning the user, newest first
+	// required: true
+	Messages []*chat.Message `json:"messages"`
+}
+
+// GetMentions returns messages with unread mentions of the current user.
+// @Summary      Get unread mentions
+// @Description  Returns messages mentioning the current user directly, with @here or with @all which were not marked as read, newest first.
+// @Tags         messages
+// @Produce      json
+// @Param        conversationId query    int64  false "Limit mentions to a conversation"
+// @Param        limit          query    int    false "Number of messages to retrieve" default(20)
+// @Param        lastID         query    int64  false "ID of the last message received"
+// @Success      200            {object}  GetMentionsResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/mentions [get]
+func (h *Handler) GetMentions(ctx *fiber.Ctx) error {
+	user := auth.MustGetUser(ctx)
+	limit, err := strconv.Atoi(ctx.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		return fiber.ErrBadRequest
+	}
+	params := &messages_dto.GetMentionsQueryParams{
+		UserId: user.ID,
+		Limit:  limit,
+	}
+	if cnvIdStr := ctx.Query("conversationId", ""); cnvIdStr != "" {
+		id, err := strconv.ParseInt(cnvIdStr, 10, 64)
+		if err != nil {
+			h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.Queries()))
+			return fiber.ErrBadRequest
+		}
+		params.ConvId = &id
+	}
+	if lastIDStr := ctx.Query("lastID", ""); lastIDStr != "" {
+		id, err := strconv.ParseInt(lastIDStr, 10, 64)
+		if err != nil {
+			h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.Queries()))
+			return fiber.ErrBadRequest
+		}
+		params.LastId = &id
+	}
+	// api keys limited to conversations must ask for one of them
+	if key := auth.GetAPIKey(ctx); key != nil && len(key.ConversationIDs) > 0 {
+		if params.ConvId == nil || !key.AllowsConversation(*params.ConvId) {
+			return fiber.NewError(fiber.StatusForbidden, "api key is not allowed for this conversation")
+		}
+	}
+
+	messages, err := h.srvs.MessageService.GetMentions(ctx.Context(), params)
+	if err != nil {
+		if errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound) {
+			return errors.Join(fiber.ErrBadRequest, err)
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&GetMentionsResponse200Payload{
+		Messages: messages,
+	})
+}
+
+// GetMentionCountsResponse200Payload represents unread mention counts per conversation.
+// swagger:model
+type GetMentionCountsResponse200Payload struct {
+	// Conversations without unread mentions are omitted
+	// required: true
+	Counts []*chat.MentionCount `json:"counts"`
+}
+
+// GetMentionCounts returns the number of unread mentions per conversation.
+// @Summary      Get unread mention counts
+// @Tags         messages
+// @Produce      json
+// @Success      200            {object}  GetMentionCountsResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/mentions/counts [get]
+func (h *Handler) GetMentionCounts(ctx *fiber.Ctx) error {
+	user := auth.MustGetUser(ctx)
+
+	counts, err := h.srvs.MessageService.GetMentionCounts(ctx.Context(), user.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	if key := auth.GetAPIKey(ctx); key != nil {
+		counts = slices.DeleteFunc(counts, func(c *chat.MentionCount) bool {
+			return !key.AllowsConversation(c.ConversationID)
+		})
+	}
+	return ctx.JSON(&GetMentionCountsResponse200Payload{
+		Counts: counts,
+	})
+}
+
+// MarkMentionsReadResponse200Payload represents a successful response for marking mentions as read.
+// swagger:model
+type MarkMentionsReadResponse200Payload struct {
+	// required: true
+	Success bool `json:"success"`
+}
+
+// MarkMentionsRead marks all mentions of the current user in the conversation as read.
+// @Summary      Mark mentions as read
+// @Tags         messages
+// @Produce      json
+// @Param        conversationId path     int64  true "Conversation ID"
+// @Success      200            {object}  MarkMentionsReadResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/mentions/read [post]
+func (h *Handler) MarkMentionsRead(ctx *fiber.Ctx) error {
+	user := auth.MustGetUser(ctx)
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+
+	if err := h.srvs.MessageService.MarkMentionsRead(ctx.Context(), user.ID, cnvId); err != nil {
+		if errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound) {
+			return errors.Join(fiber.ErrBadRequest, err)
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&MarkMentionsReadResponse200Payload{
+		Success: true,
+	})
+}
@@ -0,0 +1,143 @@
+package message
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	chat "chatapp/internal/entities/chat"
+	message_service "chatapp/internal/services/chat/message"
+	"chatapp/internal/services/chat/utils"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PinMessageResponse200Payload represents the pin of a message.
+// swagger:model
+type PinMessageResponse200Payload struct {
+	// required: true
+	Pin *chat.Pin `json:"pin"`
+}
+
+// PinMessage pins a message in a conversation.
+// @Summary      Pin a message
+// @Description  Pins a message for all participants. Only conversation admins can pin messages, pinning a pinned message does nothing.
+// @Tags         messages
+// @Produce      json
+// @Param        conversationId path     int64  true "Conversation ID"
+// @Param        messageId      path     int64  true "Message ID"
+// @Success      200            {object}  PinMessageResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/messages/{messageId}/pin [post]
+func (h *Handler) PinMessage(ctx *fiber.Ctx) error {
+	user := auth.MustGetUser(ctx)
+	cnvId, msgId, err := h.parseMessageParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	pin, err := h.srvs.MessageService.PinMessage(ctx.Context(), cnvId, msgId, user.ID)
+	if err != nil {
+		return pinError(err)
+	}
+	return ctx.JSON(&PinMessageResponse200Payload{
+		Pin: pin,
+	})
+}
+
+// UnpinMessageResponse200Payload represents a successful response for unpinning a message.
+// swagger:model
+type UnpinMessageResponse200Payload struct {
+	// required: true
+	Success bool `json:"success"`
+}
+
+// UnpinMessage removes the pin of a message.
+// @Summary      Unpin a message
+// @Description  Only conversation admins can unpin messages.
+// @Tags         messages
+// @Produce      json
+// @Param        conversationId path     int64  true "Conversation ID"
+// @Param        messageId      path     int64  true "Message ID"
+// @Success      200            {object}  UnpinMessageResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/messages/{messageId}/pin [delete]
+func (h *Handler) UnpinMessage(ctx *fiber.Ctx) error {
+	user := auth.MustGetUser(ctx)
+	cnvId, msgId, err := h.parseMessageParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := h.srvs.MessageService.UnpinMessage(ctx.Context(), cnvId, msgId, user.ID); err != nil {
+		return pinError(err)
+	}
+	return ctx.JSON(&UnpinMessageResponse200Payload{
+		Success: true,
+	})
+}
+
+// GetPinsResponse200Payload represents pinned messages of a conversation.
+// swagger:model
+type GetPinsResponse200Payload struct {
+	// Pinned messages, latest pins first
+	// required: true
+	Messages []*chat.PinnedMessage `json:"messages"`
+}
+
+// GetPins lists pinned messages of a conversation.
+// @Summary      Get pinned messages
+// @Tags         messages
+// @Produce      json
+// @Param        conversationId path     int64  true "Conversation ID"
+// @Success      200            {object}  GetPinsResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/pins [get]
+func (h *Handler) GetPins(ctx *fiber.Ctx) error {
+	user := auth.MustGetUser(ctx)
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+
+	messages, err := h.srvs.MessageService.GetPinnedMessages(ctx.Context(), cnvId, user.ID)
+	if err != nil {
+		return pinError(err)
+	}
+	return ctx.JSON(&GetPinsResponse200Payload{
+		Messages: messages,
+	})
+}
+
+func (h *Handler) parseMessageParams(ctx *fiber.Ctx) (int64, int64, error) {
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.String("fail_on", "conversationId"), slog.Any("params", ctx.AllParams()))
+		return 0, 0, fiber.ErrBadRequest
+	}
+	msgId, err := strconv.ParseInt(ctx.Params("messageId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.String("fail_on", "messageId"), slog.Any("params", ctx.AllParams()))
+		return 0, 0, fiber.ErrBadRequest
+	}
+	return cnvId, msgId, nil
+}
+
+func pinError(err error) error {
+	switch {
+	case errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound):
+		return errors.Join(fiber.ErrBadRequest, err)
+	case errors.Is(err, utils.ErrIsNotConversationAdmin):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, message_service.ErrMessageNotFound) || errors.Is(err, message_service.ErrMessageNotPinned):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, message_service.ErrTooManyPins):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	return fiber.ErrInternalServerError
+}
@@ -0,0 +1,215 @@
+package message
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	messages_dto "chatapp/internal/dto/messages"
+	chat "chatapp/internal/entities/chat"
+	"chatapp/internal/services/chat/scheduled"
+	"chatapp/internal/services/chat/utils"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ScheduleMessageRequestPayload represents the request payload for scheduling a message.
+// swagger:model
+type ScheduleMessageRequestPayload struct {
+	// Content of the message
+	// required: true
+	Content string `json:"content" validate:"required,min=3,max=250"`
+	// Time to send the message at, within a year
+	// required: true
+	SendAt time.Time `json:"send_at" validate:"required"`
+}
+
+// ScheduledMessageResponse200Payload represents a scheduled message.
+// swagger:model
+type ScheduledMessageResponse200Payload struct {
+	// required: true
+	Message *chat.ScheduledMessage `json:"message"`
+}
+
+// ScheduleMessage schedules a message in a conversation.
+// @Summary      Schedule a message
+// @Description  Sends the message at send_at. The sender must still be a participant then, otherwise the message is marked failed. Slash commands can't be scheduled.
+// @Tags         messages
+// @Accept       json
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        payload        body      ScheduleMessageRequestPayload true "Schedule Message Payload"
+// @Success      200            {object}  ScheduledMessageResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/scheduled-messages [post]
+func (h *Handler) ScheduleMessage(ctx *fiber.Ctx) error {
+	reqBody := &ScheduleMessageRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	user := auth.MustGetUser(ctx)
+
+	msg, err := h.srvs.ScheduledService.ScheduleMessage(ctx.Context(), &messages_dto.ScheduleMessageDTO{
+		ConversationID: cnvId,
+		SenderID:       user.ID,
+		Content:        reqBody.Content,
+		SendAt:         reqBody.SendAt,
+	})
+	if err != nil {
+		return scheduledError(err)
+	}
+	return ctx.JSON(&ScheduledMessageResponse200Payload{
+		Message: msg,
+	})
+}
+
+// GetScheduledMessagesResponse200Payload represents messages waiting to be sent.
+// swagger:model
+type GetScheduledMessagesResponse200Payload struct {
+	// Scheduled messages of the user, the earliest first
+	// required: true
+	Messages []*chat.ScheduledMessage `json:"messages"`
+}
+
+// GetScheduledMessages lists messages the user scheduled in a conversation.
+// @Summary      Get scheduled messages
+// @Tags         messages
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Success      200            {object}  GetScheduledMessagesResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/scheduled-messages [get]
+func (h *Handler) GetScheduledMessages(ctx *fiber.Ctx) error {
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	user := auth.MustGetUser(ctx)
+
+	messages, err := h.srvs.ScheduledService.GetScheduledMessages(ctx.Context(), cnvId, user.ID)
+	if err != nil {
+		return scheduledError(err)
+	}
+	return ctx.JSON(&GetScheduledMessagesResponse200Payload{
+		Messages: messages,
+	})
+}
+
+// UpdateScheduledMessageRequestPayload represents changes of a scheduled message.
+// swagger:model
+type UpdateScheduledMessageRequestPayload struct {
+	// New content of the message
+	Content *string `json:"content" validate:"omitempty,min=3,max=250"`
+	// New time to send the message at
+	SendAt *time.Time `json:"send_at"`
+}
+
+// UpdateScheduledMessage edits a message before it is sent.
+// @Summary      Update a scheduled message
+// @Description  Changes the content or the send time of a message which is not sent yet.
+// @Tags         messages
+// @Accept       json
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        scheduledId    path int64 true "Scheduled message ID"
+// @Param        payload        body      UpdateScheduledMessageRequestPayload true "Update Scheduled Message Payload"
+// @Success      200            {object}  ScheduledMessageResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/scheduled-messages/{scheduledId} [patch]
+func (h *Handler) UpdateScheduledMessage(ctx *fiber.Ctx) error {
+	reqBody := &UpdateScheduledMessageRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	cnvId, id, err := h.parseScheduledParams(ctx)
+	if err != nil {
+		return err
+	}
+	user := auth.MustGetUser(ctx)
+
+	msg, err := h.srvs.ScheduledService.UpdateScheduledMessage(ctx.Context(), &messages_dto.UpdateScheduledMessageDTO{
+		ConversationID: cnvId,
+		ID:             id,
+		SenderID:       user.ID,
+		Content:        reqBody.Content,
+		SendAt:         reqBody.SendAt,
+	})
+	if err != nil {
+		return scheduledError(err)
+	}
+	return ctx.JSON(&ScheduledMessageResponse200Payload{
+		Message: msg,
+	})
+}
+
+// CancelScheduledMessageResponse200Payload represents a successful response for cancelling a scheduled message.
+// swagger:model
+type CancelScheduledMessageResponse200Payload struct {
+	// required: true
+	Success bool `json:"success"`
+}
+
+// CancelScheduledMessage cancels a message before it is sent.
+// @Summary      Cancel a scheduled message
+// @Tags         messages
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        scheduledId    path int64 true "Scheduled message ID"
+// @Success      200            {object}  CancelScheduledMessageResponse200Payload
+// @Security     UserTokenAuth
+// @Security     APIKeyAuth
+// @Router       /api/v1/conversations/{conversationId}/scheduled-messages/{scheduledId} [delete]
+func (h *Handler) CancelScheduledMessage(ctx *fiber.Ctx) error {
+	cnvId, id, err := h.parseScheduledParams(ctx)
+	if err != nil {
+		return err
+	}
+	user := auth.MustGetUser(ctx)
+
+	if err := h.srvs.ScheduledService.CancelScheduledMessage(ctx.Context(), cnvId, id, user.ID); err != nil {
+		return scheduledError(err)
+	}
+	return ctx.JSON(&CancelScheduledMessageResponse200Payload{
+		Success: true,
+	})
+}
+
+func (h *Handler) parseScheduledParams(ctx *fiber.Ctx) (int64, int64, error) {
+	cnvId, err := strconv.ParseInt(ctx.Params("conversationId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.String("fail_on", "conversationId"), slog.Any("params", ctx.AllParams()))
+		return 0, 0, fiber.ErrBadRequest
+	}
+	id, err := strconv.ParseInt(ctx.Params("scheduledId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.String("fail_on", "scheduledId"), slog.Any("params", ctx.AllParams()))
+		return 0, 0, fiber.ErrBadRequest
+	}
+	return cnvId, id, nil
+}
+
+func scheduledError(err error) error {
+	switch {
+	case errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound):
+		return errors.Join(fiber.ErrBadRequest, err)
+	case errors.Is(err, scheduled.ErrInvalidSendTime) || errors.Is(err, scheduled.ErrCommandNotSchedulable):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, scheduled.ErrScheduledMessageNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, scheduled.ErrScheduledMessageNotPending):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	return fiber.ErrInternalServerError
+}
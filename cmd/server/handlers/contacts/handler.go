@@ -0,0 +1,285 @@
+package contacts
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	"chatapp/internal/entities/users"
+	eventlisteners "chatapp/internal/eventListeners"
+	"chatapp/internal/logger"
+	"chatapp/internal/services"
+	"chatapp/internal/services/contact"
+	"chatapp/internal/services/events"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	srvs   *services.Services
+	logger logger.Logger
+	evls   *eventlisteners.EventListeners
+}
+
+func NewHandler(srvs *services.Services, evls *eventlisteners.EventListeners, logger logger.Logger) *Handler {
+	return &Handler{
+		srvs:   srvs,
+		evls:   evls,
+		logger: logger,
+	}
+}
+
+// SendContactRequestPayload represents the request payload for a contact request.
+// swagger:model
+type SendContactRequestPayload struct {
+	// required: true
+	UserID int64 `json:"user_id" validate:"required"`
+}
+
+// ContactRequestResp200Body represents a contact request.
+// swagger:model
+type ContactRequestResp200Body struct {
+	// required: true
+	Request *users.ContactRequest `json:"request"`
+}
+
+// @Summary      Send contact request
+// @Description  Asks the user to become a contact. When the user already asked you, their request is accepted instead.
+// @Tags         contacts
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      SendContactRequestPayload  true  "Send Contact Request Payload"
+// @Success      200      {object}  ContactRequestResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/contact-requests [post]
+func (h *Handler) SendRequest(ctx *fiber.Ctx) error {
+	reqBody := &SendContactRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	req, err := h.srvs.ContactService.SendRequest(ctx.Context(), u.ID, reqBody.UserID)
+	if err != nil {
+		return contactError(err)
+	}
+	return ctx.JSON(&ContactRequestResp200Body{
+		Request: req,
+	})
+}
+
+// ContactRequestsResp200Body represents pending contact requests.
+// swagger:model
+type ContactRequestsResp200Body struct {
+	// required: true
+	Requests []*users.ContactRequest `json:"requests"`
+}
+
+// @Summary      Get contact requests
+// @Description  Returns pending requests sent to you, or the ones you sent with direction=outgoing.
+// @Tags         contacts
+// @Produce      json
+// @Param        direction  query string false "incoming or outgoing" default(incoming)
+// @Success      200      {object}  ContactRequestsResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/contact-requests [get]
+func (h *Handler) GetRequests(ctx *fiber.Ctx) error {
+	var incoming bool
+	switch ctx.Query("direction", "incoming") {
+	case "incoming":
+		incoming = true
+	case "outgoing":
+	default:
+		return fiber.ErrBadRequest
+	}
+	u := auth.MustGetUser(ctx)
+
+	reqs, err := h.srvs.ContactService.GetRequests(ctx.Context(), u.ID, incoming)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&ContactRequestsResp200Body{
+		Requests: reqs,
+	})
+}
+
+// @Summary      Accept contact request
+// @Tags         contacts
+// @Produce      json
+// @Param        requestId path int64 true "Contact request ID"
+// @Success      200      {object}  ContactRequestResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/contact-requests/{requestId}/accept [post]
+func (h *Handler) AcceptRequest(ctx *fiber.Ctx) error {
+	return h.answer(ctx, h.srvs.ContactService.AcceptRequest)
+}
+
+// @Summary      Decline contact request
+// @Tags         contacts
+// @Produce      json
+// @Param        requestId path int64 true "Contact request ID"
+// @Success      200      {object}  ContactRequestResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/contact-requests/{requestId}/decline [post]
+func (h *Handler) DeclineRequest(ctx *fiber.Ctx) error {
+	return h.answer(ctx, h.srvs.ContactService.DeclineRequest)
+}
+
+// @Summary      Cancel contact request
+// @Tags         contacts
+// @Produce      json
+// @Param        requestId path int64 true "Contact request ID"
+// @Success      200      {object}  ContactRequestResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/contact-requests/{requestId} [delete]
+func (h *Handler) CancelRequest(ctx *fiber.Ctx) error {
+	return h.answer(ctx, h.srvs.ContactService.CancelRequest)
+}
+
+func (h *Handler) answer(ctx *fiber.Ctx, fn func(ctx context.Context, usrId, reqId int64) (*users.ContactRequest, error)) error {
+	reqId, err := strconv.ParseInt(ctx.Params("requestId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	u := auth.MustGetUser(ctx)
+
+	req, err := fn(ctx.Context(), u.ID, reqId)
+	if err != nil {
+		return contactError(err)
+	}
+	return ctx.JSON(&ContactRequestResp200Body{
+		Request: req,
+	})
+}
+
+// ContactsResp200Body represents the contacts of the user.
+// swagger:model
+type ContactsResp200Body struct {
+	// required: true
+	Contacts []*users.Contact `json:"contacts"`
+}
+
+// @Summary      Get contacts
+// @Tags         contacts
+// @Produce      json
+// @Success      200      {object}  ContactsResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/contacts [get]
+func (h *Handler) GetContacts(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+
+	contacts, err := h.srvs.ContactService.GetContacts(ctx.Context(), u.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&ContactsResp200Body{
+		Contacts: contacts,
+	})
+}
+
+// RemoveContactResp200Body represents a successful removal.
+// swagger:model
+type RemoveContactResp200Body struct {
+	// required: true
+	Success bool `json:"success"`
+}
+
+// @Summary      Remove contact
+// @Description  Removes the users from the contacts of each other.
+// @Tags         contacts
+// @Produce      json
+// @Param        userId path int64 true "User ID of the contact"
+// @Success      200      {object}  RemoveContactResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/contacts/{userId} [delete]
+func (h *Handler) RemoveContact(ctx *fiber.Ctx) error {
+	contactId, err := strconv.ParseInt(ctx.Params("userId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.ContactService.RemoveContact(ctx.Context(), u.ID, contactId); err != nil {
+		return contactError(err)
+	}
+	return ctx.JSON(&RemoveContactResp200Body{
+		Success: true,
+	})
+}
+
+// listen ws with events addressed to the user, like contact requests
+func (h *Handler) ListenUser(conn *websocket.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer conn.Close()
+
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				break
+			}
+		}
+	}()
+
+	usr, _ := conn.Locals(auth.CtxKey).(*users.User)
+	if usr == nil {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"unauthorized"}`))
+		return
+	}
+
+	ch := make(chan events.Event)
+	h.evls.ChatEventListener.SubscribeUser(usr.ID, ch)
+	defer func() {
+		// keep receiving while unsubscribing, the channel may be blocked delivering an event to us
+		done := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-ch:
+				case <-done:
+					return
+				}
+			}
+		}()
+		h.evls.ChatEventListener.UnsubscribeUser(usr.ID, ch)
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-ch:
+			if err := conn.WriteJSON(msg); err != nil {
+				h.logger.Error(ctx, fmt.Errorf("cannot write websocket message: %w", err))
+				return
+			}
+		}
+	}
+}
+
+func contactError(err error) error {
+	switch {
+	case errors.Is(err, contact.ErrUserNotFound),
+		errors.Is(err, contact.ErrRequestNotFound),
+		errors.Is(err, contact.ErrContactNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, contact.ErrAlreadyContacts),
+		errors.Is(err, contact.ErrRequestPending):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, contact.ErrNotRequestRecipient),
+		errors.Is(err, contact.ErrNotRequestSender),
+		errors.Is(err, contact.ErrCannotSendRequest):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, contact.ErrCannotAddSelf):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return fiber.ErrInternalServerError
+}
@@ -0,0 +1,136 @@
+package devices
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/services"
+	"chatapp/internal/services/push"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	srvs   *services.Services
+	logger logger.Logger
+}
+
+func NewHandler(srvs *services.Services, logger logger.Logger) *Handler {
+	return &Handler{
+		srvs:   srvs,
+		logger: logger,
+	}
+}
+
+// RegisterDeviceRequestPayload represents the request payload for registering a device.
+// swagger:model
+type RegisterDeviceRequestPayload struct {
+	// required: true
+	Platform string `json:"platform" validate:"required,oneof=android ios web"`
+	// PushToken is the FCM registration token of android and web apps or the APNs device token of iOS apps
+	// required: true
+	PushToken string `json:"push_token" validate:"required,max=512"`
+}
+
+// DeviceResp200Body represents a registered device.
+// swagger:model
+type DeviceResp200Body struct {
+	// required: true
+	Device *users.Device `json:"device"`
+}
+
+// @Summary      Register device
+// @Description  Registers the device for push notifications. Apps call it on every start, the push token may change.
+// @Tags         devices
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      RegisterDeviceRequestPayload  true  "Register Device Request Payload"
+// @Success      200      {object}  DeviceResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/devices [post]
+func (h *Handler) RegisterDevice(ctx *fiber.Ctx) error {
+	reqBody := &RegisterDeviceRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	d, err := h.srvs.DeviceService.RegisterDevice(ctx.Context(), u.ID, reqBody.Platform, reqBody.PushToken)
+	if err != nil {
+		return deviceError(err)
+	}
+	return ctx.JSON(&DeviceResp200Body{
+		Device: d,
+	})
+}
+
+// DevicesResp200Body represents the devices of the user.
+// swagger:model
+type DevicesResp200Body struct {
+	// required: true
+	Devices []*users.Device `json:"devices"`
+}
+
+// @Summary      Get devices
+// @Tags         devices
+// @Produce      json
+// @Success      200      {object}  DevicesResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/devices [get]
+func (h *Handler) GetDevices(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+
+	devices, err := h.srvs.DeviceService.GetDevices(ctx.Context(), u.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&DevicesResp200Body{
+		Devices: devices,
+	})
+}
+
+// DeleteDeviceResp200Body represents a successful removal.
+// swagger:model
+type DeleteDeviceResp200Body struct {
+	// required: true
+	Success bool `json:"success"`
+}
+
+// @Summary      Delete device
+// @Description  Stops push notifications to the device, apps call it on sign out.
+// @Tags         devices
+// @Produce      json
+// @Param        deviceId path int64 true "Device ID"
+// @Success      200      {object}  DeleteDeviceResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/devices/{deviceId} [delete]
+func (h *Handler) DeleteDevice(ctx *fiber.Ctx) error {
+	id, err := strconv.ParseInt(ctx.Params("deviceId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.DeviceService.DeleteDevice(ctx.Context(), u.ID, id); err != nil {
+		return deviceError(err)
+	}
+	return ctx.JSON(&DeleteDeviceResp200Body{
+		Success: true,
+	})
+}
+
+func deviceError(err error) error {
+	switch {
+	case errors.Is(err, push.ErrDeviceNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, push.ErrInvalidPlatform):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return fiber.ErrInternalServerError
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"chatapp/cmd/server/handlers/admin"
 	"chatapp/cmd/server/handlers/auth"
 	"chatapp/cmd/server/handlers/chat/conversation"
 	"chatapp/cmd/server/handlers/chat/message"
+	"chatapp/cmd/server/handlers/contacts"
+	"chatapp/cmd/server/handlers/devices"
+	"chatapp/cmd/server/handlers/integrations"
 	"chatapp/cmd/server/middlewares"
+	"chatapp/internal/entities/users"
 	eventlisteners "chatapp/internal/eventListeners"
 	"chatapp/internal/logger"
 	"chatapp/internal/repositories"
@@ -18,12 +23,51 @@ import (
 type AuthHandler interface {
 	Register(c *fiber.Ctx) error
 	Login(c *fiber.Ctx) error
+	LoginMFA(c *fiber.Ctx) error
+	Refresh(c *fiber.Ctx) error
+	VerifyEmail(c *fiber.Ctx) error
+	ResendVerificationEmail(c *fiber.Ctx) error
+	ForgotPassword(c *fiber.Ctx) error
+	ResetPassword(c *fiber.Ctx) error
+	GetOIDCProviders(c *fiber.Ctx) error
+	BeginOIDCLogin(c *fiber.Ctx) error
+	FinishOIDCLogin(c *fiber.Ctx) error
+	Logout(c *fiber.Ctx) error
+	GetSessions(c *fiber.Ctx) error
+	RevokeSession(c *fiber.Ctx) error
 	GetProfile(c *fiber.Ctx) error
+	UpdateProfile(c *fiber.Ctx) error
+	UploadAvatar(c *fiber.Ctx) error
+	RemoveAvatar(c *fiber.Ctx) error
+	GetAvatar(c *fiber.Ctx) error
+	ChangeEmail(c *fiber.Ctx) error
+	ConfirmEmailChange(c *fiber.Ctx) error
+	ChangePassword(c *fiber.Ctx) error
+	UpdatePrivacy(c *fiber.Ctx) error
+	UpdateNotificationSettings(c *fiber.Ctx) error
+	SearchUsers(c *fiber.Ctx) error
+	GetUser(c *fiber.Ctx) error
+	BlockUser(c *fiber.Ctx) error
+	UnblockUser(c *fiber.Ctx) error
+	GetBlocks(c *fiber.Ctx) error
+	ExportPersonalData(c *fiber.Ctx) error
+	DeleteAccount(c *fiber.Ctx) error
+	GetJWKS(c *fiber.Ctx) error
+	EnrollTOTP(c *fiber.Ctx) error
+	ConfirmTOTP(c *fiber.Ctx) error
+	RegenerateRecoveryCodes(c *fiber.Ctx) error
+	DisableTOTP(c *fiber.Ctx) error
 }
 
 type ConversationHandler interface {
 	CreateConversation(ctx *fiber.Ctx) error
 	ShowUserTyping(ctx *fiber.Ctx) error
+	SetMessageTTL(ctx *fiber.Ctx) error
+	SetRetention(ctx *fiber.Ctx) error
+	SetMuted(ctx *fiber.Ctx) error
+	ExportConversation(ctx *fiber.Ctx) error
+	GetExport(ctx *fiber.Ctx) error
+	DownloadExport(ctx *fiber.Ctx) error
 	ListenConversation(conn *websocket.Conn)
 }
 
@@ -31,11 +75,64 @@ type MessageHandler interface {
 	SendMessage(c *fiber.Ctx) error
 	UpdateMessage(c *fiber.Ctx) error
 	GetMessages(c *fiber.Ctx) error
+	GetMentions(c *fiber.Ctx) error
+	GetMentionCounts(c *fiber.Ctx) error
+	MarkMentionsRead(c *fiber.Ctx) error
+	PinMessage(c *fiber.Ctx) error
+	UnpinMessage(c *fiber.Ctx) error
+	GetPins(c *fiber.Ctx) error
+	ScheduleMessage(c *fiber.Ctx) error
+	GetScheduledMessages(c *fiber.Ctx) error
+	UpdateScheduledMessage(c *fiber.Ctx) error
+	CancelScheduledMessage(c *fiber.Ctx) error
+}
+type IntegrationHandler interface {
+	CreateAPIKey(c *fiber.Ctx) error
+	GetAPIKeys(c *fiber.Ctx) error
+	RevokeAPIKey(c *fiber.Ctx) error
+	CreateBot(c *fiber.Ctx) error
+	GetBots(c *fiber.Ctx) error
+	DeleteBot(c *fiber.Ctx) error
+	CreateWebhook(c *fiber.Ctx) error
+	GetWebhooks(c *fiber.Ctx) error
+	DeleteWebhook(c *fiber.Ctx) error
+	GetWebhookDeliveries(c *fiber.Ctx) error
+	GetWebhookDeadLetters(c *fiber.Ctx) error
+	CreateIncomingWebhook(c *fiber.Ctx) error
+	GetIncomingWebhooks(c *fiber.Ctx) error
+	DeleteIncomingWebhook(c *fiber.Ctx) error
+	PostIncomingMessage(c *fiber.Ctx) error
+	CreateCommand(c *fiber.Ctx) error
+	GetCommands(c *fiber.Ctx) error
+	DeleteCommand(c *fiber.Ctx) error
+}
+type ContactHandler interface {
+	SendRequest(c *fiber.Ctx) error
+	GetRequests(c *fiber.Ctx) error
+	AcceptRequest(c *fiber.Ctx) error
+	DeclineRequest(c *fiber.Ctx) error
+	CancelRequest(c *fiber.Ctx) error
+	GetContacts(c *fiber.Ctx) error
+	RemoveContact(c *fiber.Ctx) error
+	ListenUser(conn *websocket.Conn)
+}
+type DeviceHandler interface {
+	RegisterDevice(c *fiber.Ctx) error
+	GetDevices(c *fiber.Ctx) error
+	DeleteDevice(c *fiber.Ctx) error
+}
+type AdminHandler interface {
+	SetLegalHold(c *fiber.Ctx) error
+	GetRetentionRuns(c *fiber.Ctx) error
 }
 type Handlers struct {
-	authHandler AuthHandler
-	convHandler ConversationHandler
-	msgHandler  MessageHandler
+	authHandler        AuthHandler
+	convHandler        ConversationHandler
+	msgHandler         MessageHandler
+	integrationHandler IntegrationHandler
+	adminHandler       AdminHandler
+	contactHandler     ContactHandler
+	deviceHandler      DeviceHandler
 
 	mdlwrs *middlewares.Middlewares
 }
@@ -52,31 +149,130 @@ func NewHandlers(
 		convHandler: conversation.NewHandler(srvs, evls, logger),
 		msgHandler:  message.NewHandler(srvs, logger),
 
+		integrationHandler: integrations.NewHandler(srvs, logger),
+		adminHandler:       admin.NewHandler(srvs, logger),
+		contactHandler:     contacts.NewHandler(srvs, evls, logger),
+		deviceHandler:      devices.NewHandler(srvs, logger),
+
 		mdlwrs: mdlwrs,
 	}
 }
 
 func (h *Handlers) RegisterRoutes(r fiber.Router) {
 	r.Get("swagger/*", swagger.HandlerDefault)
+	r.Get("/.well-known/jwks.json", h.authHandler.GetJWKS)
 
 	v1 := r.Group("/api/v1")
 
+	ipLimit := h.mdlwrs.AuthRateLimiterMiddleware.HandleByIP
 	auth := v1.Group("/auth")
-	auth.Post("/signup", h.authHandler.Register)
-	auth.Post("/signin", h.authHandler.Login)
+	auth.Post("/signup", ipLimit, h.authHandler.Register)
+	auth.Post("/signin", ipLimit, h.authHandler.Login)
+	auth.Post("/signin/mfa", ipLimit, h.authHandler.LoginMFA)
+	auth.Post("/refresh", ipLimit, h.authHandler.Refresh)
+	auth.Post("/verify-email", ipLimit, h.authHandler.VerifyEmail)
+	auth.Post("/verify-email/resend", ipLimit, h.authHandler.ResendVerificationEmail)
+	auth.Post("/forgot-password", ipLimit, h.authHandler.ForgotPassword)
+	auth.Post("/reset-password", ipLimit, h.authHandler.ResetPassword)
+	auth.Post("/confirm-email", ipLimit, h.authHandler.ConfirmEmailChange)
+	auth.Get("/oidc/providers", h.authHandler.GetOIDCProviders)
+	auth.Get("/oidc/login", ipLimit, h.authHandler.BeginOIDCLogin)
+	auth.Get("/oidc/callback", ipLimit, h.authHandler.FinishOIDCLogin)
+
+	v1.Get("/avatars/:name", h.authHandler.GetAvatar)
+
+	// incoming webhooks are authenticated by the secret URL and limited per webhook
+	v1.Post("/hooks/:token", h.integrationHandler.PostIncomingMessage)
 
 	protected := v1.Group("/")
 	protected.Use(h.mdlwrs.AuthMiddleware.Handle)
 	protected.Use(h.mdlwrs.RateLimiterMiddleware.Handle)
-	protected.Get("/profile", h.authHandler.GetProfile)
+	session := h.mdlwrs.AuthMiddleware.RequireSession
+	scope := h.mdlwrs.AuthMiddleware.RequireScope
+	protected.Get("/profile", scope(users.ScopeProfileRead), h.authHandler.GetProfile)
+	protected.Patch("/profile", session, h.authHandler.UpdateProfile)
+	protected.Put("/profile/avatar", session, h.authHandler.UploadAvatar)
+	protected.Delete("/profile/avatar", session, h.authHandler.RemoveAvatar)
+	protected.Post("/profile/email", session, h.authHandler.ChangeEmail)
+	protected.Post("/profile/password", session, h.authHandler.ChangePassword)
+	protected.Put("/profile/privacy", session, h.authHandler.UpdatePrivacy)
+	protected.Put("/profile/notifications", session, h.authHandler.UpdateNotificationSettings)
+	protected.Get("/profile/export", session, h.authHandler.ExportPersonalData)
+	protected.Delete("/profile", session, h.authHandler.DeleteAccount)
+	protected.Post("/auth/logout", session, h.authHandler.Logout)
+	protected.Get("/auth/sessions", session, h.authHandler.GetSessions)
+	protected.Delete("/auth/sessions/:sessionId", session, h.authHandler.RevokeSession)
+	protected.Post("/auth/2fa/enroll", session, h.authHandler.EnrollTOTP)
+	protected.Post("/auth/2fa/confirm", session, h.authHandler.ConfirmTOTP)
+	protected.Post("/auth/2fa/recovery-codes", session, h.authHandler.RegenerateRecoveryCodes)
+	protected.Post("/auth/2fa/disable", session, h.authHandler.DisableTOTP)
+
+	// routes below are available only after 2FA enrollment when it is required
+	protected.Use(h.mdlwrs.AuthMiddleware.RequireMFAEnrollment)
+
+	protected.Post("/api-keys", session, h.integrationHandler.CreateAPIKey)
+	protected.Get("/api-keys", session, h.integrationHandler.GetAPIKeys)
+	protected.Delete("/api-keys/:keyId", session, h.integrationHandler.RevokeAPIKey)
+	protected.Post("/bots", session, h.integrationHandler.CreateBot)
+	protected.Get("/bots", session, h.integrationHandler.GetBots)
+	protected.Delete("/bots/:botId", session, h.integrationHandler.DeleteBot)
+
+	protected.Get("/users", scope(users.ScopeUsersRead), h.authHandler.SearchUsers)
+	protected.Get("/users/:userId", scope(users.ScopeUsersRead), h.authHandler.GetUser)
+	protected.Post("/users/:userId/block", session, h.authHandler.BlockUser)
+	protected.Delete("/users/:userId/block", session, h.authHandler.UnblockUser)
+	protected.Get("/blocks", session, h.authHandler.GetBlocks)
+	protected.Post("/contact-requests", session, h.contactHandler.SendRequest)
+	protected.Get("/contact-requests", session, h.contactHandler.GetRequests)
+	protected.Post("/contact-requests/:requestId/accept", session, h.contactHandler.AcceptRequest)
+	protected.Post("/contact-requests/:requestId/decline", session, h.contactHandler.DeclineRequest)
+	protected.Delete("/contact-requests/:requestId", session, h.contactHandler.CancelRequest)
+	protected.Get("/contacts", session, h.contactHandler.GetContacts)
+	protected.Delete("/contacts/:userId", session, h.contactHandler.RemoveContact)
+	protected.Post("/devices", session, h.deviceHandler.RegisterDevice)
+	protected.Get("/devices", session, h.deviceHandler.GetDevices)
+	protected.Delete("/devices/:deviceId", session, h.deviceHandler.DeleteDevice)
+
+	protected.Post("/conversations", scope(users.ScopeConversationsWrite), h.convHandler.CreateConversation)
+	protected.Post("/conversations/:conversationId/show-user-typing", scope(users.ScopeMessagesWrite), h.convHandler.ShowUserTyping)
+	protected.Put("/conversations/:conversationId/message-ttl", scope(users.ScopeConversationsWrite), h.convHandler.SetMessageTTL)
+	protected.Put("/conversations/:conversationId/retention", scope(users.ScopeConversationsWrite), h.convHandler.SetRetention)
+	protected.Put("/conversations/:conversationId/mute", scope(users.ScopeConversationsWrite), h.convHandler.SetMuted)
+	protected.Get("/conversations/:conversationId/export", scope(users.ScopeMessagesRead), h.convHandler.ExportConversation)
+	protected.Get("/conversations/:conversationId/exports/:exportId", scope(users.ScopeMessagesRead), h.convHandler.GetExport)
+	protected.Get("/conversations/:conversationId/exports/:exportId/download", scope(users.ScopeMessagesRead), h.convHandler.DownloadExport)
+
+	protected.Post("/conversations/:conversationId/messages", scope(users.ScopeMessagesWrite), h.msgHandler.SendMessage)
+	protected.Post("/conversations/:conversationId/messages/:messageId", scope(users.ScopeMessagesWrite), h.msgHandler.UpdateMessage)
+	protected.Get("/conversations/:conversationId/messages", scope(users.ScopeMessagesRead), h.msgHandler.GetMessages)
+	protected.Post("/conversations/:conversationId/messages/:messageId/pin", scope(users.ScopeMessagesWrite), h.msgHandler.PinMessage)
+	protected.Delete("/conversations/:conversationId/messages/:messageId/pin", scope(users.ScopeMessagesWrite), h.msgHandler.UnpinMessage)
+	protected.Get("/conversations/:conversationId/pins", scope(users.ScopeMessagesRead), h.msgHandler.GetPins)
+	protected.Post("/conversations/:conversationId/scheduled-messages", scope(users.ScopeMessagesWrite), h.msgHandler.ScheduleMessage)
+	protected.Get("/conversations/:conversationId/scheduled-messages", scope(users.ScopeMessagesRead), h.msgHandler.GetScheduledMessages)
+	protected.Patch("/conversations/:conversationId/scheduled-messages/:scheduledId", scope(users.ScopeMessagesWrite), h.msgHandler.UpdateScheduledMessage)
+	protected.Delete("/conversations/:conversationId/scheduled-messages/:scheduledId", scope(users.ScopeMessagesWrite), h.msgHandler.CancelScheduledMessage)
+	protected.Post("/conversations/:conversationId/mentions/read", scope(users.ScopeMessagesWrite), h.msgHandler.MarkMentionsRead)
+	protected.Get("/mentions", scope(users.ScopeMessagesRead), h.msgHandler.GetMentions)
+	protected.Get("/mentions/counts", scope(users.ScopeMessagesRead), h.msgHandler.GetMentionCounts)
 
-	protected.Post("/conversations", h.convHandler.CreateConversation)
-	protected.Post("/conversations/:conversationId/show-user-typing", h.convHandler.ShowUserTyping)
+	protected.Post("/conversations/:conversationId/webhooks", session, h.integrationHandler.CreateWebhook)
+	protected.Get("/conversations/:conversationId/webhooks", session, h.integrationHandler.GetWebhooks)
+	protected.Delete("/conversations/:conversationId/webhooks/:webhookId", session, h.integrationHandler.DeleteWebhook)
+	protected.Get("/conversations/:conversationId/webhooks/:webhookId/deliveries", session, h.integrationHandler.GetWebhookDeliveries)
+	protected.Get("/conversations/:conversationId/webhooks/:webhookId/dead-letters", session, h.integrationHandler.GetWebhookDeadLetters)
+	protected.Post("/conversations/:conversationId/incoming-webhooks", session, h.integrationHandler.CreateIncomingWebhook)
+	protected.Get("/conversations/:conversationId/incoming-webhooks", session, h.integrationHandler.GetIncomingWebhooks)
+	protected.Delete("/conversations/:conversationId/incoming-webhooks/:webhookId", session, h.integrationHandler.DeleteIncomingWebhook)
+	protected.Post("/conversations/:conversationId/commands", session, h.integrationHandler.CreateCommand)
+	protected.Get("/conversations/:conversationId/commands", session, h.integrationHandler.GetCommands)
+	protected.Delete("/conversations/:conversationId/commands/:commandId", session, h.integrationHandler.DeleteCommand)
 
-	protected.Post("/conversations/:conversationId/messages", h.msgHandler.SendMessage)
-	protected.Post("/conversations/:conversationId/messages/:messageId", h.msgHandler.UpdateMessage)
-	protected.Get("/conversations/:conversationId/messages", h.msgHandler.GetMessages)
+	requireAdmin := h.mdlwrs.AuthMiddleware.RequireAdmin
+	protected.Put("/admin/conversations/:conversationId/legal-hold", requireAdmin, h.adminHandler.SetLegalHold)
+	protected.Get("/admin/retention-runs", requireAdmin, h.adminHandler.GetRetentionRuns)
 
-	protected.Get("/listen/conversations/:conversationId", websocket.New(h.convHandler.ListenConversation))
+	protected.Get("/listen/conversations/:conversationId", scope(users.ScopeMessagesRead), websocket.New(h.convHandler.ListenConversation))
+	protected.Get("/listen/user", session, websocket.New(h.contactHandler.ListenUser))
 
 }
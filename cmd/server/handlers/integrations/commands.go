@@ -0,0 +1,143 @@
+package integrations
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	command_dto "chatapp/internal/dto/command"
+	"chatapp/internal/entities/integrations"
+	"chatapp/internal/services/chat/command"
+	"chatapp/internal/services/chat/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateCommandRequestPayload represents the request payload for a new external slash command.
+// swagger:model
+type CreateCommandRequestPayload struct {
+	// Command name without the slash, lowercase letters, digits, '-' and '_'
+	// required: true
+	Name string `json:"name" validate:"required,min=1,max=32"`
+	// Shown by /help
+	Description string `json:"description" validate:"max=250"`
+	// URL receiving signed POST requests on every invocation
+	// required: true
+	URL string `json:"url" validate:"required,http_url,max=2048"`
+}
+
+// CreateCommandResp200Body represents a created command, the secret is shown only once.
+// swagger:model
+type CreateCommandResp200Body struct {
+	// Secret for checking the X-Chat-Signature header
+	// required: true
+	Secret string `json:"secret"`
+	// required: true
+	Command *integrations.BotCommand `json:"command"`
+}
+
+// @Summary      Create slash command
+// @Description  Registers an external command of the conversation. Invocations are POSTed to the URL signed like webhooks, the JSON response {"text", "response_type"} is shown only to the invoker unless response_type is "in_channel". Only conversation admins can manage commands.
+// @Tags         commands
+// @Accept       json
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        payload  body      CreateCommandRequestPayload  true  "Create Command Payload"
+// @Success      200      {object}  CreateCommandResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/commands [post]
+func (h *Handler) CreateCommand(ctx *fiber.Ctx) error {
+	reqBody := &CreateCommandRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	u := auth.MustGetUser(ctx)
+
+	cmd, secret, err := h.srvs.CommandService.CreateCommand(ctx.Context(), u, &command_dto.CreateCommandDTO{
+		ConversationID: cnvId,
+		Name:           reqBody.Name,
+		Description:    reqBody.Description,
+		URL:            reqBody.URL,
+	})
+	if err != nil {
+		return commandError(err)
+	}
+	return ctx.JSON(&CreateCommandResp200Body{
+		Secret:  secret,
+		Command: cmd,
+	})
+}
+
+// CommandsResp200Body represents external commands of a conversation.
+// swagger:model
+type CommandsResp200Body struct {
+	// required: true
+	Commands []*integrations.BotCommand `json:"commands"`
+}
+
+// @Summary      List slash commands
+// @Tags         commands
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Success      200      {object}  CommandsResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/commands [get]
+func (h *Handler) GetCommands(ctx *fiber.Ctx) error {
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	u := auth.MustGetUser(ctx)
+
+	cmds, err := h.srvs.CommandService.GetCommands(ctx.Context(), u.ID, cnvId)
+	if err != nil {
+		return commandError(err)
+	}
+	return ctx.JSON(&CommandsResp200Body{
+		Commands: cmds,
+	})
+}
+
+// @Summary      Delete slash command
+// @Tags         commands
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        commandId path int64 true "Command ID"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/commands/{commandId} [delete]
+func (h *Handler) DeleteCommand(ctx *fiber.Ctx) error {
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	cmdId, err := h.parseIdParam(ctx, "commandId")
+	if err != nil {
+		return err
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.CommandService.DeleteCommand(ctx.Context(), u.ID, cnvId, cmdId); err != nil {
+		return commandError(err)
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+func commandError(err error) error {
+	switch {
+	case errors.Is(err, command.ErrCommandNotFound):
+		return fiber.ErrNotFound
+	case errors.Is(err, command.ErrCommandExists):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, command.ErrInvalidCommandName):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, utils.ErrIsNotConversationAdmin):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return webhookError(err)
+}
@@ -0,0 +1,248 @@
+package integrations
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	user_dto "chatapp/internal/dto/user"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/services"
+	"chatapp/internal/services/auth/apikey"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	srvs   *services.Services
+	logger logger.Logger
+}
+
+func NewHandler(srvs *services.Services, logger logger.Logger) *Handler {
+	return &Handler{
+		srvs:   srvs,
+		logger: logger,
+	}
+}
+
+// SuccessResp200Body represents a successful response without data.
+// swagger:model
+type SuccessResp200Body struct {
+	// Indicates whether the operation was successful.
+	// required: true
+	Success bool `json:"success"`
+}
+
+// CreateAPIKeyRequestPayload represents the request payload for a new API key.
+// swagger:model
+type CreateAPIKeyRequestPayload struct {
+	// Name to recognize the key
+	// required: true
+	Name string `json:"name" validate:"required,min=1,max=100"`
+	// Create the key for this bot instead of the current user
+	BotID *int64 `json:"bot_id"`
+	// Allowed scopes: profile:read, users:read, conversations:write, messages:read, messages:write
+	// required: true
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,required"`
+	// Limit the key to these conversations, empty means any
+	ConversationIDs []int64 `json:"conversation_ids" validate:"max=100"`
+	// Expiration time, never expires when empty
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResp200Body represents a created API key, the key itself is shown only once.
+// swagger:model
+type CreateAPIKeyResp200Body struct {
+	// Key to be sent in X-API-Key header
+	// required: true
+	Key string `json:"key"`
+	// required: true
+	APIKey *users.APIKey `json:"api_key"`
+}
+
+// @Summary      Create API key
+// @Description  Creates a personal API key, or a key of an owned bot, accepted in X-API-Key header.
+// @Tags         integrations
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      CreateAPIKeyRequestPayload  true  "Create API Key Payload"
+// @Success      200      {object}  CreateAPIKeyResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/api-keys [post]
+func (h *Handler) CreateAPIKey(ctx *fiber.Ctx) error {
+	reqBody := &CreateAPIKeyRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	if reqBody.ExpiresAt != nil && reqBody.ExpiresAt.Before(time.Now()) {
+		return fiber.NewError(fiber.StatusBadRequest, "expires_at must be in the future")
+	}
+	u := auth.MustGetUser(ctx)
+
+	key, raw, err := h.srvs.APIKeyService.CreateAPIKey(ctx.Context(), u, &user_dto.CreateAPIKeyDTO{
+		Name:            reqBody.Name,
+		BotID:           reqBody.BotID,
+		Scopes:          reqBody.Scopes,
+		ConversationIDs: reqBody.ConversationIDs,
+		ExpiresAt:       reqBody.ExpiresAt,
+	})
+	if err != nil {
+		if errors.Is(err, apikey.ErrUnknownScope) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if errors.Is(err, apikey.ErrBotNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&CreateAPIKeyResp200Body{
+		Key:    raw,
+		APIKey: key,
+	})
+}
+
+// APIKeysResp200Body represents a list of active API keys.
+// swagger:model
+type APIKeysResp200Body struct {
+	// required: true
+	APIKeys []*users.APIKey `json:"api_keys"`
+}
+
+// @Summary      List API keys
+// @Description  Lists active API keys created by the user, including keys of the user's bots.
+// @Tags         integrations
+// @Produce      json
+// @Success      200      {object}  APIKeysResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/api-keys [get]
+func (h *Handler) GetAPIKeys(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+
+	keys, err := h.srvs.APIKeyService.GetAPIKeys(ctx.Context(), u.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&APIKeysResp200Body{
+		APIKeys: keys,
+	})
+}
+
+// @Summary      Revoke API key
+// @Tags         integrations
+// @Produce      json
+// @Param        keyId path int64 true "API key ID"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/api-keys/{keyId} [delete]
+func (h *Handler) RevokeAPIKey(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+	keyId, err := strconv.ParseInt(ctx.Params("keyId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	if err := h.srvs.APIKeyService.RevokeAPIKey(ctx.Context(), u.ID, keyId); err != nil {
+		if errors.Is(err, apikey.ErrAPIKeyNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+// CreateBotRequestPayload represents the request payload for a new bot.
+// swagger:model
+type CreateBotRequestPayload struct {
+	// Name of the bot
+	// required: true
+	Name string `json:"name" validate:"required,min=3,max=20,alphanum"`
+}
+
+// BotResp200Body represents a bot account.
+// swagger:model
+type BotResp200Body struct {
+	// required: true
+	Bot *users.User `json:"bot"`
+}
+
+// @Summary      Create bot
+// @Description  Creates a bot account owned by the user. Bots act only through API keys.
+// @Tags         integrations
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      CreateBotRequestPayload  true  "Create Bot Payload"
+// @Success      200      {object}  BotResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/bots [post]
+func (h *Handler) CreateBot(ctx *fiber.Ctx) error {
+	reqBody := &CreateBotRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	u := auth.MustGetUser(ctx)
+
+	bot, err := h.srvs.APIKeyService.CreateBot(ctx.Context(), nil, u, reqBody.Name)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&BotResp200Body{
+		Bot: bot,
+	})
+}
+
+// BotsResp200Body represents the bots owned by the user.
+// swagger:model
+type BotsResp200Body struct {
+	// required: true
+	Bots []*users.User `json:"bots"`
+}
+
+// @Summary      List bots
+// @Tags         integrations
+// @Produce      json
+// @Success      200      {object}  BotsResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/bots [get]
+func (h *Handler) GetBots(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+
+	bots, err := h.srvs.APIKeyService.GetBots(ctx.Context(), u.ID)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&BotsResp200Body{
+		Bots: bots,
+	})
+}
+
+// @Summary      Delete bot
+// @Description  Deletes the bot and all of its API keys.
+// @Tags         integrations
+// @Produce      json
+// @Param        botId path int64 true "Bot ID"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/bots/{botId} [delete]
+func (h *Handler) DeleteBot(ctx *fiber.Ctx) error {
+	u := auth.MustGetUser(ctx)
+	botId, err := strconv.ParseInt(ctx.Params("botId"), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return fiber.ErrBadRequest
+	}
+	if err := h.srvs.APIKeyService.DeleteBot(ctx.Context(), u.ID, botId); err != nil {
+		if errors.Is(err, apikey.ErrBotNotFound) {
+			return fiber.ErrNotFound
+		}
+		return fiber.ErrInternalServerError
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
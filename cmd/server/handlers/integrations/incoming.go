@@ -0,0 +1,185 @@
+package integrations
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	webhook_dto "chatapp/internal/dto/webhook"
+	"chatapp/internal/entities/chat"
+	"chatapp/internal/entities/integrations"
+	"chatapp/internal/services/chat/utils"
+	"chatapp/internal/services/webhook"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateIncomingWebhookRequestPayload represents the request payload for a new incoming webhook.
+// swagger:model
+type CreateIncomingWebhookRequestPayload struct {
+	// Name of the webhook bot
+	// required: true
+	Name string `json:"name" validate:"required,min=3,max=20,alphanum"`
+	// Messages per minute, server default when empty
+	RateLimitPerMinute int `json:"rate_limit_per_minute" validate:"omitempty,min=1,max=600"`
+}
+
+// CreateIncomingWebhookResp200Body represents a created incoming webhook, the URL is shown only once.
+// swagger:model
+type CreateIncomingWebhookResp200Body struct {
+	// Secret URL accepting POST requests
+	// required: true
+	URL string `json:"url"`
+	// required: true
+	Webhook *integrations.IncomingWebhook `json:"webhook"`
+}
+
+// @Summary      Create incoming webhook
+// @Description  Creates a secret URL posting messages into the conversation as a bot. Only conversation admins can manage incoming webhooks.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        payload  body      CreateIncomingWebhookRequestPayload  true  "Create Incoming Webhook Payload"
+// @Success      200      {object}  CreateIncomingWebhookResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/incoming-webhooks [post]
+func (h *Handler) CreateIncomingWebhook(ctx *fiber.Ctx) error {
+	reqBody := &CreateIncomingWebhookRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	u := auth.MustGetUser(ctx)
+
+	w, url, err := h.srvs.IncomingService.CreateIncomingWebhook(ctx.Context(), u, &webhook_dto.CreateIncomingWebhookDTO{
+		ConversationID:     cnvId,
+		Name:               reqBody.Name,
+		RateLimitPerMinute: reqBody.RateLimitPerMinute,
+	})
+	if err != nil {
+		return incomingWebhookError(err)
+	}
+	return ctx.JSON(&CreateIncomingWebhookResp200Body{
+		URL:     url,
+		Webhook: w,
+	})
+}
+
+// IncomingWebhooksResp200Body represents incoming webhooks of a conversation.
+// swagger:model
+type IncomingWebhooksResp200Body struct {
+	// required: true
+	Webhooks []*integrations.IncomingWebhook `json:"webhooks"`
+}
+
+// @Summary      List incoming webhooks
+// @Tags         webhooks
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Success      200      {object}  IncomingWebhooksResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/incoming-webhooks [get]
+func (h *Handler) GetIncomingWebhooks(ctx *fiber.Ctx) error {
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	u := auth.MustGetUser(ctx)
+
+	webhooks, err := h.srvs.IncomingService.GetIncomingWebhooks(ctx.Context(), u.ID, cnvId)
+	if err != nil {
+		return incomingWebhookError(err)
+	}
+	return ctx.JSON(&IncomingWebhooksResp200Body{
+		Webhooks: webhooks,
+	})
+}
+
+// @Summary      Delete incoming webhook
+// @Tags         webhooks
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        webhookId path int64 true "Incoming webhook ID"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/incoming-webhooks/{webhookId} [delete]
+func (h *Handler) DeleteIncomingWebhook(ctx *fiber.Ctx) error {
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	webhookId, err := h.parseIdParam(ctx, "webhookId")
+	if err != nil {
+		return err
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.IncomingService.DeleteIncomingWebhook(ctx.Context(), u.ID, cnvId, webhookId); err != nil {
+		return incomingWebhookError(err)
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+// IncomingMessageRequestPayload represents a message posted to an incoming webhook.
+// swagger:model
+type IncomingMessageRequestPayload struct {
+	// Content of the message
+	// required: true
+	Text string `json:"text" validate:"required,min=1,max=4000"`
+	// Name shown instead of the bot name
+	Username *string `json:"username" validate:"omitempty,min=1,max=50"`
+	// Avatar shown instead of the bot avatar
+	AvatarURL *string `json:"avatar_url" validate:"omitempty,http_url,max=2048"`
+}
+
+// IncomingMessageResp200Body represents the created message.
+// swagger:model
+type IncomingMessageResp200Body struct {
+	// required: true
+	Message *chat.Message `json:"message"`
+}
+
+// @Summary      Post message to incoming webhook
+// @Description  Creates a message in the webhook conversation. The URL itself authenticates the request.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        token path string true "Webhook token"
+// @Param        payload  body      IncomingMessageRequestPayload  true  "Incoming Message Payload"
+// @Success      200      {object}  IncomingMessageResp200Body
+// @Router       /api/v1/hooks/{token} [post]
+func (h *Handler) PostIncomingMessage(ctx *fiber.Ctx) error {
+	reqBody := &IncomingMessageRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+
+	msg, err := h.srvs.IncomingService.PostMessage(ctx.Context(), ctx.Params("token"), &webhook_dto.IncomingMessageDTO{
+		Text:      reqBody.Text,
+		Username:  reqBody.Username,
+		AvatarURL: reqBody.AvatarURL,
+	})
+	if err != nil {
+		return incomingWebhookError(err)
+	}
+	return ctx.JSON(&IncomingMessageResp200Body{
+		Message: msg,
+	})
+}
+
+func incomingWebhookError(err error) error {
+	switch {
+	case errors.Is(err, webhook.ErrInvalidHookToken):
+		return fiber.ErrNotFound
+	case errors.Is(err, webhook.ErrRateLimited):
+		return fiber.ErrTooManyRequests
+	case errors.Is(err, utils.ErrIsNotConversationAdmin):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return webhookError(err)
+}
@@ -0,0 +1,247 @@
+package integrations
+
+import (
+	"chatapp/cmd/server/middlewares/auth"
+	reqparser "chatapp/cmd/server/utils/req_parser"
+	webhook_dto "chatapp/internal/dto/webhook"
+	"chatapp/internal/entities/integrations"
+	"chatapp/internal/services/chat/utils"
+	"chatapp/internal/services/netguard"
+	"chatapp/internal/services/webhook"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateWebhookRequestPayload represents the request payload for a new outgoing webhook.
+// swagger:model
+type CreateWebhookRequestPayload struct {
+	// URL receiving signed POST requests
+	// required: true
+	URL string `json:"url" validate:"required,http_url,max=2048"`
+	// Events to deliver: message_created, message_updated, message_expired, message_pinned, message_unpinned, participant_added, conversation_updated, user_updated. All when empty
+	Events []string `json:"events" validate:"dive,required"`
+}
+
+// CreateWebhookResp200Body represents a created webhook, the secret is shown only once.
+// swagger:model
+type CreateWebhookResp200Body struct {
+	// Secret for checking the X-Chat-Signature header
+	// required: true
+	Secret string `json:"secret"`
+	// required: true
+	Webhook *integrations.Webhook `json:"webhook"`
+}
+
+// @Summary      Create outgoing webhook
+// @Description  Subscribes a URL to conversation events. Requests are signed with HMAC-SHA256 of "<X-Chat-Timestamp>.<body>" in X-Chat-Signature header. Only conversation admins can create webhooks, the URL must point to a public address.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        payload  body      CreateWebhookRequestPayload  true  "Create Webhook Payload"
+// @Success      200      {object}  CreateWebhookResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/webhooks [post]
+func (h *Handler) CreateWebhook(ctx *fiber.Ctx) error {
+	reqBody := &CreateWebhookRequestPayload{}
+	if err := reqparser.ParseReqBody(ctx, reqBody); err != nil {
+		return errors.Join(fiber.ErrBadRequest, err)
+	}
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	u := auth.MustGetUser(ctx)
+
+	w, secret, err := h.srvs.WebhookService.CreateWebhook(ctx.Context(), &webhook_dto.CreateWebhookDTO{
+		ConversationID: cnvId,
+		UserID:         u.ID,
+		URL:            reqBody.URL,
+		Events:         reqBody.Events,
+	})
+	if err != nil {
+		return webhookError(err)
+	}
+	return ctx.JSON(&CreateWebhookResp200Body{
+		Secret:  secret,
+		Webhook: w,
+	})
+}
+
+// WebhooksResp200Body represents webhooks of a conversation.
+// swagger:model
+type WebhooksResp200Body struct {
+	// required: true
+	Webhooks []*integrations.Webhook `json:"webhooks"`
+}
+
+// @Summary      List outgoing webhooks
+// @Tags         webhooks
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Success      200      {object}  WebhooksResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/webhooks [get]
+func (h *Handler) GetWebhooks(ctx *fiber.Ctx) error {
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	u := auth.MustGetUser(ctx)
+
+	webhooks, err := h.srvs.WebhookService.GetWebhooks(ctx.Context(), u.ID, cnvId)
+	if err != nil {
+		return webhookError(err)
+	}
+	return ctx.JSON(&WebhooksResp200Body{
+		Webhooks: webhooks,
+	})
+}
+
+// @Summary      Delete outgoing webhook
+// @Tags         webhooks
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        webhookId path int64 true "Webhook ID"
+// @Success      200      {object}  SuccessResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/webhooks/{webhookId} [delete]
+func (h *Handler) DeleteWebhook(ctx *fiber.Ctx) error {
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	webhookId, err := h.parseIdParam(ctx, "webhookId")
+	if err != nil {
+		return err
+	}
+	u := auth.MustGetUser(ctx)
+
+	if err := h.srvs.WebhookService.DeleteWebhook(ctx.Context(), u.ID, cnvId, webhookId); err != nil {
+		return webhookError(err)
+	}
+	return ctx.JSON(&SuccessResp200Body{
+		Success: true,
+	})
+}
+
+// WebhookDeliveriesResp200Body represents the delivery log of a webhook.
+// swagger:model
+type WebhookDeliveriesResp200Body struct {
+	// required: true
+	Deliveries []*integrations.WebhookDelivery `json:"deliveries"`
+}
+
+// @Summary      Webhook delivery log
+// @Description  Lists deliveries of a webhook, newest first.
+// @Tags         webhooks
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        webhookId path int64 true "Webhook ID"
+// @Param        limit  query int   false "Number of deliveries to retrieve" default(20)
+// @Param        lastID query int64 false "ID of the last delivery received"
+// @Success      200      {object}  WebhookDeliveriesResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/webhooks/{webhookId}/deliveries [get]
+func (h *Handler) GetWebhookDeliveries(ctx *fiber.Ctx) error {
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	webhookId, err := h.parseIdParam(ctx, "webhookId")
+	if err != nil {
+		return err
+	}
+	limit, err := strconv.Atoi(ctx.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		return fiber.ErrBadRequest
+	}
+	var lastID *int64
+	if lastIDStr := ctx.Query("lastID", ""); lastIDStr != "" {
+		id, err := strconv.ParseInt(lastIDStr, 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+		lastID = &id
+	}
+	u := auth.MustGetUser(ctx)
+
+	deliveries, err := h.srvs.WebhookService.GetDeliveries(ctx.Context(), &webhook_dto.GetDeliveriesQueryParams{
+		ConvId:    cnvId,
+		WebhookId: webhookId,
+		UserId:    u.ID,
+		Limit:     limit,
+		LastId:    lastID,
+	})
+	if err != nil {
+		return webhookError(err)
+	}
+	return ctx.JSON(&WebhookDeliveriesResp200Body{
+		Deliveries: deliveries,
+	})
+}
+
+// WebhookDeadLettersResp200Body represents deliveries which failed all attempts.
+// swagger:model
+type WebhookDeadLettersResp200Body struct {
+	// required: true
+	DeadLetters []*integrations.WebhookDeadLetter `json:"dead_letters"`
+}
+
+// @Summary      Webhook dead letters
+// @Description  Lists the latest deliveries which failed all attempts.
+// @Tags         webhooks
+// @Produce      json
+// @Param        conversationId path int64 true "Conversation ID"
+// @Param        webhookId path int64 true "Webhook ID"
+// @Success      200      {object}  WebhookDeadLettersResp200Body
+// @Security     UserTokenAuth
+// @Router       /api/v1/conversations/{conversationId}/webhooks/{webhookId}/dead-letters [get]
+func (h *Handler) GetWebhookDeadLetters(ctx *fiber.Ctx) error {
+	cnvId, err := h.parseIdParam(ctx, "conversationId")
+	if err != nil {
+		return err
+	}
+	webhookId, err := h.parseIdParam(ctx, "webhookId")
+	if err != nil {
+		return err
+	}
+	u := auth.MustGetUser(ctx)
+
+	deadLetters, err := h.srvs.WebhookService.GetDeadLetters(ctx.Context(), u.ID, cnvId, webhookId, 100)
+	if err != nil {
+		return webhookError(err)
+	}
+	return ctx.JSON(&WebhookDeadLettersResp200Body{
+		DeadLetters: deadLetters,
+	})
+}
+
+func (h *Handler) parseIdParam(ctx *fiber.Ctx, name string) (int64, error) {
+	id, err := strconv.ParseInt(ctx.Params(name), 10, 64)
+	if err != nil {
+		h.logger.Error(ctx.Context(), fmt.Errorf("failed to parse query params: %w", err), slog.Any("params", ctx.AllParams()))
+		return 0, fiber.ErrBadRequest
+	}
+	return id, nil
+}
+
+func webhookError(err error) error {
+	switch {
+	case errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound):
+		return errors.Join(fiber.ErrBadRequest, err)
+	case errors.Is(err, utils.ErrIsNotConversationAdmin):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, netguard.ErrInvalidURL) || errors.Is(err, netguard.ErrPrivateAddress):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, webhook.ErrWebhookNotFound):
+		return fiber.ErrNotFound
+	case errors.Is(err, webhook.ErrUnknownEvent):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return fiber.ErrInternalServerError
+}
@@ -28,6 +28,9 @@ import (
 // @securityDefinitions.apikey UserTokenAuth
 // @in header
 // @name X-User-Token
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
 // @contact.name   API Support
 // @contact.url    http://www.swagger.io/support
 // @contact.email  support@swagger.io
@@ -61,14 +64,27 @@ func main() {
 
 	//init dependencies
 	rps := repositories.NewRepositories(cls)
-	evls := eventlisteners.NewEventListeners()
-	srvs := services.NewServices(cfg, cls, logger, rps, evls)
+	evls := eventlisteners.NewEventListeners(logger)
+	srvs, err := services.NewServices(cfg, cls, logger, rps, evls)
+	if err != nil {
+		logger.Fatal(ctx, err)
+	}
 	middlewares := middlewares.NewMiddlewares(srvs, rps, logger)
 	h := handlers.NewHandlers(srvs, middlewares, rps, evls, logger)
 
 	h.RegisterRoutes(app)
 
 	var wg sync.WaitGroup
+	//run background workers
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	for _, w := range srvs.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Run(workersCtx)
+		}()
+	}
+
 	wg.Add(1)
 	//run server
 	go func() {
@@ -89,6 +105,7 @@ func main() {
 	if err != nil {
 		logger.Fatal(ctx, fmt.Errorf("server shutdown failed: %v", err))
 	}
+	stopWorkers()
 	logger.Info(ctx, "Server shutdown gracefully")
 
 	wg.Wait()
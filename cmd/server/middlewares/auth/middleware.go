@@ -6,14 +6,19 @@ import (
 	"chatapp/internal/logger"
 	"chatapp/internal/repositories"
 	"chatapp/internal/services"
+	"chatapp/internal/services/auth/apikey"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-var ErrUserIdExpected = errors.New("user ID expected")
+var (
+	ErrUserIdExpected    = errors.New("user ID expected")
+	ErrSessionIdExpected = errors.New("session ID expected")
+)
 
 type Middleware struct {
 	srvs   *services.Services
@@ -30,19 +35,34 @@ func NewMiddleware(srvs *services.Services, repos *repositories.Repositories, lo
 }
 
 const authHeaderName = "X-User-Token"
+const apiKeyHeaderName = "X-API-Key"
 const CtxKey = "user_id"
+const SessionCtxKey = "session_id"
+const APIKeyCtxKey = "api_key_id"
 
+// Handle authenticates the request by a session JWT or by a personal API key.
 func (m *Middleware) Handle(ctx *fiber.Ctx) error {
+	if key := ctx.Get(apiKeyHeaderName); key != "" {
+		return m.handleAPIKey(ctx, key)
+	}
 	token := ctx.Get(authHeaderName)
 	if token == "" {
 		return fiber.ErrUnauthorized
 	}
-	userId, err := m.srvs.JwtService.VerifyAuthToken(token)
+	claims, err := m.srvs.JwtService.VerifyAuthToken(token)
 	if err != nil {
 		return fiber.ErrUnauthorized
 	}
 
-	user, err := m.repos.UserRepository.GetUserById(ctx.Context(), *userId)
+	active, err := m.srvs.SessionService.IsSessionActive(ctx.Context(), claims.UserID, claims.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to check session: %w", err)
+	}
+	if !active {
+		return fiber.ErrUnauthorized
+	}
+
+	user, err := m.repos.UserRepository.GetUserById(ctx.Context(), claims.UserID)
 	if err != nil {
 		return fmt.Errorf("failed to get user by id: %w", err)
 	}
@@ -51,7 +71,78 @@ func (m *Middleware) Handle(ctx *fiber.Ctx) error {
 		return fiber.ErrNotFound
 	}
 	ctx.Locals(CtxKey, user)
-	fu.SetLoggerAttrs(ctx, slog.Int64(CtxKey, *userId))
+	ctx.Locals(SessionCtxKey, claims.SessionID)
+	fu.SetLoggerAttrs(ctx, slog.Int64(CtxKey, claims.UserID), slog.Int64(SessionCtxKey, claims.SessionID))
+	return ctx.Next()
+}
+
+func (m *Middleware) handleAPIKey(ctx *fiber.Ctx, raw string) error {
+	user, key, err := m.srvs.APIKeyService.Authenticate(ctx.Context(), raw)
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidAPIKey) {
+			return fiber.ErrUnauthorized
+		}
+		return fmt.Errorf("failed to check api key: %w", err)
+	}
+	ctx.Locals(CtxKey, user)
+	ctx.Locals(APIKeyCtxKey, key)
+	fu.SetLoggerAttrs(ctx, slog.Int64(CtxKey, user.ID), slog.Int64(APIKeyCtxKey, key.ID))
+	return ctx.Next()
+}
+
+// RequireSession rejects API keys on routes managing the account itself.
+func (m *Middleware) RequireSession(ctx *fiber.Ctx) error {
+	if GetAPIKey(ctx) != nil {
+		return fiber.NewError(fiber.StatusForbidden, "not available for api keys")
+	}
+	return ctx.Next()
+}
+
+// RequireAdmin allows only system administrators signed in with a session.
+func (m *Middleware) RequireAdmin(ctx *fiber.Ctx) error {
+	if GetAPIKey(ctx) != nil || !MustGetUser(ctx).IsAdmin {
+		return fiber.NewError(fiber.StatusForbidden, "administrator access required")
+	}
+	return ctx.Next()
+}
+
+// RequireScope checks the scope of an API key and, for routes with a conversationId param,
+// the conversations the key is limited to. Conversation routes without the param, like mentions
+// or creating a conversation, span all conversations and are denied to limited keys.
+// Session tokens are not limited.
+func (m *Middleware) RequireScope(scope string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		key := GetAPIKey(ctx)
+		if key == nil {
+			return ctx.Next()
+		}
+		if !key.HasScope(scope) {
+			return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("api key has no %s scope", scope))
+		}
+		param := ctx.Params("conversationId")
+		if param == "" {
+			if key.IsConversationLimited() && users.IsConversationScope(scope) {
+				return fiber.NewError(fiber.StatusForbidden, "api key is limited to conversations")
+			}
+			return ctx.Next()
+		}
+		cnvId, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return fiber.ErrBadRequest
+		}
+		if !key.AllowsConversation(cnvId) {
+			return fiber.NewError(fiber.StatusForbidden, "api key is not allowed for this conversation")
+		}
+		return ctx.Next()
+	}
+}
+
+// RequireMFAEnrollment blocks users without 2FA when it is mandatory.
+// Routes needed to enroll must be registered before this middleware.
+func (m *Middleware) RequireMFAEnrollment(ctx *fiber.Ctx) error {
+	if m.srvs.UserService.RequiresMFAEnrollment(MustGetUser(ctx)) {
+		return fiber.NewError(fiber.StatusForbidden, "two-factor authentication enrollment required")
+	}
 	return ctx.Next()
 }
 
@@ -67,3 +158,17 @@ func MustGetUser(ctx *fiber.Ctx) *users.User {
 	val := ctx.Locals(CtxKey)
 	return mustBeUser(val)
 }
+
+// GetAPIKey returns the key used for the request, nil for session tokens.
+func GetAPIKey(ctx *fiber.Ctx) *users.APIKey {
+	key, _ := ctx.Locals(APIKeyCtxKey).(*users.APIKey)
+	return key
+}
+
+func MustGetSessionID(ctx *fiber.Ctx) int64 {
+	if id, ok := ctx.Locals(SessionCtxKey).(int64); ok {
+		return id
+	}
+
+	panic(ErrSessionIdExpected)
+}
@@ -11,6 +11,8 @@ import (
 type Middlewares struct {
 	AuthMiddleware        *auth.Middleware
 	RateLimiterMiddleware *ratelimiter.Middleware
+	// AuthRateLimiterMiddleware limits public auth endpoints by IP
+	AuthRateLimiterMiddleware *ratelimiter.Middleware
 }
 
 func NewMiddlewares(
@@ -21,5 +23,7 @@ func NewMiddlewares(
 	return &Middlewares{
 		AuthMiddleware:        auth.NewMiddleware(srvs, rps, logger),
 		RateLimiterMiddleware: ratelimiter.NewMiddleware(5, 10),
+
+		AuthRateLimiterMiddleware: ratelimiter.NewMiddleware(1, 10),
 	}
 }
@@ -38,7 +38,15 @@ func (rl *Middleware) getLimiter(key string) *rate.Limiter {
 
 func (rl *Middleware) Handle(c *fiber.Ctx) error {
 	userID := auth.MustGetUser(c).ID
-	key := fmt.Sprintf("user-%d", userID)
+	return rl.allow(c, fmt.Sprintf("user-%d", userID))
+}
+
+// HandleByIP limits requests of unauthenticated endpoints by client IP.
+func (rl *Middleware) HandleByIP(c *fiber.Ctx) error {
+	return rl.allow(c, fmt.Sprintf("ip-%s", c.IP()))
+}
+
+func (rl *Middleware) allow(c *fiber.Ctx, key string) error {
 	limiter := rl.getLimiter(key)
 
 	if !limiter.Allow() {
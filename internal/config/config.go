@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env"
 	"github.com/go-playground/validator/v10"
@@ -17,7 +18,135 @@ type Config struct {
 	DBUser     string `env:"DB_USER" validate:"required"`
 	DBPassword string `env:"DB_PASSWORD" validate:"required"`
 	DBName     string `env:"DB_NAME" validate:"required"`
-	JWTSecret  string `env:"JWT_SECRET" validate:"required"`
+	JWTSecret  string `env:"JWT_SECRET" validate:"required_without=JWTKeysDir"`
+
+	// AppSecret signs one-time tokens sent to users
+	AppSecret    string `env:"APP_SECRET" validate:"required,min=32"`
+	AppPublicURL string `env:"APP_PUBLIC_URL" envDefault:"http://localhost:9001" validate:"required,url"`
+
+	JWTIssuer      string `env:"JWT_ISSUER" envDefault:"chatapp" validate:"required"`
+	JWTKeysDir     string `env:"JWT_KEYS_DIR"`
+	JWTActiveKeyID string `env:"JWT_ACTIVE_KEY_ID" validate:"required_with=JWTKeysDir"`
+
+	LoginThrottleWindow   time.Duration `env:"LOGIN_THROTTLE_WINDOW" envDefault:"15m" validate:"required"`
+	LoginFreeAttempts     int           `env:"LOGIN_FREE_ATTEMPTS" envDefault:"3" validate:"gte=0"`
+	LoginIPFreeAttempts   int           `env:"LOGIN_IP_FREE_ATTEMPTS" envDefault:"20" validate:"gte=0"`
+	LoginBackoffBase      time.Duration `env:"LOGIN_BACKOFF_BASE" envDefault:"1s" validate:"required"`
+	LoginBackoffMax       time.Duration `env:"LOGIN_BACKOFF_MAX" envDefault:"5m" validate:"required"`
+	LoginLockoutThreshold int           `env:"LOGIN_LOCKOUT_THRESHOLD" envDefault:"10" validate:"gt=0"`
+	LoginLockoutDuration  time.Duration `env:"LOGIN_LOCKOUT_DURATION" envDefault:"30m" validate:"required"`
+
+	PasswordMinLength        int    `env:"PASSWORD_MIN_LENGTH" envDefault:"8" validate:"gte=8"`
+	PasswordMaxLength        int    `env:"PASSWORD_MAX_LENGTH" envDefault:"128" validate:"gtefield=PasswordMinLength,lte=128"`
+	PasswordBreachedListFile string `env:"PASSWORD_BREACHED_LIST_FILE"`
+	PasswordHashAlgorithm    string `env:"PASSWORD_HASH_ALGORITHM" envDefault:"argon2id" validate:"oneof=argon2id bcrypt"`
+	Argon2Memory             uint   `env:"ARGON2_MEMORY_KIB" envDefault:"65536" validate:"gte=8192"`
+	Argon2Iterations         uint   `env:"ARGON2_ITERATIONS" envDefault:"3" validate:"gte=1"`
+	Argon2Parallelism        uint   `env:"ARGON2_PARALLELISM" envDefault:"2" validate:"gte=1,lte=255"`
+	BcryptCost               int    `env:"BCRYPT_COST" envDefault:"10" validate:"gte=10,lte=31"`
+
+	AccessTokenTTL  time.Duration `env:"JWT_ACCESS_TOKEN_TTL" envDefault:"15m" validate:"required"`
+	RefreshTokenTTL time.Duration `env:"JWT_REFRESH_TOKEN_TTL" envDefault:"720h" validate:"required"`
+
+	MailerDriver  string `env:"MAILER_DRIVER" envDefault:"outbox" validate:"oneof=smtp outbox"`
+	MailFrom      string `env:"MAIL_FROM" envDefault:"Chat <no-reply@localhost>" validate:"required"`
+	MailOutboxDir string `env:"MAIL_OUTBOX_DIR"`
+	SMTPHost      string `env:"SMTP_HOST" validate:"required_if=MailerDriver smtp"`
+	SMTPPort      string `env:"SMTP_PORT" envDefault:"587" validate:"required,number"`
+	SMTPUsername  string `env:"SMTP_USERNAME"`
+	SMTPPassword  string `env:"SMTP_PASSWORD"`
+
+	RequireEmailVerification bool          `env:"REQUIRE_EMAIL_VERIFICATION" envDefault:"false"`
+	EmailVerificationTTL     time.Duration `env:"EMAIL_VERIFICATION_TTL" envDefault:"48h" validate:"required"`
+	PasswordResetTTL         time.Duration `env:"PASSWORD_RESET_TTL" envDefault:"1h" validate:"required"`
+
+	MFAIssuer       string        `env:"MFA_ISSUER" envDefault:"Chat" validate:"required"`
+	MFARequired     bool          `env:"MFA_REQUIRED" envDefault:"false"`
+	MFAChallengeTTL time.Duration `env:"MFA_CHALLENGE_TTL" envDefault:"5m" validate:"required"`
+
+	OIDCProviderNames []string `env:"OIDC_PROVIDERS" envSeparator:","`
+	// OIDCSuccessRedirectURL receives tokens in the URL fragment after SSO, JSON is returned when empty
+	OIDCSuccessRedirectURL string               `env:"OIDC_SUCCESS_REDIRECT_URL" validate:"omitempty,url"`
+	OIDCProviders          []OIDCProviderConfig `validate:"dive"`
+
+	// WebhookAllowPrivateNetworks lets webhooks and external commands reach loopback and private addresses, meant for local development
+	WebhookAllowPrivateNetworks bool          `env:"WEBHOOK_ALLOW_PRIVATE_NETWORKS" envDefault:"false"`
+	WebhookTimeout              time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s" validate:"required"`
+	WebhookMaxAttempts          int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8" validate:"min=1"`
+	WebhookBackoffBase          time.Duration `env:"WEBHOOK_BACKOFF_BASE" envDefault:"10s" validate:"required"`
+	WebhookBackoffMax           time.Duration `env:"WEBHOOK_BACKOFF_MAX" envDefault:"1h" validate:"required"`
+	WebhookPollInterval         time.Duration `env:"WEBHOOK_POLL_INTERVAL" envDefault:"2s" validate:"required"`
+	WebhookBatchSize            int           `env:"WEBHOOK_BATCH_SIZE" envDefault:"20" validate:"min=1"`
+	// IncomingWebhookRateLimit is the default of messages per minute for a new incoming webhook
+	IncomingWebhookRateLimit int `env:"INCOMING_WEBHOOK_RATE_LIMIT" envDefault:"60" validate:"min=1"`
+	// BotCommandTimeout bounds the wait for an external slash command, the invoker is waiting for the response
+	BotCommandTimeout time.Duration `env:"BOT_COMMAND_TIMEOUT" envDefault:"3s" validate:"required"`
+
+	JobPollInterval time.Duration `env:"JOB_POLL_INTERVAL" envDefault:"1s" validate:"required"`
+	JobBatchSize    int           `env:"JOB_BATCH_SIZE" envDefault:"20" validate:"min=1"`
+	// JobLease must outlive the longest job, otherwise another replica could run it twice
+	JobLease       time.Duration `env:"JOB_LEASE" envDefault:"5m" validate:"required"`
+	JobMaxAttempts int           `env:"JOB_MAX_ATTEMPTS" envDefault:"5" validate:"min=1"`
+	JobBackoffBase time.Duration `env:"JOB_BACKOFF_BASE" envDefault:"30s" validate:"required"`
+	JobBackoffMax  time.Duration `env:"JOB_BACKOFF_MAX" envDefault:"1h" validate:"required"`
+
+	MessageExpiryInterval  time.Duration `env:"MESSAGE_EXPIRY_INTERVAL" envDefault:"30s" validate:"required"`
+	MessageExpiryBatchSize int           `env:"MESSAGE_EXPIRY_BATCH_SIZE" envDefault:"500" validate:"min=1"`
+
+	// RetentionDefaultDays purges messages older than the period in conversations without an override, 0 keeps them forever
+	RetentionDefaultDays int           `env:"RETENTION_DEFAULT_DAYS" envDefault:"0" validate:"min=0"`
+	RetentionArchive     bool          `env:"RETENTION_ARCHIVE" envDefault:"false"`
+	RetentionInterval    time.Duration `env:"RETENTION_INTERVAL" envDefault:"1h" validate:"required"`
+	// RetentionBatchSize bounds the rows locked by a single delete statement
+	RetentionBatchSize  int           `env:"RETENTION_BATCH_SIZE" envDefault:"1000" validate:"min=1"`
+	RetentionBatchPause time.Duration `env:"RETENTION_BATCH_PAUSE" envDefault:"100ms"`
+
+	// ExportDir stores files of asynchronous exports, they are deleted after ExportTTL
+	ExportDir string `env:"EXPORT_DIR" envDefault:"exports" validate:"required"`
+	// ExportSyncMaxMessages is the largest conversation streamed directly, larger ones are exported by a job
+	ExportSyncMaxMessages int64         `env:"EXPORT_SYNC_MAX_MESSAGES" envDefault:"5000" validate:"min=0"`
+	ExportBatchSize       int           `env:"EXPORT_BATCH_SIZE" envDefault:"500" validate:"min=1"`
+	ExportTTL             time.Duration `env:"EXPORT_TTL" envDefault:"24h" validate:"required"`
+	ExportCleanupInterval time.Duration `env:"EXPORT_CLEANUP_INTERVAL" envDefault:"1h" validate:"required"`
+
+	// AvatarDir stores uploaded avatars, they are resized to AvatarSize squares
+	AvatarDir           string `env:"AVATAR_DIR" envDefault:"avatars" validate:"required"`
+	AvatarSize          int    `env:"AVATAR_SIZE" envDefault:"256" validate:"min=32,max=1024"`
+	AvatarMaxUploadSize int64  `env:"AVATAR_MAX_UPLOAD_SIZE" envDefault:"2097152" validate:"min=1"`
+
+	// AccountDeletionPolicy is what deleting an account does: anonymize scrubs the account in place,
+	// delete removes it. Messages are kept either way, attributed to a deleted user placeholder.
+	AccountDeletionPolicy string `env:"ACCOUNT_DELETION_POLICY" envDefault:"anonymize" validate:"oneof=anonymize delete"`
+
+	// PushDriver sends push notifications: log only logs them, remote sends them through FCM and APNs.
+	// Platforms without credentials of their provider are not pushed to by the remote driver.
+	PushDriver  string        `env:"PUSH_DRIVER" envDefault:"log" validate:"oneof=log remote"`
+	PushTimeout time.Duration `env:"PUSH_TIMEOUT" envDefault:"10s" validate:"required"`
+	// PushConcurrency bounds messages whose notifications are being sent at once
+	PushConcurrency int `env:"PUSH_CONCURRENCY" envDefault:"16" validate:"min=1"`
+	// FCMCredentialsFile is the service account JSON key of the Firebase project, it serves Android and web devices
+	FCMCredentialsFile string `env:"FCM_CREDENTIALS_FILE"`
+	FCMProjectID       string `env:"FCM_PROJECT_ID" validate:"required_with=FCMCredentialsFile"`
+	// APNsKeyFile is the .p8 token signing key of the Apple developer team, it serves iOS devices
+	APNsKeyFile string `env:"APNS_KEY_FILE"`
+	APNsKeyID   string `env:"APNS_KEY_ID" validate:"required_with=APNsKeyFile"`
+	APNsTeamID  string `env:"APNS_TEAM_ID" validate:"required_with=APNsKeyFile"`
+	// APNsTopic is the bundle id of the iOS app
+	APNsTopic      string `env:"APNS_TOPIC" validate:"required_with=APNsKeyFile"`
+	APNsProduction bool   `env:"APNS_PRODUCTION" envDefault:"false"`
+
+	// DigestInterval is how often users away for DigestInactivity are emailed a digest of unread messages,
+	// each user gets at most one digest per their daily or weekly frequency
+	DigestInterval   time.Duration `env:"DIGEST_INTERVAL" envDefault:"15m" validate:"required"`
+	DigestInactivity time.Duration `env:"DIGEST_INACTIVITY" envDefault:"24h" validate:"required"`
+	DigestBatchSize  int           `env:"DIGEST_BATCH_SIZE" envDefault:"100" validate:"min=1"`
+	// DigestMessagesPerConversation is the number of latest unread messages quoted for each conversation
+	DigestMessagesPerConversation int `env:"DIGEST_MESSAGES_PER_CONVERSATION" envDefault:"3" validate:"min=1"`
+
+	// ImportBatchSize is the number of rows written by one statement of the importer
+	ImportBatchSize int `env:"IMPORT_BATCH_SIZE" envDefault:"1000" validate:"min=1"`
+	// ImportPlaceholderDomain is the email domain of placeholder accounts for imported users without an email
+	ImportPlaceholderDomain string `env:"IMPORT_PLACEHOLDER_DOMAIN" envDefault:"import.invalid" validate:"required,hostname"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -27,6 +156,7 @@ func LoadConfig() (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse config from env: %w", err)
 	}
+	cfg.OIDCProviders = loadOIDCProviders(cfg.OIDCProviderNames)
 
 	validate := validator.New()
 	if err := validate.Struct(cfg); err != nil {
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// OIDCProviderConfig describes an OpenID Connect identity provider.
+// Providers are listed in OIDC_PROVIDERS, each one is configured with OIDC_<NAME>_* variables.
+type OIDCProviderConfig struct {
+	Name         string   `validate:"required,alphanum"`
+	DisplayName  string   `validate:"required"`
+	Issuer       string   `validate:"required,url"`
+	ClientID     string   `validate:"required"`
+	ClientSecret string   `validate:"required"`
+	Scopes       []string `validate:"required,min=1"`
+	// AllowedDomains restricts sign in to verified emails of these domains, empty allows any
+	AllowedDomains []string
+}
+
+func loadOIDCProviders(names []string) []OIDCProviderConfig {
+	providers := make([]OIDCProviderConfig, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := fmt.Sprintf("OIDC_%s_", strings.ToUpper(name))
+		p := OIDCProviderConfig{
+			Name:           name,
+			DisplayName:    getenvOr(prefix+"DISPLAY_NAME", name),
+			Issuer:         strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:       os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret:   os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:         splitList(getenvOr(prefix+"SCOPES", "openid,email,profile")),
+			AllowedDomains: splitList(os.Getenv(prefix + "ALLOWED_DOMAINS")),
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+func (c *Config) OIDCProvider(name string) (*OIDCProviderConfig, bool) {
+	for i := range c.OIDCProviders {
+		if c.OIDCProviders[i].Name == name {
+			return &c.OIDCProviders[i], true
+		}
+	}
+	return nil, false
+}
+
+func getenvOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func splitList(s string) []string {
+	res := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
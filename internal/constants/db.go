@@ -5,4 +5,28 @@ const (
 	ConversationTable            = "conversations"
 	ConversationParticipantTable = "conversation_participants"
 	MessageTable                 = "messages"
+	SessionTable                 = "sessions"
+	LoginAttemptTable            = "login_attempts"
+	UserTokenTable               = "user_tokens"
+	MFARecoveryCodeTable         = "mfa_recovery_codes"
+	UserIdentityTable            = "user_identities"
+	APIKeyTable                  = "api_keys"
+	WebhookTable                 = "webhooks"
+	WebhookDeliveryTable         = "webhook_deliveries"
+	WebhookDeadLetterTable       = "webhook_dead_letters"
+	IncomingWebhookTable         = "incoming_webhooks"
+	BotCommandTable              = "bot_commands"
+	MessageMentionTable          = "message_mentions"
+	MessagePinTable              = "message_pins"
+	JobTable                     = "jobs"
+	ScheduledMessageTable        = "scheduled_messages"
+	MessageArchiveTable          = "messages_archive"
+	RetentionRunTable            = "retention_runs"
+	ExportTable                  = "exports"
+	MessageReactionTable         = "message_reactions"
+	UserBlockTable               = "user_blocks"
+	ContactRequestTable          = "contact_requests"
+	ContactTable                 = "contacts"
+	DeviceTable                  = "devices"
+	EmailDigestTable             = "email_digests"
 )
@@ -0,0 +1,8 @@
+package command_dto
+
+type CreateCommandDTO struct {
+	ConversationID int64
+	Name           string
+	Description    string
+	URL            string
+}
@@ -1,8 +1,46 @@
 package messages_dto
 
+import "time"
+
 type GetMessageQueryParams struct {
 	ConvId         int64
 	Limit          int
 	LastReceivedId *int64
 	UserId         int64
 }
+
+type SendMessageDTO struct {
+	ConversationID int64
+	SenderID       int64
+	Content        string
+	DisplayName    *string
+	AvatarURL      *string
+	// Raw content is stored as is, even when it looks like a slash command
+	Raw bool
+	// TTL deletes the message after the period, the shorter of it and the conversation TTL applies
+	TTL *time.Duration
+}
+
+type GetMentionsQueryParams struct {
+	UserId int64
+	// ConvId limits mentions to a conversation, all conversations when nil
+	ConvId *int64
+	Limit  int
+	LastId *int64
+}
+
+type ScheduleMessageDTO struct {
+	ConversationID int64
+	SenderID       int64
+	Content        string
+	SendAt         time.Time
+}
+
+// UpdateScheduledMessageDTO changes only fields which are not nil
+type UpdateScheduledMessageDTO struct {
+	ConversationID int64
+	ID             int64
+	SenderID       int64
+	Content        *string
+	SendAt         *time.Time
+}
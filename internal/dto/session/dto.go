@@ -0,0 +1,17 @@
+package session_dto
+
+import "time"
+
+type CreateSessionDTO struct {
+	UserID    int64
+	UserAgent string
+	IP        string
+}
+
+type TokenPair struct {
+	SessionID        int64
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
@@ -1,7 +1,85 @@
 package user_dto
 
+import "time"
+
 type CreateUserDTO struct {
 	Name     string
 	Password string
 	Email    string
-}
\ No newline at end of file
+}
+
+type LoginDTO struct {
+	Email     string
+	Password  string
+	IP        string
+	UserAgent string
+}
+
+type MFALoginDTO struct {
+	Token        string
+	Code         string
+	RecoveryCode string
+	IP           string
+	UserAgent    string
+}
+
+// ExternalIdentityDTO is a user identity asserted by an OpenID Connect provider.
+type ExternalIdentityDTO struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+type CreateBotDTO struct {
+	OwnerID  int64
+	Name     string
+	Email    string
+	Password string
+}
+
+type CreateAPIKeyDTO struct {
+	Name string
+	// BotID creates the key for an owned bot instead of the user itself
+	BotID           *int64
+	Scopes          []string
+	ConversationIDs []int64
+	ExpiresAt       *time.Time
+}
+
+// CreateImportedUserDTO is a placeholder account for a user found in an imported archive.
+type CreateImportedUserDTO struct {
+	Name     string
+	Email    string
+	Password string
+	IsBot    bool
+}
+
+// UpdateProfileDTO changes the fields which are set, an empty display name clears it.
+type UpdateProfileDTO struct {
+	DisplayName *string
+	Bio         *string
+	Status      *string
+	Timezone    *string
+}
+
+// UpdatePrivacyDTO changes the settings which are set.
+type UpdatePrivacyDTO struct {
+	Discoverability *string
+	DMPolicy        *string
+}
+
+// NotificationSettingsDTO changes the settings which are set.
+type NotificationSettingsDTO struct {
+	PushLevel       *string
+	DigestFrequency *string
+	QuietHours      *QuietHoursDTO
+}
+
+// QuietHoursDTO sets quiet hours from Start to End, both nil turns them off.
+type QuietHoursDTO struct {
+	Start *int
+	End   *int
+}
@@ -0,0 +1,29 @@
+package webhook_dto
+
+type CreateWebhookDTO struct {
+	ConversationID int64
+	UserID         int64
+	URL            string
+	// Events to deliver, all supported events when empty
+	Events []string
+}
+
+type GetDeliveriesQueryParams struct {
+	ConvId    int64
+	WebhookId int64
+	UserId    int64
+	Limit     int
+	LastId    *int64
+}
+
+type CreateIncomingWebhookDTO struct {
+	ConversationID     int64
+	Name               string
+	RateLimitPerMinute int
+}
+
+type IncomingMessageDTO struct {
+	Text      string
+	Username  *string
+	AvatarURL *string
+}
@@ -1,6 +1,9 @@
 package chat
 
-import "time"
+import (
+	"chatapp/internal/entities/users"
+	"time"
+)
 
 // Conversation represents a chat conversation between users.
 // @Description Represents a chat conversation between users.
@@ -10,12 +13,53 @@ type Conversation struct {
 	ID        int64     `db:"id" json:"id"`
 	Name      string    `db:"name" json:"name"`
 	IsGroup   bool      `db:"is_group" json:"is_group"`
+	Topic     string    `db:"topic" json:"topic"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// MessageTTL in seconds deletes new messages after the period, messages are kept when nil
+	MessageTTL *int `db:"message_ttl" json:"message_ttl"`
+	// RetentionDays overrides the default retention, 0 keeps messages forever
+	RetentionDays *int `db:"retention_days" json:"retention_days"`
+	// LegalHold suspends purging of the conversation, it is visible only to system administrators
+	LegalHold bool `db:"legal_hold" json:"-"`
+	// ExternalID identifies imported conversations in their source, like a Slack channel id
+	ExternalID *string `db:"external_id" json:"-"`
 }
 
+// Membership is a conversation together with the user's participation in it.
+type Membership struct {
+	Conversation
+	Role     string    `db:"role" json:"role"`
+	JoinedAt time.Time `db:"joined_at" json:"joined_at"`
+}
+
+const (
+	ParticipantRoleAdmin  = "admin"
+	ParticipantRoleMember = "member"
+)
+
 type ConversationParticipant struct {
 	ConversationID int64     `db:"conversation_id" json:"conversation_id"`
 	UserID         int64     `db:"user_id" json:"user_id"`
 	JoinedAt       time.Time `db:"joined_at" json:"joined_at"`
+	Role           string    `db:"role" json:"role"`
+	// Muted without MutedUntil means muted until turned off
+	Muted      bool       `db:"muted" json:"muted"`
+	MutedUntil *time.Time `db:"muted_until" json:"muted_until"`
+	// LastSeenAt is when the user last had the conversation open
+	LastSeenAt time.Time `db:"last_seen_at" json:"last_seen_at"`
+}
+
+func (p *ConversationParticipant) IsAdmin() bool {
+	return p.Role == ParticipantRoleAdmin
+}
+
+func (p *ConversationParticipant) IsMuted(now time.Time) bool {
+	return p.Muted && (p.MutedUntil == nil || now.Before(*p.MutedUntil))
+}
+
+// UserUpdated carries a changed profile to a conversation the user participates in.
+type UserUpdated struct {
+	ConversationID int64             `json:"conversation_id"`
+	User           *users.PublicUser `json:"user"`
 }
@@ -0,0 +1,15 @@
+package chat
+
+// DigestMessage is an unread message quoted in an email digest, with the unread totals of its conversation.
+type DigestMessage struct {
+	Message
+	ConversationName string `db:"conversation_name"`
+	IsGroup          bool   `db:"is_group"`
+	SenderName       string `db:"sender_name"`
+	// Mentioned is set when the message mentions the user and the mention is unread
+	Mentioned    bool `db:"mentioned"`
+	UnreadCount  int  `db:"unread_count"`
+	MentionCount int  `db:"mention_count"`
+	// Rank is 1 for the latest unread message of the conversation
+	Rank int `db:"rank"`
+}
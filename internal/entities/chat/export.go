@@ -0,0 +1,47 @@
+package chat
+
+import "time"
+
+type ExportFormat string
+
+const (
+	ExportFormatJSON ExportFormat = "json"
+	ExportFormatHTML ExportFormat = "html"
+	ExportFormatTXT  ExportFormat = "txt"
+)
+
+type ExportStatus string
+
+const (
+	ExportStatusPending ExportStatus = "pending"
+	ExportStatusRunning ExportStatus = "running"
+	ExportStatusReady   ExportStatus = "ready"
+	ExportStatusFailed  ExportStatus = "failed"
+)
+
+// Export is a conversation history written to a file by a background job, it is downloadable until ExpiresAt.
+type Export struct {
+	ID             int64        `db:"id" json:"id"`
+	ConversationID int64        `db:"conversation_id" json:"conversation_id"`
+	RequestedBy    int64        `db:"requested_by" json:"requested_by"`
+	Format         ExportFormat `db:"format" json:"format"`
+	Status         ExportStatus `db:"status" json:"status"`
+	FilePath       *string      `db:"file_path" json:"-"`
+	Size           *int64       `db:"size" json:"size"`
+	Error          *string      `db:"error" json:"error"`
+	CreatedAt      time.Time    `db:"created_at" json:"created_at"`
+	FinishedAt     *time.Time   `db:"finished_at" json:"finished_at"`
+	ExpiresAt      *time.Time   `db:"expires_at" json:"expires_at"`
+}
+
+// ExportedMessage is a message with the name of its sender at the time of the export.
+type ExportedMessage struct {
+	Message
+	SenderName *string `db:"sender_name" json:"sender_name"`
+}
+
+// ParticipantProfile is a participant with the username.
+type ParticipantProfile struct {
+	ConversationParticipant
+	Username string `db:"user_name" json:"user_name"`
+}
@@ -0,0 +1,14 @@
+package chat
+
+// ImportedReaction is a reaction from an import archive, it references its message by external id.
+type ImportedReaction struct {
+	MessageExternalID string
+	UserID            int64
+	Emoji             string
+}
+
+// ImportedThreadLink attaches an imported reply to its thread root, both are referenced by external id.
+type ImportedThreadLink struct {
+	MessageExternalID string
+	ParentExternalID  string
+}
@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	MentionKindUser = "user"
+	// MentionKindHere notifies participants online in the conversation
+	MentionKindHere = "here"
+	// MentionKindAll notifies every participant
+	MentionKindAll = "all"
+)
+
+// Mention is a range of the message content referring to users.
+// Offset and Length are counted in unicode code points.
+type Mention struct {
+	Kind   string `json:"kind"`
+	UserID int64  `json:"user_id,omitempty"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// Mentions are stored as JSON with the message.
+type Mentions []Mention
+
+func (m Mentions) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (m *Mentions) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	}
+	return fmt.Errorf("unsupported mentions type %T", src)
+}
+
+// MentionCount is the number of unread mentions of a user in a conversation.
+type MentionCount struct {
+	ConversationID int64 `db:"conversation_id" json:"conversation_id"`
+	Count          int   `db:"count" json:"count"`
+}
@@ -9,4 +9,35 @@ type Message struct {
 	Content        string    `db:"content" json:"content"`
 	CreatedAt      time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+
+	// DisplayName and AvatarURL override the sender's profile, used by incoming webhooks
+	DisplayName *string `db:"display_name" json:"display_name,omitempty"`
+	AvatarURL   *string `db:"avatar_url" json:"avatar_url,omitempty"`
+
+	Mentions Mentions `db:"mentions" json:"mentions,omitempty"`
+
+	// ParentID is the thread root the message replies to
+	ParentID *int64 `db:"parent_id" json:"parent_id,omitempty"`
+	// ExternalID identifies imported messages in their source, like the Slack ts
+	ExternalID *string `db:"external_id" json:"-"`
+
+	// ExpiresAt is the time the message is deleted at, clients should hide it from then
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+
+	// Ephemeral messages are command responses shown only to the invoker, they are never stored
+	Ephemeral bool `db:"-" json:"ephemeral,omitempty"`
+}
+
+// ExpiredMessage identifies a deleted expired message, clients purge their copies on message_expired events.
+type ExpiredMessage struct {
+	ID             int64 `db:"id" json:"id"`
+	ConversationID int64 `db:"conversation_id" json:"conversation_id"`
+}
+
+// Reaction is an emoji a user reacted to a message with.
+type Reaction struct {
+	MessageID int64     `db:"message_id" json:"message_id"`
+	UserID    int64     `db:"user_id" json:"user_id"`
+	Emoji     string    `db:"emoji" json:"emoji"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
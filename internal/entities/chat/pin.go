@@ -0,0 +1,21 @@
+package chat
+
+import "time"
+
+// Pin marks a message as pinned in its conversation, pins are removed with the message.
+type Pin struct {
+	ConversationID int64     `db:"conversation_id" json:"conversation_id"`
+	MessageID      int64     `db:"message_id" json:"message_id"`
+	PinnedBy       *int64    `db:"pinned_by" json:"pinned_by"`
+	PinnedAt       time.Time `db:"pinned_at" json:"pinned_at"`
+
+	// UnpinnedBy is set only in message_unpinned events
+	UnpinnedBy *int64 `db:"-" json:"unpinned_by,omitempty"`
+}
+
+// PinnedMessage is a message with the details of its pin.
+type PinnedMessage struct {
+	Message
+	PinnedBy *int64    `db:"pinned_by" json:"pinned_by"`
+	PinnedAt time.Time `db:"pinned_at" json:"pinned_at"`
+}
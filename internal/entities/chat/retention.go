@@ -0,0 +1,60 @@
+package chat
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type RetentionRunStatus string
+
+const (
+	RetentionRunStatusRunning   RetentionRunStatus = "running"
+	RetentionRunStatusSucceeded RetentionRunStatus = "succeeded"
+	RetentionRunStatusFailed    RetentionRunStatus = "failed"
+)
+
+// RetentionTarget is a conversation to purge with its effective retention.
+type RetentionTarget struct {
+	ConversationID int64 `db:"id"`
+	RetentionDays  int   `db:"retention_days"`
+}
+
+// PurgeCounts maps conversation ids to the number of messages purged from them.
+type PurgeCounts map[int64]int64
+
+func (c PurgeCounts) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+func (c *PurgeCounts) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	}
+	return fmt.Errorf("unsupported purge counts type %T", src)
+}
+
+// RetentionRun is the audit entry of a purge run.
+type RetentionRun struct {
+	ID                int64              `db:"id" json:"id"`
+	Status            RetentionRunStatus `db:"status" json:"status"`
+	Archive           bool               `db:"archive" json:"archive"`
+	DefaultDays       int                `db:"default_days" json:"default_days"`
+	Conversations     int                `db:"conversations" json:"conversations"`
+	HeldConversations int                `db:"held_conversations" json:"held_conversations"`
+	PurgedMessages    int64              `db:"purged_messages" json:"purged_messages"`
+	Details           PurgeCounts        `db:"details" json:"details"`
+	Error             *string            `db:"error" json:"error"`
+	StartedAt         time.Time          `db:"started_at" json:"started_at"`
+	FinishedAt        *time.Time         `db:"finished_at" json:"finished_at"`
+}
@@ -0,0 +1,28 @@
+package chat
+
+import "time"
+
+type ScheduledMessageStatus string
+
+const (
+	ScheduledMessageStatusScheduled ScheduledMessageStatus = "scheduled"
+	ScheduledMessageStatusSending   ScheduledMessageStatus = "sending"
+	ScheduledMessageStatusSent      ScheduledMessageStatus = "sent"
+	ScheduledMessageStatusFailed    ScheduledMessageStatus = "failed"
+	ScheduledMessageStatusCancelled ScheduledMessageStatus = "cancelled"
+)
+
+// ScheduledMessage is sent by a background job at SendAt, it can be edited or cancelled until then.
+type ScheduledMessage struct {
+	ID             int64                  `db:"id" json:"id"`
+	ConversationID int64                  `db:"conversation_id" json:"conversation_id"`
+	SenderID       int64                  `db:"sender_id" json:"sender_id"`
+	Content        string                 `db:"content" json:"content"`
+	SendAt         time.Time              `db:"send_at" json:"send_at"`
+	Status         ScheduledMessageStatus `db:"status" json:"status"`
+	JobID          *int64                 `db:"job_id" json:"-"`
+	MessageID      *int64                 `db:"message_id" json:"message_id"`
+	LastError      *string                `db:"last_error" json:"last_error"`
+	CreatedAt      time.Time              `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time              `db:"updated_at" json:"updated_at"`
+}
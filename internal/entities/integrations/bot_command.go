@@ -0,0 +1,17 @@
+package integrations
+
+import "time"
+
+// BotCommand is a slash command of a conversation handled by an external service.
+// Invocations are signed like webhook deliveries, public responses are sent by a dedicated bot user.
+type BotCommand struct {
+	ID             int64     `db:"id" json:"id"`
+	ConversationID int64     `db:"conversation_id" json:"conversation_id"`
+	CreatedBy      int64     `db:"created_by" json:"created_by"`
+	BotUserID      int64     `db:"bot_user_id" json:"bot_user_id"`
+	Name           string    `db:"name" json:"name"`
+	Description    string    `db:"description" json:"description"`
+	URL            string    `db:"url" json:"url"`
+	Secret         string    `db:"secret" json:"-"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+}
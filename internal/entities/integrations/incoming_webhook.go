@@ -0,0 +1,16 @@
+package integrations
+
+import "time"
+
+// IncomingWebhook lets external systems post messages into a conversation through a secret URL.
+// Messages are sent by a dedicated bot user, only the hash of the URL token is stored.
+type IncomingWebhook struct {
+	ID                 int64     `db:"id" json:"id"`
+	ConversationID     int64     `db:"conversation_id" json:"conversation_id"`
+	CreatedBy          int64     `db:"created_by" json:"created_by"`
+	BotUserID          int64     `db:"bot_user_id" json:"bot_user_id"`
+	Name               string    `db:"name" json:"name"`
+	TokenHash          string    `db:"token_hash" json:"-"`
+	RateLimitPerMinute int       `db:"rate_limit_per_minute" json:"rate_limit_per_minute"`
+	CreatedAt          time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,61 @@
+package integrations
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "pending"
+	DeliveryStatusSucceeded DeliveryStatus = "succeeded"
+	DeliveryStatusDead      DeliveryStatus = "dead"
+)
+
+// Webhook is an outgoing subscription to events of a conversation.
+// The signing secret is stored encrypted and shown to the user only on creation.
+type Webhook struct {
+	ID             int64          `db:"id" json:"id"`
+	ConversationID int64          `db:"conversation_id" json:"conversation_id"`
+	CreatedBy      int64          `db:"created_by" json:"created_by"`
+	URL            string         `db:"url" json:"url"`
+	Secret         string         `db:"secret" json:"-"`
+	Events         pq.StringArray `db:"events" json:"events"`
+	IsActive       bool           `db:"is_active" json:"is_active"`
+	CreatedAt      time.Time      `db:"created_at" json:"created_at"`
+}
+
+func (w *Webhook) Subscribes(eventType string) bool {
+	return w.IsActive && slices.Contains(w.Events, eventType)
+}
+
+type WebhookDelivery struct {
+	ID             int64           `db:"id" json:"id"`
+	WebhookID      int64           `db:"webhook_id" json:"webhook_id"`
+	EventType      string          `db:"event_type" json:"event_type"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         DeliveryStatus  `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	ResponseStatus *int            `db:"response_status" json:"response_status"`
+	LastError      *string         `db:"last_error" json:"last_error"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at"`
+}
+
+// WebhookDeadLetter is a delivery that failed all attempts.
+type WebhookDeadLetter struct {
+	ID             int64           `db:"id" json:"id"`
+	DeliveryID     int64           `db:"delivery_id" json:"delivery_id"`
+	WebhookID      int64           `db:"webhook_id" json:"webhook_id"`
+	EventType      string          `db:"event_type" json:"event_type"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	ResponseStatus *int            `db:"response_status" json:"response_status"`
+	LastError      *string         `db:"last_error" json:"last_error"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Job is a unit of background work stored in Postgres, so it survives restarts and runs on a single replica.
+// Pending jobs with the same kind and unique key are deduplicated.
+type Job struct {
+	ID          int64           `db:"id" json:"id"`
+	Kind        string          `db:"kind" json:"kind"`
+	UniqueKey   *string         `db:"unique_key" json:"unique_key"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	Status      Status          `db:"status" json:"status"`
+	RunAt       time.Time       `db:"run_at" json:"run_at"`
+	Attempts    int             `db:"attempts" json:"attempts"`
+	MaxAttempts int             `db:"max_attempts" json:"max_attempts"`
+	LastError   *string         `db:"last_error" json:"last_error"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at" json:"updated_at"`
+	FinishedAt  *time.Time      `db:"finished_at" json:"finished_at"`
+}
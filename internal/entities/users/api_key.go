@@ -0,0 +1,58 @@
+package users
+
+import (
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ScopeProfileRead        = "profile:read"
+	ScopeUsersRead          = "users:read"
+	ScopeConversationsWrite = "conversations:write"
+	ScopeMessagesRead       = "messages:read"
+	ScopeMessagesWrite      = "messages:write"
+)
+
+var APIKeyScopes = []string{ScopeProfileRead, ScopeUsersRead, ScopeConversationsWrite, ScopeMessagesRead, ScopeMessagesWrite}
+
+// IsConversationScope reports whether the scope grants access to conversation content,
+// keys limited to conversations can use it only on routes of those conversations.
+func IsConversationScope(scope string) bool {
+	return scope == ScopeConversationsWrite || scope == ScopeMessagesRead || scope == ScopeMessagesWrite
+}
+
+// APIKey is a long-lived credential of a user or a bot.
+// Only the hash of the secret is stored, the prefix is used to find the key.
+type APIKey struct {
+	ID         int64          `db:"id" json:"id"`
+	UserID     int64          `db:"user_id" json:"user_id"`
+	CreatedBy  int64          `db:"created_by" json:"created_by"`
+	Name       string         `db:"name" json:"name"`
+	Prefix     string         `db:"prefix" json:"prefix"`
+	SecretHash string         `db:"secret_hash" json:"-"`
+	Scopes     pq.StringArray `db:"scopes" json:"scopes"`
+	// ConversationIDs limits the key to these conversations, empty means any conversation of the user
+	ConversationIDs pq.Int64Array `db:"conversation_ids" json:"conversation_ids"`
+	CreatedAt       time.Time     `db:"created_at" json:"created_at"`
+	ExpiresAt       *time.Time    `db:"expires_at" json:"expires_at"`
+	LastUsedAt      *time.Time    `db:"last_used_at" json:"last_used_at"`
+	RevokedAt       *time.Time    `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+func (k *APIKey) IsConversationLimited() bool {
+	return len(k.ConversationIDs) > 0
+}
+
+func (k *APIKey) AllowsConversation(cnvId int64) bool {
+	return !k.IsConversationLimited() || slices.Contains(k.ConversationIDs, cnvId)
+}
@@ -0,0 +1,12 @@
+package users
+
+import "time"
+
+// Block hides messages of the blocked user from the blocker and keeps the blocked user
+// from starting direct conversations with the blocker or adding them to groups.
+type Block struct {
+	BlockerID int64     `db:"blocker_id" json:"-"`
+	BlockedID int64     `db:"blocked_id" json:"user_id"`
+	Username  string    `db:"user_name" json:"user_name"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
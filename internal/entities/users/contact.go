@@ -0,0 +1,27 @@
+package users
+
+import "time"
+
+const (
+	ContactRequestPending  = "pending"
+	ContactRequestAccepted = "accepted"
+	ContactRequestDeclined = "declined"
+	ContactRequestCanceled = "canceled"
+)
+
+type ContactRequest struct {
+	ID          int64      `db:"id" json:"id"`
+	SenderID    int64      `db:"sender_id" json:"sender_id"`
+	RecipientID int64      `db:"recipient_id" json:"recipient_id"`
+	Status      string     `db:"status" json:"status"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	RespondedAt *time.Time `db:"responded_at" json:"responded_at"`
+}
+
+// Contact is a user who accepted a contact request of the user or whose request the user accepted.
+type Contact struct {
+	UserID    int64     `db:"user_id" json:"-"`
+	ContactID int64     `db:"contact_id" json:"user_id"`
+	Username  string    `db:"user_name" json:"user_name"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package users
+
+import "time"
+
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+	PlatformWeb     = "web"
+)
+
+var DevicePlatforms = []string{PlatformAndroid, PlatformIOS, PlatformWeb}
+
+const (
+	PushLevelAll = "all"
+	// PushLevelMentions pushes mentions and messages of direct conversations only
+	PushLevelMentions = "mentions"
+	PushLevelNone     = "none"
+)
+
+// Device receives push notifications of its user while they are not online.
+type Device struct {
+	ID         int64     `db:"id" json:"id"`
+	UserID     int64     `db:"user_id" json:"-"`
+	Platform   string    `db:"platform" json:"platform"`
+	PushToken  string    `db:"push_token" json:"-"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	LastSeenAt time.Time `db:"last_seen_at" json:"last_seen_at"`
+}
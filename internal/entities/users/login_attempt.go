@@ -0,0 +1,20 @@
+package users
+
+import "time"
+
+// LoginAttempt is an audit record of a sign in try, successful or not.
+type LoginAttempt struct {
+	ID        int64     `db:"id" json:"id"`
+	UserID    *int64    `db:"user_id" json:"user_id"`
+	Email     string    `db:"email" json:"email"`
+	IP        string    `db:"ip" json:"ip"`
+	UserAgent string    `db:"user_agent" json:"user_agent"`
+	Success   bool      `db:"success" json:"success"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// FailureStats describes recent failed attempts for an email or IP.
+type FailureStats struct {
+	Count       int        `db:"count"`
+	LastAttempt *time.Time `db:"last_attempt"`
+}
@@ -0,0 +1,29 @@
+package users
+
+import "time"
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+	DigestOff    = "off"
+)
+
+// InQuietHours reports whether the time falls into the user's quiet hours.
+// Quiet hours may span midnight, like 22 to 7. Users without a timezone are treated as UTC.
+func (u *User) InQuietHours(t time.Time) bool {
+	if u.QuietHoursStart == nil || u.QuietHoursEnd == nil {
+		return false
+	}
+	loc := time.UTC
+	if u.Timezone != "" {
+		if l, err := time.LoadLocation(u.Timezone); err == nil {
+			loc = l
+		}
+	}
+	h := t.In(loc).Hour()
+	start, end := *u.QuietHoursStart, *u.QuietHoursEnd
+	if start <= end {
+		return h >= start && h < end
+	}
+	return h >= start || h < end
+}
@@ -0,0 +1,22 @@
+package users
+
+import "time"
+
+// Session represents a signed in device of the user.
+// It holds the hash of the current refresh token, the token itself is never stored.
+type Session struct {
+	ID                int64      `db:"id" json:"id"`
+	UserID            int64      `db:"user_id" json:"user_id"`
+	RefreshTokenHash  string     `db:"refresh_token_hash" json:"-"`
+	PreviousTokenHash *string    `db:"previous_token_hash" json:"-"`
+	UserAgent         string     `db:"user_agent" json:"user_agent"`
+	IP                string     `db:"ip" json:"ip"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+	LastUsedAt        time.Time  `db:"last_used_at" json:"last_used_at"`
+	ExpiresAt         time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt         *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -2,6 +2,7 @@ package users
 
 import (
 	user_dto "chatapp/internal/dto/user"
+	"fmt"
 	"time"
 )
 
@@ -11,6 +12,111 @@ type User struct {
 	Email     string    `db:"email" json:"email"`
 	Password  string    `db:"password" json:"-"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
+
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at"`
+	TOTPEnabledAt   *time.Time `db:"totp_enabled_at" json:"totp_enabled_at"`
+	// TOTPSecret is encrypted, it is set on enrollment before TOTPEnabledAt
+	TOTPSecret   *string `db:"totp_secret" json:"-"`
+	TOTPLastStep int64   `db:"totp_last_step" json:"-"`
+
+	FailedLoginAttempts int        `db:"failed_login_attempts" json:"-"`
+	LockedUntil         *time.Time `db:"locked_until" json:"-"`
+
+	// IsBot users can't sign in, they act only through API keys created by the owner
+	IsBot   bool   `db:"is_bot" json:"is_bot"`
+	OwnerID *int64 `db:"owner_id" json:"owner_id,omitempty"`
+
+	// IsAdmin users administer the whole server, like legal holds and retention audits
+	IsAdmin bool `db:"is_admin" json:"-"`
+
+	// DeletedAt is set on accounts anonymized on their owner's request
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+
+	DisplayName *string `db:"display_name" json:"display_name"`
+	Bio         string  `db:"bio" json:"bio"`
+	Status      string  `db:"status_text" json:"status"`
+	// Timezone is an IANA name like Europe/Berlin, empty when not set
+	Timezone  string  `db:"timezone" json:"timezone"`
+	AvatarURL *string `db:"avatar_url" json:"avatar_url"`
+	// PendingEmail waits for confirmation from its inbox before it replaces Email
+	PendingEmail *string `db:"pending_email" json:"pending_email,omitempty"`
+
+	// Discoverability controls who can find the user and see their profile
+	Discoverability string `db:"discoverability" json:"discoverability"`
+	// DMPolicy controls who can start direct conversations with the user
+	DMPolicy string `db:"dm_policy" json:"dm_policy"`
+	// PushLevel controls which messages are pushed to devices of the user
+	PushLevel string `db:"push_level" json:"push_level"`
+	// DigestFrequency controls how often unread messages are emailed while the user is away
+	DigestFrequency string `db:"digest_frequency" json:"digest_frequency"`
+	// QuietHoursStart and QuietHoursEnd are hours in the user's timezone without notifications
+	QuietHoursStart *int `db:"quiet_hours_start" json:"quiet_hours_start"`
+	QuietHoursEnd   *int `db:"quiet_hours_end" json:"quiet_hours_end"`
+}
+
+// PublicUser is the profile of a user as other users see it.
+type PublicUser struct {
+	ID          int64   `json:"id"`
+	Username    string  `json:"user_name"`
+	DisplayName *string `json:"display_name"`
+	Bio         string  `json:"bio"`
+	Status      string  `json:"status"`
+	Timezone    string  `json:"timezone"`
+	AvatarURL   *string `json:"avatar_url"`
+	IsBot       bool    `json:"is_bot"`
+	IsDeleted   bool    `json:"is_deleted,omitempty"`
+}
+
+func (u *User) Public() *PublicUser {
+	return &PublicUser{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		Status:      u.Status,
+		Timezone:    u.Timezone,
+		AvatarURL:   u.AvatarURL,
+		IsBot:       u.IsBot,
+		IsDeleted:   u.IsDeleted(),
+	}
+}
+
+const (
+	// DeletedUserName replaces the name of deleted accounts
+	DeletedUserName = "Deleted user"
+	// DeletedUserEmail is the placeholder account messages of deleted accounts are moved to
+	DeletedUserEmail = "deleted-user@deleted.invalid"
+)
+
+const (
+	// DiscoverableEveryone users are listed in the user directory
+	DiscoverableEveryone = "everyone"
+	// DiscoverableConversations users are hidden from the directory, only people sharing
+	// a conversation with them can see their profile
+	DiscoverableConversations = "conversations"
+)
+
+const (
+	DMPolicyEveryone = "everyone"
+	// DMPolicyContacts lets only contacts start direct conversations with the user
+	DMPolicyContacts = "contacts"
+)
+
+// AnonymizedEmail frees the email of an anonymized account while keeping the column unique.
+func AnonymizedEmail(id int64) string {
+	return fmt.Sprintf("deleted-%d@deleted.invalid", id)
+}
+
+// DeletedIntegrations counts what was removed together with an account, it is logged as the deletion audit.
+type DeletedIntegrations struct {
+	Webhooks         int64 `json:"webhooks"`
+	IncomingWebhooks int64 `json:"incoming_webhooks"`
+	Commands         int64 `json:"commands"`
+	Bots             int64 `json:"bots"`
+}
+
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
 }
 
 func FromCreateDto(dto user_dto.CreateUserDTO) *User {
@@ -20,3 +126,15 @@ func FromCreateDto(dto user_dto.CreateUserDTO) *User {
 		Password: dto.Password,
 	}
 }
+
+func (u *User) IsDeleted() bool {
+	return u.DeletedAt != nil
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) IsTOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil && u.TOTPSecret != nil
+}
@@ -1,13 +1,16 @@
 package eventlisteners
 
-import "chatapp/internal/services/chat"
+import (
+	"chatapp/internal/logger"
+	"chatapp/internal/services/chat"
+)
 
 type EventListeners struct {
 	ChatEventListener *chat.EventListener
 }
 
-func NewEventListeners() *EventListeners {
+func NewEventListeners(logger logger.Logger) *EventListeners {
 	return &EventListeners{
-		ChatEventListener: chat.NewEventListener(),
+		ChatEventListener: chat.NewEventListener(logger),
 	}
 }
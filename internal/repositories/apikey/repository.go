@@ -0,0 +1,81 @@
+package apikey
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/users"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, k *users.APIKey) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (user_id, created_by, name, prefix, secret_hash, scopes, conversation_ids, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), $8)
+	RETURNING id, created_at`, constants.APIKeyTable)
+
+	return r.db.QueryRowContext(ctx, query, k.UserID, k.CreatedBy, k.Name, k.Prefix, k.SecretHash, k.Scopes, k.ConversationIDs, k.ExpiresAt).
+		Scan(&k.ID, &k.CreatedAt)
+}
+
+func (r *Repository) GetKeyByPrefix(ctx context.Context, prefix string) (*users.APIKey, error) {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE prefix = $1 LIMIT 1`, constants.APIKeyTable)
+
+	k := &users.APIKey{}
+	if err := r.db.GetContext(ctx, k, query, prefix); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return k, nil
+}
+
+// GetKeysCreatedBy returns keys of the user and of the user's bots.
+func (r *Repository) GetKeysCreatedBy(ctx context.Context, usrId int64) ([]*users.APIKey, error) {
+	var res []*users.APIKey
+	query := fmt.Sprintf(`
+	SELECT * FROM %s
+	WHERE created_by = $1 AND revoked_at IS NULL
+	ORDER BY id DESC`, constants.APIKeyTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, usrId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) Revoke(ctx context.Context, createdBy, id int64) (bool, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET revoked_at = NOW()
+	WHERE id = $1 AND created_by = $2 AND revoked_at IS NULL`, constants.APIKeyTable)
+
+	res, err := r.db.ExecContext(ctx, query, id, createdBy)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *Repository) TouchLastUsed(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET last_used_at = NOW() WHERE id = $1`, constants.APIKeyTable)
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
@@ -0,0 +1,98 @@
+package block
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/users"
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// Block is idempotent, blocking a user twice keeps the first block.
+func (r *Repository) Block(ctx context.Context, blockerId, blockedId int64) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (blocker_id, blocked_id, created_at)
+	VALUES ($1, $2, NOW())
+	ON CONFLICT (blocker_id, blocked_id) DO NOTHING`, constants.UserBlockTable)
+
+	_, err := r.db.ExecContext(ctx, query, blockerId, blockedId)
+	return err
+}
+
+func (r *Repository) Unblock(ctx context.Context, blockerId, blockedId int64) (bool, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE blocker_id = $1 AND blocked_id = $2`, constants.UserBlockTable)
+
+	res, err := r.db.ExecContext(ctx, query, blockerId, blockedId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetBlocks returns the users blocked by the user, most recently blocked first.
+func (r *Repository) GetBlocks(ctx context.Context, blockerId int64) ([]*users.Block, error) {
+	var res []*users.Block
+	query := fmt.Sprintf(`
+	SELECT b.*, u.user_name FROM %s b
+	JOIN %s u ON u.id = b.blocked_id
+	WHERE b.blocker_id = $1
+	ORDER BY b.created_at DESC`, constants.UserBlockTable, constants.UserTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, blockerId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) GetBlockedIds(ctx context.Context, blockerId int64) ([]int64, error) {
+	var res []int64
+	query := fmt.Sprintf(`SELECT blocked_id FROM %s WHERE blocker_id = $1`, constants.UserBlockTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, blockerId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetBlockersOf returns which of the users have blocked the blocked user.
+func (r *Repository) GetBlockersOf(ctx context.Context, blockedId int64, usrIds []int64) ([]int64, error) {
+	var res []int64
+	query := fmt.Sprintf(`
+	SELECT blocker_id FROM %s
+	WHERE blocked_id = $1 AND blocker_id = ANY($2)`, constants.UserBlockTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, blockedId, pq.Array(usrIds)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// IsBlockedEither reports whether either user has blocked the other.
+func (r *Repository) IsBlockedEither(ctx context.Context, usrId, otherId int64) (bool, error) {
+	var exists bool
+	query := fmt.Sprintf(`
+	SELECT EXISTS(
+	SELECT 1 FROM %s
+	WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1))`, constants.UserBlockTable)
+
+	if err := r.db.GetContext(ctx, &exists, query, usrId, otherId); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
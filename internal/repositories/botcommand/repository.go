@@ -0,0 +1,68 @@
+package botcommand
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/integrations"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, tx *sqlx.Tx, c *integrations.BotCommand) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (conversation_id, created_by, bot_user_id, name, description, url, secret, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+	RETURNING id, created_at`, constants.BotCommandTable)
+
+	return tx.QueryRowContext(ctx, query, c.ConversationID, c.CreatedBy, c.BotUserID, c.Name, c.Description, c.URL, c.Secret).
+		Scan(&c.ID, &c.CreatedAt)
+}
+
+func (r *Repository) GetCommand(ctx context.Context, cnvId int64, name string) (*integrations.BotCommand, error) {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE conversation_id = $1 AND name = $2 LIMIT 1`, constants.BotCommandTable)
+
+	c := &integrations.BotCommand{}
+	if err := r.db.GetContext(ctx, c, query, cnvId, name); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *Repository) GetConversationCommands(ctx context.Context, cnvId int64) ([]*integrations.BotCommand, error) {
+	var res []*integrations.BotCommand
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE conversation_id = $1 ORDER BY name`, constants.BotCommandTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, cnvId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) Delete(ctx context.Context, cnvId, id int64) (bool, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1 AND conversation_id = $2`, constants.BotCommandTable)
+
+	res, err := r.db.ExecContext(ctx, query, id, cnvId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"chatapp/internal/constants"
 	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type Repository struct {
@@ -30,15 +33,29 @@ func (r *Repository) Create(ctx context.Context, tx *sqlx.Tx, cnv *chatEnts.Conv
 	return tx.GetContext(ctx, &cnv.ID, query, cnv.Name, cnv.IsGroup)
 }
 
-func (r *Repository) AddParticipant(ctx context.Context, tx *sqlx.Tx, cnvId, usrId int64) error {
+func (r *Repository) AddParticipant(ctx context.Context, tx *sqlx.Tx, cnvId, usrId int64, role string) error {
 	query := fmt.Sprintf(`
-	INSERT INTO %s (conversation_id, user_id, joined_at)
-	VALUES ($1, $2, NOW())`, constants.ConversationParticipantTable)
+	INSERT INTO %s (conversation_id, user_id, joined_at, role)
+	VALUES ($1, $2, NOW(), $3)`, constants.ConversationParticipantTable)
 
-	_, err := tx.ExecContext(ctx, query, cnvId, usrId)
+	_, err := tx.ExecContext(ctx, query, cnvId, usrId, role)
 	return err
 }
 
+func (r *Repository) GetParticipant(ctx context.Context, cnvId, usrId int64) (*chatEnts.ConversationParticipant, error) {
+	p := &chatEnts.ConversationParticipant{}
+	query := fmt.Sprintf(`
+	SELECT * FROM %s WHERE conversation_id = $1 AND user_id = $2 LIMIT 1`, constants.ConversationParticipantTable)
+
+	if err := r.db.GetContext(ctx, p, query, cnvId, usrId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
 func (r *Repository) IsParticipant(ctx context.Context, cnvId, usrId int64) (bool, error) {
 	var exists bool
 	query := fmt.Sprintf(`
@@ -50,6 +67,21 @@ func (r *Repository) IsParticipant(ctx context.Context, cnvId, usrId int64) (boo
 	}
 	return exists, nil
 }
+
+// SharesConversation reports whether both users participate in at least one common conversation.
+func (r *Repository) SharesConversation(ctx context.Context, usrId, otherId int64) (bool, error) {
+	var exists bool
+	query := fmt.Sprintf(`
+	SELECT EXISTS(
+	SELECT 1 FROM %[1]s a JOIN %[1]s b ON b.conversation_id = a.conversation_id
+	WHERE a.user_id = $1 AND b.user_id = $2)`, constants.ConversationParticipantTable)
+
+	if err := r.db.GetContext(ctx, &exists, query, usrId, otherId); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
 func (r *Repository) IsConversationExists(ctx context.Context, cnvId int64) (bool, error) {
 	var exists bool
 	query := fmt.Sprintf(`
@@ -78,8 +110,130 @@ func (r *Repository) GetConversationById(ctx context.Context, cnvId int64) (*cha
 func (r *Repository) GetParticipants(ctx context.Context, cnvId int64) ([]*chatEnts.ConversationParticipant, error) {
 	var pts []*chatEnts.ConversationParticipant
 	query := fmt.Sprintf(`
-	SELECT FROM %s WHERE conversation_id = $1`, constants.ConversationParticipantTable)
+	SELECT * FROM %s WHERE conversation_id = $1`, constants.ConversationParticipantTable)
 
 	err := r.db.SelectContext(ctx, &pts, query, cnvId)
 	return pts, err
 }
+
+// GetParticipantProfiles returns participants with their usernames in the order they joined.
+func (r *Repository) GetParticipantProfiles(ctx context.Context, cnvId int64) ([]*chatEnts.ParticipantProfile, error) {
+	var res []*chatEnts.ParticipantProfile
+	query := fmt.Sprintf(`
+	SELECT p.*, u.user_name FROM %s p
+	JOIN %s u ON u.id = p.user_id
+	WHERE p.conversation_id = $1
+	ORDER BY p.joined_at, p.user_id`, constants.ConversationParticipantTable, constants.UserTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, cnvId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetMemberships returns the conversations the user participates in.
+func (r *Repository) GetMemberships(ctx context.Context, usrId int64) ([]*chatEnts.Membership, error) {
+	var res []*chatEnts.Membership
+	query := fmt.Sprintf(`
+	SELECT c.*, p.role, p.joined_at FROM %s p
+	JOIN %s c ON c.id = p.conversation_id
+	WHERE p.user_id = $1
+	ORDER BY p.joined_at, c.id`, constants.ConversationParticipantTable, constants.ConversationTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, usrId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) UpdateTopic(ctx context.Context, cnv *chatEnts.Conversation, topic string) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET topic = $1, updated_at = NOW()
+	WHERE id = $2
+	RETURNING topic, updated_at`, constants.ConversationTable)
+
+	return r.db.QueryRowContext(ctx, query, topic, cnv.ID).Scan(&cnv.Topic, &cnv.UpdatedAt)
+}
+
+// SetMessageTTL sets the lifetime of new messages in seconds, nil keeps messages forever.
+func (r *Repository) SetMessageTTL(ctx context.Context, cnv *chatEnts.Conversation, ttl *int) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET message_ttl = $1, updated_at = NOW()
+	WHERE id = $2
+	RETURNING message_ttl, updated_at`, constants.ConversationTable)
+
+	return r.db.QueryRowContext(ctx, query, ttl, cnv.ID).Scan(&cnv.MessageTTL, &cnv.UpdatedAt)
+}
+
+// SetRetention overrides the default retention in days, nil restores the default and 0 keeps messages forever.
+func (r *Repository) SetRetention(ctx context.Context, cnv *chatEnts.Conversation, days *int) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET retention_days = $1, updated_at = NOW()
+	WHERE id = $2
+	RETURNING retention_days, updated_at`, constants.ConversationTable)
+
+	return r.db.QueryRowContext(ctx, query, days, cnv.ID).Scan(&cnv.RetentionDays, &cnv.UpdatedAt)
+}
+
+func (r *Repository) SetLegalHold(ctx context.Context, cnv *chatEnts.Conversation, hold bool) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET legal_hold = $1, updated_at = NOW()
+	WHERE id = $2
+	RETURNING legal_hold, updated_at`, constants.ConversationTable)
+
+	return r.db.QueryRowContext(ctx, query, hold, cnv.ID).Scan(&cnv.LegalHold, &cnv.UpdatedAt)
+}
+
+// SetMuted mutes notifications of the conversation for the participant, until nil means until unmuted.
+func (r *Repository) SetMuted(ctx context.Context, cnvId, usrId int64, muted bool, until *time.Time) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET muted = $1, muted_until = $2
+	WHERE conversation_id = $3 AND user_id = $4`, constants.ConversationParticipantTable)
+
+	_, err := r.db.ExecContext(ctx, query, muted, until, cnvId, usrId)
+	return err
+}
+
+// MarkSeen records that the participant has the conversation open, earlier messages are not digested.
+func (r *Repository) MarkSeen(ctx context.Context, cnvId, usrId int64) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET last_seen_at = NOW()
+	WHERE conversation_id = $1 AND user_id = $2`, constants.ConversationParticipantTable)
+
+	_, err := r.db.ExecContext(ctx, query, cnvId, usrId)
+	return err
+}
+
+// CountUnreadMessages returns the number of messages the user didn't see in all conversations, counted like
+// digests: messages since the user last had the conversation open, except muted conversations and blocked senders.
+func (r *Repository) CountUnreadMessages(ctx context.Context, usrId int64) (int, error) {
+	var n int
+	query := fmt.Sprintf(`
+	SELECT COUNT(*) FROM %s m
+	JOIN %s p ON p.conversation_id = m.conversation_id AND p.user_id = $1
+	WHERE m.sender_id <> $1 AND m.created_at > p.last_seen_at
+		AND (m.expires_at IS NULL OR m.expires_at > NOW())
+		AND NOT (p.muted AND (p.muted_until IS NULL OR p.muted_until > NOW()))
+		AND m.sender_id NOT IN (SELECT blocked_id FROM %s WHERE blocker_id = $1)`,
+		constants.MessageTable, constants.ConversationParticipantTable, constants.UserBlockTable)
+
+	if err := r.db.GetContext(ctx, &n, query, usrId); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// GetParticipantsByUsernames returns participants having one of the names, several participants can share a name.
+func (r *Repository) GetParticipantsByUsernames(ctx context.Context, cnvId int64, names []string) ([]*users.User, error) {
+	var res []*users.User
+	query := fmt.Sprintf(`
+	SELECT u.* FROM %s u
+	JOIN %s p ON p.user_id = u.id
+	WHERE p.conversation_id = $1 AND u.user_name = ANY($2)
+	ORDER BY u.id`, constants.UserTable, constants.ConversationParticipantTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, cnvId, pq.Array(names)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
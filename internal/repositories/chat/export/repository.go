@@ -0,0 +1,108 @@
+package export
+
+import (
+	"chatapp/internal/constants"
+	chatEnts "chatapp/internal/entities/chat"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, e *chatEnts.Export) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (conversation_id, requested_by, format, status, created_at)
+	VALUES ($1, $2, $3, $4, NOW())
+	RETURNING id, created_at`, constants.ExportTable)
+
+	return r.db.QueryRowContext(ctx, query, e.ConversationID, e.RequestedBy, e.Format, e.Status).Scan(&e.ID, &e.CreatedAt)
+}
+
+func (r *Repository) GetExport(ctx context.Context, cnvId, id int64) (*chatEnts.Export, error) {
+	e := &chatEnts.Export{}
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1 AND conversation_id = $2`, constants.ExportTable)
+
+	if err := r.db.GetContext(ctx, e, query, id, cnvId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// Start moves a pending export to running, nil is returned when another job already took it.
+func (r *Repository) Start(ctx context.Context, id int64) (*chatEnts.Export, error) {
+	e := &chatEnts.Export{}
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1
+	WHERE id = $2 AND status = $3
+	RETURNING *`, constants.ExportTable)
+
+	if err := r.db.GetContext(ctx, e, query, chatEnts.ExportStatusRunning, id, chatEnts.ExportStatusPending); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// Release returns a running export to pending, so a retried job can start it again.
+func (r *Repository) Release(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1 WHERE id = $2 AND status = $3`, constants.ExportTable)
+
+	_, err := r.db.ExecContext(ctx, query, chatEnts.ExportStatusPending, id, chatEnts.ExportStatusRunning)
+	return err
+}
+
+// MarkReady finishes the export, it expires ttl after NOW() so the cleanup compares it in the same time zone.
+func (r *Repository) MarkReady(ctx context.Context, e *chatEnts.Export, ttl time.Duration) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, file_path = $2, size = $3, finished_at = NOW(), expires_at = NOW() + make_interval(secs => $4)
+	WHERE id = $5
+	RETURNING status, finished_at, expires_at`, constants.ExportTable)
+
+	return r.db.QueryRowContext(ctx, query, chatEnts.ExportStatusReady, e.FilePath, e.Size, ttl.Seconds(), e.ID).
+		Scan(&e.Status, &e.FinishedAt, &e.ExpiresAt)
+}
+
+func (r *Repository) MarkFailed(ctx context.Context, e *chatEnts.Export, lastError string) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, error = $2, finished_at = NOW()
+	WHERE id = $3
+	RETURNING status, error, finished_at`, constants.ExportTable)
+
+	return r.db.QueryRowContext(ctx, query, chatEnts.ExportStatusFailed, lastError, e.ID).
+		Scan(&e.Status, &e.Error, &e.FinishedAt)
+}
+
+// DeleteExpired deletes up to limit exports past their expiry and returns them, so their files can be removed.
+func (r *Repository) DeleteExpired(ctx context.Context, limit int) ([]*chatEnts.Export, error) {
+	var res []*chatEnts.Export
+	query := fmt.Sprintf(`
+	DELETE FROM %[1]s WHERE id IN (
+		SELECT id FROM %[1]s
+		WHERE expires_at IS NOT NULL AND expires_at <= NOW()
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING *`, constants.ExportTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, limit); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
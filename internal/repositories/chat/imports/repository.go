@@ -0,0 +1,147 @@
+package imports
+
+import (
+	"chatapp/internal/constants"
+	chatEnts "chatapp/internal/entities/chat"
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// timestampLayout formats timestamps for the TIMESTAMP array casts, they are stored in UTC like NOW()
+const timestampLayout = "2006-01-02 15:04:05.999999"
+
+// Repository writes imported data. Every insert skips rows that already exist, so imports can be re-run.
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// UpsertConversation creates the conversation with the external id or loads the existing one, created reports which happened.
+func (r *Repository) UpsertConversation(ctx context.Context, cnv *chatEnts.Conversation) (bool, error) {
+	var created bool
+	query := fmt.Sprintf(`
+	INSERT INTO %s (name, is_group, topic, external_id, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $5)
+	ON CONFLICT (external_id) WHERE external_id IS NOT NULL
+	DO UPDATE SET external_id = EXCLUDED.external_id
+	RETURNING id, (xmax = 0)`, constants.ConversationTable)
+
+	err := r.db.QueryRowContext(ctx, query, cnv.Name, cnv.IsGroup, cnv.Topic, cnv.ExternalID, cnv.CreatedAt.UTC()).Scan(&cnv.ID, &created)
+	return created, err
+}
+
+func (r *Repository) AddParticipants(ctx context.Context, cnvId int64, participants []*chatEnts.ConversationParticipant) (int64, error) {
+	if len(participants) == 0 {
+		return 0, nil
+	}
+	usrIds := make([]int64, len(participants))
+	roles := make([]string, len(participants))
+	for i, p := range participants {
+		usrIds[i] = p.UserID
+		roles[i] = p.Role
+	}
+
+	query := fmt.Sprintf(`
+	INSERT INTO %s (conversation_id, user_id, joined_at, role)
+	SELECT $1, t.user_id, NOW(), t.role FROM unnest($2::bigint[], $3::text[]) AS t(user_id, role)
+	ON CONFLICT (conversation_id, user_id) DO NOTHING`, constants.ConversationParticipantTable)
+
+	res, err := r.db.ExecContext(ctx, query, cnvId, pq.Array(usrIds), pq.Array(roles))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// InsertMessages bulk inserts messages with their original timestamps, messages must have an external id.
+func (r *Repository) InsertMessages(ctx context.Context, cnvId int64, msgs []*chatEnts.Message) (int64, error) {
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+	senders := make([]int64, len(msgs))
+	contents := make([]string, len(msgs))
+	extIds := make([]string, len(msgs))
+	createdAt := make([]string, len(msgs))
+	updatedAt := make([]string, len(msgs))
+	for i, m := range msgs {
+		senders[i] = m.SenderID
+		contents[i] = m.Content
+		extIds[i] = *m.ExternalID
+		createdAt[i] = m.CreatedAt.UTC().Format(timestampLayout)
+		updatedAt[i] = m.UpdatedAt.UTC().Format(timestampLayout)
+	}
+
+	query := fmt.Sprintf(`
+	INSERT INTO %s (conversation_id, sender_id, content, external_id, created_at, updated_at)
+	SELECT $1, t.sender_id, t.content, t.external_id, t.created_at, t.updated_at
+	FROM unnest($2::bigint[], $3::text[], $4::text[], $5::timestamp[], $6::timestamp[])
+		AS t(sender_id, content, external_id, created_at, updated_at)
+	ON CONFLICT (conversation_id, external_id) WHERE external_id IS NOT NULL DO NOTHING`, constants.MessageTable)
+
+	res, err := r.db.ExecContext(ctx, query, cnvId, pq.Array(senders), pq.Array(contents), pq.Array(extIds), pq.Array(createdAt), pq.Array(updatedAt))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// LinkThreads sets the parent of imported replies, links whose messages are missing are skipped.
+func (r *Repository) LinkThreads(ctx context.Context, cnvId int64, links []*chatEnts.ImportedThreadLink) (int64, error) {
+	if len(links) == 0 {
+		return 0, nil
+	}
+	children := make([]string, len(links))
+	parents := make([]string, len(links))
+	for i, l := range links {
+		children[i] = l.MessageExternalID
+		parents[i] = l.ParentExternalID
+	}
+
+	query := fmt.Sprintf(`
+	UPDATE %[1]s m SET parent_id = p.id
+	FROM unnest($2::text[], $3::text[]) AS t(child, parent)
+	JOIN %[1]s p ON p.conversation_id = $1 AND p.external_id = t.parent
+	WHERE m.conversation_id = $1 AND m.external_id = t.child AND m.parent_id IS DISTINCT FROM p.id`, constants.MessageTable)
+
+	res, err := r.db.ExecContext(ctx, query, cnvId, pq.Array(children), pq.Array(parents))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// InsertReactions adds reactions to imported messages, dated like the message they react to.
+func (r *Repository) InsertReactions(ctx context.Context, cnvId int64, reactions []*chatEnts.ImportedReaction) (int64, error) {
+	if len(reactions) == 0 {
+		return 0, nil
+	}
+	extIds := make([]string, len(reactions))
+	usrIds := make([]int64, len(reactions))
+	emojis := make([]string, len(reactions))
+	for i, re := range reactions {
+		extIds[i] = re.MessageExternalID
+		usrIds[i] = re.UserID
+		emojis[i] = re.Emoji
+	}
+
+	query := fmt.Sprintf(`
+	INSERT INTO %s (message_id, user_id, emoji, created_at)
+	SELECT m.id, t.user_id, t.emoji, m.created_at
+	FROM unnest($2::text[], $3::bigint[], $4::text[]) AS t(external_id, user_id, emoji)
+	JOIN %s m ON m.conversation_id = $1 AND m.external_id = t.external_id
+	ON CONFLICT (message_id, user_id, emoji) DO NOTHING`, constants.MessageReactionTable, constants.MessageTable)
+
+	res, err := r.db.ExecContext(ctx, query, cnvId, pq.Array(extIds), pq.Array(usrIds), pq.Array(emojis))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
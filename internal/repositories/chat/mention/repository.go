@@ -0,0 +1,96 @@
+package mention
+
+import (
+	"chatapp/internal/constants"
+	chatEnts "chatapp/internal/entities/chat"
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// Replace sets the users mentioned in the message, users still mentioned after an edit keep their read state.
+func (r *Repository) Replace(ctx context.Context, msg *chatEnts.Message, usrIds []int64) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if len(usrIds) == 0 {
+		// a nil array binds NULL and ANY(NULL) matches nothing, so drop the rows of the message explicitly
+		deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE message_id = $1`, constants.MessageMentionTable)
+		_, err = tx.ExecContext(ctx, deleteQuery, msg.ID)
+		return err
+	}
+	deleteQuery := fmt.Sprintf(`
+	DELETE FROM %s WHERE message_id = $1 AND NOT (user_id = ANY($2))`, constants.MessageMentionTable)
+	if _, err = tx.ExecContext(ctx, deleteQuery, msg.ID, pq.Array(usrIds)); err != nil {
+		return err
+	}
+	insertQuery := fmt.Sprintf(`
+	INSERT INTO %s (message_id, conversation_id, user_id, created_at)
+	SELECT $1, $2, unnest($3::BIGINT[]), NOW()
+	ON CONFLICT (message_id, user_id) DO NOTHING`, constants.MessageMentionTable)
+	_, err = tx.ExecContext(ctx, insertQuery, msg.ID, msg.ConversationID, pq.Array(usrIds))
+	return err
+}
+
+// GetUnreadMessages returns messages with unread mentions of the user, newest first.
+func (r *Repository) GetUnreadMessages(ctx context.Context, usrId int64, cnvId, lastId *int64, limit int) ([]*chatEnts.Message, error) {
+	var messages []*chatEnts.Message
+	query := fmt.Sprintf(`
+	SELECT m.* FROM %s m
+	JOIN %s mm ON mm.message_id = m.id
+	WHERE mm.user_id = $1 AND mm.read_at IS NULL
+	AND ($2::BIGINT IS NULL OR mm.conversation_id = $2)
+	AND ($3::BIGINT IS NULL OR m.id < $3)
+	AND (m.expires_at IS NULL OR m.expires_at > NOW())
+	ORDER BY m.id DESC
+	LIMIT $4`, constants.MessageTable, constants.MessageMentionTable)
+
+	if err := r.db.SelectContext(ctx, &messages, query, usrId, cnvId, lastId, limit); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *Repository) GetUnreadCounts(ctx context.Context, usrId int64) ([]*chatEnts.MentionCount, error) {
+	var res []*chatEnts.MentionCount
+	query := fmt.Sprintf(`
+	SELECT conversation_id, COUNT(*) AS count FROM %s
+	WHERE user_id = $1 AND read_at IS NULL
+	GROUP BY conversation_id
+	ORDER BY conversation_id`, constants.MessageMentionTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, usrId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) MarkRead(ctx context.Context, usrId, cnvId int64) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET read_at = NOW()
+	WHERE user_id = $1 AND conversation_id = $2 AND read_at IS NULL`, constants.MessageMentionTable)
+
+	_, err := r.db.ExecContext(ctx, query, usrId, cnvId)
+	return err
+}
@@ -0,0 +1,131 @@
+package mention
+
+import (
+	"chatapp/internal/constants"
+	chatEnts "chatapp/internal/entities/chat"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// newTestDB connects to TEST_DATABASE_URL and creates the mentions table in a throwaway schema.
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	admin, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	schema := fmt.Sprintf("test_mentions_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	db, err := sqlx.Connect("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// the message_mentions table of migrations/000016_create_message_mentions_table.up.sql without its foreign keys
+	db.MustExec(fmt.Sprintf(`
+	CREATE TABLE %s (
+		message_id BIGINT NOT NULL,
+		conversation_id BIGINT NOT NULL,
+		user_id BIGINT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		read_at TIMESTAMP,
+		PRIMARY KEY (message_id, user_id)
+	)`, constants.MessageMentionTable))
+	return db
+}
+
+func mentioned(t *testing.T, db *sqlx.DB, msgId int64) []int64 {
+	t.Helper()
+	var ids []int64
+	query := fmt.Sprintf(`SELECT user_id FROM %s WHERE message_id = $1 ORDER BY user_id`, constants.MessageMentionTable)
+	if err := db.Select(&ids, query, msgId); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestReplaceKeepsReadStateOfRemainingMentions(t *testing.T) {
+	db := newTestDB(t)
+	r := NewRepository(db)
+	ctx := context.Background()
+	msg := &chatEnts.Message{ID: 1, ConversationID: 10}
+
+	if err := r.Replace(ctx, msg, []int64{2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.MarkRead(ctx, 2, msg.ConversationID); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Replace(ctx, msg, []int64{2, 4}); err != nil {
+		t.Fatal(err)
+	}
+	if got := mentioned(t, db, msg.ID); !slices.Equal(got, []int64{2, 4}) {
+		t.Fatalf("mentioned = %v, want [2 4]", got)
+	}
+	counts, err := r.GetUnreadCounts(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 0 {
+		t.Fatalf("user 2 still mentioned, read state must be kept: %+v", counts)
+	}
+}
+
+func TestReplaceRemovesMentionsEditedOut(t *testing.T) {
+	db := newTestDB(t)
+	r := NewRepository(db)
+	ctx := context.Background()
+	msg := &chatEnts.Message{ID: 1, ConversationID: 10}
+	other := &chatEnts.Message{ID: 2, ConversationID: 10}
+
+	if err := r.Replace(ctx, msg, []int64{2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Replace(ctx, other, []int64{2}); err != nil {
+		t.Fatal(err)
+	}
+	// an edit without mention tokens resolves to nil users
+	if err := r.Replace(ctx, msg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := mentioned(t, db, msg.ID); len(got) != 0 {
+		t.Fatalf("mentions left after editing them out: %v", got)
+	}
+	if got := mentioned(t, db, other.ID); !slices.Equal(got, []int64{2}) {
+		t.Fatalf("mentions of another message changed: %v", got)
+	}
+	counts, err := r.GetUnreadCounts(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 0 {
+		t.Fatalf("unread counts left for a user edited out: %+v", counts)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -21,20 +22,27 @@ func NewRepository(db *sqlx.DB) *Repository {
 	}
 }
 
-func (r *Repository) Create(ctx context.Context, message *chatEnts.Message) error {
+// Create inserts the message, a message with a ttl expires that long after NOW() so the expiry is in the database time zone.
+func (r *Repository) Create(ctx context.Context, message *chatEnts.Message, ttl *time.Duration) error {
+	var ttlSecs *float64
+	if ttl != nil {
+		secs := ttl.Seconds()
+		ttlSecs = &secs
+	}
 	query := fmt.Sprintf(`
-	INSERT INTO %s (conversation_id, sender_id, content, created_at)
-	VALUES ($1, $2, $3, NOW()) RETURNING id`, constants.MessageTable)
-	return r.db.GetContext(ctx, &message.ID, query, message.ConversationID, message.SenderID, message.Content)
+	INSERT INTO %s (conversation_id, sender_id, content, display_name, avatar_url, mentions, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, NOW() + make_interval(secs => $7), NOW()) RETURNING id, expires_at, created_at, updated_at`, constants.MessageTable)
+	return r.db.QueryRowContext(ctx, query, message.ConversationID, message.SenderID, message.Content, message.DisplayName, message.AvatarURL, message.Mentions, ttlSecs).
+		Scan(&message.ID, &message.ExpiresAt, &message.CreatedAt, &message.UpdatedAt)
 }
 
-func (r *Repository) Update(ctx context.Context, msg *chatEnts.Message, content string) error {
+func (r *Repository) Update(ctx context.Context, msg *chatEnts.Message, content string, mentions chatEnts.Mentions) error {
 	query := fmt.Sprintf(`
-	UPDATE %s SET content = $1, updated_at = NOW()
-	WHERE id = $2
-	RETURNING id, updated_at, content;`, constants.MessageTable)
+	UPDATE %s SET content = $1, mentions = $2, updated_at = NOW()
+	WHERE id = $3
+	RETURNING id, updated_at, content, mentions;`, constants.MessageTable)
 
-	if err := r.db.QueryRowContext(ctx, query, content, msg.ID).Scan(&msg.ID, &msg.UpdatedAt, &msg.Content); err != nil {
+	if err := r.db.QueryRowContext(ctx, query, content, mentions, msg.ID).Scan(&msg.ID, &msg.UpdatedAt, &msg.Content, &msg.Mentions); err != nil {
 		return err
 	}
 	return nil
@@ -43,7 +51,7 @@ func (r *Repository) Update(ctx context.Context, msg *chatEnts.Message, content
 func (r *Repository) GetMessages(ctx context.Context, qParams *messages_dto.GetMessageQueryParams) ([]*chatEnts.Message, error) {
 	var messages []*chatEnts.Message
 	query := r.getMessagesQueryString(qParams)
-	args := []interface{}{qParams.ConvId, qParams.Limit}
+	args := []interface{}{qParams.ConvId, qParams.Limit, qParams.UserId}
 	if qParams.LastReceivedId != nil {
 		args = append(args, qParams.LastReceivedId)
 	}
@@ -60,6 +68,7 @@ func (r *Repository) GetMessageById(ctx context.Context, convId, msgId int64) (*
 	query := fmt.Sprintf(`
 	SELECT * FROM %s
 	WHERE id = $1 AND conversation_id = $2
+	AND (expires_at IS NULL OR expires_at > NOW())
 	LIMIT 1`, constants.MessageTable)
 	message := &chatEnts.Message{}
 
@@ -72,10 +81,78 @@ func (r *Repository) GetMessageById(ctx context.Context, convId, msgId int64) (*
 	return message, nil
 }
 
+// GetMessagesAfter returns up to limit messages with ids greater than afterId in the order they were sent,
+// with the current names of their senders. It is used to iterate over the whole history.
+func (r *Repository) GetMessagesAfter(ctx context.Context, cnvId, afterId int64, limit int) ([]*chatEnts.ExportedMessage, error) {
+	var messages []*chatEnts.ExportedMessage
+	query := fmt.Sprintf(`
+	SELECT m.*, u.user_name AS sender_name FROM %s m
+	LEFT JOIN %s u ON u.id = m.sender_id
+	WHERE m.conversation_id = $1 AND m.id > $2
+	AND (m.expires_at IS NULL OR m.expires_at > NOW())
+	ORDER BY m.id
+	LIMIT $3`, constants.MessageTable, constants.UserTable)
+
+	if err := r.db.SelectContext(ctx, &messages, query, cnvId, afterId, limit); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetSentMessages pages through messages the user sent in any conversation, ordered by id.
+func (r *Repository) GetSentMessages(ctx context.Context, usrId, afterId int64, limit int) ([]*chatEnts.Message, error) {
+	var messages []*chatEnts.Message
+	query := fmt.Sprintf(`
+	SELECT * FROM %s
+	WHERE sender_id = $1 AND id > $2
+	ORDER BY id
+	LIMIT $3`, constants.MessageTable)
+
+	if err := r.db.SelectContext(ctx, &messages, query, usrId, afterId, limit); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *Repository) Count(ctx context.Context, cnvId int64) (int64, error) {
+	var n int64
+	query := fmt.Sprintf(`
+	SELECT COUNT(*) FROM %s
+	WHERE conversation_id = $1 AND (expires_at IS NULL OR expires_at > NOW())`, constants.MessageTable)
+
+	if err := r.db.GetContext(ctx, &n, query, cnvId); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// DeleteExpired deletes up to limit expired messages and returns them.
+// Locked rows are skipped, so sweepers of several replicas don't wait for each other.
+func (r *Repository) DeleteExpired(ctx context.Context, limit int) ([]*chatEnts.ExpiredMessage, error) {
+	var res []*chatEnts.ExpiredMessage
+	query := fmt.Sprintf(`
+	DELETE FROM %[1]s WHERE id IN (
+		SELECT id FROM %[1]s
+		WHERE expires_at IS NOT NULL AND expires_at <= NOW()
+		ORDER BY expires_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, conversation_id`, constants.MessageTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, limit); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 func (r *Repository) getMessagesQueryString(params *messages_dto.GetMessageQueryParams) string {
-	whereQuery := "conversation_id = $1"
+	// expired messages are hidden until the sweeper deletes them
+	whereQuery := "conversation_id = $1 AND (expires_at IS NULL OR expires_at > NOW())"
+	// messages of users the reader blocked are hidden from them
+	whereQuery += fmt.Sprintf(" AND sender_id NOT IN (SELECT blocked_id FROM %s WHERE blocker_id = $3)", constants.UserBlockTable)
 	if params.LastReceivedId != nil {
-		whereQuery += " and id < $3"
+		whereQuery += " and id < $4"
 	}
 	return fmt.Sprintf(`
 	SELECT * FROM %s WHERE %s
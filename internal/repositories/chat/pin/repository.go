@@ -0,0 +1,77 @@
+package pin
+
+import (
+	"chatapp/internal/constants"
+	chatEnts "chatapp/internal/entities/chat"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// Create pins the message, false is returned when it is already pinned.
+func (r *Repository) Create(ctx context.Context, p *chatEnts.Pin) (bool, error) {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (message_id, conversation_id, pinned_by, pinned_at)
+	VALUES ($1, $2, $3, NOW())
+	ON CONFLICT (message_id) DO NOTHING
+	RETURNING pinned_at`, constants.MessagePinTable)
+
+	if err := r.db.QueryRowContext(ctx, query, p.MessageID, p.ConversationID, p.PinnedBy).Scan(&p.PinnedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete unpins the message and returns the removed pin, nil when the message was not pinned.
+func (r *Repository) Delete(ctx context.Context, cnvId, msgId int64) (*chatEnts.Pin, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE message_id = $1 AND conversation_id = $2 RETURNING *`, constants.MessagePinTable)
+
+	p := &chatEnts.Pin{}
+	if err := r.db.GetContext(ctx, p, query, msgId, cnvId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *Repository) Count(ctx context.Context, cnvId int64) (int, error) {
+	var n int
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE conversation_id = $1`, constants.MessagePinTable)
+
+	if err := r.db.GetContext(ctx, &n, query, cnvId); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// GetPinnedMessages returns pinned messages of the conversation, latest pins first.
+func (r *Repository) GetPinnedMessages(ctx context.Context, cnvId int64) ([]*chatEnts.PinnedMessage, error) {
+	var res []*chatEnts.PinnedMessage
+	query := fmt.Sprintf(`
+	SELECT m.*, p.pinned_by, p.pinned_at FROM %s p
+	JOIN %s m ON m.id = p.message_id
+	WHERE p.conversation_id = $1 AND (m.expires_at IS NULL OR m.expires_at > NOW())
+	ORDER BY p.pinned_at DESC, p.message_id DESC`, constants.MessagePinTable, constants.MessageTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, cnvId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,111 @@
+package retention
+
+import (
+	"chatapp/internal/constants"
+	chatEnts "chatapp/internal/entities/chat"
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// GetTargets returns conversations with a retention period which are not on legal hold.
+func (r *Repository) GetTargets(ctx context.Context, defaultDays int) ([]*chatEnts.RetentionTarget, error) {
+	var res []*chatEnts.RetentionTarget
+	query := fmt.Sprintf(`
+	SELECT id, COALESCE(retention_days, $1) AS retention_days FROM %s
+	WHERE NOT legal_hold AND COALESCE(retention_days, $1) > 0
+	ORDER BY id`, constants.ConversationTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, defaultDays); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) CountHeld(ctx context.Context) (int, error) {
+	var n int
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE legal_hold`, constants.ConversationTable)
+
+	if err := r.db.GetContext(ctx, &n, query); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// PurgeBatch deletes up to limit messages of the conversation older than the days, moving them to the archive
+// when archive is set. The cutoff is taken from NOW() like created_at. A legal hold placed during the run stops
+// the purge at the next batch.
+func (r *Repository) PurgeBatch(ctx context.Context, cnvId int64, days, limit int, archive bool) (int64, error) {
+	purge := fmt.Sprintf(`
+	DELETE FROM %[1]s WHERE id IN (
+		SELECT id FROM %[1]s
+		WHERE conversation_id = $1 AND created_at < NOW() - make_interval(days => $2)
+		AND NOT EXISTS (SELECT 1 FROM %[2]s WHERE id = $1 AND legal_hold)
+		ORDER BY created_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)`, constants.MessageTable, constants.ConversationTable)
+
+	var query string
+	if archive {
+		query = fmt.Sprintf(`
+		WITH purged AS (%s
+		RETURNING id, conversation_id, sender_id, content, display_name, avatar_url, mentions, created_at, updated_at)
+		INSERT INTO %s (id, conversation_id, sender_id, content, display_name, avatar_url, mentions, created_at, updated_at, archived_at)
+		SELECT id, conversation_id, sender_id, content, display_name, avatar_url, mentions, created_at, updated_at, NOW() FROM purged
+		ON CONFLICT (id) DO NOTHING`, purge, constants.MessageArchiveTable)
+	} else {
+		query = purge
+	}
+
+	res, err := r.db.ExecContext(ctx, query, cnvId, days, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *Repository) CreateRun(ctx context.Context, run *chatEnts.RetentionRun) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (status, archive, default_days, started_at)
+	VALUES ($1, $2, $3, NOW())
+	RETURNING id, started_at`, constants.RetentionRunTable)
+
+	return r.db.QueryRowContext(ctx, query, run.Status, run.Archive, run.DefaultDays).Scan(&run.ID, &run.StartedAt)
+}
+
+func (r *Repository) FinishRun(ctx context.Context, run *chatEnts.RetentionRun) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, conversations = $2, held_conversations = $3, purged_messages = $4, details = $5, error = $6, finished_at = NOW()
+	WHERE id = $7
+	RETURNING finished_at`, constants.RetentionRunTable)
+
+	return r.db.QueryRowContext(ctx, query, run.Status, run.Conversations, run.HeldConversations, run.PurgedMessages, run.Details, run.Error, run.ID).
+		Scan(&run.FinishedAt)
+}
+
+// GetRuns returns purge runs, newest first.
+func (r *Repository) GetRuns(ctx context.Context, lastId *int64, limit int) ([]*chatEnts.RetentionRun, error) {
+	var res []*chatEnts.RetentionRun
+	query := fmt.Sprintf(`
+	SELECT * FROM %s
+	WHERE ($1::BIGINT IS NULL OR id < $1)
+	ORDER BY id DESC
+	LIMIT $2`, constants.RetentionRunTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, lastId, limit); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,124 @@
+package scheduled
+
+import (
+	"chatapp/internal/constants"
+	chatEnts "chatapp/internal/entities/chat"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, tx *sqlx.Tx, m *chatEnts.ScheduledMessage) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (conversation_id, sender_id, content, send_at, status, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+	RETURNING id, status, created_at, updated_at`, constants.ScheduledMessageTable)
+
+	return tx.QueryRowContext(ctx, query, m.ConversationID, m.SenderID, m.Content, m.SendAt, chatEnts.ScheduledMessageStatusScheduled).
+		Scan(&m.ID, &m.Status, &m.CreatedAt, &m.UpdatedAt)
+}
+
+func (r *Repository) SetJob(ctx context.Context, tx *sqlx.Tx, id, jobId int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET job_id = $1 WHERE id = $2`, constants.ScheduledMessageTable)
+
+	_, err := tx.ExecContext(ctx, query, jobId, id)
+	return err
+}
+
+func (r *Repository) GetScheduledMessage(ctx context.Context, cnvId, id int64) (*chatEnts.ScheduledMessage, error) {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1 AND conversation_id = $2 LIMIT 1`, constants.ScheduledMessageTable)
+
+	m := &chatEnts.ScheduledMessage{}
+	if err := r.db.GetContext(ctx, m, query, id, cnvId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetPending returns messages of the sender waiting to be sent, soonest first.
+func (r *Repository) GetPending(ctx context.Context, cnvId, senderId int64) ([]*chatEnts.ScheduledMessage, error) {
+	var res []*chatEnts.ScheduledMessage
+	query := fmt.Sprintf(`
+	SELECT * FROM %s
+	WHERE conversation_id = $1 AND sender_id = $2 AND status = $3
+	ORDER BY send_at, id`, constants.ScheduledMessageTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, cnvId, senderId, chatEnts.ScheduledMessageStatusScheduled); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Update changes a message still waiting to be sent, false is returned otherwise.
+func (r *Repository) Update(ctx context.Context, tx *sqlx.Tx, m *chatEnts.ScheduledMessage) (bool, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET content = $1, send_at = $2, job_id = $3, updated_at = NOW()
+	WHERE id = $4 AND status = $5
+	RETURNING updated_at`, constants.ScheduledMessageTable)
+
+	if err := tx.QueryRowContext(ctx, query, m.Content, m.SendAt, m.JobID, m.ID, chatEnts.ScheduledMessageStatusScheduled).Scan(&m.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Claim marks the message as sending when it still waits for the job, nil is returned when it was edited, cancelled or sent.
+func (r *Repository) Claim(ctx context.Context, id, jobId int64) (*chatEnts.ScheduledMessage, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, updated_at = NOW()
+	WHERE id = $2 AND job_id = $3 AND status = $4
+	RETURNING *`, constants.ScheduledMessageTable)
+
+	m := &chatEnts.ScheduledMessage{}
+	if err := r.db.GetContext(ctx, m, query, chatEnts.ScheduledMessageStatusSending, id, jobId, chatEnts.ScheduledMessageStatusScheduled); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetStatus moves the message from one status to another, false is returned when it is not in the from status.
+func (r *Repository) SetStatus(ctx context.Context, id int64, from, to chatEnts.ScheduledMessageStatus, lastError *string) (bool, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, last_error = $2, updated_at = NOW()
+	WHERE id = $3 AND status = $4`, constants.ScheduledMessageTable)
+
+	res, err := r.db.ExecContext(ctx, query, to, lastError, id, from)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *Repository) MarkSent(ctx context.Context, id, msgId int64) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, message_id = $2, last_error = NULL, updated_at = NOW()
+	WHERE id = $3`, constants.ScheduledMessageTable)
+
+	_, err := r.db.ExecContext(ctx, query, chatEnts.ScheduledMessageStatusSent, msgId, id)
+	return err
+}
@@ -0,0 +1,177 @@
+package contact
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/users"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrRequestExists = errors.New("a contact request between the users is already pending")
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) CreateRequest(ctx context.Context, req *users.ContactRequest) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (sender_id, recipient_id, status, created_at)
+	VALUES ($1, $2, $3, NOW())
+	RETURNING id, created_at`, constants.ContactRequestTable)
+
+	req.Status = users.ContactRequestPending
+	if err := r.db.QueryRowContext(ctx, query, req.SenderID, req.RecipientID, req.Status).Scan(&req.ID, &req.CreatedAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrRequestExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) GetRequest(ctx context.Context, id int64) (*users.ContactRequest, error) {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1 LIMIT 1`, constants.ContactRequestTable)
+	req := &users.ContactRequest{}
+
+	if err := r.db.GetContext(ctx, req, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return req, nil
+}
+
+// GetPendingRequest returns the pending request from the sender to the recipient, nil when there is none.
+func (r *Repository) GetPendingRequest(ctx context.Context, senderId, recipientId int64) (*users.ContactRequest, error) {
+	query := fmt.Sprintf(`
+	SELECT * FROM %s
+	WHERE sender_id = $1 AND recipient_id = $2 AND status = $3
+	LIMIT 1`, constants.ContactRequestTable)
+	req := &users.ContactRequest{}
+
+	if err := r.db.GetContext(ctx, req, query, senderId, recipientId, users.ContactRequestPending); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return req, nil
+}
+
+// GetPendingRequests returns requests waiting for an answer of the user when incoming,
+// otherwise the ones the user sent, newest first.
+func (r *Repository) GetPendingRequests(ctx context.Context, usrId int64, incoming bool) ([]*users.ContactRequest, error) {
+	column := "sender_id"
+	if incoming {
+		column = "recipient_id"
+	}
+	var res []*users.ContactRequest
+	query := fmt.Sprintf(`
+	SELECT * FROM %s
+	WHERE %s = $1 AND status = $2
+	ORDER BY id DESC`, constants.ContactRequestTable, column)
+
+	if err := r.db.SelectContext(ctx, &res, query, usrId, users.ContactRequestPending); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Respond moves a pending request to the status, nil is returned when the request is no longer pending.
+// Accepting a request makes both users contacts of each other.
+func (r *Repository) Respond(ctx context.Context, id int64, status string) (req *users.ContactRequest, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	req = &users.ContactRequest{}
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, responded_at = NOW()
+	WHERE id = $2 AND status = $3
+	RETURNING *`, constants.ContactRequestTable)
+	if err = tx.GetContext(ctx, req, query, status, id, users.ContactRequestPending); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if status != users.ContactRequestAccepted {
+		return req, nil
+	}
+
+	query = fmt.Sprintf(`
+	INSERT INTO %s (user_id, contact_id, created_at)
+	VALUES ($1, $2, NOW()), ($2, $1, NOW())
+	ON CONFLICT (user_id, contact_id) DO NOTHING`, constants.ContactTable)
+	if _, err = tx.ExecContext(ctx, query, req.SenderID, req.RecipientID); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// GetContacts returns contacts of the user ordered by name.
+func (r *Repository) GetContacts(ctx context.Context, usrId int64) ([]*users.Contact, error) {
+	var res []*users.Contact
+	query := fmt.Sprintf(`
+	SELECT c.*, u.user_name FROM %s c
+	JOIN %s u ON u.id = c.contact_id
+	WHERE c.user_id = $1
+	ORDER BY u.user_name, c.contact_id`, constants.ContactTable, constants.UserTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, usrId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) IsContact(ctx context.Context, usrId, otherId int64) (bool, error) {
+	var exists bool
+	query := fmt.Sprintf(`
+	SELECT EXISTS(
+	SELECT 1 FROM %s WHERE user_id = $1 AND contact_id = $2)`, constants.ContactTable)
+
+	if err := r.db.GetContext(ctx, &exists, query, usrId, otherId); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// RemoveContact removes the users from the contacts of each other.
+func (r *Repository) RemoveContact(ctx context.Context, usrId, contactId int64) (bool, error) {
+	query := fmt.Sprintf(`
+	DELETE FROM %s
+	WHERE (user_id = $1 AND contact_id = $2) OR (user_id = $2 AND contact_id = $1)`, constants.ContactTable)
+
+	res, err := r.db.ExecContext(ctx, query, usrId, contactId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
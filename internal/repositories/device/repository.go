@@ -0,0 +1,74 @@
+package device
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/users"
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// Register stores the device, a known push token is moved to the user and marked as seen.
+func (r *Repository) Register(ctx context.Context, d *users.Device) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (user_id, platform, push_token, created_at, last_seen_at)
+	VALUES ($1, $2, $3, NOW(), NOW())
+	ON CONFLICT (push_token) DO UPDATE SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, last_seen_at = NOW()
+	RETURNING *`, constants.DeviceTable)
+
+	return r.db.GetContext(ctx, d, query, d.UserID, d.Platform, d.PushToken)
+}
+
+func (r *Repository) GetUserDevices(ctx context.Context, usrId int64) ([]*users.Device, error) {
+	var res []*users.Device
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE user_id = $1 ORDER BY id`, constants.DeviceTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, usrId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) GetDevicesOfUsers(ctx context.Context, usrIds []int64) ([]*users.Device, error) {
+	var res []*users.Device
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE user_id = ANY($1)`, constants.DeviceTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, pq.Array(usrIds)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) Delete(ctx context.Context, usrId, id int64) (bool, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1 AND user_id = $2`, constants.DeviceTable)
+
+	res, err := r.db.ExecContext(ctx, query, id, usrId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// DeleteByToken removes a device the push provider reported as unregistered.
+func (r *Repository) DeleteByToken(ctx context.Context, token string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE push_token = $1`, constants.DeviceTable)
+
+	_, err := r.db.ExecContext(ctx, query, token)
+	return err
+}
@@ -0,0 +1,93 @@
+package digest
+
+import (
+	"chatapp/internal/constants"
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// GetDueUsers returns users with digests turned on who were not active for the inactivity and
+// didn't get a digest within their frequency, ordered by id after lastId.
+// Activity is a refreshed session or an open conversation.
+func (r *Repository) GetDueUsers(ctx context.Context, inactivity time.Duration, lastId int64, limit int) ([]*users.User, error) {
+	var res []*users.User
+	query := fmt.Sprintf(`
+	SELECT u.* FROM %s u
+	WHERE u.id > $1 AND u.digest_frequency <> $2
+		AND NOT u.is_bot AND u.deleted_at IS NULL AND u.email_verified_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM %s s WHERE s.user_id = u.id AND s.last_used_at > NOW() - make_interval(secs => $3))
+		AND NOT EXISTS (SELECT 1 FROM %s p WHERE p.user_id = u.id AND p.last_seen_at > NOW() - make_interval(secs => $3))
+		AND NOT EXISTS (
+			SELECT 1 FROM %s d WHERE d.user_id = u.id
+				AND d.sent_at > NOW() - CASE u.digest_frequency WHEN $4 THEN INTERVAL '7 days' ELSE INTERVAL '1 day' END)
+	ORDER BY u.id
+	LIMIT $5`, constants.UserTable, constants.SessionTable, constants.ConversationParticipantTable, constants.EmailDigestTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, lastId, users.DigestOff, inactivity.Seconds(), users.DigestWeekly, limit); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetUnreadMessages returns up to perConversation latest messages of each conversation the user didn't see
+// since they last had it open and which are newer than the last digest. Muted conversations and
+// messages of blocked users are left out.
+func (r *Repository) GetUnreadMessages(ctx context.Context, usrId int64, perConversation int) ([]*chatEnts.DigestMessage, error) {
+	var res []*chatEnts.DigestMessage
+	query := fmt.Sprintf(`
+	SELECT * FROM (
+		SELECT m.*, c.name AS conversation_name, c.is_group,
+			CASE WHEN u.deleted_at IS NOT NULL THEN $3 ELSE COALESCE(m.display_name, NULLIF(u.display_name, ''), u.user_name) END AS sender_name,
+			mm.user_id IS NOT NULL AS mentioned,
+			COUNT(*) OVER w AS unread_count,
+			COUNT(mm.user_id) OVER w AS mention_count,
+			ROW_NUMBER() OVER (PARTITION BY m.conversation_id ORDER BY m.id DESC) AS rank
+		FROM %s m
+		JOIN %s p ON p.conversation_id = m.conversation_id AND p.user_id = $1
+		JOIN %s c ON c.id = m.conversation_id
+		JOIN %s u ON u.id = m.sender_id
+		LEFT JOIN %s mm ON mm.message_id = m.id AND mm.user_id = $1 AND mm.read_at IS NULL
+		WHERE m.sender_id <> $1 AND m.created_at > p.last_seen_at
+			AND m.id > COALESCE((SELECT last_message_id FROM %s WHERE user_id = $1), 0)
+			AND (m.expires_at IS NULL OR m.expires_at > NOW())
+			AND NOT (p.muted AND (p.muted_until IS NULL OR p.muted_until > NOW()))
+			AND m.sender_id NOT IN (SELECT blocked_id FROM %s WHERE blocker_id = $1)
+		WINDOW w AS (PARTITION BY m.conversation_id)
+	) d
+	WHERE rank <= $2
+	ORDER BY conversation_id, id`,
+		constants.MessageTable, constants.ConversationParticipantTable, constants.ConversationTable, constants.UserTable,
+		constants.MessageMentionTable, constants.EmailDigestTable, constants.UserBlockTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, usrId, perConversation, users.DeletedUserName); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Record stores the digest sent to the user, messages up to lastMessageId are not digested again.
+func (r *Repository) Record(ctx context.Context, usrId, lastMessageId int64, count int) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (user_id, last_message_id, message_count, sent_at)
+	VALUES ($1, $2, $3, NOW())
+	ON CONFLICT (user_id) DO UPDATE SET last_message_id = EXCLUDED.last_message_id,
+		message_count = EXCLUDED.message_count, sent_at = EXCLUDED.sent_at`, constants.EmailDigestTable)
+
+	_, err := r.db.ExecContext(ctx, query, usrId, lastMessageId, count)
+	return err
+}
@@ -0,0 +1,62 @@
+package identity
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/users"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, i *users.Identity) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (user_id, provider, subject, email, created_at, last_login_at)
+	VALUES ($1, $2, $3, $4, NOW(), NOW())
+	ON CONFLICT (provider, subject) DO UPDATE SET last_login_at = NOW()
+	RETURNING id, user_id, created_at, last_login_at`, constants.UserIdentityTable)
+
+	return r.db.QueryRowContext(ctx, query, i.UserID, i.Provider, i.Subject, i.Email).
+		Scan(&i.ID, &i.UserID, &i.CreatedAt, &i.LastLoginAt)
+}
+
+func (r *Repository) GetIdentity(ctx context.Context, provider, subject string) (*users.Identity, error) {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE provider = $1 AND subject = $2 LIMIT 1`, constants.UserIdentityTable)
+
+	i := &users.Identity{}
+	if err := r.db.GetContext(ctx, i, query, provider, subject); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return i, nil
+}
+
+func (r *Repository) TouchLogin(ctx context.Context, id int64, email string) error {
+	query := fmt.Sprintf(`UPDATE %s SET last_login_at = NOW(), email = $1 WHERE id = $2`, constants.UserIdentityTable)
+
+	_, err := r.db.ExecContext(ctx, query, email, id)
+	return err
+}
+
+func (r *Repository) GetUserIdentities(ctx context.Context, usrId int64) ([]*users.Identity, error) {
+	var res []*users.Identity
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE user_id = $1 ORDER BY id`, constants.UserIdentityTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, usrId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,128 @@
+package job
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/jobs"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// Create stores the job within the transaction q, or directly when q is nil. False is returned when a pending job
+// of the same kind has the same unique key.
+func (r *Repository) Create(ctx context.Context, q sqlx.QueryerContext, j *jobs.Job) (bool, error) {
+	if q == nil {
+		q = r.db
+	}
+	query := fmt.Sprintf(`
+	INSERT INTO %s (kind, unique_key, payload, status, run_at, max_attempts, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+	ON CONFLICT (kind, unique_key) WHERE status = 'pending' DO NOTHING
+	RETURNING id, created_at, updated_at`, constants.JobTable)
+
+	err := q.QueryRowxContext(ctx, query, j.Kind, j.UniqueKey, string(j.Payload), jobs.StatusPending, j.RunAt, j.MaxAttempts).
+		Scan(&j.ID, &j.CreatedAt, &j.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	j.Status = jobs.StatusPending
+	return true, nil
+}
+
+// ClaimDue leases due jobs by pushing run_at forward, so concurrent schedulers don't pick the same rows.
+// Jobs of a crashed scheduler become due again after the lease.
+func (r *Repository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*jobs.Job, error) {
+	var res []*jobs.Job
+	query := fmt.Sprintf(`
+	UPDATE %[1]s SET run_at = NOW() + make_interval(secs => $3), attempts = attempts + 1, updated_at = NOW()
+	WHERE id IN (
+		SELECT id FROM %[1]s
+		WHERE status = $1 AND run_at <= NOW()
+		ORDER BY run_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING *`, constants.JobTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, jobs.StatusPending, limit, lease.Seconds()); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) MarkSucceeded(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, last_error = NULL, finished_at = NOW(), updated_at = NOW()
+	WHERE id = $2`, constants.JobTable)
+
+	_, err := r.db.ExecContext(ctx, query, jobs.StatusSucceeded, id)
+	return err
+}
+
+func (r *Repository) MarkFailed(ctx context.Context, id int64, lastError string) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, last_error = $2, finished_at = NOW(), updated_at = NOW()
+	WHERE id = $3`, constants.JobTable)
+
+	_, err := r.db.ExecContext(ctx, query, jobs.StatusFailed, lastError, id)
+	return err
+}
+
+func (r *Repository) ScheduleRetry(ctx context.Context, id int64, runAt time.Time, lastError string) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET run_at = $1, last_error = $2, updated_at = NOW()
+	WHERE id = $3 AND status = $4`, constants.JobTable)
+
+	_, err := r.db.ExecContext(ctx, query, runAt, lastError, id, jobs.StatusPending)
+	return err
+}
+
+// Cancel cancels a pending job, false is returned when it is already finished.
+func (r *Repository) Cancel(ctx context.Context, id int64) (bool, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, finished_at = NOW(), updated_at = NOW()
+	WHERE id = $2 AND status = $3`, constants.JobTable)
+
+	res, err := r.db.ExecContext(ctx, query, jobs.StatusCancelled, id, jobs.StatusPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Reschedule moves a pending job, false is returned when it is already finished.
+func (r *Repository) Reschedule(ctx context.Context, id int64, runAt time.Time) (bool, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET run_at = $1, updated_at = NOW()
+	WHERE id = $2 AND status = $3`, constants.JobTable)
+
+	res, err := r.db.ExecContext(ctx, query, runAt, id, jobs.StatusPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,183 @@
+package job
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/jobs"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// newTestDB connects to TEST_DATABASE_URL and creates the jobs table in a throwaway schema.
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	admin, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	schema := fmt.Sprintf("test_jobs_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	db, err := sqlx.Connect("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// the jobs table of migrations/000018_create_jobs_table.up.sql
+	db.MustExec(fmt.Sprintf(`
+	CREATE TABLE %s (
+		id BIGSERIAL PRIMARY KEY,
+		kind VARCHAR(64) NOT NULL,
+		unique_key VARCHAR(255),
+		payload JSONB NOT NULL DEFAULT '{}',
+		status VARCHAR(16) NOT NULL DEFAULT 'pending',
+		run_at TIMESTAMP NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		max_attempts INT NOT NULL,
+		last_error TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		finished_at TIMESTAMP
+	)`, constants.JobTable))
+	db.MustExec(fmt.Sprintf(`CREATE UNIQUE INDEX ON %s (kind, unique_key) WHERE status = 'pending'`, constants.JobTable))
+	return db
+}
+
+func createDue(t *testing.T, r *Repository, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		// run_at has no time zone, a day back is due whatever the zones of the client and the database
+		j := &jobs.Job{Kind: "test", Payload: []byte("{}"), RunAt: time.Now().Add(-24 * time.Hour), MaxAttempts: 3}
+		if _, err := r.Create(context.Background(), nil, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClaimDueSkipsLockedRows(t *testing.T) {
+	db := newTestDB(t)
+	r := NewRepository(db)
+	createDue(t, r, 4)
+
+	// another replica is in the middle of claiming the first two jobs
+	tx := db.MustBegin()
+	defer tx.Rollback()
+	var locked []int64
+	if err := tx.Select(&locked, fmt.Sprintf(`SELECT id FROM %s ORDER BY id LIMIT 2 FOR UPDATE`, constants.JobTable)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	claimed, err := r.ClaimDue(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("claim while rows are locked: %v", err)
+	}
+	if len(claimed) != 2 {
+		t.Fatalf("claimed %d jobs, want the 2 unlocked ones", len(claimed))
+	}
+	for _, j := range claimed {
+		if j.ID == locked[0] || j.ID == locked[1] {
+			t.Fatalf("claimed locked job %d", j.ID)
+		}
+	}
+}
+
+func TestConcurrentClaimsDontOverlap(t *testing.T) {
+	db := newTestDB(t)
+	r := NewRepository(db)
+	const total = 50
+	createDue(t, r, total)
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64]int)
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < 5; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				claimed, err := r.ClaimDue(context.Background(), 3, time.Minute)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(claimed) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, j := range claimed {
+					seen[j.ID]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != total {
+		t.Fatalf("claimed %d distinct jobs, want %d", len(seen), total)
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("job %d claimed %d times", id, n)
+		}
+	}
+}
+
+func TestClaimDueLease(t *testing.T) {
+	db := newTestDB(t)
+	r := NewRepository(db)
+	createDue(t, r, 1)
+	ctx := context.Background()
+
+	claimed, err := r.ClaimDue(ctx, 10, time.Second)
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 1 {
+		t.Fatalf("first claim = %v, %v", claimed, err)
+	}
+	if again, err := r.ClaimDue(ctx, 10, time.Second); err != nil || len(again) != 0 {
+		t.Fatalf("leased job claimed again: %v, %v", again, err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	expired, err := r.ClaimDue(ctx, 10, time.Second)
+	if err != nil || len(expired) != 1 || expired[0].ID != claimed[0].ID || expired[0].Attempts != 2 {
+		t.Fatalf("claim after the lease = %v, %v", expired, err)
+	}
+
+	// finished jobs are never claimed, however old their lease
+	if err := r.MarkSucceeded(ctx, expired[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if done, err := r.ClaimDue(ctx, 10, time.Second); err != nil || len(done) != 0 {
+		t.Fatalf("finished job claimed: %v, %v", done, err)
+	}
+}
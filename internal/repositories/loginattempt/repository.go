@@ -0,0 +1,57 @@
+package loginattempt
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/users"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, a *users.LoginAttempt) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (user_id, email, ip, user_agent, success, created_at)
+	VALUES ($1, $2, $3, $4, $5, NOW())
+	RETURNING id, created_at`, constants.LoginAttemptTable)
+
+	return r.db.QueryRowContext(ctx, query, a.UserID, a.Email, a.IP, a.UserAgent, a.Success).Scan(&a.ID, &a.CreatedAt)
+}
+
+// GetEmailFailures counts failed attempts for the email made after its last successful sign in.
+func (r *Repository) GetEmailFailures(ctx context.Context, email string, since time.Time) (*users.FailureStats, error) {
+	query := fmt.Sprintf(`
+	SELECT COUNT(*) AS count, MAX(created_at) AS last_attempt FROM %[1]s
+	WHERE email = $1 AND success = FALSE AND created_at > GREATEST($2, COALESCE(
+		(SELECT MAX(created_at) FROM %[1]s WHERE email = $1 AND success = TRUE), $2))`, constants.LoginAttemptTable)
+
+	stats := &users.FailureStats{}
+	if err := r.db.GetContext(ctx, stats, query, email, since); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetIPFailures counts failed attempts from the IP, successful sign ins do not reset it.
+func (r *Repository) GetIPFailures(ctx context.Context, ip string, since time.Time) (*users.FailureStats, error) {
+	query := fmt.Sprintf(`
+	SELECT COUNT(*) AS count, MAX(created_at) AS last_attempt FROM %s
+	WHERE ip = $1 AND success = FALSE AND created_at > $2`, constants.LoginAttemptTable)
+
+	stats := &users.FailureStats{}
+	if err := r.db.GetContext(ctx, stats, query, ip, since); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,66 @@
+package mfarecovery
+
+import (
+	"chatapp/internal/constants"
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// Replace drops old recovery codes of the user and stores the new ones.
+func (r *Repository) Replace(ctx context.Context, usrId int64, hashes []string) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, constants.MFARecoveryCodeTable), usrId); err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, code_hash, created_at) VALUES ($1, $2, NOW())`, constants.MFARecoveryCodeTable)
+	for _, h := range hashes {
+		if _, err = tx.ExecContext(ctx, query, usrId, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) Consume(ctx context.Context, usrId int64, hash string) (bool, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET used_at = NOW()
+	WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, constants.MFARecoveryCodeTable)
+
+	res, err := r.db.ExecContext(ctx, query, usrId, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *Repository) DeleteAll(ctx context.Context, usrId int64) error {
+	_, err := r.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, constants.MFARecoveryCodeTable), usrId)
+	return err
+}
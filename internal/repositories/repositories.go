@@ -5,11 +5,33 @@ import (
 	messages_dto "chatapp/internal/dto/messages"
 	user_dto "chatapp/internal/dto/user"
 	"chatapp/internal/entities/chat"
+	"chatapp/internal/entities/integrations"
+	"chatapp/internal/entities/jobs"
 	"chatapp/internal/entities/users"
+	"chatapp/internal/repositories/apikey"
+	"chatapp/internal/repositories/block"
+	"chatapp/internal/repositories/botcommand"
 	"chatapp/internal/repositories/chat/conversation"
+	"chatapp/internal/repositories/chat/export"
+	"chatapp/internal/repositories/chat/imports"
+	"chatapp/internal/repositories/chat/mention"
 	"chatapp/internal/repositories/chat/message"
+	"chatapp/internal/repositories/chat/pin"
+	"chatapp/internal/repositories/chat/retention"
+	"chatapp/internal/repositories/chat/scheduled"
+	"chatapp/internal/repositories/contact"
+	"chatapp/internal/repositories/device"
+	"chatapp/internal/repositories/digest"
+	"chatapp/internal/repositories/identity"
+	"chatapp/internal/repositories/job"
+	"chatapp/internal/repositories/loginattempt"
+	"chatapp/internal/repositories/mfarecovery"
+	"chatapp/internal/repositories/session"
 	"chatapp/internal/repositories/user"
+	"chatapp/internal/repositories/usertoken"
+	"chatapp/internal/repositories/webhook"
 	"context"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -18,31 +40,263 @@ type UserRepositoryInterface interface {
 	Create(ctx context.Context, userDto *user_dto.CreateUserDTO) (*users.User, error)
 	GetUserById(ctx context.Context, id int64) (*users.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*users.User, error)
+	RegisterFailedLogin(ctx context.Context, id int64, threshold int, lockFor time.Duration) (*time.Time, error)
+	ResetFailedLogins(ctx context.Context, id int64) error
+	UpdatePassword(ctx context.Context, id int64, hash string) error
+	MarkEmailVerified(ctx context.Context, id int64) error
+	SetTOTPSecret(ctx context.Context, id int64, secret string) error
+	EnableTOTP(ctx context.Context, id, step int64) error
+	DisableTOTP(ctx context.Context, id int64) error
+	UseTOTPStep(ctx context.Context, id, step int64) (bool, error)
+	CreateBot(ctx context.Context, q sqlx.QueryerContext, dto *user_dto.CreateBotDTO) (*users.User, error)
+	GetBots(ctx context.Context, ownerId int64) ([]*users.User, error)
+	DeleteBot(ctx context.Context, ownerId, botId int64) (bool, error)
+	GetUsersByUsername(ctx context.Context, name string, limit int) ([]*users.User, error)
+	CreateImported(ctx context.Context, dto *user_dto.CreateImportedUserDTO) (*users.User, bool, error)
+	UpdateProfile(ctx context.Context, usr *users.User) error
+	SetAvatarURL(ctx context.Context, id int64, url *string) error
+	SetPendingEmail(ctx context.Context, id int64, email *string) error
+	ConfirmPendingEmail(ctx context.Context, id int64) (*users.User, error)
+	UpdatePrivacy(ctx context.Context, usr *users.User) error
+	UpdateNotificationSettings(ctx context.Context, usr *users.User) error
+	GetUsersByIds(ctx context.Context, ids []int64) ([]*users.User, error)
+	SearchUsers(ctx context.Context, prefix string, lastId *int64, limit int) ([]*users.User, error)
+	Anonymize(ctx context.Context, usr *users.User, password string) (*users.DeletedIntegrations, error)
+	DeleteAccount(ctx context.Context, usr *users.User) (*users.DeletedIntegrations, error)
 }
 type ConversationRepositoryInterface interface {
 	Create(ctx context.Context, tx *sqlx.Tx, cnv *chat.Conversation) error
 	IsParticipant(ctx context.Context, cnvId, usrId int64) (bool, error)
+	SharesConversation(ctx context.Context, usrId, otherId int64) (bool, error)
 	IsConversationExists(ctx context.Context, cnvId int64) (bool, error)
-	AddParticipant(ctx context.Context, tx *sqlx.Tx, cnvId, usrId int64) error
+	AddParticipant(ctx context.Context, tx *sqlx.Tx, cnvId, usrId int64, role string) error
+	GetParticipant(ctx context.Context, cnvId, usrId int64) (*chat.ConversationParticipant, error)
 	GetConversationById(ctx context.Context, cnvId int64) (*chat.Conversation, error)
 	GetParticipants(ctx context.Context, cnvId int64) ([]*chat.ConversationParticipant, error)
+	GetParticipantProfiles(ctx context.Context, cnvId int64) ([]*chat.ParticipantProfile, error)
+	GetMemberships(ctx context.Context, usrId int64) ([]*chat.Membership, error)
+	UpdateTopic(ctx context.Context, cnv *chat.Conversation, topic string) error
+	SetMessageTTL(ctx context.Context, cnv *chat.Conversation, ttl *int) error
+	SetRetention(ctx context.Context, cnv *chat.Conversation, days *int) error
+	SetLegalHold(ctx context.Context, cnv *chat.Conversation, hold bool) error
+	SetMuted(ctx context.Context, cnvId, usrId int64, muted bool, until *time.Time) error
+	MarkSeen(ctx context.Context, cnvId, usrId int64) error
+	CountUnreadMessages(ctx context.Context, usrId int64) (int, error)
+	GetParticipantsByUsernames(ctx context.Context, cnvId int64, names []string) ([]*users.User, error)
 }
 type MessageRepositoryInterface interface {
-	Create(ctx context.Context, message *chat.Message) error
+	Create(ctx context.Context, message *chat.Message, ttl *time.Duration) error
 	GetMessages(ctx context.Context, qParams *messages_dto.GetMessageQueryParams) ([]*chat.Message, error)
-	Update(ctx context.Context, msg *chat.Message, content string) error
+	Update(ctx context.Context, msg *chat.Message, content string, mentions chat.Mentions) error
 	GetMessageById(ctx context.Context, convId, msgId int64) (*chat.Message, error)
+	GetMessagesAfter(ctx context.Context, cnvId, afterId int64, limit int) ([]*chat.ExportedMessage, error)
+	GetSentMessages(ctx context.Context, usrId, afterId int64, limit int) ([]*chat.Message, error)
+	Count(ctx context.Context, cnvId int64) (int64, error)
+	DeleteExpired(ctx context.Context, limit int) ([]*chat.ExpiredMessage, error)
 }
+type MentionRepositoryInterface interface {
+	Replace(ctx context.Context, msg *chat.Message, usrIds []int64) error
+	GetUnreadMessages(ctx context.Context, usrId int64, cnvId, lastId *int64, limit int) ([]*chat.Message, error)
+	GetUnreadCounts(ctx context.Context, usrId int64) ([]*chat.MentionCount, error)
+	MarkRead(ctx context.Context, usrId, cnvId int64) error
+}
+type PinRepositoryInterface interface {
+	Create(ctx context.Context, p *chat.Pin) (bool, error)
+	Delete(ctx context.Context, cnvId, msgId int64) (*chat.Pin, error)
+	Count(ctx context.Context, cnvId int64) (int, error)
+	GetPinnedMessages(ctx context.Context, cnvId int64) ([]*chat.PinnedMessage, error)
+}
+type ScheduledMessageRepositoryInterface interface {
+	Create(ctx context.Context, tx *sqlx.Tx, m *chat.ScheduledMessage) error
+	SetJob(ctx context.Context, tx *sqlx.Tx, id, jobId int64) error
+	GetScheduledMessage(ctx context.Context, cnvId, id int64) (*chat.ScheduledMessage, error)
+	GetPending(ctx context.Context, cnvId, senderId int64) ([]*chat.ScheduledMessage, error)
+	Update(ctx context.Context, tx *sqlx.Tx, m *chat.ScheduledMessage) (bool, error)
+	Claim(ctx context.Context, id, jobId int64) (*chat.ScheduledMessage, error)
+	SetStatus(ctx context.Context, id int64, from, to chat.ScheduledMessageStatus, lastError *string) (bool, error)
+	MarkSent(ctx context.Context, id, msgId int64) error
+}
+type JobRepositoryInterface interface {
+	Create(ctx context.Context, q sqlx.QueryerContext, j *jobs.Job) (bool, error)
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*jobs.Job, error)
+	MarkSucceeded(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, lastError string) error
+	ScheduleRetry(ctx context.Context, id int64, runAt time.Time, lastError string) error
+	Cancel(ctx context.Context, id int64) (bool, error)
+	Reschedule(ctx context.Context, id int64, runAt time.Time) (bool, error)
+}
+type SessionRepositoryInterface interface {
+	Create(ctx context.Context, s *users.Session) error
+	GetSessionById(ctx context.Context, id int64) (*users.Session, error)
+	GetSessionByRefreshTokenHash(ctx context.Context, hash string) (*users.Session, error)
+	GetSessionByPreviousTokenHash(ctx context.Context, hash string) (*users.Session, error)
+	Rotate(ctx context.Context, id int64, oldHash, newHash string, expiresAt time.Time) (bool, error)
+	GetActiveSessions(ctx context.Context, usrId int64) ([]*users.Session, error)
+	GetUserSessions(ctx context.Context, usrId int64) ([]*users.Session, error)
+	Revoke(ctx context.Context, usrId, id int64) (bool, error)
+	RevokeAll(ctx context.Context, usrId int64) error
+	RevokeOthers(ctx context.Context, usrId, keepId int64) error
+}
+type LoginAttemptRepositoryInterface interface {
+	Create(ctx context.Context, a *users.LoginAttempt) error
+	GetEmailFailures(ctx context.Context, email string, since time.Time) (*users.FailureStats, error)
+	GetIPFailures(ctx context.Context, ip string, since time.Time) (*users.FailureStats, error)
+}
+type UserTokenRepositoryInterface interface {
+	Create(ctx context.Context, t *users.Token) error
+	Consume(ctx context.Context, hash string, purpose users.TokenPurpose) (*users.Token, error)
+	Invalidate(ctx context.Context, usrId int64, purpose users.TokenPurpose) error
+}
+type MFARecoveryCodeRepositoryInterface interface {
+	Replace(ctx context.Context, usrId int64, hashes []string) error
+	Consume(ctx context.Context, usrId int64, hash string) (bool, error)
+	DeleteAll(ctx context.Context, usrId int64) error
+}
+type IdentityRepositoryInterface interface {
+	Create(ctx context.Context, i *users.Identity) error
+	GetIdentity(ctx context.Context, provider, subject string) (*users.Identity, error)
+	TouchLogin(ctx context.Context, id int64, email string) error
+	GetUserIdentities(ctx context.Context, usrId int64) ([]*users.Identity, error)
+}
+type APIKeyRepositoryInterface interface {
+	Create(ctx context.Context, k *users.APIKey) error
+	GetKeyByPrefix(ctx context.Context, prefix string) (*users.APIKey, error)
+	GetKeysCreatedBy(ctx context.Context, usrId int64) ([]*users.APIKey, error)
+	Revoke(ctx context.Context, createdBy, id int64) (bool, error)
+	TouchLastUsed(ctx context.Context, id int64) error
+}
+type WebhookRepositoryInterface interface {
+	Create(ctx context.Context, w *integrations.Webhook) error
+	GetWebhookById(ctx context.Context, id int64) (*integrations.Webhook, error)
+	GetConversationWebhooks(ctx context.Context, cnvId int64) ([]*integrations.Webhook, error)
+	Delete(ctx context.Context, cnvId, id int64) (bool, error)
+	CreateDelivery(ctx context.Context, d *integrations.WebhookDelivery) error
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*integrations.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, d *integrations.WebhookDelivery) error
+	ScheduleRetry(ctx context.Context, d *integrations.WebhookDelivery) error
+	MoveToDeadLetter(ctx context.Context, d *integrations.WebhookDelivery) error
+	GetDeliveries(ctx context.Context, webhookId int64, lastId *int64, limit int) ([]*integrations.WebhookDelivery, error)
+	GetDeadLetters(ctx context.Context, webhookId int64, limit int) ([]*integrations.WebhookDeadLetter, error)
+}
+type IncomingWebhookRepositoryInterface interface {
+	Create(ctx context.Context, tx *sqlx.Tx, w *integrations.IncomingWebhook) error
+	GetWebhookByTokenHash(ctx context.Context, hash string) (*integrations.IncomingWebhook, error)
+	GetConversationWebhooks(ctx context.Context, cnvId int64) ([]*integrations.IncomingWebhook, error)
+	Delete(ctx context.Context, cnvId, id int64) (bool, error)
+}
+type BotCommandRepositoryInterface interface {
+	Create(ctx context.Context, tx *sqlx.Tx, c *integrations.BotCommand) error
+	GetCommand(ctx context.Context, cnvId int64, name string) (*integrations.BotCommand, error)
+	GetConversationCommands(ctx context.Context, cnvId int64) ([]*integrations.BotCommand, error)
+	Delete(ctx context.Context, cnvId, id int64) (bool, error)
+}
+type RetentionRepositoryInterface interface {
+	GetTargets(ctx context.Context, defaultDays int) ([]*chat.RetentionTarget, error)
+	CountHeld(ctx context.Context) (int, error)
+	PurgeBatch(ctx context.Context, cnvId int64, days, limit int, archive bool) (int64, error)
+	CreateRun(ctx context.Context, run *chat.RetentionRun) error
+	FinishRun(ctx context.Context, run *chat.RetentionRun) error
+	GetRuns(ctx context.Context, lastId *int64, limit int) ([]*chat.RetentionRun, error)
+}
+type ExportRepositoryInterface interface {
+	Create(ctx context.Context, e *chat.Export) error
+	GetExport(ctx context.Context, cnvId, id int64) (*chat.Export, error)
+	Start(ctx context.Context, id int64) (*chat.Export, error)
+	Release(ctx context.Context, id int64) error
+	MarkReady(ctx context.Context, e *chat.Export, ttl time.Duration) error
+	MarkFailed(ctx context.Context, e *chat.Export, lastError string) error
+	DeleteExpired(ctx context.Context, limit int) ([]*chat.Export, error)
+}
+type ImportRepositoryInterface interface {
+	UpsertConversation(ctx context.Context, cnv *chat.Conversation) (bool, error)
+	AddParticipants(ctx context.Context, cnvId int64, participants []*chat.ConversationParticipant) (int64, error)
+	InsertMessages(ctx context.Context, cnvId int64, msgs []*chat.Message) (int64, error)
+	LinkThreads(ctx context.Context, cnvId int64, links []*chat.ImportedThreadLink) (int64, error)
+	InsertReactions(ctx context.Context, cnvId int64, reactions []*chat.ImportedReaction) (int64, error)
+}
+type BlockRepositoryInterface interface {
+	Block(ctx context.Context, blockerId, blockedId int64) error
+	Unblock(ctx context.Context, blockerId, blockedId int64) (bool, error)
+	GetBlocks(ctx context.Context, blockerId int64) ([]*users.Block, error)
+	GetBlockedIds(ctx context.Context, blockerId int64) ([]int64, error)
+	GetBlockersOf(ctx context.Context, blockedId int64, usrIds []int64) ([]int64, error)
+	IsBlockedEither(ctx context.Context, usrId, otherId int64) (bool, error)
+}
+
+type ContactRepositoryInterface interface {
+	CreateRequest(ctx context.Context, req *users.ContactRequest) error
+	GetRequest(ctx context.Context, id int64) (*users.ContactRequest, error)
+	GetPendingRequest(ctx context.Context, senderId, recipientId int64) (*users.ContactRequest, error)
+	GetPendingRequests(ctx context.Context, usrId int64, incoming bool) ([]*users.ContactRequest, error)
+	Respond(ctx context.Context, id int64, status string) (*users.ContactRequest, error)
+	GetContacts(ctx context.Context, usrId int64) ([]*users.Contact, error)
+	IsContact(ctx context.Context, usrId, otherId int64) (bool, error)
+	RemoveContact(ctx context.Context, usrId, contactId int64) (bool, error)
+}
+
+type DeviceRepositoryInterface interface {
+	Register(ctx context.Context, d *users.Device) error
+	GetUserDevices(ctx context.Context, usrId int64) ([]*users.Device, error)
+	GetDevicesOfUsers(ctx context.Context, usrIds []int64) ([]*users.Device, error)
+	Delete(ctx context.Context, usrId, id int64) (bool, error)
+	DeleteByToken(ctx context.Context, token string) error
+}
+type DigestRepositoryInterface interface {
+	GetDueUsers(ctx context.Context, inactivity time.Duration, lastId int64, limit int) ([]*users.User, error)
+	GetUnreadMessages(ctx context.Context, usrId int64, perConversation int) ([]*chat.DigestMessage, error)
+	Record(ctx context.Context, usrId, lastMessageId int64, count int) error
+}
+
 type Repositories struct {
-	UserRepository         UserRepositoryInterface
-	ConversationRepository ConversationRepositoryInterface
-	MessageRepository      MessageRepositoryInterface
+	UserRepository             UserRepositoryInterface
+	ConversationRepository     ConversationRepositoryInterface
+	MessageRepository          MessageRepositoryInterface
+	MentionRepository          MentionRepositoryInterface
+	PinRepository              PinRepositoryInterface
+	ScheduledMessageRepository ScheduledMessageRepositoryInterface
+	JobRepository              JobRepositoryInterface
+	RetentionRepository        RetentionRepositoryInterface
+	ExportRepository           ExportRepositoryInterface
+	ImportRepository           ImportRepositoryInterface
+	SessionRepository          SessionRepositoryInterface
+	LoginAttemptRepository     LoginAttemptRepositoryInterface
+	UserTokenRepository        UserTokenRepositoryInterface
+	MFARecoveryCodeRepository  MFARecoveryCodeRepositoryInterface
+	IdentityRepository         IdentityRepositoryInterface
+	APIKeyRepository           APIKeyRepositoryInterface
+	WebhookRepository          WebhookRepositoryInterface
+	IncomingWebhookRepository  IncomingWebhookRepositoryInterface
+	BotCommandRepository       BotCommandRepositoryInterface
+	BlockRepository            BlockRepositoryInterface
+	ContactRepository          ContactRepositoryInterface
+	DeviceRepository           DeviceRepositoryInterface
+	DigestRepository           DigestRepositoryInterface
 }
 
 func NewRepositories(clients *clients.Clients) *Repositories {
 	return &Repositories{
-		UserRepository:         user.NewRepository(clients.Postgres),
-		ConversationRepository: conversation.NewRepository(clients.Postgres),
-		MessageRepository:      message.NewRepository(clients.Postgres),
+		UserRepository:             user.NewRepository(clients.Postgres),
+		ConversationRepository:     conversation.NewRepository(clients.Postgres),
+		MessageRepository:          message.NewRepository(clients.Postgres),
+		MentionRepository:          mention.NewRepository(clients.Postgres),
+		PinRepository:              pin.NewRepository(clients.Postgres),
+		ScheduledMessageRepository: scheduled.NewRepository(clients.Postgres),
+		JobRepository:              job.NewRepository(clients.Postgres),
+		RetentionRepository:        retention.NewRepository(clients.Postgres),
+		ExportRepository:           export.NewRepository(clients.Postgres),
+		ImportRepository:           imports.NewRepository(clients.Postgres),
+		SessionRepository:          session.NewRepository(clients.Postgres),
+		LoginAttemptRepository:     loginattempt.NewRepository(clients.Postgres),
+		UserTokenRepository:        usertoken.NewRepository(clients.Postgres),
+		MFARecoveryCodeRepository:  mfarecovery.NewRepository(clients.Postgres),
+		IdentityRepository:         identity.NewRepository(clients.Postgres),
+		APIKeyRepository:           apikey.NewRepository(clients.Postgres),
+		WebhookRepository:          webhook.NewRepository(clients.Postgres),
+		IncomingWebhookRepository:  webhook.NewIncomingRepository(clients.Postgres),
+		BotCommandRepository:       botcommand.NewRepository(clients.Postgres),
+		BlockRepository:            block.NewRepository(clients.Postgres),
+		ContactRepository:          contact.NewRepository(clients.Postgres),
+		DeviceRepository:           device.NewRepository(clients.Postgres),
+		DigestRepository:           digest.NewRepository(clients.Postgres),
 	}
 }
@@ -0,0 +1,135 @@
+package session
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/users"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, s *users.Session) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (user_id, refresh_token_hash, user_agent, ip, created_at, last_used_at, expires_at)
+	VALUES ($1, $2, $3, $4, NOW(), NOW(), $5)
+	RETURNING id, created_at, last_used_at`, constants.SessionTable)
+
+	return r.db.QueryRowContext(ctx, query, s.UserID, s.RefreshTokenHash, s.UserAgent, s.IP, s.ExpiresAt).
+		Scan(&s.ID, &s.CreatedAt, &s.LastUsedAt)
+}
+
+func (r *Repository) GetSessionById(ctx context.Context, id int64) (*users.Session, error) {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1 LIMIT 1`, constants.SessionTable)
+	return r.getOne(ctx, query, id)
+}
+
+func (r *Repository) GetSessionByRefreshTokenHash(ctx context.Context, hash string) (*users.Session, error) {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE refresh_token_hash = $1 LIMIT 1`, constants.SessionTable)
+	return r.getOne(ctx, query, hash)
+}
+
+func (r *Repository) GetSessionByPreviousTokenHash(ctx context.Context, hash string) (*users.Session, error) {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE previous_token_hash = $1 LIMIT 1`, constants.SessionTable)
+	return r.getOne(ctx, query, hash)
+}
+
+// Rotate swaps the refresh token of an active session.
+// It returns false when the old hash is no longer current, e.g. a concurrent refresh already won.
+func (r *Repository) Rotate(ctx context.Context, id int64, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET refresh_token_hash = $1, previous_token_hash = $2, expires_at = $3, last_used_at = NOW()
+	WHERE id = $4 AND refresh_token_hash = $2 AND revoked_at IS NULL`, constants.SessionTable)
+
+	res, err := r.db.ExecContext(ctx, query, newHash, oldHash, expiresAt, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetUserSessions returns all sessions of the user, revoked and expired ones included.
+func (r *Repository) GetUserSessions(ctx context.Context, usrId int64) ([]*users.Session, error) {
+	var sessions []*users.Session
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE user_id = $1 ORDER BY id`, constants.SessionTable)
+
+	if err := r.db.SelectContext(ctx, &sessions, query, usrId); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *Repository) GetActiveSessions(ctx context.Context, usrId int64) ([]*users.Session, error) {
+	var sessions []*users.Session
+	query := fmt.Sprintf(`
+	SELECT * FROM %s
+	WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	ORDER BY last_used_at DESC`, constants.SessionTable)
+
+	if err := r.db.SelectContext(ctx, &sessions, query, usrId); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *Repository) Revoke(ctx context.Context, usrId, id int64) (bool, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET revoked_at = NOW()
+	WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, constants.SessionTable)
+
+	res, err := r.db.ExecContext(ctx, query, id, usrId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *Repository) RevokeAll(ctx context.Context, usrId int64) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET revoked_at = NOW()
+	WHERE user_id = $1 AND revoked_at IS NULL`, constants.SessionTable)
+
+	_, err := r.db.ExecContext(ctx, query, usrId)
+	return err
+}
+
+// RevokeOthers signs the user out of every session except the current one.
+func (r *Repository) RevokeOthers(ctx context.Context, usrId, keepId int64) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET revoked_at = NOW()
+	WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL`, constants.SessionTable)
+
+	_, err := r.db.ExecContext(ctx, query, usrId, keepId)
+	return err
+}
+
+func (r *Repository) getOne(ctx context.Context, query string, args ...any) (*users.Session, error) {
+	s := &users.Session{}
+	if err := r.db.GetContext(ctx, s, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return s, nil
+}
@@ -8,6 +8,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -75,3 +76,430 @@ func (r *Repository) GetUserById(ctx context.Context, id int64) (*users.User, er
 
 	return user, nil
 }
+
+// RegisterFailedLogin increments failed sign in counter and locks the account once it reaches the threshold.
+func (r *Repository) RegisterFailedLogin(ctx context.Context, id int64, threshold int, lockFor time.Duration) (*time.Time, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET
+		failed_login_attempts = failed_login_attempts + 1,
+		locked_until = CASE WHEN failed_login_attempts + 1 >= $2 THEN NOW() + make_interval(secs => $3) ELSE locked_until END
+	WHERE id = $1
+	RETURNING locked_until`, constants.UserTable)
+
+	var lockedUntil *time.Time
+	if err := r.db.GetContext(ctx, &lockedUntil, query, id, threshold, lockFor.Seconds()); err != nil {
+		return nil, err
+	}
+	return lockedUntil, nil
+}
+
+func (r *Repository) ResetFailedLogins(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET failed_login_attempts = 0, locked_until = NULL
+	WHERE id = $1 AND (failed_login_attempts > 0 OR locked_until IS NOT NULL)`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *Repository) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	query := fmt.Sprintf(`UPDATE %s SET password = $1 WHERE id = $2`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, hash, id)
+	return err
+}
+
+func (r *Repository) MarkEmailVerified(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`UPDATE %s SET email_verified_at = NOW() WHERE id = $1 AND email_verified_at IS NULL`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// SetTOTPSecret stores a pending secret, 2FA stays disabled until EnableTOTP.
+func (r *Repository) SetTOTPSecret(ctx context.Context, id int64, secret string) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET totp_secret = $1, totp_enabled_at = NULL, totp_last_step = 0
+	WHERE id = $2`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, secret, id)
+	return err
+}
+
+func (r *Repository) EnableTOTP(ctx context.Context, id, step int64) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET totp_enabled_at = NOW(), totp_last_step = $1
+	WHERE id = $2 AND totp_secret IS NOT NULL`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, step, id)
+	return err
+}
+
+func (r *Repository) DisableTOTP(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0
+	WHERE id = $1`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// UseTOTPStep stores the accepted time step, false means the code was already used.
+func (r *Repository) UseTOTPStep(ctx context.Context, id, step int64) (bool, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET totp_last_step = $1
+	WHERE id = $2 AND totp_last_step < $1`, constants.UserTable)
+
+	res, err := r.db.ExecContext(ctx, query, step, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// CreateBot stores the bot within the transaction q, or directly when q is nil.
+func (r *Repository) CreateBot(ctx context.Context, q sqlx.QueryerContext, dto *user_dto.CreateBotDTO) (*users.User, error) {
+	if q == nil {
+		q = r.db
+	}
+	user := &users.User{
+		Username:  dto.Name,
+		Email:     dto.Email,
+		Password:  dto.Password,
+		CreatedAt: time.Now(),
+		IsBot:     true,
+		OwnerID:   &dto.OwnerID,
+	}
+
+	query, args, err := r.db.BindNamed(fmt.Sprintf(`
+	INSERT INTO %s (user_name, email, password, created_at, email_verified_at, is_bot, owner_id)
+	VALUES (:user_name, :email, :password, :created_at, :created_at, :is_bot, :owner_id) RETURNING id`, constants.UserTable), user)
+	if err != nil {
+		return nil, err
+	}
+	if err = sqlx.GetContext(ctx, q, &user.ID, query, args...); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrUserAlreadyExists
+		}
+		return nil, err
+	}
+	user.EmailVerifiedAt = &user.CreatedAt
+
+	return user, nil
+}
+
+// CreateImported creates a placeholder account for an imported user, an existing account with the email is returned instead.
+func (r *Repository) CreateImported(ctx context.Context, dto *user_dto.CreateImportedUserDTO) (*users.User, bool, error) {
+	user := &users.User{
+		Username:  dto.Name,
+		Email:     dto.Email,
+		Password:  dto.Password,
+		CreatedAt: time.Now(),
+		IsBot:     dto.IsBot,
+	}
+
+	query, args, err := r.db.BindNamed(fmt.Sprintf(`
+	INSERT INTO %s (user_name, email, password, created_at, is_bot)
+	VALUES (:user_name, :email, :password, :created_at, :is_bot)
+	ON CONFLICT (email) DO NOTHING RETURNING id`, constants.UserTable), user)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = r.db.GetContext(ctx, &user.ID, query, args...); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, false, err
+		}
+		existing, err := r.GetUserByEmail(ctx, dto.Email)
+		return existing, false, err
+	}
+	return user, true, nil
+}
+
+func (r *Repository) GetBots(ctx context.Context, ownerId int64) ([]*users.User, error) {
+	var res []*users.User
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE owner_id = $1 AND is_bot ORDER BY id`, constants.UserTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, ownerId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DeleteBot removes the bot, its messages are kept and moved to the deleted user placeholder.
+func (r *Repository) DeleteBot(ctx context.Context, ownerId, botId int64) (deleted bool, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	bot := fmt.Sprintf(`SELECT id FROM %s WHERE id = $1 AND owner_id = $2 AND is_bot`, constants.UserTable)
+	if err = reassignMessages(ctx, tx, bot, botId, ownerId); err != nil {
+		return false, err
+	}
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1 AND owner_id = $2 AND is_bot`, constants.UserTable)
+	res, err := tx.ExecContext(ctx, query, botId, ownerId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// UpdateProfile saves the editable profile fields of the user.
+func (r *Repository) UpdateProfile(ctx context.Context, usr *users.User) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET display_name = $1, bio = $2, status_text = $3, timezone = $4
+	WHERE id = $5`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, usr.DisplayName, usr.Bio, usr.Status, usr.Timezone, usr.ID)
+	return err
+}
+
+func (r *Repository) SetAvatarURL(ctx context.Context, id int64, url *string) error {
+	query := fmt.Sprintf(`UPDATE %s SET avatar_url = $1 WHERE id = $2`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, url, id)
+	return err
+}
+
+func (r *Repository) SetPendingEmail(ctx context.Context, id int64, email *string) error {
+	query := fmt.Sprintf(`UPDATE %s SET pending_email = $1 WHERE id = $2`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, email, id)
+	return err
+}
+
+// UpdatePrivacy saves the privacy settings of the user.
+func (r *Repository) UpdatePrivacy(ctx context.Context, usr *users.User) error {
+	query := fmt.Sprintf(`UPDATE %s SET discoverability = $1, dm_policy = $2 WHERE id = $3`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, usr.Discoverability, usr.DMPolicy, usr.ID)
+	return err
+}
+
+func (r *Repository) UpdateNotificationSettings(ctx context.Context, usr *users.User) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET push_level = $1, digest_frequency = $2, quiet_hours_start = $3, quiet_hours_end = $4
+	WHERE id = $5`, constants.UserTable)
+
+	_, err := r.db.ExecContext(ctx, query, usr.PushLevel, usr.DigestFrequency, usr.QuietHoursStart, usr.QuietHoursEnd, usr.ID)
+	return err
+}
+
+func (r *Repository) GetUsersByIds(ctx context.Context, ids []int64) ([]*users.User, error) {
+	var res []*users.User
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = ANY($1)`, constants.UserTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SearchUsers returns discoverable users whose user name or display name starts with the prefix,
+// ordered by id after lastId. Bots and deleted accounts are never listed.
+func (r *Repository) SearchUsers(ctx context.Context, prefix string, lastId *int64, limit int) ([]*users.User, error) {
+	var res []*users.User
+	query := fmt.Sprintf(`
+	SELECT * FROM %s
+	WHERE discoverability = $1 AND NOT is_bot AND deleted_at IS NULL
+		AND (lower(user_name) LIKE $2 OR lower(display_name) LIKE $2)
+		AND ($3::BIGINT IS NULL OR id > $3)
+	ORDER BY id
+	LIMIT $4`, constants.UserTable)
+
+	pattern := likeEscaper.Replace(strings.ToLower(prefix)) + "%"
+	if err := r.db.SelectContext(ctx, &res, query, users.DiscoverableEveryone, pattern, lastId, limit); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// likeEscaper makes wildcards in user input match literally, backslash is the default LIKE escape
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ConfirmPendingEmail replaces the email with the confirmed pending one, nil is returned when there is none.
+func (r *Repository) ConfirmPendingEmail(ctx context.Context, id int64) (*users.User, error) {
+	user := &users.User{}
+	query := fmt.Sprintf(`
+	UPDATE %s SET email = pending_email, pending_email = NULL, email_verified_at = NOW()
+	WHERE id = $1 AND pending_email IS NOT NULL
+	RETURNING *`, constants.UserTable)
+
+	if err := r.db.GetContext(ctx, user, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrUserAlreadyExists
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// Anonymize scrubs the personal data of the account and signs it out everywhere. The row is kept,
+// so messages of the account stay attributed to a distinct "deleted user". Integrations set up by the
+// user are removed and their bots disabled the same way, nothing keeps acting on the user's behalf.
+func (r *Repository) Anonymize(ctx context.Context, usr *users.User, password string) (res *users.DeletedIntegrations, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	query := fmt.Sprintf(`
+	UPDATE %s SET user_name = $1, email = $2, password = $3, email_verified_at = NULL,
+		totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0,
+		failed_login_attempts = 0, locked_until = NULL, is_admin = FALSE, deleted_at = NOW(),
+		display_name = NULL, bio = '', status_text = '', timezone = '', avatar_url = NULL, pending_email = NULL
+	WHERE id = $4 AND deleted_at IS NULL`, constants.UserTable)
+	if _, err = tx.ExecContext(ctx, query, users.DeletedUserName, users.AnonymizedEmail(usr.ID), password, usr.ID); err != nil {
+		return nil, err
+	}
+	if res, err = deleteIntegrations(ctx, tx, usr.ID); err != nil {
+		return nil, err
+	}
+
+	// bots keep their rows like the owner, so their messages stay attributed
+	bots := fmt.Sprintf(`SELECT id FROM %s WHERE owner_id = $1`, constants.UserTable)
+	query = fmt.Sprintf(`UPDATE %s SET deleted_at = NOW() WHERE owner_id = $1 AND deleted_at IS NULL`, constants.UserTable)
+	if res.Bots, err = execCount(ctx, tx, query, usr.ID); err != nil {
+		return nil, err
+	}
+
+	for _, q := range []string{
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, constants.SessionTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, constants.UserTokenTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, constants.MFARecoveryCodeTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, constants.UserIdentityTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 OR created_by = $1 OR user_id IN (%s)`, constants.APIKeyTable, bots),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 OR user_id IN (%s)`, constants.ConversationParticipantTable, bots),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, constants.MessageMentionTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE sender_id = $1`, constants.ScheduledMessageTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE requested_by = $1`, constants.ExportTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE blocker_id = $1 OR blocked_id = $1`, constants.UserBlockTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE sender_id = $1 OR recipient_id = $1`, constants.ContactRequestTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 OR contact_id = $1`, constants.ContactTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, constants.DeviceTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, constants.EmailDigestTable),
+	} {
+		if _, err = tx.ExecContext(ctx, q, usr.ID); err != nil {
+			return nil, err
+		}
+	}
+	return res, deleteLoginAttempts(ctx, tx, usr)
+}
+
+// DeleteAccount deletes the account together with its bots and integrations. Their messages are kept
+// and moved to the deleted user placeholder.
+func (r *Repository) DeleteAccount(ctx context.Context, usr *users.User) (res *users.DeletedIntegrations, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if err = reassignMessages(ctx, tx, fmt.Sprintf(`SELECT id FROM %s WHERE id = $1 OR owner_id = $1`, constants.UserTable), usr.ID); err != nil {
+		return nil, err
+	}
+	if err = deleteLoginAttempts(ctx, tx, usr); err != nil {
+		return nil, err
+	}
+	// the foreign keys cascade too, the explicit deletes count what goes for the audit
+	if res, err = deleteIntegrations(ctx, tx, usr.ID); err != nil {
+		return nil, err
+	}
+	if res.Bots, err = execCount(ctx, tx, fmt.Sprintf(`DELETE FROM %s WHERE owner_id = $1`, constants.UserTable), usr.ID); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, constants.UserTable), usr.ID)
+	return res, err
+}
+
+// deleteIntegrations removes the outgoing and incoming webhooks and the commands the user set up.
+func deleteIntegrations(ctx context.Context, tx *sqlx.Tx, usrId int64) (*users.DeletedIntegrations, error) {
+	res := &users.DeletedIntegrations{}
+	for _, d := range []struct {
+		table string
+		count *int64
+	}{
+		{constants.WebhookTable, &res.Webhooks},
+		{constants.IncomingWebhookTable, &res.IncomingWebhooks},
+		{constants.BotCommandTable, &res.Commands},
+	} {
+		n, err := execCount(ctx, tx, fmt.Sprintf(`DELETE FROM %s WHERE created_by = $1`, d.table), usrId)
+		if err != nil {
+			return nil, err
+		}
+		*d.count = n
+	}
+	return res, nil
+}
+
+func execCount(ctx context.Context, tx *sqlx.Tx, query string, args ...any) (int64, error) {
+	r, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected()
+}
+
+// reassignMessages moves messages, archived ones included, of the senders selected by the query to the deleted user placeholder.
+func reassignMessages(ctx context.Context, tx *sqlx.Tx, senders string, args ...any) error {
+	for _, table := range []string{constants.MessageTable, constants.MessageArchiveTable} {
+		query := fmt.Sprintf(`
+		UPDATE %s SET sender_id = (SELECT id FROM %s WHERE email = '%s')
+		WHERE sender_id IN (%s)`, table, constants.UserTable, users.DeletedUserEmail, senders)
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteLoginAttempts removes the sign in history, attempts before the account existed are matched by email.
+func deleteLoginAttempts(ctx context.Context, tx *sqlx.Tx, usr *users.User) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 OR email = $2`, constants.LoginAttemptTable)
+	_, err := tx.ExecContext(ctx, query, usr.ID, usr.Email)
+	return err
+}
+
+// GetUsersByUsername returns up to limit users with the name, user names are not unique.
+func (r *Repository) GetUsersByUsername(ctx context.Context, name string, limit int) ([]*users.User, error) {
+	var res []*users.User
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE user_name = $1 ORDER BY id LIMIT $2`, constants.UserTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, name, limit); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,58 @@
+package usertoken
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/users"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, t *users.Token) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (user_id, purpose, token_hash, created_at, expires_at)
+	VALUES ($1, $2, $3, NOW(), $4)
+	RETURNING id, created_at`, constants.UserTokenTable)
+
+	return r.db.QueryRowContext(ctx, query, t.UserID, t.Purpose, t.TokenHash, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+}
+
+// Consume marks an unused and not expired token as used and returns it.
+// Nil is returned when there is no such token, so every token works only once.
+func (r *Repository) Consume(ctx context.Context, hash string, purpose users.TokenPurpose) (*users.Token, error) {
+	query := fmt.Sprintf(`
+	UPDATE %s SET used_at = NOW()
+	WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+	RETURNING *`, constants.UserTokenTable)
+
+	t := &users.Token{}
+	if err := r.db.GetContext(ctx, t, query, hash, purpose); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// Invalidate expires all unused tokens of the user with the purpose.
+func (r *Repository) Invalidate(ctx context.Context, usrId int64, purpose users.TokenPurpose) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET used_at = NOW()
+	WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`, constants.UserTokenTable)
+
+	_, err := r.db.ExecContext(ctx, query, usrId, purpose)
+	return err
+}
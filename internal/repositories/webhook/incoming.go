@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/integrations"
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IncomingRepository struct {
+	db *sqlx.DB
+}
+
+func NewIncomingRepository(db *sqlx.DB) *IncomingRepository {
+	return &IncomingRepository{
+		db: db,
+	}
+}
+
+func (r *IncomingRepository) Create(ctx context.Context, tx *sqlx.Tx, w *integrations.IncomingWebhook) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (conversation_id, created_by, bot_user_id, name, token_hash, rate_limit_per_minute, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	RETURNING id, created_at`, constants.IncomingWebhookTable)
+
+	return tx.QueryRowContext(ctx, query, w.ConversationID, w.CreatedBy, w.BotUserID, w.Name, w.TokenHash, w.RateLimitPerMinute).
+		Scan(&w.ID, &w.CreatedAt)
+}
+
+func (r *IncomingRepository) GetWebhookByTokenHash(ctx context.Context, hash string) (*integrations.IncomingWebhook, error) {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE token_hash = $1 LIMIT 1`, constants.IncomingWebhookTable)
+
+	w := &integrations.IncomingWebhook{}
+	if err := r.db.GetContext(ctx, w, query, hash); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *IncomingRepository) GetConversationWebhooks(ctx context.Context, cnvId int64) ([]*integrations.IncomingWebhook, error) {
+	var res []*integrations.IncomingWebhook
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE conversation_id = $1 ORDER BY id`, constants.IncomingWebhookTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, cnvId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *IncomingRepository) Delete(ctx context.Context, cnvId, id int64) (bool, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1 AND conversation_id = $2`, constants.IncomingWebhookTable)
+
+	res, err := r.db.ExecContext(ctx, query, id, cnvId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
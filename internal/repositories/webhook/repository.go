@@ -0,0 +1,175 @@
+package webhook
+
+import (
+	"chatapp/internal/constants"
+	"chatapp/internal/entities/integrations"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, w *integrations.Webhook) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (conversation_id, created_by, url, secret, events, is_active, created_at)
+	VALUES ($1, $2, $3, $4, $5, TRUE, NOW())
+	RETURNING id, is_active, created_at`, constants.WebhookTable)
+
+	return r.db.QueryRowContext(ctx, query, w.ConversationID, w.CreatedBy, w.URL, w.Secret, w.Events).
+		Scan(&w.ID, &w.IsActive, &w.CreatedAt)
+}
+
+func (r *Repository) GetWebhookById(ctx context.Context, id int64) (*integrations.Webhook, error) {
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE id = $1 LIMIT 1`, constants.WebhookTable)
+
+	w := &integrations.Webhook{}
+	if err := r.db.GetContext(ctx, w, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *Repository) GetConversationWebhooks(ctx context.Context, cnvId int64) ([]*integrations.Webhook, error) {
+	var res []*integrations.Webhook
+	query := fmt.Sprintf(`SELECT * FROM %s WHERE conversation_id = $1 ORDER BY id`, constants.WebhookTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, cnvId); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) Delete(ctx context.Context, cnvId, id int64) (bool, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1 AND conversation_id = $2`, constants.WebhookTable)
+
+	res, err := r.db.ExecContext(ctx, query, id, cnvId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *Repository) CreateDelivery(ctx context.Context, d *integrations.WebhookDelivery) error {
+	query := fmt.Sprintf(`
+	INSERT INTO %s (webhook_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+	VALUES ($1, $2, $3, $4, 0, NOW(), NOW())
+	RETURNING id, next_attempt_at, created_at`, constants.WebhookDeliveryTable)
+
+	return r.db.QueryRowContext(ctx, query, d.WebhookID, d.EventType, string(d.Payload), integrations.DeliveryStatusPending).
+		Scan(&d.ID, &d.NextAttemptAt, &d.CreatedAt)
+}
+
+// ClaimDueDeliveries leases pending deliveries which are due by pushing their next attempt forward,
+// so concurrent workers don't pick the same rows. A crashed worker's deliveries become due again after the lease.
+func (r *Repository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*integrations.WebhookDelivery, error) {
+	var res []*integrations.WebhookDelivery
+	query := fmt.Sprintf(`
+	UPDATE %[1]s SET next_attempt_at = NOW() + make_interval(secs => $3)
+	WHERE id IN (
+		SELECT id FROM %[1]s
+		WHERE status = $1 AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING *`, constants.WebhookDeliveryTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, integrations.DeliveryStatusPending, limit, lease.Seconds()); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) MarkDelivered(ctx context.Context, d *integrations.WebhookDelivery) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, attempts = $2, response_status = $3, last_error = NULL, delivered_at = NOW()
+	WHERE id = $4`, constants.WebhookDeliveryTable)
+
+	_, err := r.db.ExecContext(ctx, query, integrations.DeliveryStatusSucceeded, d.Attempts, d.ResponseStatus, d.ID)
+	return err
+}
+
+func (r *Repository) ScheduleRetry(ctx context.Context, d *integrations.WebhookDelivery) error {
+	query := fmt.Sprintf(`
+	UPDATE %s SET attempts = $1, next_attempt_at = $2, response_status = $3, last_error = $4
+	WHERE id = $5`, constants.WebhookDeliveryTable)
+
+	_, err := r.db.ExecContext(ctx, query, d.Attempts, d.NextAttemptAt, d.ResponseStatus, d.LastError, d.ID)
+	return err
+}
+
+// MoveToDeadLetter gives up on the delivery and keeps a copy in the dead-letter table.
+func (r *Repository) MoveToDeadLetter(ctx context.Context, d *integrations.WebhookDelivery) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	query := fmt.Sprintf(`
+	UPDATE %s SET status = $1, attempts = $2, response_status = $3, last_error = $4
+	WHERE id = $5`, constants.WebhookDeliveryTable)
+	if _, err = tx.ExecContext(ctx, query, integrations.DeliveryStatusDead, d.Attempts, d.ResponseStatus, d.LastError, d.ID); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`
+	INSERT INTO %s (delivery_id, webhook_id, event_type, payload, attempts, response_status, last_error, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())`, constants.WebhookDeadLetterTable)
+	_, err = tx.ExecContext(ctx, query, d.ID, d.WebhookID, d.EventType, string(d.Payload), d.Attempts, d.ResponseStatus, d.LastError)
+	return err
+}
+
+// GetDeliveries returns the delivery log of a webhook, newest first.
+func (r *Repository) GetDeliveries(ctx context.Context, webhookId int64, lastId *int64, limit int) ([]*integrations.WebhookDelivery, error) {
+	var res []*integrations.WebhookDelivery
+	query := fmt.Sprintf(`
+	SELECT * FROM %s
+	WHERE webhook_id = $1 AND ($2::BIGINT IS NULL OR id < $2)
+	ORDER BY id DESC
+	LIMIT $3`, constants.WebhookDeliveryTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, webhookId, lastId, limit); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Repository) GetDeadLetters(ctx context.Context, webhookId int64, limit int) ([]*integrations.WebhookDeadLetter, error) {
+	var res []*integrations.WebhookDeadLetter
+	query := fmt.Sprintf(`
+	SELECT * FROM %s
+	WHERE webhook_id = $1
+	ORDER BY id DESC
+	LIMIT $2`, constants.WebhookDeadLetterTable)
+
+	if err := r.db.SelectContext(ctx, &res, query, webhookId, limit); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,218 @@
+package account
+
+import (
+	"archive/zip"
+	"chatapp/internal/config"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"chatapp/internal/services/avatar"
+	"chatapp/internal/services/hash"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+const (
+	PolicyAnonymize = "anonymize"
+	PolicyDelete    = "delete"
+
+	// unusablePassword is no valid hash, anonymized accounts can't sign in with any password
+	unusablePassword = "!"
+)
+
+var (
+	ErrInvalidPassword = errors.New("invalid password")
+	ErrAccountDeleted  = errors.New("account is deleted")
+)
+
+// Service serves data subject requests: exporting everything tied to an account and deleting it.
+// Deletion never removes messages, they stay in other people's conversation history
+// attributed to a deleted user.
+type Service struct {
+	cfg         *config.Config
+	logger      logger.Logger
+	repos       *repositories.Repositories
+	hashService *hash.Service
+	avatars     *avatar.Store
+}
+
+func NewService(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories) *Service {
+	return &Service{
+		cfg:         cfg,
+		logger:      logger,
+		repos:       repos,
+		hashService: hash.NewService(cfg),
+		avatars:     avatar.NewStore(cfg),
+	}
+}
+
+// WriteExport writes a zip archive with a JSON file for each kind of data held about the user.
+// Messages are read in batches so large histories are not loaded at once.
+func (s *Service) WriteExport(ctx context.Context, usrId int64, w io.Writer) (err error) {
+	usr, err := s.repos.UserRepository.GetUserById(ctx, usrId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if usr == nil || usr.IsDeleted() {
+		return ErrAccountDeleted
+	}
+
+	zw := zip.NewWriter(w)
+	defer func() {
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	if err = writeJSON(zw, "profile.json", usr); err != nil {
+		return err
+	}
+	identities, err := s.repos.IdentityRepository.GetUserIdentities(ctx, usrId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(zw, "identities.json", identities); err != nil {
+		return err
+	}
+	sessions, err := s.repos.SessionRepository.GetUserSessions(ctx, usrId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(zw, "sessions.json", sessions); err != nil {
+		return err
+	}
+	keys, err := s.repos.APIKeyRepository.GetKeysCreatedBy(ctx, usrId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(zw, "api_keys.json", keys); err != nil {
+		return err
+	}
+	bots, err := s.repos.UserRepository.GetBots(ctx, usrId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(zw, "bots.json", bots); err != nil {
+		return err
+	}
+	blocks, err := s.repos.BlockRepository.GetBlocks(ctx, usrId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(zw, "blocks.json", blocks); err != nil {
+		return err
+	}
+	contacts, err := s.repos.ContactRepository.GetContacts(ctx, usrId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(zw, "contacts.json", contacts); err != nil {
+		return err
+	}
+	devices, err := s.repos.DeviceRepository.GetUserDevices(ctx, usrId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(zw, "devices.json", devices); err != nil {
+		return err
+	}
+	memberships, err := s.repos.ConversationRepository.GetMemberships(ctx, usrId)
+	if err != nil {
+		return err
+	}
+	if err = writeJSON(zw, "conversations.json", memberships); err != nil {
+		return err
+	}
+	return s.writeMessages(ctx, zw, usrId)
+}
+
+func (s *Service) writeMessages(ctx context.Context, zw *zip.Writer, usrId int64) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "messages.json", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "["); err != nil {
+		return err
+	}
+	var lastId int64
+	first := true
+	for {
+		msgs, err := s.repos.MessageRepository.GetSentMessages(ctx, usrId, lastId, s.cfg.ExportBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if !first {
+				if _, err := io.WriteString(f, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			b, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write(b); err != nil {
+				return err
+			}
+			lastId = m.ID
+		}
+		if len(msgs) < s.cfg.ExportBatchSize {
+			break
+		}
+	}
+	_, err = io.WriteString(f, "]")
+	return err
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// DeleteAccount deletes the account per the configured policy after the password is confirmed.
+// Either way the user is signed out everywhere, their webhooks, commands and bots stop working
+// and their messages stay with a deleted user as sender.
+func (s *Service) DeleteAccount(ctx context.Context, usr *users.User, password string) error {
+	if usr.IsDeleted() {
+		return ErrAccountDeleted
+	}
+	ok, err := s.hashService.CompareHashWithPassword(usr.Password, password)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to check password: %w", err), slog.Int64("userId", usr.ID))
+		return fmt.Errorf("failed to check password: %w", err)
+	}
+	if !ok {
+		return ErrInvalidPassword
+	}
+
+	var removed *users.DeletedIntegrations
+	if s.cfg.AccountDeletionPolicy == PolicyDelete {
+		removed, err = s.repos.UserRepository.DeleteAccount(ctx, usr)
+	} else {
+		removed, err = s.repos.UserRepository.Anonymize(ctx, usr, unusablePassword)
+	}
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to delete account: %w", err),
+			slog.Int64("userId", usr.ID), slog.String("policy", s.cfg.AccountDeletionPolicy))
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+	if usr.AvatarURL != nil {
+		if err := s.avatars.Remove(*usr.AvatarURL); err != nil {
+			s.logger.Error(ctx, fmt.Errorf("failed to delete avatar file: %w", err), slog.Int64("userId", usr.ID))
+		}
+	}
+	s.logger.Info(ctx, "account deleted", slog.Int64("userId", usr.ID), slog.String("policy", s.cfg.AccountDeletionPolicy),
+		slog.Any("integrations", removed))
+	return nil
+}
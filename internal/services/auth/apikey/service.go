@@ -0,0 +1,233 @@
+package apikey
+
+import (
+	"chatapp/internal/config"
+	user_dto "chatapp/internal/dto/user"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"chatapp/internal/services/hash"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	keyPrefix = "cak_"
+	// lastUsedPrecision avoids a write on every request made with the same key
+	lastUsedPrecision = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrUnknownScope   = errors.New("unknown api key scope")
+	ErrBotNotFound    = errors.New("bot not found")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+// Service manages personal API keys and bot accounts.
+// A key is "cak_<prefix>_<secret>", the prefix is stored in plain text to find the key, the secret only as a hash.
+type Service struct {
+	cfg         *config.Config
+	logger      logger.Logger
+	repos       *repositories.Repositories
+	hashService *hash.Service
+}
+
+func NewService(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories) *Service {
+	return &Service{
+		cfg:         cfg,
+		logger:      logger,
+		repos:       repos,
+		hashService: hash.NewService(cfg),
+	}
+}
+
+// CreateAPIKey issues a key for the user or one of the user's bots. The plain key is returned only here.
+func (s *Service) CreateAPIKey(ctx context.Context, usr *users.User, dto *user_dto.CreateAPIKeyDTO) (*users.APIKey, string, error) {
+	for _, scope := range dto.Scopes {
+		if !slices.Contains(users.APIKeyScopes, scope) {
+			return nil, "", fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+	}
+
+	subjectId := usr.ID
+	if dto.BotID != nil {
+		bot, err := s.getOwnedBot(ctx, usr.ID, *dto.BotID)
+		if err != nil {
+			return nil, "", err
+		}
+		subjectId = bot.ID
+	}
+
+	prefix, err := randomHex(4)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := s.hashService.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &users.APIKey{
+		UserID:          subjectId,
+		CreatedBy:       usr.ID,
+		Name:            dto.Name,
+		Prefix:          prefix,
+		SecretHash:      s.hashService.HashToken(secret),
+		Scopes:          dto.Scopes,
+		ConversationIDs: dto.ConversationIDs,
+		ExpiresAt:       dto.ExpiresAt,
+	}
+	if key.ConversationIDs == nil {
+		key.ConversationIDs = []int64{}
+	}
+	if err := s.repos.APIKeyRepository.Create(ctx, key); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to create api key: %w", err), slog.Int64("userId", usr.ID))
+		return nil, "", fmt.Errorf("failed to create api key: %w", err)
+	}
+	s.logger.Info(ctx, "api key created", slog.Int64("userId", usr.ID), slog.Int64("keyId", key.ID), slog.Int64("subjectId", subjectId))
+
+	return key, keyPrefix + prefix + "_" + secret, nil
+}
+
+// Authenticate resolves the user a key acts for.
+func (s *Service) Authenticate(ctx context.Context, raw string) (*users.User, *users.APIKey, error) {
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(raw, keyPrefix), "_")
+	if !ok || !strings.HasPrefix(raw, keyPrefix) || prefix == "" || secret == "" {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	key, err := s.repos.APIKeyRepository.GetKeyByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	if key == nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(s.hashService.HashToken(secret))) != 1 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if !key.IsActive(now) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.repos.UserRepository.GetUserById(ctx, key.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	if !canUseKeys(user, now) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	// bot keys stop working with their owner, locking the owner out must lock out the bots too
+	if key.CreatedBy != user.ID {
+		owner, err := s.repos.UserRepository.GetUserById(ctx, key.CreatedBy)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get user by id: %w", err)
+		}
+		if !canUseKeys(owner, now) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedPrecision {
+		if err := s.repos.APIKeyRepository.TouchLastUsed(ctx, key.ID); err != nil {
+			s.logger.Error(ctx, fmt.Errorf("failed to update api key last used: %w", err), slog.Int64("keyId", key.ID))
+		}
+	}
+	return user, key, nil
+}
+
+func canUseKeys(usr *users.User, now time.Time) bool {
+	return usr != nil && !usr.IsDeleted() && !usr.IsLocked(now)
+}
+
+func (s *Service) GetAPIKeys(ctx context.Context, usrId int64) ([]*users.APIKey, error) {
+	return s.repos.APIKeyRepository.GetKeysCreatedBy(ctx, usrId)
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, usrId, keyId int64) error {
+	ok, err := s.repos.APIKeyRepository.Revoke(ctx, usrId, keyId)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	s.logger.Info(ctx, "api key revoked", slog.Int64("userId", usrId), slog.Int64("keyId", keyId))
+	return nil
+}
+
+// CreateBot registers a bot owned by the user within the transaction q, or directly when q is nil.
+// Bots have no usable password and sign in only with API keys.
+func (s *Service) CreateBot(ctx context.Context, q sqlx.QueryerContext, owner *users.User, name string) (*users.User, error) {
+	secret, err := s.hashService.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	pwdHash, err := s.hashService.HashPassword(secret)
+	if err != nil {
+		return nil, err
+	}
+	suffix, err := randomHex(6)
+	if err != nil {
+		return nil, err
+	}
+
+	bot, err := s.repos.UserRepository.CreateBot(ctx, q, &user_dto.CreateBotDTO{
+		OwnerID:  owner.ID,
+		Name:     name,
+		Email:    fmt.Sprintf("bot-%d-%s@bots.invalid", owner.ID, suffix),
+		Password: pwdHash,
+	})
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to create bot: %w", err), slog.Int64("userId", owner.ID))
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+	s.logger.Info(ctx, "bot created", slog.Int64("userId", owner.ID), slog.Int64("botId", bot.ID))
+	return bot, nil
+}
+
+func (s *Service) GetBots(ctx context.Context, ownerId int64) ([]*users.User, error) {
+	return s.repos.UserRepository.GetBots(ctx, ownerId)
+}
+
+// DeleteBot removes the bot together with its keys.
+func (s *Service) DeleteBot(ctx context.Context, ownerId, botId int64) error {
+	ok, err := s.repos.UserRepository.DeleteBot(ctx, ownerId, botId)
+	if err != nil {
+		return fmt.Errorf("failed to delete bot: %w", err)
+	}
+	if !ok {
+		return ErrBotNotFound
+	}
+	s.logger.Info(ctx, "bot deleted", slog.Int64("userId", ownerId), slog.Int64("botId", botId))
+	return nil
+}
+
+func (s *Service) getOwnedBot(ctx context.Context, ownerId, botId int64) (*users.User, error) {
+	bot, err := s.repos.UserRepository.GetUserById(ctx, botId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot: %w", err)
+	}
+	if bot == nil || !bot.IsBot || bot.OwnerID == nil || *bot.OwnerID != ownerId {
+		return nil, ErrBotNotFound
+	}
+	return bot, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
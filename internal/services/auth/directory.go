@@ -0,0 +1,80 @@
+package user
+
+import (
+	user_dto "chatapp/internal/dto/user"
+	"chatapp/internal/entities/users"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+var (
+	ErrUserNotFound           = errors.New("user not found")
+	ErrInvalidDiscoverability = errors.New("discoverability must be everyone or conversations")
+	ErrInvalidDMPolicy        = errors.New("dm policy must be everyone or contacts")
+)
+
+// SearchUsers looks up discoverable users by a user name or display name prefix.
+func (s *Service) SearchUsers(ctx context.Context, prefix string, lastId *int64, limit int) ([]*users.PublicUser, error) {
+	found, err := s.repos.UserRepository.SearchUsers(ctx, strings.TrimSpace(prefix), lastId, limit)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to search users: %w", err), slog.String("prefix", prefix))
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	res := make([]*users.PublicUser, 0, len(found))
+	for _, u := range found {
+		res = append(res, u.Public())
+	}
+	return res, nil
+}
+
+// GetPublicProfile returns the profile of a user as the viewer may see it. Users hidden from the
+// directory are reported as not found unless they share a conversation with the viewer.
+func (s *Service) GetPublicProfile(ctx context.Context, viewer *users.User, id int64) (*users.PublicUser, error) {
+	usr, err := s.repos.UserRepository.GetUserById(ctx, id)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get user: %w", err), slog.Int64("userId", id))
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if usr == nil {
+		return nil, ErrUserNotFound
+	}
+	if usr.ID == viewer.ID || usr.Discoverability == users.DiscoverableEveryone {
+		return usr.Public(), nil
+	}
+
+	shared, err := s.repos.ConversationRepository.SharesConversation(ctx, viewer.ID, usr.ID)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to check shared conversations: %w", err), slog.Int64("userId", id))
+		return nil, fmt.Errorf("failed to check shared conversations: %w", err)
+	}
+	if !shared {
+		return nil, ErrUserNotFound
+	}
+	return usr.Public(), nil
+}
+
+// UpdatePrivacy changes whether the user is listed in the user directory and who can start
+// direct conversations with them.
+func (s *Service) UpdatePrivacy(ctx context.Context, usr *users.User, dto *user_dto.UpdatePrivacyDTO) (*users.User, error) {
+	updated := *usr
+	if dto.Discoverability != nil {
+		if *dto.Discoverability != users.DiscoverableEveryone && *dto.Discoverability != users.DiscoverableConversations {
+			return nil, ErrInvalidDiscoverability
+		}
+		updated.Discoverability = *dto.Discoverability
+	}
+	if dto.DMPolicy != nil {
+		if *dto.DMPolicy != users.DMPolicyEveryone && *dto.DMPolicy != users.DMPolicyContacts {
+			return nil, ErrInvalidDMPolicy
+		}
+		updated.DMPolicy = *dto.DMPolicy
+	}
+	if err := s.repos.UserRepository.UpdatePrivacy(ctx, &updated); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to update privacy settings: %w", err), slog.Int64("userId", usr.ID))
+		return nil, fmt.Errorf("failed to update privacy settings: %w", err)
+	}
+	return &updated, nil
+}
@@ -0,0 +1,143 @@
+package user
+
+import (
+	user_dto "chatapp/internal/dto/user"
+	"chatapp/internal/entities/users"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const maxUsernameLength = 50
+
+var (
+	ErrIdentityEmailMissing  = errors.New("identity provider did not return an email")
+	ErrIdentityEmailConflict = errors.New("an account with this email already exists, sign in with password first")
+)
+
+// LoginWithIdentity signs in a user authenticated by an external provider.
+// Known identities sign in directly. A new identity is linked to the account with the same email
+// only if the provider verified that email, otherwise a new account is created.
+func (s *Service) LoginWithIdentity(ctx context.Context, dto *user_dto.ExternalIdentityDTO) (*LoginResult, error) {
+	user, err := s.resolveIdentity(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsLocked(time.Now()) {
+		return nil, ErrAccountLocked
+	}
+
+	if user.IsTOTPEnabled() {
+		token, err := s.createMFAChallenge(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFAToken: token}, nil
+	}
+	return &LoginResult{User: user}, nil
+}
+
+func (s *Service) resolveIdentity(ctx context.Context, dto *user_dto.ExternalIdentityDTO) (*users.User, error) {
+	identity, err := s.repos.IdentityRepository.GetIdentity(ctx, dto.Provider, dto.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+	if identity != nil {
+		if err := s.repos.IdentityRepository.TouchLogin(ctx, identity.ID, dto.Email); err != nil {
+			s.logger.Error(ctx, fmt.Errorf("failed to update identity: %w", err), slog.Int64("identityId", identity.ID))
+		}
+		user, err := s.repos.UserRepository.GetUserById(ctx, identity.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		if !canSignIn(user) {
+			return nil, ErrUnAutorize
+		}
+		return user, nil
+	}
+
+	if dto.Email == "" {
+		return nil, ErrIdentityEmailMissing
+	}
+	user, err := s.repos.UserRepository.GetUserByEmail(ctx, dto.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user != nil && !canSignIn(user) {
+		return nil, ErrUnAutorize
+	}
+	if user != nil && !dto.EmailVerified {
+		return nil, ErrIdentityEmailConflict
+	}
+	if user == nil {
+		if user, err = s.createExternalUser(ctx, dto); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repos.IdentityRepository.Create(ctx, &users.Identity{
+		UserID:   user.ID,
+		Provider: dto.Provider,
+		Subject:  dto.Subject,
+		Email:    dto.Email,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+	s.logger.Info(ctx, "linked external identity", slog.Int64("userId", user.ID), slog.String("provider", dto.Provider))
+
+	if dto.EmailVerified && !user.IsEmailVerified() {
+		if err := s.repos.UserRepository.MarkEmailVerified(ctx, user.ID); err != nil {
+			s.logger.Error(ctx, fmt.Errorf("failed to mark email verified: %w", err), slog.Int64("userId", user.ID))
+		} else {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+	}
+	return user, nil
+}
+
+// canSignIn rejects bots and deleted accounts the same way password sign in does.
+func canSignIn(user *users.User) bool {
+	return user != nil && !user.IsBot && !user.IsDeleted()
+}
+
+// createExternalUser registers an account without a usable password, the user can set one via password reset.
+func (s *Service) createExternalUser(ctx context.Context, dto *user_dto.ExternalIdentityDTO) (*users.User, error) {
+	secret, err := s.hasService.GenerateToken()
+	if err != nil {
+		return nil, errors.Join(ErrCannotCreateUser, err)
+	}
+	pwdHash, err := s.hasService.HashPassword(secret)
+	if err != nil {
+		return nil, errors.Join(ErrCannotCreateUser, err)
+	}
+
+	user, err := s.repos.UserRepository.Create(ctx, &user_dto.CreateUserDTO{
+		Name:     externalUsername(dto),
+		Email:    dto.Email,
+		Password: pwdHash,
+	})
+	if err != nil {
+		s.logger.Error(ctx, errors.Join(ErrCannotCreateUser, err), slog.String("email", dto.Email))
+		return nil, errors.Join(ErrCannotCreateUser, err)
+	}
+	s.logger.Info(ctx, "created new user from external identity", slog.Int64("userId", user.ID), slog.String("provider", dto.Provider))
+	return user, nil
+}
+
+func externalUsername(dto *user_dto.ExternalIdentityDTO) string {
+	name := dto.PreferredUsername
+	if name == "" {
+		name = dto.Name
+	}
+	if name == "" {
+		name, _, _ = strings.Cut(dto.Email, "@")
+	}
+	if r := []rune(name); len(r) > maxUsernameLength {
+		name = string(r[:maxUsernameLength])
+	}
+	return name
+}
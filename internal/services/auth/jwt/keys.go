@@ -0,0 +1,157 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKeyId       = errors.New("unknown key id")
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+)
+
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// keySet holds the key used to sign new tokens and all keys still accepted for verification.
+// Keys are loaded from a directory of PEM files, the file name without extension is the key id.
+type keySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+	// secret is the legacy HS256 secret, tokens without kid are verified with it.
+	secret []byte
+}
+
+func newKeySet(dir, activeKeyId, secret string) (*keySet, error) {
+	ks := &keySet{
+		keys: make(map[string]*signingKey),
+	}
+	if secret != "" {
+		ks.secret = []byte(secret)
+	}
+	if dir == "" {
+		if ks.secret == nil {
+			return nil, fmt.Errorf("either JWT keys directory or JWT secret must be configured")
+		}
+		return ks, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list JWT keys: %w", err)
+	}
+	for _, f := range files {
+		key, err := loadKey(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWT key %s: %w", f, err)
+		}
+		ks.keys[key.id] = key
+	}
+
+	active, ok := ks.keys[activeKeyId]
+	if !ok {
+		return nil, fmt.Errorf("active JWT key %q: %w", activeKeyId, ErrUnknownKeyId)
+	}
+	if active.private == nil {
+		return nil, fmt.Errorf("active JWT key %q has no private key", activeKeyId)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+func (ks *keySet) sign(claims jwt.Claims) (string, error) {
+	if ks.active == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.secret)
+	}
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.id
+	return token.SignedString(ks.active.private)
+}
+
+func (ks *keySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if ks.secret == nil || token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("token has no key id")
+		}
+		return ks.secret, nil
+	}
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyId, kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), kid)
+	}
+	return key.public, nil
+}
+
+func (ks *keySet) validMethods() []string {
+	methods := []string{}
+	if ks.secret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	for _, k := range ks.keys {
+		methods = append(methods, k.method.Alg())
+	}
+	return methods
+}
+
+func loadKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	key := &signingKey{
+		id: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKeyType, parsed)
+	}
+	return key, nil
+}
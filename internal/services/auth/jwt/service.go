@@ -4,6 +4,8 @@ import (
 	"chatapp/internal/config"
 	"chatapp/internal/logger"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -12,42 +14,71 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-const tokenTTL = 5 * time.Hour
-
 type Service struct {
 	cfg    *config.Config
 	logger logger.Logger
+	keys   *keySet
 }
 
-func NewService(cfg *config.Config, logger logger.Logger) *Service {
+func NewService(cfg *config.Config, logger logger.Logger) (*Service, error) {
+	keys, err := newKeySet(cfg.JWTKeysDir, cfg.JWTActiveKeyID, cfg.JWTSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWT keys: %w", err)
+	}
 	return &Service{
 		cfg:    cfg,
 		logger: logger,
-	}
+		keys:   keys,
+	}, nil
+}
+
+type authClaims struct {
+	jwt.RegisteredClaims
+	SessionID int64 `json:"sid"`
 }
 
-func (s *Service) CreateToken(ctx context.Context, userId int64) (string, error) {
+// TokenClaims holds verified data of an access token.
+type TokenClaims struct {
+	UserID    int64
+	SessionID int64
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+func (s *Service) CreateToken(ctx context.Context, userId, sessionId int64) (string, time.Time, error) {
 	now := time.Now()
-	claims := jwt.RegisteredClaims{
-		Subject:   strconv.FormatInt(userId, 10),
-		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	expiresAt := now.Add(s.cfg.AccessTokenTTL)
 
-	signedToken, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	jti, err := newTokenId()
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to generate token id: %w", err), slog.Int64("userId", userId))
+		return "", time.Time{}, err
+	}
+	claims := authClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    s.cfg.JWTIssuer,
+			Subject:   strconv.FormatInt(userId, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		SessionID: sessionId,
+	}
+	signedToken, err := s.keys.sign(claims)
 	if err != nil {
 		s.logger.Error(ctx, fmt.Errorf("failed to sign token: %w", err), slog.Int64("userId", userId))
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
 	}
-	return signedToken, nil
+	return signedToken, expiresAt, nil
 }
-func (s *Service) VerifyAuthToken(token string) (*int64, error) {
-	claims := jwt.RegisteredClaims{}
 
-	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.cfg.JWTSecret), nil
-	})
+func (s *Service) VerifyAuthToken(token string) (*TokenClaims, error) {
+	claims := authClaims{}
+
+	_, err := jwt.ParseWithClaims(token, &claims, s.keys.keyFunc,
+		jwt.WithValidMethods(s.keys.validMethods()),
+		jwt.WithIssuer(s.cfg.JWTIssuer),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
 	}
@@ -55,5 +86,29 @@ func (s *Service) VerifyAuthToken(token string) (*int64, error) {
 	if err != nil {
 		return nil, fmt.Errorf("fail to parse subejct to int64: %w", err)
 	}
-	return &userId, nil
+	if claims.SessionID == 0 || claims.ID == "" {
+		return nil, fmt.Errorf("token is not bound to a session")
+	}
+	res := &TokenClaims{
+		UserID:    userId,
+		SessionID: claims.SessionID,
+		TokenID:   claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		res.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return res, nil
+}
+
+func newTokenId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetJWKS returns public keys other services can use to verify issued tokens.
+func (s *Service) GetJWKS() *JWKS {
+	return s.keys.jwks()
 }
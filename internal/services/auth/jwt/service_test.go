@@ -0,0 +1,233 @@
+package jwt
+
+import (
+	"chatapp/internal/config"
+	"chatapp/internal/logger"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writeKey(t *testing.T, dir, kid string, private any) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newKeysDir(t *testing.T) (string, ed25519.PrivateKey, *rsa.PrivateKey) {
+	t.Helper()
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "ed-1", edKey)
+	writeKey(t, dir, "rsa-1", rsaKey)
+	return dir, edKey, rsaKey
+}
+
+func newTestService(t *testing.T, dir, activeKeyId, secret string) *Service {
+	t.Helper()
+	s, err := NewService(&config.Config{
+		JWTKeysDir:     dir,
+		JWTActiveKeyID: activeKeyId,
+		JWTSecret:      secret,
+		JWTIssuer:      "chatapp-test",
+		AccessTokenTTL: time.Minute,
+	}, logger.NewSLogger())
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	return s
+}
+
+func tokenKid(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &authClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func signedClaims(userId, sessionId int64) authClaims {
+	return authClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "test-token",
+			Issuer:    "chatapp-test",
+			Subject:   strconv.FormatInt(userId, 10),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		SessionID: sessionId,
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	dir, _, _ := newKeysDir(t)
+	for _, kid := range []string{"ed-1", "rsa-1"} {
+		s := newTestService(t, dir, kid, "")
+		token, _, err := s.CreateToken(context.Background(), 7, 11)
+		if err != nil {
+			t.Fatalf("create token with %s: %v", kid, err)
+		}
+		if got := tokenKid(t, token); got != kid {
+			t.Errorf("kid = %q, want %q", got, kid)
+		}
+		claims, err := s.VerifyAuthToken(token)
+		if err != nil {
+			t.Fatalf("verify token signed with %s: %v", kid, err)
+		}
+		if claims.UserID != 7 || claims.SessionID != 11 || claims.TokenID == "" {
+			t.Errorf("claims = %+v", claims)
+		}
+	}
+}
+
+func TestJWKSVerifiesIssuedTokens(t *testing.T) {
+	dir, _, _ := newKeysDir(t)
+	s := newTestService(t, dir, "ed-1", "")
+	token, _, err := s.CreateToken(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := s.GetJWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "ed-1" || jwks.Keys[1].Kid != "rsa-1" {
+		t.Fatalf("jwks = %+v", jwks.Keys)
+	}
+	ed, rs := jwks.Keys[0], jwks.Keys[1]
+	if ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != "EdDSA" || rs.Kty != "RSA" || rs.Alg != "RS256" || rs.E != "AQAB" {
+		t.Fatalf("unexpected jwks keys %+v", jwks.Keys)
+	}
+
+	// a consumer knowing only the published key can verify the token
+	x, err := base64.RawURLEncoding.DecodeString(ed.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return ed25519.PublicKey(x), nil
+	}, jwt.WithValidMethods([]string{"EdDSA"}))
+	if err != nil {
+		t.Fatalf("verify with published key: %v", err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir, _, _ := newKeysDir(t)
+	old := newTestService(t, dir, "ed-1", "")
+	oldToken, _, err := old.CreateToken(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newTestService(t, dir, "rsa-1", "")
+	if _, err := rotated.VerifyAuthToken(oldToken); err != nil {
+		t.Fatalf("token of the previous key rejected after rotation: %v", err)
+	}
+	newToken, _, err := rotated.CreateToken(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tokenKid(t, newToken); got != "rsa-1" {
+		t.Fatalf("kid after rotation = %q, want rsa-1", got)
+	}
+
+	// once the previous key is removed its tokens are rejected
+	if err := os.Remove(filepath.Join(dir, "ed-1.pem")); err != nil {
+		t.Fatal(err)
+	}
+	retired := newTestService(t, dir, "rsa-1", "")
+	if _, err := retired.VerifyAuthToken(oldToken); err == nil {
+		t.Fatal("token of a removed key accepted")
+	}
+	if _, err := retired.VerifyAuthToken(newToken); err != nil {
+		t.Fatalf("token of the active key rejected: %v", err)
+	}
+}
+
+func TestKidMismatch(t *testing.T) {
+	dir, edKey, rsaKey := newKeysDir(t)
+	s := newTestService(t, dir, "ed-1", "")
+
+	sign := func(method jwt.SigningMethod, kid string, key any) string {
+		token := jwt.NewWithClaims(method, signedClaims(1, 1))
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	if _, err := s.VerifyAuthToken(sign(jwt.SigningMethodEdDSA, "ed-1", edKey)); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if _, err := s.VerifyAuthToken(sign(jwt.SigningMethodEdDSA, "unknown", edKey)); !errors.Is(err, ErrUnknownKeyId) {
+		t.Errorf("unknown kid: got %v, want %v", err, ErrUnknownKeyId)
+	}
+	// the RSA key signed, but the header names the Ed25519 key
+	if _, err := s.VerifyAuthToken(sign(jwt.SigningMethodRS256, "ed-1", rsaKey)); err == nil {
+		t.Error("token signed by another key than its kid accepted")
+	}
+	// the right algorithm, but another Ed25519 key
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifyAuthToken(sign(jwt.SigningMethodEdDSA, "ed-1", otherKey)); err == nil {
+		t.Error("token signed by a foreign key accepted")
+	}
+	if _, err := s.VerifyAuthToken(sign(jwt.SigningMethodEdDSA, "", edKey)); err == nil {
+		t.Error("token without kid accepted without a legacy secret")
+	}
+}
+
+func TestLegacySecret(t *testing.T) {
+	dir, _, _ := newKeysDir(t)
+	s := newTestService(t, dir, "ed-1", "legacy-secret")
+
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, signedClaims(1, 1)).SignedString([]byte("legacy-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifyAuthToken(legacy); err != nil {
+		t.Fatalf("legacy token rejected: %v", err)
+	}
+
+	// the secret must not verify tokens naming a key
+	withKid := jwt.NewWithClaims(jwt.SigningMethodHS256, signedClaims(1, 1))
+	withKid.Header["kid"] = "ed-1"
+	forged, err := withKid.SignedString([]byte("legacy-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifyAuthToken(forged); err == nil {
+		t.Fatal("HS256 token with the kid of an asymmetric key accepted")
+	}
+}
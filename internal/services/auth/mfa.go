@@ -0,0 +1,230 @@
+package user
+
+import (
+	user_dto "chatapp/internal/dto/user"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/services/auth/totp"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const recoveryCodesCount = 10
+
+var (
+	ErrInvalidMFACode      = errors.New("invalid two-factor code")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled      = errors.New("two-factor authentication enrollment not started")
+	ErrMFANotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrMFAEnrollmentNeeded = errors.New("two-factor authentication enrollment required")
+)
+
+// LoginResult is either a signed in user or, for accounts with 2FA, a challenge to finish with a code.
+type LoginResult struct {
+	User     *users.User
+	MFAToken string
+}
+
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// EnrollTOTP generates a new secret, it becomes active only after ConfirmTOTP.
+func (s *Service) EnrollTOTP(ctx context.Context, user *users.User) (*TOTPEnrollment, error) {
+	if user.IsTOTPEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.box.Seal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+	if err := s.repos.UserRepository.SetTOTPSecret(ctx, user.ID, sealed); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to store totp secret: %w", err), slog.Int64("userId", user.ID))
+		return nil, fmt.Errorf("failed to enroll totp: %w", err)
+	}
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.cfg.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables 2FA once the user proves the authenticator works, and returns one-time recovery codes.
+func (s *Service) ConfirmTOTP(ctx context.Context, user *users.User, code string) ([]string, error) {
+	if user.IsTOTPEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	secret, err := s.box.Open(*user.TOTPSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, err := s.regenerateRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repos.UserRepository.EnableTOTP(ctx, user.ID, step); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to enable totp: %w", err), slog.Int64("userId", user.ID))
+		return nil, fmt.Errorf("failed to enable totp: %w", err)
+	}
+	s.logger.Info(ctx, "two-factor authentication enabled", slog.Int64("userId", user.ID))
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces recovery codes, a valid current code is required.
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, user *users.User, code string) ([]string, error) {
+	if !user.IsTOTPEnabled() {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.checkTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+	return s.regenerateRecoveryCodes(ctx, user.ID)
+}
+
+func (s *Service) DisableTOTP(ctx context.Context, user *users.User, code string) error {
+	if !user.IsTOTPEnabled() {
+		return ErrMFANotEnabled
+	}
+	if s.cfg.MFARequired {
+		return ErrMFAEnrollmentNeeded
+	}
+	if err := s.checkTOTP(ctx, user, code); err != nil {
+		return err
+	}
+	if err := s.repos.UserRepository.DisableTOTP(ctx, user.ID); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to disable totp: %w", err), slog.Int64("userId", user.ID))
+		return fmt.Errorf("failed to disable totp: %w", err)
+	}
+	if err := s.repos.MFARecoveryCodeRepository.DeleteAll(ctx, user.ID); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to delete recovery codes: %w", err), slog.Int64("userId", user.ID))
+	}
+	s.logger.Info(ctx, "two-factor authentication disabled", slog.Int64("userId", user.ID))
+	return nil
+}
+
+// VerifyMFALogin finishes a two-step login with a TOTP or recovery code.
+// The challenge token is single-use, a wrong code requires signing in with the password again.
+func (s *Service) VerifyMFALogin(ctx context.Context, dto *user_dto.MFALoginDTO) (*users.User, error) {
+	usrId, err := s.tokens.Consume(ctx, dto.Token, users.TokenPurposeMFAChallenge)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repos.UserRepository.GetUserById(ctx, usrId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.IsDeleted() || !user.IsTOTPEnabled() {
+		return nil, ErrInvalidToken
+	}
+	attempt := &users.LoginAttempt{
+		UserID:    &user.ID,
+		Email:     user.Email,
+		IP:        dto.IP,
+		UserAgent: dto.UserAgent,
+	}
+	// the account may have been locked by failures since the challenge was issued
+	if user.IsLocked(time.Now()) {
+		s.throttle.Record(ctx, attempt)
+		return nil, ErrAccountLocked
+	}
+
+	if dto.RecoveryCode != "" {
+		err = s.useRecoveryCode(ctx, user.ID, dto.RecoveryCode)
+	} else {
+		err = s.checkTOTP(ctx, user, dto.Code)
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.loginFailed(ctx, user, attempt)
+		}
+		return nil, err
+	}
+	s.loginSucceeded(ctx, user, attempt)
+	return user, nil
+}
+
+// RequiresMFAEnrollment tells whether the user must set up 2FA before using the API.
+func (s *Service) RequiresMFAEnrollment(user *users.User) bool {
+	return s.cfg.MFARequired && !user.IsBot && !user.IsTOTPEnabled()
+}
+
+func (s *Service) createMFAChallenge(ctx context.Context, user *users.User) (string, error) {
+	token, err := s.tokens.Issue(ctx, user.ID, users.TokenPurposeMFAChallenge, s.cfg.MFAChallengeTTL)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to issue mfa challenge: %w", err), slog.Int64("userId", user.ID))
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *Service) checkTOTP(ctx context.Context, user *users.User, code string) error {
+	secret, err := s.box.Open(*user.TOTPSecret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return ErrInvalidMFACode
+	}
+	fresh, err := s.repos.UserRepository.UseTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return fmt.Errorf("failed to store totp step: %w", err)
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *Service) useRecoveryCode(ctx context.Context, usrId int64, code string) error {
+	ok, err := s.repos.MFARecoveryCodeRepository.Consume(ctx, usrId, s.hasService.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	s.logger.Info(ctx, "recovery code used", slog.Int64("userId", usrId))
+	return nil
+}
+
+func (s *Service) regenerateRecoveryCodes(ctx context.Context, usrId int64) ([]string, error) {
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, s.hasService.HashToken(normalizeRecoveryCode(code)))
+	}
+	if err := s.repos.MFARecoveryCodeRepository.Replace(ctx, usrId, hashes); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to store recovery codes: %w", err), slog.Int64("userId", usrId))
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,52 @@
+package user
+
+import (
+	user_dto "chatapp/internal/dto/user"
+	"chatapp/internal/entities/users"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+var (
+	ErrInvalidPushLevel       = errors.New("push level must be all, mentions or none")
+	ErrInvalidDigestFrequency = errors.New("digest frequency must be daily, weekly or off")
+	ErrInvalidQuietHours      = errors.New("quiet hours need a different start and end hour between 0 and 23")
+)
+
+// UpdateNotificationSettings changes which messages are pushed to the user's devices, how often
+// unread messages are emailed and the hours without notifications.
+func (s *Service) UpdateNotificationSettings(ctx context.Context, usr *users.User, dto *user_dto.NotificationSettingsDTO) (*users.User, error) {
+	updated := *usr
+	if dto.PushLevel != nil {
+		switch *dto.PushLevel {
+		case users.PushLevelAll, users.PushLevelMentions, users.PushLevelNone:
+		default:
+			return nil, ErrInvalidPushLevel
+		}
+		updated.PushLevel = *dto.PushLevel
+	}
+	if dto.DigestFrequency != nil {
+		switch *dto.DigestFrequency {
+		case users.DigestDaily, users.DigestWeekly, users.DigestOff:
+		default:
+			return nil, ErrInvalidDigestFrequency
+		}
+		updated.DigestFrequency = *dto.DigestFrequency
+	}
+	if q := dto.QuietHours; q != nil {
+		if (q.Start == nil) != (q.End == nil) {
+			return nil, ErrInvalidQuietHours
+		}
+		if q.Start != nil && (*q.Start < 0 || *q.Start > 23 || *q.End < 0 || *q.End > 23 || *q.Start == *q.End) {
+			return nil, ErrInvalidQuietHours
+		}
+		updated.QuietHoursStart, updated.QuietHoursEnd = q.Start, q.End
+	}
+	if err := s.repos.UserRepository.UpdateNotificationSettings(ctx, &updated); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to update notification settings: %w", err), slog.Int64("userId", usr.ID))
+		return nil, fmt.Errorf("failed to update notification settings: %w", err)
+	}
+	return &updated, nil
+}
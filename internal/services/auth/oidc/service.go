@@ -0,0 +1,211 @@
+package oidc
+
+import (
+	"chatapp/internal/config"
+	user_dto "chatapp/internal/dto/user"
+	"chatapp/internal/logger"
+	"chatapp/internal/services/secretbox"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	CallbackPath = "/api/v1/auth/oidc/callback"
+	StateTTL     = 10 * time.Minute
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown identity provider")
+	ErrInvalidState     = errors.New("invalid or expired login state")
+	ErrDomainNotAllowed = errors.New("email domain is not allowed for this provider")
+	ErrEmailNotVerified = errors.New("identity provider did not verify the email")
+)
+
+type ProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// AuthRequest is the start of an authorization code flow.
+// State must be kept by the client (a cookie) and handed back to FinishLogin.
+type AuthRequest struct {
+	URL   string
+	State string
+}
+
+type loginState struct {
+	Provider  string    `json:"p"`
+	State     string    `json:"s"`
+	Nonce     string    `json:"n"`
+	Verifier  string    `json:"v"`
+	ExpiresAt time.Time `json:"e"`
+}
+
+// Service implements the OpenID Connect authorization code flow with PKCE.
+// Login state is sealed into an opaque value, so any replica can finish a flow started by another.
+type Service struct {
+	cfg       *config.Config
+	logger    logger.Logger
+	box       *secretbox.Box
+	providers map[string]*provider
+}
+
+func NewService(cfg *config.Config, logger logger.Logger, box *secretbox.Box) *Service {
+	client := &http.Client{Timeout: 10 * time.Second}
+	providers := make(map[string]*provider, len(cfg.OIDCProviders))
+	for _, p := range cfg.OIDCProviders {
+		providers[p.Name] = newProvider(p, client)
+	}
+	return &Service{
+		cfg:       cfg,
+		logger:    logger,
+		box:       box,
+		providers: providers,
+	}
+}
+
+func (s *Service) Providers() []ProviderInfo {
+	res := make([]ProviderInfo, 0, len(s.cfg.OIDCProviders))
+	for _, p := range s.cfg.OIDCProviders {
+		res = append(res, ProviderInfo{Name: p.Name, DisplayName: p.DisplayName})
+	}
+	return res
+}
+
+// SuccessRedirectURL is where browsers are sent after a login, empty means answering with JSON.
+func (s *Service) SuccessRedirectURL() string {
+	return s.cfg.OIDCSuccessRedirectURL
+}
+
+func (s *Service) BeginLogin(ctx context.Context, providerName string) (*AuthRequest, error) {
+	p, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	st := &loginState{
+		Provider:  providerName,
+		ExpiresAt: time.Now().Add(StateTTL),
+	}
+	var err error
+	if st.State, err = randomString(); err != nil {
+		return nil, err
+	}
+	if st.Nonce, err = randomString(); err != nil {
+		return nil, err
+	}
+	if st.Verifier, err = randomString(); err != nil {
+		return nil, err
+	}
+	challenge := sha256.Sum256([]byte(st.Verifier))
+
+	u, err := p.authCodeURL(ctx, s.redirectURI(), st.State, st.Nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to build authorization url: %w", err), slog.String("provider", providerName))
+		return nil, err
+	}
+	raw, err := json.Marshal(st)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.box.Seal(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal login state: %w", err)
+	}
+	return &AuthRequest{URL: u, State: sealed}, nil
+}
+
+// FinishLogin validates the callback, exchanges the code and verifies the ID token.
+func (s *Service) FinishLogin(ctx context.Context, sealedState, state, code string) (*user_dto.ExternalIdentityDTO, error) {
+	st, err := s.openState(sealedState)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(st.State), []byte(state)) != 1 || code == "" {
+		return nil, ErrInvalidState
+	}
+	p, ok := s.providers[st.Provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	tokens, err := p.exchange(ctx, code, s.redirectURI(), st.Verifier)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to exchange authorization code: %w", err), slog.String("provider", st.Provider))
+		return nil, err
+	}
+	claims, err := p.verifyIDToken(ctx, tokens.IDToken, st.Nonce)
+	if err != nil {
+		s.logger.Warn(ctx, "id token verification failed", slog.String("provider", st.Provider), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	identity := &user_dto.ExternalIdentityDTO{
+		Provider:          st.Provider,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.emailVerified(),
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}
+	if len(p.cfg.AllowedDomains) > 0 {
+		// anyone can claim an address of the domain at some providers, only a verified one proves membership
+		if !identity.EmailVerified {
+			return nil, ErrEmailNotVerified
+		}
+		if !domainAllowed(p.cfg.AllowedDomains, identity.Email) {
+			return nil, ErrDomainNotAllowed
+		}
+	}
+	return identity, nil
+}
+
+func (s *Service) openState(sealed string) (*loginState, error) {
+	if sealed == "" {
+		return nil, ErrInvalidState
+	}
+	raw, err := s.box.Open(sealed)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	st := &loginState{}
+	if err := json.Unmarshal([]byte(raw), st); err != nil {
+		return nil, ErrInvalidState
+	}
+	if time.Now().After(st.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+	return st, nil
+}
+
+func (s *Service) redirectURI() string {
+	return strings.TrimSuffix(s.cfg.AppPublicURL, "/") + CallbackPath
+}
+
+func domainAllowed(allowed []string, email string) bool {
+	_, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return false
+	}
+	return slices.ContainsFunc(allowed, func(d string) bool {
+		return strings.EqualFold(d, domain)
+	})
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
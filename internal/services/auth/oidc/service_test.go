@@ -0,0 +1,251 @@
+package oidc
+
+import (
+	"chatapp/internal/config"
+	"chatapp/internal/logger"
+	"chatapp/internal/services/secretbox"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "chatapp"
+	testClientSecret = "client-secret"
+	testAppURL       = "https://chat.example.com"
+)
+
+// fakeIdP is a minimal OpenID provider: discovery, JWKS and a token endpoint checking PKCE.
+type fakeIdP struct {
+	t      *testing.T
+	srv    *httptest.Server
+	key    *rsa.PrivateKey
+	claims func(c jwt.MapClaims)
+
+	mu     sync.Mutex
+	issued map[string]authorization
+}
+
+type authorization struct {
+	nonce     string
+	challenge string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{t: t, key: key, issued: make(map[string]authorization)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+func (idp *fakeIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	_ = json.NewEncoder(w).Encode(discoveryDocument{
+		Issuer:                idp.srv.URL,
+		AuthorizationEndpoint: idp.srv.URL + "/authorize",
+		TokenEndpoint:         idp.srv.URL + "/token",
+		JWKSURI:               idp.srv.URL + "/jwks",
+	})
+}
+
+func (idp *fakeIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := idp.key.PublicKey
+	_ = json.NewEncoder(w).Encode(map[string]any{"keys": []jsonWebKey{{
+		Kty: "RSA",
+		Kid: "idp-key",
+		Alg: "RS256",
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+// authorize plays the user approving the login, it returns the code the browser would bring back.
+func (idp *fakeIdP) authorize(authURL string) (state, code string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != testClientID || q.Get("redirect_uri") != testAppURL+CallbackPath || q.Get("code_challenge_method") != "S256" {
+		idp.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	code = "code-" + q.Get("state")
+	idp.mu.Lock()
+	idp.issued[code] = authorization{nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	idp.mu.Unlock()
+	return q.Get("state"), code
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != testClientID || secret != testClientSecret {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+	idp.mu.Lock()
+	authz, ok := idp.issued[r.PostFormValue("code")]
+	delete(idp.issued, r.PostFormValue("code"))
+	idp.mu.Unlock()
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != authz.challenge {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":            idp.srv.URL,
+		"aud":            testClientID,
+		"sub":            "user-1",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          authz.nonce,
+		"email":          "ann@example.com",
+		"email_verified": true,
+		"name":           "Ann",
+	}
+	if idp.claims != nil {
+		idp.claims(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "idp-key"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	_ = json.NewEncoder(w).Encode(tokenResponse{AccessToken: "access", IDToken: signed, TokenType: "Bearer"})
+}
+
+func newTestService(t *testing.T, idp *fakeIdP, allowedDomains ...string) *Service {
+	box, err := secretbox.New(strings.Repeat("s", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewService(&config.Config{
+		AppPublicURL: testAppURL,
+		OIDCProviders: []config.OIDCProviderConfig{{
+			Name:           "corp",
+			DisplayName:    "Corp",
+			Issuer:         idp.srv.URL,
+			ClientID:       testClientID,
+			ClientSecret:   testClientSecret,
+			Scopes:         []string{"openid", "email"},
+			AllowedDomains: allowedDomains,
+		}},
+	}, logger.NewSLogger(), box)
+}
+
+// login runs the whole flow: begin, approve at the IdP and finish with the callback values.
+func login(t *testing.T, s *Service, idp *fakeIdP) (*AuthRequest, string, string) {
+	t.Helper()
+	req, err := s.BeginLogin(context.Background(), "corp")
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	state, code := idp.authorize(req.URL)
+	return req, state, code
+}
+
+func TestLoginFlow(t *testing.T) {
+	idp := newFakeIdP(t)
+	s := newTestService(t, idp)
+
+	req, state, code := login(t, s, idp)
+	identity, err := s.FinishLogin(context.Background(), req.State, state, code)
+	if err != nil {
+		t.Fatalf("finish login: %v", err)
+	}
+	if identity.Provider != "corp" || identity.Subject != "user-1" || identity.Email != "ann@example.com" || !identity.EmailVerified || identity.Name != "Ann" {
+		t.Fatalf("identity = %+v", identity)
+	}
+
+	// the code is single use at the IdP, the state can't be replayed to log in again
+	if _, err := s.FinishLogin(context.Background(), req.State, state, code); err == nil {
+		t.Fatal("replayed callback accepted")
+	}
+}
+
+func TestLoginRejectsForgedCallbacks(t *testing.T) {
+	idp := newFakeIdP(t)
+	s := newTestService(t, idp)
+
+	req, _, code := login(t, s, idp)
+	if _, err := s.FinishLogin(context.Background(), req.State, "other-state", code); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("state mismatch: got %v, want %v", err, ErrInvalidState)
+	}
+	if _, err := s.FinishLogin(context.Background(), "tampered", "other-state", code); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("tampered state: got %v, want %v", err, ErrInvalidState)
+	}
+	if _, err := s.BeginLogin(context.Background(), "unknown"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("unknown provider: got %v, want %v", err, ErrUnknownProvider)
+	}
+}
+
+func TestLoginRejectsInvalidIDTokens(t *testing.T) {
+	cases := map[string]func(c jwt.MapClaims){
+		"nonce":    func(c jwt.MapClaims) { c["nonce"] = "other" },
+		"audience": func(c jwt.MapClaims) { c["aud"] = "other-client" },
+		"issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"expired":  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"subject":  func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			idp := newFakeIdP(t)
+			idp.claims = mutate
+			s := newTestService(t, idp)
+
+			req, state, code := login(t, s, idp)
+			if _, err := s.FinishLogin(context.Background(), req.State, state, code); !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("got %v, want %v", err, ErrInvalidIDToken)
+			}
+		})
+	}
+}
+
+func TestAllowedDomains(t *testing.T) {
+	cases := []struct {
+		name   string
+		claims func(c jwt.MapClaims)
+		want   error
+	}{
+		{"allowed", nil, nil},
+		{"verified as string", func(c jwt.MapClaims) { c["email_verified"] = "true" }, nil},
+		{"other domain", func(c jwt.MapClaims) { c["email"] = "ann@evil.com" }, ErrDomainNotAllowed},
+		{"unverified", func(c jwt.MapClaims) { c["email_verified"] = false }, ErrEmailNotVerified},
+		{"verification missing", func(c jwt.MapClaims) { delete(c, "email_verified") }, ErrEmailNotVerified},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			idp := newFakeIdP(t)
+			idp.claims = tc.claims
+			s := newTestService(t, idp, "example.com")
+
+			req, state, code := login(t, s, idp)
+			if _, err := s.FinishLogin(context.Background(), req.State, state, code); !errors.Is(err, tc.want) {
+				t.Fatalf("got %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package password
+
+import (
+	"bufio"
+	"chatapp/internal/config"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrWeakPassword = errors.New("password does not meet policy")
+)
+
+// Policy validates new passwords.
+// Any printable characters are allowed, passphrases are encouraged by a generous max length.
+type Policy struct {
+	minLength int
+	maxLength int
+	// breached holds upper case hex SHA-1 of known leaked passwords
+	breached map[string]struct{}
+}
+
+func NewPolicy(cfg *config.Config) (*Policy, error) {
+	p := &Policy{
+		minLength: cfg.PasswordMinLength,
+		maxLength: cfg.PasswordMaxLength,
+		breached:  make(map[string]struct{}),
+	}
+	if cfg.PasswordBreachedListFile == "" {
+		return p, nil
+	}
+	if err := p.loadBreached(cfg.PasswordBreachedListFile); err != nil {
+		return nil, fmt.Errorf("failed to load breached passwords list: %w", err)
+	}
+	return p, nil
+}
+
+// Validate checks the password, userInputs like username or email must not be used as password.
+func (p *Policy) Validate(password string, userInputs ...string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, p.minLength)
+	}
+	if length > p.maxLength {
+		return fmt.Errorf("%w: must be at most %d characters", ErrWeakPassword, p.maxLength)
+	}
+	if strings.TrimSpace(password) == "" {
+		return fmt.Errorf("%w: must not be blank", ErrWeakPassword)
+	}
+	for _, in := range userInputs {
+		if in != "" && strings.EqualFold(password, in) {
+			return fmt.Errorf("%w: must not match your username or email", ErrWeakPassword)
+		}
+	}
+	if _, ok := p.breached[sha1Hex(password)]; ok {
+		return fmt.Errorf("%w: appears in a list of breached passwords", ErrWeakPassword)
+	}
+	return nil
+}
+
+// loadBreached reads one entry per line.
+// A line is either a plain password or SHA-1 hex as published by Have I Been Pwned ("HASH:count").
+func (p *Policy) loadBreached(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if h, _, _ := strings.Cut(line, ":"); isSHA1Hex(h) {
+			p.breached[strings.ToUpper(h)] = struct{}{}
+			continue
+		}
+		p.breached[sha1Hex(line)] = struct{}{}
+	}
+	return sc.Err()
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != sha1.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,203 @@
+package user
+
+import (
+	user_dto "chatapp/internal/dto/user"
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/repositories/user"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// avatarURLPrefix is the route avatars are served from
+const avatarURLPrefix = "/api/v1/avatars/"
+
+var (
+	ErrInvalidTimezone = errors.New("unknown timezone")
+	ErrInvalidPassword = errors.New("invalid password")
+	ErrEmailTaken      = errors.New("email is already in use")
+	ErrSameEmail       = errors.New("email is already set")
+)
+
+// UpdateProfile changes the profile fields set in the dto and notifies the user's conversations.
+func (s *Service) UpdateProfile(ctx context.Context, usr *users.User, dto *user_dto.UpdateProfileDTO) (*users.User, error) {
+	if dto.Timezone != nil && *dto.Timezone != "" {
+		if _, err := time.LoadLocation(*dto.Timezone); err != nil || *dto.Timezone == "Local" {
+			return nil, ErrInvalidTimezone
+		}
+	}
+
+	updated := *usr
+	if dto.DisplayName != nil {
+		updated.DisplayName = nil
+		if name := strings.TrimSpace(*dto.DisplayName); name != "" {
+			updated.DisplayName = &name
+		}
+	}
+	if dto.Bio != nil {
+		updated.Bio = strings.TrimSpace(*dto.Bio)
+	}
+	if dto.Status != nil {
+		updated.Status = strings.TrimSpace(*dto.Status)
+	}
+	if dto.Timezone != nil {
+		updated.Timezone = *dto.Timezone
+	}
+
+	if err := s.repos.UserRepository.UpdateProfile(ctx, &updated); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to update profile: %w", err), slog.Int64("userId", usr.ID))
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+	s.postUserUpdated(ctx, &updated)
+	return &updated, nil
+}
+
+// SetAvatar stores the uploaded image as the user's avatar, the previous one is deleted.
+func (s *Service) SetAvatar(ctx context.Context, usr *users.User, r io.Reader) (*users.User, error) {
+	name, err := s.avatars.Save(usr.ID, r)
+	if err != nil {
+		return nil, err
+	}
+	url := avatarURLPrefix + name
+	if err := s.repos.UserRepository.SetAvatarURL(ctx, usr.ID, &url); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to set avatar: %w", err), slog.Int64("userId", usr.ID))
+		_ = s.avatars.Remove(url)
+		return nil, fmt.Errorf("failed to set avatar: %w", err)
+	}
+	s.removeAvatar(ctx, usr)
+
+	updated := *usr
+	updated.AvatarURL = &url
+	s.postUserUpdated(ctx, &updated)
+	return &updated, nil
+}
+
+func (s *Service) RemoveAvatar(ctx context.Context, usr *users.User) (*users.User, error) {
+	if usr.AvatarURL == nil {
+		return usr, nil
+	}
+	if err := s.repos.UserRepository.SetAvatarURL(ctx, usr.ID, nil); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to remove avatar: %w", err), slog.Int64("userId", usr.ID))
+		return nil, fmt.Errorf("failed to remove avatar: %w", err)
+	}
+	s.removeAvatar(ctx, usr)
+
+	updated := *usr
+	updated.AvatarURL = nil
+	s.postUserUpdated(ctx, &updated)
+	return &updated, nil
+}
+
+// AvatarPath returns the file of the avatar with the name.
+func (s *Service) AvatarPath(name string) (string, error) {
+	return s.avatars.Path(name)
+}
+
+func (s *Service) removeAvatar(ctx context.Context, usr *users.User) {
+	if usr.AvatarURL == nil {
+		return
+	}
+	if err := s.avatars.Remove(*usr.AvatarURL); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to delete avatar file: %w", err), slog.Int64("userId", usr.ID))
+	}
+}
+
+// RequestEmailChange mails a confirmation link to the new address, the email changes once it is followed.
+func (s *Service) RequestEmailChange(ctx context.Context, usr *users.User, email, password string) error {
+	if strings.EqualFold(email, usr.Email) {
+		return ErrSameEmail
+	}
+	if err := s.checkPassword(ctx, usr, password); err != nil {
+		return err
+	}
+	existing, err := s.repos.UserRepository.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user by email: %w", err)
+	}
+	if existing != nil {
+		return ErrEmailTaken
+	}
+
+	if err := s.repos.UserRepository.SetPendingEmail(ctx, usr.ID, &email); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to set pending email: %w", err), slog.Int64("userId", usr.ID))
+		return fmt.Errorf("failed to set pending email: %w", err)
+	}
+	token, err := s.tokens.Issue(ctx, usr.ID, users.TokenPurposeEmailChange, s.cfg.EmailVerificationTTL)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to issue email change token: %w", err), slog.Int64("userId", usr.ID))
+		return err
+	}
+	recipient := *usr
+	recipient.Email = email
+	return s.sendTokenMail(ctx, "change_email", "/confirm-email", &recipient, token, s.cfg.EmailVerificationTTL)
+}
+
+// ConfirmEmailChange replaces the email with the pending one the token was sent to.
+func (s *Service) ConfirmEmailChange(ctx context.Context, token string) error {
+	usrId, err := s.tokens.Consume(ctx, token, users.TokenPurposeEmailChange)
+	if err != nil {
+		return err
+	}
+	usr, err := s.repos.UserRepository.ConfirmPendingEmail(ctx, usrId)
+	if err != nil {
+		if errors.Is(err, user.ErrUserAlreadyExists) {
+			return ErrEmailTaken
+		}
+		s.logger.Error(ctx, fmt.Errorf("failed to confirm email change: %w", err), slog.Int64("userId", usrId))
+		return fmt.Errorf("failed to confirm email change: %w", err)
+	}
+	if usr == nil {
+		return ErrInvalidToken
+	}
+	s.logger.Info(ctx, "email changed", slog.Int64("userId", usrId))
+	return nil
+}
+
+// ChangePassword sets a new password after checking the current one, other sessions are signed out.
+func (s *Service) ChangePassword(ctx context.Context, usr *users.User, sessionId int64, current, password string) error {
+	if err := s.checkPassword(ctx, usr, current); err != nil {
+		return err
+	}
+	if err := s.policy.Validate(password, usr.Username, usr.Email); err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, usr.ID, password); err != nil {
+		return err
+	}
+	if err := s.repos.SessionRepository.RevokeOthers(ctx, usr.ID, sessionId); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to revoke sessions: %w", err), slog.Int64("userId", usr.ID))
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	s.logger.Info(ctx, "password changed", slog.Int64("userId", usr.ID))
+	return nil
+}
+
+func (s *Service) checkPassword(ctx context.Context, usr *users.User, password string) error {
+	ok, err := s.hasService.CompareHashWithPassword(usr.Password, password)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to check password: %w", err), slog.Int64("userId", usr.ID))
+		return fmt.Errorf("failed to check password: %w", err)
+	}
+	if !ok {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// postUserUpdated publishes the public profile to every conversation of the user.
+func (s *Service) postUserUpdated(ctx context.Context, usr *users.User) {
+	memberships, err := s.repos.ConversationRepository.GetMemberships(ctx, usr.ID)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get conversations for user_updated: %w", err), slog.Int64("userId", usr.ID))
+		return
+	}
+	public := usr.Public()
+	for _, m := range memberships {
+		s.evl.PostUserUpdated(&chatEnts.UserUpdated{ConversationID: m.ID, User: public})
+	}
+}
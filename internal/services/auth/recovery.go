@@ -0,0 +1,149 @@
+package user
+
+import (
+	"chatapp/internal/entities/users"
+	"chatapp/internal/services/auth/tokens"
+	"chatapp/internal/services/mailer"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrEmailNotVerified = errors.New("email is not verified")
+	ErrInvalidToken     = tokens.ErrInvalidToken
+)
+
+// SendVerificationEmail mails a link confirming ownership of the user's email.
+func (s *Service) SendVerificationEmail(ctx context.Context, user *users.User) error {
+	if user.IsEmailVerified() {
+		return nil
+	}
+	token, err := s.tokens.Issue(ctx, user.ID, users.TokenPurposeEmailVerification, s.cfg.EmailVerificationTTL)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to issue verification token: %w", err), slog.Int64("userId", user.ID))
+		return err
+	}
+	return s.sendTokenMail(ctx, "verify_email", "/verify-email", user, token, s.cfg.EmailVerificationTTL)
+}
+
+// ResendVerificationEmail is called by not signed in users, it never reveals whether the email is registered.
+func (s *Service) ResendVerificationEmail(ctx context.Context, email string) error {
+	user, err := s.repos.UserRepository.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user by email: %w", err)
+	}
+	if user == nil {
+		return nil
+	}
+	return s.SendVerificationEmail(ctx, user)
+}
+
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	usrId, err := s.tokens.Consume(ctx, token, users.TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	if err := s.repos.UserRepository.MarkEmailVerified(ctx, usrId); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to mark email verified: %w", err), slog.Int64("userId", usrId))
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+	s.logger.Info(ctx, "email verified", slog.Int64("userId", usrId))
+	return nil
+}
+
+// ForgotPassword mails a password reset link, unknown emails are silently ignored.
+func (s *Service) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.repos.UserRepository.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user by email: %w", err)
+	}
+	if user == nil || user.IsBot || user.IsDeleted() {
+		s.logger.Info(ctx, "password reset requested for unknown email")
+		return nil
+	}
+	token, err := s.tokens.Issue(ctx, user.ID, users.TokenPurposePasswordReset, s.cfg.PasswordResetTTL)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to issue password reset token: %w", err), slog.Int64("userId", user.ID))
+		return err
+	}
+	return s.sendTokenMail(ctx, "reset_password", "/reset-password", user, token, s.cfg.PasswordResetTTL)
+}
+
+// ResetPassword sets a new password and signs the user out everywhere.
+// Following the emailed link also proves ownership of the email.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	usrId, err := s.tokens.Consume(ctx, token, users.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	user, err := s.repos.UserRepository.GetUserById(ctx, usrId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return ErrInvalidToken
+	}
+	if err := s.policy.Validate(password, user.Username, user.Email); err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, user.ID, password); err != nil {
+		return err
+	}
+	if err := s.repos.UserRepository.MarkEmailVerified(ctx, user.ID); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to mark email verified: %w", err), slog.Int64("userId", user.ID))
+	}
+	if err := s.repos.UserRepository.ResetFailedLogins(ctx, user.ID); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to reset failed logins: %w", err), slog.Int64("userId", user.ID))
+	}
+	if err := s.repos.SessionRepository.RevokeAll(ctx, user.ID); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to revoke sessions: %w", err), slog.Int64("userId", user.ID))
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	s.logger.Info(ctx, "password reset", slog.Int64("userId", user.ID))
+	return nil
+}
+
+func (s *Service) setPassword(ctx context.Context, usrId int64, password string) error {
+	hash, err := s.hasService.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.repos.UserRepository.UpdatePassword(ctx, usrId, hash); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to update password: %w", err), slog.Int64("userId", usrId))
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) sendTokenMail(ctx context.Context, tmpl, path string, user *users.User, token string, ttl time.Duration) error {
+	msg, err := mailer.Render(tmpl, []string{user.Email}, map[string]any{
+		"Username":  user.Username,
+		"Link":      fmt.Sprintf("%s%s?token=%s", s.cfg.AppPublicURL, path, url.QueryEscape(token)),
+		"ExpiresIn": formatTTL(ttl),
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to send %s mail: %w", tmpl, err), slog.Int64("userId", user.ID))
+		return err
+	}
+	return nil
+}
+
+func formatTTL(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	case d >= 2*time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
@@ -1,38 +1,76 @@
 package user
 
 import (
+	"chatapp/internal/config"
 	user_dto "chatapp/internal/dto/user"
 	"chatapp/internal/entities/users"
 	"chatapp/internal/logger"
 	"chatapp/internal/repositories"
+	"chatapp/internal/services/auth/password"
+	"chatapp/internal/services/auth/throttle"
+	"chatapp/internal/services/auth/tokens"
+	"chatapp/internal/services/avatar"
+	chat_events "chatapp/internal/services/chat"
 	"chatapp/internal/services/hash"
+	"chatapp/internal/services/mailer"
+	"chatapp/internal/services/secretbox"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 )
 
 var (
 	ErrCannotCreateUser = errors.New("cannot create user")
 	ErrUnAutorize       = errors.New("cannot authorize user")
+	ErrAccountLocked    = errors.New("account is temporary locked")
 )
 
 type Service struct {
+	cfg        *config.Config
 	logger     logger.Logger
 	hasService *hash.Service
+	throttle   *throttle.Service
+	policy     *password.Policy
+	tokens     *tokens.Service
+	mailer     mailer.Mailer
+	box        *secretbox.Box
+	avatars    *avatar.Store
+	evl        *chat_events.EventListener
 	repos      *repositories.Repositories
 }
 
-func NewService(logger logger.Logger, repos *repositories.Repositories) *Service {
+func NewService(
+	cfg *config.Config,
+	logger logger.Logger,
+	repos *repositories.Repositories,
+	policy *password.Policy,
+	mailer mailer.Mailer,
+	box *secretbox.Box,
+	evl *chat_events.EventListener,
+) *Service {
 	return &Service{
+		cfg:        cfg,
 		logger:     logger,
-		hasService: hash.NewService(),
+		hasService: hash.NewService(cfg),
+		throttle:   throttle.NewService(cfg, logger, repos),
+		policy:     policy,
+		tokens:     tokens.NewService(cfg, repos),
+		mailer:     mailer,
+		box:        box,
+		avatars:    avatar.NewStore(cfg),
+		evl:        evl,
 		repos:      repos,
 	}
 }
 
 func (s *Service) Register(ctx context.Context, user *user_dto.CreateUserDTO) (*users.User, error) {
-	s.logger.Info(ctx, "reuest for new user creation", slog.Any("user", user))
+	s.logger.Info(ctx, "reuest for new user creation", slog.String("name", user.Name), slog.String("email", user.Email))
+
+	if err := s.policy.Validate(user.Password, user.Name, user.Email); err != nil {
+		return nil, err
+	}
 
 	hash, err := s.hasService.HashPassword(user.Password)
 	if err != nil {
@@ -42,38 +80,132 @@ func (s *Service) Register(ctx context.Context, user *user_dto.CreateUserDTO) (*
 
 	newUser, err := s.repos.UserRepository.Create(ctx, user)
 	if err != nil {
-		s.logger.Error(ctx, errors.Join(ErrCannotCreateUser, err), slog.Any("user", user))
+		s.logger.Error(ctx, errors.Join(ErrCannotCreateUser, err), slog.String("email", user.Email))
 		return nil, errors.Join(ErrCannotCreateUser, err)
 	}
 
 	s.logger.Info(ctx, "created new user", slog.Any("user", newUser))
+
+	if err := s.SendVerificationEmail(ctx, newUser); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to send verification email: %w", err), slog.Int64("userId", newUser.ID))
+	}
 	return newUser, nil
 }
 
-func (s *Service) Login(ctx context.Context, email, password string) (*users.User, error) {
-	user, err := s.repos.UserRepository.GetUserByEmail(ctx, email)
+// Login checks user credentials.
+// Unknown emails go through the same password check as existing ones so timing doesn't reveal registered emails.
+// For accounts with 2FA the result holds a challenge token to be finished with VerifyMFALogin.
+func (s *Service) Login(ctx context.Context, dto *user_dto.LoginDTO) (*LoginResult, error) {
+	if err := s.throttle.Check(ctx, dto.Email, dto.IP); err != nil {
+		return nil, err
+	}
+	attempt := &users.LoginAttempt{
+		Email:     dto.Email,
+		IP:        dto.IP,
+		UserAgent: dto.UserAgent,
+	}
+
+	user, err := s.repos.UserRepository.GetUserByEmail(ctx, dto.Email)
 	if err != nil {
 		s.logger.Info(ctx, "Cannot authorize user due to error",
 			slog.Any("error", err),
-			slog.String("email", email),
-			slog.String("password", password))
+			slog.String("email", dto.Email))
 		return nil, err
 	}
-	if user == nil {
-		s.logger.Info(ctx, fmt.Sprintf("cannot found user with email: %s", email))
+	if user == nil || user.IsBot || user.IsDeleted() {
+		s.hasService.CompareWithDummy(dto.Password)
+		s.throttle.Record(ctx, attempt)
+		s.logger.Info(ctx, fmt.Sprintf("cannot found user with email: %s", dto.Email))
 		return nil, ErrUnAutorize
 	}
-	passOk, err := s.hasService.CompareHashWithPassword(user.Password, password)
+	attempt.UserID = &user.ID
+
+	passOk, err := s.hasService.CompareHashWithPassword(user.Password, dto.Password)
 	if err != nil {
 		s.logger.Info(ctx, "Cannot authorize user due to error",
 			slog.Any("error", err),
-			slog.String("email", email),
-			slog.String("password", password))
+			slog.String("email", dto.Email))
 		return nil, err
 	}
+	if user.IsLocked(time.Now()) {
+		s.throttle.Record(ctx, attempt)
+		return nil, ErrAccountLocked
+	}
 	if !passOk {
+		s.loginFailed(ctx, user, attempt)
 		return nil, errors.Join(ErrUnAutorize, fmt.Errorf("password hash check failed"))
 	}
 
-	return user, nil
+	if s.cfg.RequireEmailVerification && !user.IsEmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
+
+	// the plain password is not kept for the second step, so the hash is upgraded now
+	s.rehashIfNeeded(ctx, user, dto.Password)
+	if user.IsTOTPEnabled() {
+		// the login succeeds, and failures are reset, only once the second factor is verified
+		token, err := s.createMFAChallenge(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFAToken: token}, nil
+	}
+	s.loginSucceeded(ctx, user, attempt)
+	return &LoginResult{User: user}, nil
+}
+
+// loginFailed records a failed attempt, the account is locked after LoginLockoutThreshold failures in a row.
+// Wrong passwords and wrong second factor codes count alike.
+func (s *Service) loginFailed(ctx context.Context, user *users.User, attempt *users.LoginAttempt) {
+	s.throttle.Record(ctx, attempt)
+	lockedUntil, err := s.repos.UserRepository.RegisterFailedLogin(ctx, user.ID, s.cfg.LoginLockoutThreshold, s.cfg.LoginLockoutDuration)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to register failed login: %w", err), slog.Int64("userId", user.ID))
+	} else if lockedUntil != nil && time.Now().Before(*lockedUntil) {
+		s.logger.Warn(ctx, "account locked after failed logins", slog.Int64("userId", user.ID), slog.Time("lockedUntil", *lockedUntil))
+		// sent in the background, so the response doesn't take longer when the account gets locked
+		go s.sendLockedMail(context.WithoutCancel(ctx), user)
+	}
+}
+
+// sendLockedMail tells the owner about the lock, login responses don't reveal it.
+func (s *Service) sendLockedMail(ctx context.Context, user *users.User) {
+	msg, err := mailer.Render("account_locked", []string{user.Email}, map[string]any{
+		"Username":  user.Username,
+		"LockedFor": formatTTL(s.cfg.LoginLockoutDuration),
+		"Link":      s.cfg.AppPublicURL + "/forgot-password",
+	})
+	if err == nil {
+		err = s.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to send account locked mail: %w", err), slog.Int64("userId", user.ID))
+	}
+}
+
+func (s *Service) loginSucceeded(ctx context.Context, user *users.User, attempt *users.LoginAttempt) {
+	attempt.Success = true
+	s.throttle.Record(ctx, attempt)
+	if err := s.repos.UserRepository.ResetFailedLogins(ctx, user.ID); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to reset failed logins: %w", err), slog.Int64("userId", user.ID))
+	}
+}
+
+// rehashIfNeeded upgrades a stored hash made with an outdated algorithm or cost.
+// The plain password is only known right after a successful check, so this is the only place to do it.
+func (s *Service) rehashIfNeeded(ctx context.Context, user *users.User, password string) {
+	if !s.hasService.NeedsRehash(user.Password) {
+		return
+	}
+	hash, err := s.hasService.HashPassword(password)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to rehash password: %w", err), slog.Int64("userId", user.ID))
+		return
+	}
+	if err := s.repos.UserRepository.UpdatePassword(ctx, user.ID, hash); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to store rehashed password: %w", err), slog.Int64("userId", user.ID))
+		return
+	}
+	user.Password = hash
+	s.logger.Info(ctx, "password rehashed", slog.Int64("userId", user.ID))
 }
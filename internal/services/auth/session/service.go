@@ -0,0 +1,180 @@
+package session
+
+import (
+	"chatapp/internal/config"
+	session_dto "chatapp/internal/dto/session"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"chatapp/internal/services/hash"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+type tokenIssuer interface {
+	CreateToken(ctx context.Context, userId, sessionId int64) (string, time.Time, error)
+}
+
+type Service struct {
+	cfg         *config.Config
+	logger      logger.Logger
+	repos       *repositories.Repositories
+	hashService *hash.Service
+	tokens      tokenIssuer
+}
+
+func NewService(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories, tokens tokenIssuer) *Service {
+	return &Service{
+		cfg:         cfg,
+		logger:      logger,
+		repos:       repos,
+		hashService: hash.NewService(cfg),
+		tokens:      tokens,
+	}
+}
+
+// CreateSession starts a new session for a signed in user and issues the first token pair.
+func (s *Service) CreateSession(ctx context.Context, dto *session_dto.CreateSessionDTO) (*session_dto.TokenPair, error) {
+	refreshToken, err := s.hashService.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	sess := &users.Session{
+		UserID:           dto.UserID,
+		RefreshTokenHash: s.hashService.HashToken(refreshToken),
+		UserAgent:        truncate(dto.UserAgent, 255),
+		IP:               truncate(dto.IP, 64),
+		ExpiresAt:        time.Now().Add(s.cfg.RefreshTokenTTL),
+	}
+	if err := s.repos.SessionRepository.Create(ctx, sess); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to create session: %w", err), slog.Int64("userId", dto.UserID))
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	s.logger.Info(ctx, "session created", slog.Int64("userId", sess.UserID), slog.Int64("sessionId", sess.ID))
+
+	return s.issue(ctx, sess, refreshToken)
+}
+
+// Refresh rotates the refresh token and issues a new access token.
+// Presenting an already rotated refresh token revokes the whole session since the token was most likely stolen.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*session_dto.TokenPair, error) {
+	oldHash := s.hashService.HashToken(refreshToken)
+
+	sess, err := s.repos.SessionRepository.GetSessionByRefreshTokenHash(ctx, oldHash)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get session by refresh token: %w", err))
+		return nil, fmt.Errorf("failed to refresh session: %w", err)
+	}
+	if sess == nil {
+		s.revokeReused(ctx, oldHash)
+		return nil, ErrInvalidRefreshToken
+	}
+	if !sess.IsActive(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newToken, err := s.hashService.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	sess.RefreshTokenHash = s.hashService.HashToken(newToken)
+	sess.ExpiresAt = time.Now().Add(s.cfg.RefreshTokenTTL)
+
+	rotated, err := s.repos.SessionRepository.Rotate(ctx, sess.ID, oldHash, sess.RefreshTokenHash, sess.ExpiresAt)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to rotate refresh token: %w", err), slog.Int64("sessionId", sess.ID))
+		return nil, fmt.Errorf("failed to refresh session: %w", err)
+	}
+	if !rotated {
+		return nil, ErrInvalidRefreshToken
+	}
+	return s.issue(ctx, sess, newToken)
+}
+
+func (s *Service) IsSessionActive(ctx context.Context, usrId, sessionId int64) (bool, error) {
+	sess, err := s.repos.SessionRepository.GetSessionById(ctx, sessionId)
+	if err != nil {
+		return false, fmt.Errorf("failed to get session: %w", err)
+	}
+	if sess == nil || sess.UserID != usrId {
+		return false, nil
+	}
+	return sess.IsActive(time.Now()), nil
+}
+
+func (s *Service) GetSessions(ctx context.Context, usrId int64) ([]*users.Session, error) {
+	sessions, err := s.repos.SessionRepository.GetActiveSessions(ctx, usrId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get sessions: %w", err), slog.Int64("userId", usrId))
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (s *Service) RevokeSession(ctx context.Context, usrId, sessionId int64) error {
+	ok, err := s.repos.SessionRepository.Revoke(ctx, usrId, sessionId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to revoke session: %w", err), slog.Int64("userId", usrId), slog.Int64("sessionId", sessionId))
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	s.logger.Info(ctx, "session revoked", slog.Int64("userId", usrId), slog.Int64("sessionId", sessionId))
+	return nil
+}
+
+func (s *Service) RevokeAllSessions(ctx context.Context, usrId int64) error {
+	if err := s.repos.SessionRepository.RevokeAll(ctx, usrId); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to revoke sessions: %w", err), slog.Int64("userId", usrId))
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) issue(ctx context.Context, sess *users.Session, refreshToken string) (*session_dto.TokenPair, error) {
+	accessToken, expiresAt, err := s.tokens.CreateToken(ctx, sess.UserID, sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &session_dto.TokenPair{
+		SessionID:        sess.ID,
+		AccessToken:      accessToken,
+		AccessExpiresAt:  expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: sess.ExpiresAt,
+	}, nil
+}
+
+func (s *Service) revokeReused(ctx context.Context, hash string) {
+	sess, err := s.repos.SessionRepository.GetSessionByPreviousTokenHash(ctx, hash)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to check refresh token reuse: %w", err))
+		return
+	}
+	if sess == nil || sess.RevokedAt != nil {
+		return
+	}
+	if _, err := s.repos.SessionRepository.Revoke(ctx, sess.UserID, sess.ID); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to revoke reused session: %w", err), slog.Int64("sessionId", sess.ID))
+		return
+	}
+	s.logger.Warn(ctx, "refresh token reuse detected, session revoked", slog.Int64("userId", sess.UserID), slog.Int64("sessionId", sess.ID))
+}
+
+// truncate cuts the string to max characters on rune boundaries, a split rune would be rejected as invalid UTF-8.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
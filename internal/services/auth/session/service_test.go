@@ -0,0 +1,21 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateKeepsRunes(t *testing.T) {
+	ua := strings.Repeat("ж", 300)
+	got := truncate(ua, 255)
+	if !utf8.ValidString(got) {
+		t.Fatalf("truncated user agent is not valid UTF-8")
+	}
+	if n := utf8.RuneCountInString(got); n != 255 {
+		t.Fatalf("truncated to %d characters, want 255", n)
+	}
+	if got := truncate("Mozilla/5.0", 255); got != "Mozilla/5.0" {
+		t.Fatalf("short user agent changed: %q", got)
+	}
+}
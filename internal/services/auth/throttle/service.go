@@ -0,0 +1,112 @@
+package throttle
+
+import (
+	"chatapp/internal/config"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many login attempts")
+)
+
+// ThrottledError tells the caller how long to wait before the next attempt.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// Service slows down sign in attempts per email and per IP with exponential backoff.
+// Attempts are kept in the login_attempts table so the limits are shared between replicas.
+type Service struct {
+	cfg    *config.Config
+	logger logger.Logger
+	repos  *repositories.Repositories
+}
+
+func NewService(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories) *Service {
+	return &Service{
+		cfg:    cfg,
+		logger: logger,
+		repos:  repos,
+	}
+}
+
+func (s *Service) Check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	since := now.Add(-s.cfg.LoginThrottleWindow)
+
+	emailStats, err := s.repos.LoginAttemptRepository.GetEmailFailures(ctx, attemptEmail(email), since)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get email login failures: %w", err))
+		return fmt.Errorf("failed to check login attempts: %w", err)
+	}
+	ipStats, err := s.repos.LoginAttemptRepository.GetIPFailures(ctx, ip, since)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get ip login failures: %w", err))
+		return fmt.Errorf("failed to check login attempts: %w", err)
+	}
+
+	wait := max(
+		s.wait(now, emailStats, s.cfg.LoginFreeAttempts),
+		s.wait(now, ipStats, s.cfg.LoginIPFreeAttempts),
+	)
+	if wait > 0 {
+		s.logger.Warn(ctx, "login throttled", slog.String("ip", ip), slog.Int("emailFailures", emailStats.Count), slog.Int("ipFailures", ipStats.Count))
+		return &ThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (s *Service) Record(ctx context.Context, a *users.LoginAttempt) {
+	// cut to the column sizes, an attempt failing to store would not count toward the limits
+	a.Email = attemptEmail(a.Email)
+	a.IP = truncate(a.IP, 64)
+	a.UserAgent = truncate(a.UserAgent, 255)
+	if err := s.repos.LoginAttemptRepository.Create(ctx, a); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to store login attempt: %w", err), slog.String("ip", a.IP))
+	}
+}
+
+func (s *Service) wait(now time.Time, stats *users.FailureStats, free int) time.Duration {
+	if stats.LastAttempt == nil || stats.Count < free {
+		return 0
+	}
+	delay := s.cfg.LoginBackoffBase
+	for i := free; i < stats.Count && delay < s.cfg.LoginBackoffMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, s.cfg.LoginBackoffMax)
+	return stats.LastAttempt.Add(delay).Sub(now)
+}
+
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// attemptEmail is the email attempts are stored and counted by.
+func attemptEmail(email string) string {
+	return truncate(NormalizeEmail(email), 100)
+}
+
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
@@ -0,0 +1,82 @@
+package tokens
+
+import (
+	"chatapp/internal/config"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/repositories"
+	"chatapp/internal/services/hash"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// Service issues signed single-use tokens for links sent by email.
+// A token is "<random>.<hmac(purpose, random)>", the signature lets forged tokens be rejected without a DB lookup.
+type Service struct {
+	secret      []byte
+	repos       *repositories.Repositories
+	hashService *hash.Service
+}
+
+func NewService(cfg *config.Config, repos *repositories.Repositories) *Service {
+	return &Service{
+		secret:      []byte(cfg.AppSecret),
+		repos:       repos,
+		hashService: hash.NewService(cfg),
+	}
+}
+
+// Issue creates a new token, previous unused tokens of the same purpose stop working.
+func (s *Service) Issue(ctx context.Context, usrId int64, purpose users.TokenPurpose, ttl time.Duration) (string, error) {
+	random, err := s.hashService.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	token := random + "." + s.sign(purpose, random)
+
+	if err := s.repos.UserTokenRepository.Invalidate(ctx, usrId, purpose); err != nil {
+		return "", fmt.Errorf("failed to invalidate previous tokens: %w", err)
+	}
+	if err := s.repos.UserTokenRepository.Create(ctx, &users.Token{
+		UserID:    usrId,
+		Purpose:   purpose,
+		TokenHash: s.hashService.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+	return token, nil
+}
+
+// Consume checks the token and marks it used, returning the owner's id.
+func (s *Service) Consume(ctx context.Context, token string, purpose users.TokenPurpose) (int64, error) {
+	random, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(purpose, random))) {
+		return 0, ErrInvalidToken
+	}
+	t, err := s.repos.UserTokenRepository.Consume(ctx, s.hashService.HashToken(token), purpose)
+	if err != nil {
+		return 0, fmt.Errorf("failed to consume token: %w", err)
+	}
+	if t == nil {
+		return 0, ErrInvalidToken
+	}
+	return t.UserID, nil
+}
+
+func (s *Service) sign(purpose users.TokenPurpose, random string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{':'})
+	mac.Write([]byte(random))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
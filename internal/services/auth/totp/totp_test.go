@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890"
+var rfc6238Secret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// The RFC lists 8 digit codes, the last 6 digits are the 6 digit ones.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		code, err := Code(rfc6238Secret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("code at %d: %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("code at %d = %s, want %s", v.unix, code, v.code)
+		}
+	}
+}
+
+func TestValidateRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		at := time.Unix(v.unix, 0)
+		step, ok := Validate(rfc6238Secret, v.code, at)
+		if !ok || step != v.unix/period {
+			t.Errorf("validate at %d = (%d, %v), want (%d, true)", v.unix, step, ok, v.unix/period)
+		}
+	}
+	if _, ok := Validate(rfc6238Secret, "000000", time.Unix(59, 0)); ok {
+		t.Error("wrong code accepted")
+	}
+	if _, ok := Validate(rfc6238Secret, "28708", time.Unix(59, 0)); ok {
+		t.Error("short code accepted")
+	}
+}
+
+func TestValidateEarlierStep(t *testing.T) {
+	issued := time.Unix(1111111111, 0)
+	code, err := Code(rfc6238Secret, issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted, ok := Validate(rfc6238Secret, code, issued)
+	if !ok {
+		t.Fatal("fresh code rejected")
+	}
+
+	// within the skew window the code is still valid, the matched step lets callers reject the replay
+	step, ok := Validate(rfc6238Secret, code, issued.Add(period*time.Second))
+	if !ok || step != accepted {
+		t.Fatalf("replay in the next period = (%d, %v), want (%d, true)", step, ok, accepted)
+	}
+
+	// codes of steps outside the window are rejected outright
+	if _, ok := Validate(rfc6238Secret, code, issued.Add((skew+1)*period*time.Second)); ok {
+		t.Fatal("code of an expired step accepted")
+	}
+}
@@ -0,0 +1,174 @@
+package avatar
+
+import (
+	"bytes"
+	"chatapp/internal/config"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Uploaded images are checked by their header before decoding, a small compressed file can
+// declare dimensions needing gigabytes once decoded. The bounds fit phone photos and panoramas,
+// a decoded image takes at most 64MB.
+const (
+	maxSourceSide   = 6000
+	maxSourcePixels = 16 << 20
+)
+
+var (
+	ErrInvalidImage  = errors.New("unsupported or corrupted image")
+	ErrImageTooLarge = errors.New("image is too large")
+	ErrNotFound      = errors.New("avatar not found")
+)
+
+var fileNameRe = regexp.MustCompile(`^[0-9]+-[0-9a-f]{16}\.png$`)
+
+// Store keeps avatars as square PNG files named "<user id>-<random>.png". A new name is
+// used for every upload, so clients and proxies can cache them forever.
+type Store struct {
+	dir     string
+	size    int
+	maxSize int64
+}
+
+func NewStore(cfg *config.Config) *Store {
+	return &Store{
+		dir:     cfg.AvatarDir,
+		size:    cfg.AvatarSize,
+		maxSize: cfg.AvatarMaxUploadSize,
+	}
+}
+
+// Save crops the image to a centered square, scales it down and stores it, the file name is returned.
+func (s *Store) Save(usrId int64, r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.maxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > s.maxSize {
+		return "", ErrImageTooLarge
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return "", ErrInvalidImage
+	}
+	if cfg.Width > maxSourceSide || cfg.Height > maxSourceSide || cfg.Width*cfg.Height > maxSourcePixels {
+		return "", ErrImageTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrInvalidImage
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%d-%s.png", usrId, hex.EncodeToString(suffix))
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := png.Encode(tmp, resize(squareCrop(src), s.size)); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return name, os.Rename(tmp.Name(), filepath.Join(s.dir, name))
+}
+
+// Path returns the file of a stored avatar, names not produced by Save are rejected.
+func (s *Store) Path(name string) (string, error) {
+	if !fileNameRe.MatchString(name) {
+		return "", ErrNotFound
+	}
+	p := filepath.Join(s.dir, name)
+	if _, err := os.Stat(p); err != nil {
+		return "", ErrNotFound
+	}
+	return p, nil
+}
+
+// Remove deletes the avatar the URL points to, URLs of other origins are ignored.
+func (s *Store) Remove(url string) error {
+	name := url[strings.LastIndex(url, "/")+1:]
+	if !fileNameRe.MatchString(name) {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func squareCrop(src image.Image) image.Image {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), src, image.Pt(x0, y0), draw.Src)
+	return dst
+}
+
+// resize scales a square image down to size by averaging the source pixels covered by each
+// destination pixel, smaller images are kept as they are.
+func resize(src image.Image, size int) image.Image {
+	img, ok := src.(*image.NRGBA)
+	side := src.Bounds().Dx()
+	if !ok || side <= size {
+		return src
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for dy := 0; dy < size; dy++ {
+		sy0, sy1 := dy*side/size, (dy+1)*side/size
+		for dx := 0; dx < size; dx++ {
+			sx0, sx1 := dx*side/size, (dx+1)*side/size
+
+			var r, g, b, a, n int
+			for sy := sy0; sy < sy1; sy++ {
+				row := img.Pix[sy*img.Stride:]
+				for sx := sx0; sx < sx1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					// weight colors by alpha so transparent pixels don't darken edges
+					r += int(p[0]) * int(p[3])
+					g += int(p[1]) * int(p[3])
+					b += int(p[2]) * int(p[3])
+					a += int(p[3])
+					n++
+				}
+			}
+			o := dst.Pix[dy*dst.Stride+dx*4:]
+			if a > 0 {
+				o[0], o[1], o[2] = uint8(r/a), uint8(g/a), uint8(b/a)
+			}
+			o[3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,92 @@
+package avatar
+
+import (
+	"bytes"
+	"chatapp/internal/config"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestStore(t *testing.T) *Store {
+	return NewStore(&config.Config{AvatarDir: t.TempDir(), AvatarSize: 64, AvatarMaxUploadSize: 1 << 20})
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withDimensions rewrites the IHDR chunk of a PNG, the pixel data is left as is.
+// It is how decompression bombs look: a tiny file declaring a huge image.
+func withDimensions(t *testing.T, data []byte, w, h uint32) []byte {
+	t.Helper()
+	res := bytes.Clone(data)
+	// signature (8), chunk length (4), "IHDR" (4), then width and height
+	binary.BigEndian.PutUint32(res[16:], w)
+	binary.BigEndian.PutUint32(res[20:], h)
+	binary.BigEndian.PutUint32(res[29:], crc32.ChecksumIEEE(res[12:29]))
+	return res
+}
+
+func TestSaveScalesToSquare(t *testing.T) {
+	s := newTestStore(t)
+	name, err := s.Save(7, bytes.NewReader(encodePNG(t, 300, 200)))
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if !fileNameRe.MatchString(name) {
+		t.Fatalf("unexpected name %q", name)
+	}
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, err := png.DecodeConfig(f)
+	if err != nil || cfg.Width != 64 || cfg.Height != 64 {
+		t.Fatalf("stored avatar is %dx%d (%v), want 64x64", cfg.Width, cfg.Height, err)
+	}
+}
+
+func TestSaveRejectsHugeDimensions(t *testing.T) {
+	s := newTestStore(t)
+	small := encodePNG(t, 2, 2)
+	cases := map[string][2]uint32{
+		"wide":   {maxSourceSide + 1, 1},
+		"tall":   {1, maxSourceSide + 1},
+		"pixels": {maxSourceSide, maxSourceSide},
+		"bomb":   {1 << 20, 1 << 20},
+	}
+	for name, dims := range cases {
+		_, err := s.Save(1, bytes.NewReader(withDimensions(t, small, dims[0], dims[1])))
+		if !errors.Is(err, ErrImageTooLarge) {
+			t.Errorf("%s %dx%d: got %v, want %v", name, dims[0], dims[1], err, ErrImageTooLarge)
+		}
+	}
+}
+
+func TestSaveRejectsInvalidImages(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Save(1, bytes.NewReader([]byte("not an image"))); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("got %v, want %v", err, ErrInvalidImage)
+	}
+	big := bytes.Repeat([]byte{0}, 2<<20)
+	if _, err := s.Save(1, bytes.NewReader(big)); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("oversized upload: got %v, want %v", err, ErrImageTooLarge)
+	}
+}
@@ -0,0 +1,87 @@
+package block
+
+import (
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	chat_events "chatapp/internal/services/chat"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+var (
+	ErrCannotBlockSelf = errors.New("users can't block themselves")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrNotBlocked      = errors.New("user is not blocked")
+)
+
+// Service manages blocks between users. Websockets load the blocked ids once and keep them
+// up to date with the block events posted to the user.
+type Service struct {
+	logger logger.Logger
+	repos  *repositories.Repositories
+	evl    *chat_events.EventListener
+}
+
+func NewService(logger logger.Logger, repos *repositories.Repositories, evl *chat_events.EventListener) *Service {
+	return &Service{
+		logger: logger,
+		repos:  repos,
+		evl:    evl,
+	}
+}
+
+func (s *Service) Block(ctx context.Context, usrId, blockedId int64) error {
+	if usrId == blockedId {
+		return ErrCannotBlockSelf
+	}
+	blocked, err := s.repos.UserRepository.GetUserById(ctx, blockedId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get user: %w", err), slog.Int64("userId", blockedId))
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if blocked == nil {
+		return ErrUserNotFound
+	}
+	if err := s.repos.BlockRepository.Block(ctx, usrId, blockedId); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to block user: %w", err), slog.Int64("userId", usrId), slog.Int64("blockedId", blockedId))
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+	s.evl.PostUserBlocked(usrId, blockedId)
+	s.logger.Info(ctx, "user blocked", slog.Int64("userId", usrId), slog.Int64("blockedId", blockedId))
+	return nil
+}
+
+func (s *Service) Unblock(ctx context.Context, usrId, blockedId int64) error {
+	ok, err := s.repos.BlockRepository.Unblock(ctx, usrId, blockedId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to unblock user: %w", err), slog.Int64("userId", usrId), slog.Int64("blockedId", blockedId))
+		return fmt.Errorf("failed to unblock user: %w", err)
+	}
+	if !ok {
+		return ErrNotBlocked
+	}
+	s.evl.PostUserUnblocked(usrId, blockedId)
+	s.logger.Info(ctx, "user unblocked", slog.Int64("userId", usrId), slog.Int64("blockedId", blockedId))
+	return nil
+}
+
+func (s *Service) GetBlocks(ctx context.Context, usrId int64) ([]*users.Block, error) {
+	blocks, err := s.repos.BlockRepository.GetBlocks(ctx, usrId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get blocks: %w", err), slog.Int64("userId", usrId))
+		return nil, fmt.Errorf("failed to get blocks: %w", err)
+	}
+	return blocks, nil
+}
+
+// GetBlockedIds returns the ids of users the user blocked.
+func (s *Service) GetBlockedIds(ctx context.Context, usrId int64) ([]int64, error) {
+	ids, err := s.repos.BlockRepository.GetBlockedIds(ctx, usrId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blocked users: %w", err)
+	}
+	return ids, nil
+}
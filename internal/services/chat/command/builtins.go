@@ -0,0 +1,251 @@
+package command
+
+import (
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/services/chat/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxTopicLength = 250
+
+func (s *Service) help(ctx context.Context, inv *Invocation, _ *users.User) (*Response, error) {
+	var b strings.Builder
+	b.WriteString("Available commands:")
+
+	names := make([]string, 0, len(s.builtins))
+	for name := range s.builtins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n%s - %s", s.builtins[name].usage, s.builtins[name].description)
+	}
+
+	cmds, err := s.repos.BotCommandRepository.GetConversationCommands(ctx, inv.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commands: %w", err)
+	}
+	for _, cmd := range cmds {
+		fmt.Fprintf(&b, "\n/%s - %s", cmd.Name, cmd.Description)
+	}
+	return ephemeral("%s", b.String()), nil
+}
+
+func (s *Service) me(_ context.Context, inv *Invocation, usr *users.User) (*Response, error) {
+	if inv.Args == "" {
+		return ephemeral("Usage: %s", s.builtins["me"].usage), nil
+	}
+	return public("_%s %s_", usr.Username, inv.Args), nil
+}
+
+func (s *Service) topic(ctx context.Context, inv *Invocation, usr *users.User) (*Response, error) {
+	cnv, err := s.repos.ConversationRepository.GetConversationById(ctx, inv.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if cnv == nil {
+		return nil, utils.ErrConversationNotFound
+	}
+	if inv.Args == "" {
+		if cnv.Topic == "" {
+			return ephemeral("The conversation has no topic"), nil
+		}
+		return ephemeral("Topic: %s", cnv.Topic), nil
+	}
+
+	if err := s.aCh.CanManageConversation(ctx, inv.ConversationID, usr.ID); err != nil {
+		if errors.Is(err, utils.ErrIsNotConversationAdmin) {
+			return ephemeral("Only conversation admins can change the topic"), nil
+		}
+		return nil, err
+	}
+	if utf8.RuneCountInString(inv.Args) > maxTopicLength {
+		return ephemeral("The topic must not be longer than %d characters", maxTopicLength), nil
+	}
+	if err := s.repos.ConversationRepository.UpdateTopic(ctx, cnv, inv.Args); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to update topic: %w", err), slog.Int64("conversationId", cnv.ID))
+		return nil, fmt.Errorf("failed to update topic: %w", err)
+	}
+	s.evl.PostConversationUpdated(cnv)
+
+	return public("%s changed the topic to: %s", usr.Username, cnv.Topic), nil
+}
+
+func (s *Service) invite(ctx context.Context, inv *Invocation, usr *users.User) (*Response, error) {
+	target := strings.TrimPrefix(inv.Args, "@")
+	if target == "" || strings.ContainsFunc(target, unicode.IsSpace) {
+		return ephemeral("Usage: %s", s.builtins["invite"].usage), nil
+	}
+	if err := s.aCh.CanManageConversation(ctx, inv.ConversationID, usr.ID); err != nil {
+		if errors.Is(err, utils.ErrIsNotConversationAdmin) {
+			return ephemeral("Only conversation admins can invite users"), nil
+		}
+		return nil, err
+	}
+	cnv, err := s.repos.ConversationRepository.GetConversationById(ctx, inv.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if cnv == nil {
+		return nil, utils.ErrConversationNotFound
+	}
+	if !cnv.IsGroup {
+		return ephemeral("Users can be invited only to group conversations"), nil
+	}
+
+	invitee, res, err := s.resolveUser(ctx, usr, target)
+	if err != nil || res != nil {
+		return res, err
+	}
+	isParticipant, err := s.repos.ConversationRepository.IsParticipant(ctx, cnv.ID, invitee.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check participant: %w", err)
+	}
+	if isParticipant {
+		return ephemeral("%s is already in the conversation", invitee.Username), nil
+	}
+	blockers, err := s.repos.BlockRepository.GetBlockersOf(ctx, usr.ID, []int64{invitee.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check blocks: %w", err)
+	}
+	if len(blockers) > 0 {
+		return ephemeral("%s can't be invited", invitee.Username), nil
+	}
+	if err := s.addMember(ctx, cnv.ID, invitee.ID); err != nil {
+		return nil, err
+	}
+	s.logger.Info(ctx, "user invited", slog.Int64("conversationId", cnv.ID), slog.Int64("userId", invitee.ID), slog.Int64("invitedBy", usr.ID))
+
+	return public("%s invited %s", usr.Username, invitee.Username), nil
+}
+
+// maxInviteCandidates bounds the users with the same name looked at before the ones the inviter can't see are dropped
+const maxInviteCandidates = 10
+
+// resolveUser finds the user by email or by name, names are not unique so an ambiguous name is reported to the invoker.
+// Users the inviter can't invite are reported as not found, so the reply does not tell whether an email is registered.
+func (s *Service) resolveUser(ctx context.Context, inviter *users.User, target string) (*users.User, *Response, error) {
+	if strings.Contains(target, "@") {
+		u, err := s.repos.UserRepository.GetUserByEmail(ctx, target)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		if u != nil {
+			ok, err := s.canInvite(ctx, inviter, u)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ok {
+				return u, nil, nil
+			}
+		}
+		return nil, ephemeral("User %s not found", target), nil
+	}
+
+	found, err := s.repos.UserRepository.GetUsersByUsername(ctx, target, maxInviteCandidates)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	var invitable []*users.User
+	for _, u := range found {
+		ok, err := s.canInvite(ctx, inviter, u)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			invitable = append(invitable, u)
+		}
+	}
+	switch len(invitable) {
+	case 0:
+		return nil, ephemeral("User @%s not found", target), nil
+	case 1:
+		return invitable[0], nil, nil
+	}
+	return nil, ephemeral("Several users are named @%s, invite by email instead", target), nil
+}
+
+// canInvite applies the directory rules to invites: bots, deleted accounts and the deleted user placeholder can't be
+// invited, and users hidden from the directory can be invited only by people sharing a conversation with them.
+func (s *Service) canInvite(ctx context.Context, inviter, u *users.User) (bool, error) {
+	if u.IsBot || u.IsDeleted() || u.Email == users.DeletedUserEmail {
+		return false, nil
+	}
+	if u.Discoverability == users.DiscoverableEveryone {
+		return true, nil
+	}
+	shared, err := s.repos.ConversationRepository.SharesConversation(ctx, inviter.ID, u.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check shared conversations: %w", err)
+	}
+	return shared, nil
+}
+
+func (s *Service) addMember(ctx context.Context, cnvId, usrId int64) (err error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				s.logger.Error(ctx, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+			}
+			return
+		}
+		if commitErr := tx.Commit(); commitErr != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", commitErr)
+			return
+		}
+		s.evl.PostParticipantAdded(&chatEnts.ConversationParticipant{
+			ConversationID: cnvId,
+			UserID:         usrId,
+			JoinedAt:       time.Now(),
+			Role:           chatEnts.ParticipantRoleMember,
+		})
+	}()
+
+	if err = s.repos.ConversationRepository.AddParticipant(ctx, tx, cnvId, usrId, chatEnts.ParticipantRoleMember); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("faild to add participant: %w", err), slog.Int64("conversationId", cnvId), slog.Int64("userId", usrId))
+		return fmt.Errorf("faild to add participant: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) mute(ctx context.Context, inv *Invocation, usr *users.User) (*Response, error) {
+	var (
+		muted = true
+		until *time.Time
+		res   *Response
+	)
+	switch inv.Args {
+	case "":
+		res = ephemeral("Notifications of this conversation are muted, type /mute off to unmute")
+	case "off":
+		muted = false
+		res = ephemeral("Notifications of this conversation are unmuted")
+	default:
+		d, err := time.ParseDuration(inv.Args)
+		if err != nil || d <= 0 {
+			return ephemeral("Usage: %s", s.builtins["mute"].usage), nil
+		}
+		t := time.Now().Add(d).UTC()
+		until = &t
+		res = ephemeral("Notifications of this conversation are muted until %s", t.Format("2006-01-02 15:04 MST"))
+	}
+
+	if err := s.repos.ConversationRepository.SetMuted(ctx, inv.ConversationID, usr.ID, muted, until); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to mute conversation: %w", err), slog.Int64("conversationId", inv.ConversationID))
+		return nil, fmt.Errorf("failed to mute conversation: %w", err)
+	}
+	return res, nil
+}
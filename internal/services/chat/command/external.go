@@ -0,0 +1,224 @@
+package command
+
+import (
+	"bytes"
+	command_dto "chatapp/internal/dto/command"
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/integrations"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/services/netguard"
+	"chatapp/internal/services/webhook"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// CommandEvent is sent in the event header of invocations
+	CommandEvent = "slash_command"
+
+	ResponseTypeEphemeral = "ephemeral"
+	ResponseTypeInChannel = "in_channel"
+
+	maxResponseSize = 64 << 10
+)
+
+// invocationPayload is the JSON body sent to external commands.
+type invocationPayload struct {
+	Command        string    `json:"command"`
+	Text           string    `json:"text"`
+	ConversationID int64     `json:"conversation_id"`
+	UserID         int64     `json:"user_id"`
+	UserName       string    `json:"user_name"`
+	InvokedAt      time.Time `json:"invoked_at"`
+}
+
+// commandResponse is the JSON body external commands respond with, responses are ephemeral unless response_type is "in_channel".
+type commandResponse struct {
+	Text         string `json:"text"`
+	ResponseType string `json:"response_type"`
+}
+
+// CreateCommand registers an external command, a bot answering on its behalf is added to the conversation.
+// The signing secret is shown only once, the URL must point to a public address.
+func (s *Service) CreateCommand(ctx context.Context, owner *users.User, dto *command_dto.CreateCommandDTO) (cmd *integrations.BotCommand, secret string, err error) {
+	if err := s.aCh.CanManageConversation(ctx, dto.ConversationID, owner.ID); err != nil {
+		return nil, "", err
+	}
+	if !namePattern.MatchString(dto.Name) {
+		return nil, "", ErrInvalidCommandName
+	}
+	if err := netguard.CheckURL(ctx, dto.URL, s.cfg.WebhookAllowPrivateNetworks); err != nil {
+		return nil, "", err
+	}
+	if _, ok := s.builtins[dto.Name]; ok {
+		return nil, "", ErrCommandExists
+	}
+	existing, err := s.repos.BotCommandRepository.GetCommand(ctx, dto.ConversationID, dto.Name)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get command: %w", err)
+	}
+	if existing != nil {
+		return nil, "", ErrCommandExists
+	}
+
+	secret, err = s.hashService.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	sealed, err := s.box.Seal(secret)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt command secret: %w", err)
+	}
+	cmd = &integrations.BotCommand{
+		ConversationID: dto.ConversationID,
+		CreatedBy:      owner.ID,
+		Name:           dto.Name,
+		Description:    dto.Description,
+		URL:            dto.URL,
+		Secret:         sealed,
+	}
+
+	// the bot is created in the transaction, so a failure doesn't leave a bot without its command
+	var bot *users.User
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				s.logger.Error(ctx, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+			}
+			return
+		}
+		if commitErr := tx.Commit(); commitErr != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", commitErr)
+			return
+		}
+		s.evl.PostParticipantAdded(&chatEnts.ConversationParticipant{
+			ConversationID: cmd.ConversationID,
+			UserID:         bot.ID,
+			JoinedAt:       time.Now(),
+			Role:           chatEnts.ParticipantRoleMember,
+		})
+	}()
+
+	if bot, err = s.bots.CreateBot(ctx, tx, owner, dto.Name); err != nil {
+		return nil, "", err
+	}
+	cmd.BotUserID = bot.ID
+	if err = s.repos.ConversationRepository.AddParticipant(ctx, tx, dto.ConversationID, bot.ID, chatEnts.ParticipantRoleMember); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("faild to add command bot to conversation: %w", err), slog.Int64("conversationId", dto.ConversationID))
+		return nil, "", fmt.Errorf("faild to add command bot to conversation: %w", err)
+	}
+	if err = s.repos.BotCommandRepository.Create(ctx, tx, cmd); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to create command: %w", err), slog.Int64("conversationId", dto.ConversationID))
+		return nil, "", fmt.Errorf("failed to create command: %w", err)
+	}
+	s.logger.Info(ctx, "command created", slog.Int64("commandId", cmd.ID), slog.Int64("conversationId", cmd.ConversationID))
+
+	return cmd, secret, nil
+}
+
+func (s *Service) GetCommands(ctx context.Context, usrId, cnvId int64) ([]*integrations.BotCommand, error) {
+	if err := s.aCh.CanManageConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	return s.repos.BotCommandRepository.GetConversationCommands(ctx, cnvId)
+}
+
+// DeleteCommand unregisters the command, the bot stays in the conversation as the author of past responses.
+func (s *Service) DeleteCommand(ctx context.Context, usrId, cnvId, cmdId int64) error {
+	if err := s.aCh.CanManageConversation(ctx, cnvId, usrId); err != nil {
+		return err
+	}
+	ok, err := s.repos.BotCommandRepository.Delete(ctx, cnvId, cmdId)
+	if err != nil {
+		return fmt.Errorf("failed to delete command: %w", err)
+	}
+	if !ok {
+		return ErrCommandNotFound
+	}
+	s.logger.Info(ctx, "command deleted", slog.Int64("commandId", cmdId), slog.Int64("conversationId", cnvId))
+	return nil
+}
+
+// invoke calls the external command, failures are reported to the invoker instead of failing the message.
+func (s *Service) invoke(ctx context.Context, cmd *integrations.BotCommand, inv *Invocation, usr *users.User) *Response {
+	res, err := s.call(ctx, cmd, inv, usr)
+	if err != nil {
+		s.logger.Warn(ctx, "external command failed", slog.Int64("commandId", cmd.ID), slog.String("error", err.Error()))
+		return ephemeral("/%s failed to respond, try again later", cmd.Name)
+	}
+	if res.Text == "" {
+		return ephemeral("/%s received", cmd.Name)
+	}
+	if res.ResponseType == ResponseTypeInChannel {
+		return &Response{
+			Text:     res.Text,
+			SenderID: cmd.BotUserID,
+		}
+	}
+	return &Response{
+		Text:      res.Text,
+		Ephemeral: true,
+		SenderID:  cmd.BotUserID,
+	}
+}
+
+func (s *Service) call(ctx context.Context, cmd *integrations.BotCommand, inv *Invocation, usr *users.User) (*commandResponse, error) {
+	secret, err := s.box.Open(cmd.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt command secret: %w", err)
+	}
+	body, err := json.Marshal(&invocationPayload{
+		Command:        cmd.Name,
+		Text:           inv.Args,
+		ConversationID: inv.ConversationID,
+		UserID:         usr.ID,
+		UserName:       usr.Username,
+		InvokedAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	ts := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cmd.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "chatapp-commands/1.0")
+	req.Header.Set(webhook.EventHeader, CommandEvent)
+	req.Header.Set(webhook.TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(secret, ts, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	res := &commandResponse{}
+	if len(bytes.TrimSpace(respBody)) == 0 {
+		return res, nil
+	}
+	if err := json.Unmarshal(respBody, res); err != nil {
+		return nil, fmt.Errorf("invalid response body: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,158 @@
+package command
+
+import (
+	"chatapp/internal/clients"
+	"chatapp/internal/config"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	chat_events "chatapp/internal/services/chat"
+	"chatapp/internal/services/chat/utils"
+	"chatapp/internal/services/hash"
+	"chatapp/internal/services/netguard"
+	"chatapp/internal/services/secretbox"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrCommandNotFound    = errors.New("command not found")
+	ErrCommandExists      = errors.New("command already exists")
+	ErrInvalidCommandName = errors.New("command name must start with a letter and contain only lowercase letters, digits, '-' and '_'")
+)
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// Invocation is a message starting with "/" sent by a participant.
+type Invocation struct {
+	ConversationID int64
+	UserID         int64
+	Name           string
+	Args           string
+}
+
+// Response is the reply of a command. Public responses are stored as regular messages.
+type Response struct {
+	Text string
+	// Ephemeral responses are shown only to the invoker and never stored
+	Ephemeral bool
+	// SenderID sends the response on behalf of another user, the invoker when zero
+	SenderID int64
+}
+
+func ephemeral(format string, args ...any) *Response {
+	return &Response{
+		Text:      fmt.Sprintf(format, args...),
+		Ephemeral: true,
+	}
+}
+
+func public(format string, args ...any) *Response {
+	return &Response{
+		Text: fmt.Sprintf(format, args...),
+	}
+}
+
+// Parse splits "/name args" into the command name and its arguments.
+// Messages like "/usr/bin is empty" are not commands, ok is false for them.
+func Parse(content string) (name, args string, ok bool) {
+	if !strings.HasPrefix(content, "/") {
+		return "", "", false
+	}
+	name = content[1:]
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, args = name[:i], name[i:]
+	}
+	name = strings.ToLower(name)
+	if !namePattern.MatchString(name) {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(args), true
+}
+
+type botCreator interface {
+	CreateBot(ctx context.Context, q sqlx.QueryerContext, owner *users.User, name string) (*users.User, error)
+}
+
+type builtin struct {
+	usage       string
+	description string
+	run         func(ctx context.Context, inv *Invocation, usr *users.User) (*Response, error)
+}
+
+// Service runs slash commands: built-in commands first, then external commands registered in the conversation.
+type Service struct {
+	cfg         *config.Config
+	logger      logger.Logger
+	repos       *repositories.Repositories
+	db          *sqlx.DB
+	aCh         *utils.AccessChecker
+	box         *secretbox.Box
+	hashService *hash.Service
+	bots        botCreator
+	evl         *chat_events.EventListener
+	client      *http.Client
+
+	builtins map[string]*builtin
+}
+
+func NewService(
+	cfg *config.Config,
+	cls *clients.Clients,
+	logger logger.Logger,
+	repos *repositories.Repositories,
+	box *secretbox.Box,
+	bots botCreator,
+	evl *chat_events.EventListener,
+) *Service {
+	s := &Service{
+		cfg:         cfg,
+		logger:      logger,
+		repos:       repos,
+		db:          cls.Postgres,
+		aCh:         utils.NewAccesChecker(logger, repos),
+		box:         box,
+		hashService: hash.NewService(cfg),
+		bots:        bots,
+		evl:         evl,
+		client:      netguard.NewClient(cfg.BotCommandTimeout, cfg.WebhookAllowPrivateNetworks),
+	}
+	s.builtins = map[string]*builtin{
+		"help":   {usage: "/help", description: "list available commands", run: s.help},
+		"me":     {usage: "/me <action>", description: "send an action, like \"/me waves\"", run: s.me},
+		"topic":  {usage: "/topic [text]", description: "show or change the conversation topic", run: s.topic},
+		"invite": {usage: "/invite @user|email", description: "add a user to the conversation", run: s.invite},
+		"mute":   {usage: "/mute [duration|off]", description: "mute notifications of the conversation, like \"/mute 2h\"", run: s.mute},
+	}
+	return s
+}
+
+// Execute runs the command, mistakes of the invoker like a wrong usage are reported as ephemeral responses.
+func (s *Service) Execute(ctx context.Context, inv *Invocation) (*Response, error) {
+	usr, err := s.repos.UserRepository.GetUserById(ctx, inv.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoker: %w", err)
+	}
+	if usr == nil {
+		return nil, fmt.Errorf("invoker %d not found", inv.UserID)
+	}
+
+	if b, ok := s.builtins[inv.Name]; ok {
+		return b.run(ctx, inv, usr)
+	}
+	cmd, err := s.repos.BotCommandRepository.GetCommand(ctx, inv.ConversationID, inv.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get command: %w", err)
+	}
+	if cmd == nil {
+		return ephemeral("Unknown command /%s, type /help to list available commands", inv.Name), nil
+	}
+	return s.invoke(ctx, cmd, inv, usr), nil
+}
@@ -3,6 +3,7 @@ package conversation
 import (
 	"chatapp/internal/clients"
 	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
 	"chatapp/internal/logger"
 	"chatapp/internal/repositories"
 	chat_events "chatapp/internal/services/chat"
@@ -12,12 +13,16 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
 var (
 	ErrIsNotConversationParticipant = errors.New("user is not conversation participant")
+	ErrBlocked                      = errors.New("a participant has blocked you or is blocked by you")
+	ErrContactsOnly                 = errors.New("the user accepts direct conversations only from contacts")
 )
 
 type Service struct {
@@ -44,7 +49,12 @@ func NewService(
 	}
 }
 
-func (s *Service) CreateConversation(ctx context.Context, name string, isGroup bool, pts []int64) (cnv *chatEnts.Conversation, err error) {
+// CreateConversation creates a conversation with the creator as admin.
+// There is nobody to moderate in direct conversations, so both sides are admins there.
+func (s *Service) CreateConversation(ctx context.Context, creatorId int64, name string, isGroup bool, pts []int64) (cnv *chatEnts.Conversation, err error) {
+	if err := s.checkBlocks(ctx, creatorId, pts); err != nil {
+		return nil, err
+	}
 	cnv = &chatEnts.Conversation{
 		Name:    name,
 		IsGroup: isGroup,
@@ -69,6 +79,14 @@ func (s *Service) CreateConversation(ctx context.Context, name string, isGroup b
 		} else {
 			if commitErr := tx.Commit(); commitErr != nil {
 				err = fmt.Errorf("failed to commit transaction: %w", commitErr)
+				return
+			}
+			for _, p := range pts {
+				s.evl.PostParticipantAdded(&chatEnts.ConversationParticipant{
+					ConversationID: cnv.ID,
+					UserID:         p,
+					JoinedAt:       time.Now(),
+				})
 			}
 		}
 	}()
@@ -79,7 +97,11 @@ func (s *Service) CreateConversation(ctx context.Context, name string, isGroup b
 		return nil, fmt.Errorf("failed create conversation: %w", err)
 	}
 	for _, p := range pts {
-		if err = s.repos.ConversationRepository.AddParticipant(ctx, tx, cnv.ID, p); err != nil {
+		role := chatEnts.ParticipantRoleMember
+		if p == creatorId || !isGroup {
+			role = chatEnts.ParticipantRoleAdmin
+		}
+		if err = s.repos.ConversationRepository.AddParticipant(ctx, tx, cnv.ID, p, role); err != nil {
 			s.logger.Error(ctx, fmt.Errorf("faild to add participant in conversation: %w", err),
 				slog.Any("conversation", cnv), slog.Int64("userId", p))
 			return nil, fmt.Errorf("faild to add participant in conversation: %w", err)
@@ -90,6 +112,61 @@ func (s *Service) CreateConversation(ctx context.Context, name string, isGroup b
 	return cnv, nil
 }
 
+// checkBlocks rejects adding users who blocked the creator. Conversations with a single other user are also
+// rejected when the creator blocked them or isn't allowed by their DM policy, a "group" of two is still a DM.
+func (s *Service) checkBlocks(ctx context.Context, creatorId int64, pts []int64) error {
+	others := make([]int64, 0, len(pts))
+	for _, p := range pts {
+		if p != creatorId && !slices.Contains(others, p) {
+			others = append(others, p)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	if len(others) == 1 {
+		blocked, err := s.repos.BlockRepository.IsBlockedEither(ctx, creatorId, others[0])
+		if err != nil {
+			s.logger.Error(ctx, fmt.Errorf("failed to check blocks: %w", err), slog.Int64("userId", creatorId))
+			return fmt.Errorf("failed to check blocks: %w", err)
+		}
+		if blocked {
+			return ErrBlocked
+		}
+		return s.checkDMPolicy(ctx, creatorId, others[0])
+	}
+	blockers, err := s.repos.BlockRepository.GetBlockersOf(ctx, creatorId, others)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to check blocks: %w", err), slog.Int64("userId", creatorId))
+		return fmt.Errorf("failed to check blocks: %w", err)
+	}
+	if len(blockers) > 0 {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// checkDMPolicy rejects direct conversations with users who accept them only from contacts.
+func (s *Service) checkDMPolicy(ctx context.Context, creatorId, otherId int64) error {
+	other, err := s.repos.UserRepository.GetUserById(ctx, otherId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get user: %w", err), slog.Int64("userId", otherId))
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if other == nil || other.DMPolicy != users.DMPolicyContacts {
+		return nil
+	}
+	isContact, err := s.repos.ContactRepository.IsContact(ctx, otherId, creatorId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to check contact: %w", err), slog.Int64("userId", otherId))
+		return fmt.Errorf("failed to check contact: %w", err)
+	}
+	if !isContact {
+		return ErrContactsOnly
+	}
+	return nil
+}
+
 func (s *Service) PostUserTyping(ctx context.Context, usrId, cnvId int64) error {
 	if err := s.aCh.CanAccessConversation(ctx, cnvId, usrId); err != nil {
 		s.logger.Error(ctx, fmt.Errorf("PostUserTyping: failed to access conversation: %w", err), slog.Int64("conversation", cnvId), slog.Int64("user", usrId))
@@ -98,3 +175,57 @@ func (s *Service) PostUserTyping(ctx context.Context, usrId, cnvId int64) error
 	s.evl.PostUserTyping(cnvId, usrId)
 	return nil
 }
+
+// SetMessageTTL sets the lifetime of new messages, nil keeps them forever. Existing messages keep their expiry.
+func (s *Service) SetMessageTTL(ctx context.Context, usrId, cnvId int64, ttl *time.Duration) (*chatEnts.Conversation, error) {
+	if err := s.aCh.CanManageConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	cnv, err := s.repos.ConversationRepository.GetConversationById(ctx, cnvId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get conversation: %w", err), slog.Int64("conversation", cnvId))
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if cnv == nil {
+		return nil, utils.ErrConversationNotFound
+	}
+	var seconds *int
+	if ttl != nil {
+		n := int(ttl.Seconds())
+		seconds = &n
+	}
+	if err := s.repos.ConversationRepository.SetMessageTTL(ctx, cnv, seconds); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to set message ttl: %w", err), slog.Int64("conversation", cnvId))
+		return nil, fmt.Errorf("failed to set message ttl: %w", err)
+	}
+	s.evl.PostConversationUpdated(cnv)
+	s.logger.Info(ctx, "conversation message ttl changed", slog.Int64("conversation", cnvId), slog.Int64("user", usrId), slog.Any("ttl", seconds))
+	return cnv, nil
+}
+
+// SetMuted mutes notifications of the conversation for the user, until nil mutes it until unmuted.
+func (s *Service) SetMuted(ctx context.Context, usrId, cnvId int64, muted bool, until *time.Time) (*chatEnts.ConversationParticipant, error) {
+	if err := s.aCh.CanAccessConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	if !muted {
+		until = nil
+	}
+	if err := s.repos.ConversationRepository.SetMuted(ctx, cnvId, usrId, muted, until); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to set muted: %w", err), slog.Int64("conversation", cnvId), slog.Int64("user", usrId))
+		return nil, fmt.Errorf("failed to set muted: %w", err)
+	}
+	p, err := s.repos.ConversationRepository.GetParticipant(ctx, cnvId, usrId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get participant: %w", err), slog.Int64("conversation", cnvId), slog.Int64("user", usrId))
+		return nil, fmt.Errorf("failed to get participant: %w", err)
+	}
+	return p, nil
+}
+
+// MarkSeen records that the user has the conversation open, messages up to now are not digested.
+func (s *Service) MarkSeen(ctx context.Context, cnvId, usrId int64) {
+	if err := s.repos.ConversationRepository.MarkSeen(ctx, cnvId, usrId); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to mark conversation seen: %w", err), slog.Int64("conversation", cnvId), slog.Int64("user", usrId))
+	}
+}
@@ -2,21 +2,91 @@ package chat
 
 import (
 	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
 	"chatapp/internal/services/events"
+	"context"
+	"fmt"
+	"log/slog"
 	"sync"
 )
 
 type EventListener struct {
 	ecs map[int64]*events.EventChannel
 	mu  sync.RWMutex
+
+	// all receives events of every conversation, for background consumers like webhooks.
+	// It never waits for them, events are dropped for a consumer which falls behind.
+	all *events.EventChannel
+
+	// online counts open websockets of users per conversation
+	online   map[int64]map[int64]int
+	onlineMu sync.RWMutex
+
+	// ucs carries events addressed to a user rather than a conversation, like contact requests
+	ucs map[int64]*events.EventChannel
+	uMu sync.RWMutex
 }
 
-func NewEventListener() *EventListener {
+func NewEventListener(logger logger.Logger) *EventListener {
+	dropped := func(ev events.Event) {
+		logger.Error(context.Background(), fmt.Errorf("background consumer is too slow, event dropped"), slog.String("event", string(ev.Type)))
+	}
 	return &EventListener{
-		ecs: make(map[int64]*events.EventChannel),
+		ecs:    make(map[int64]*events.EventChannel),
+		all:    events.StartLossyEventChannel(dropped),
+		online: make(map[int64]map[int64]int),
+		ucs:    make(map[int64]*events.EventChannel),
+	}
+}
+
+// SetOnline marks the user as listening to the conversation, every call must be paired with SetOffline.
+func (e *EventListener) SetOnline(cnvId, usrId int64) {
+	e.onlineMu.Lock()
+	defer e.onlineMu.Unlock()
+
+	users, ok := e.online[cnvId]
+	if !ok {
+		users = make(map[int64]int)
+		e.online[cnvId] = users
+	}
+	users[usrId]++
+}
+
+func (e *EventListener) SetOffline(cnvId, usrId int64) {
+	e.onlineMu.Lock()
+	defer e.onlineMu.Unlock()
+
+	users, ok := e.online[cnvId]
+	if !ok {
+		return
+	}
+	users[usrId]--
+	if users[usrId] <= 0 {
+		delete(users, usrId)
+	}
+	if len(users) == 0 {
+		delete(e.online, cnvId)
 	}
 }
 
+// IsOnline reports whether the user has an open websocket of the conversation.
+func (e *EventListener) IsOnline(cnvId, usrId int64) bool {
+	e.onlineMu.RLock()
+	defer e.onlineMu.RUnlock()
+
+	return e.online[cnvId][usrId] > 0
+}
+
+// SubscribeAll subscribes to events of all conversations except typing notifications.
+func (e *EventListener) SubscribeAll(l chan<- events.Event) {
+	e.all.Subscribe(l)
+}
+
+func (e *EventListener) UnsubscribeAll(l chan<- events.Event) {
+	e.all.Unsubscribe(l)
+}
+
 func (e *EventListener) SubscribeChannel(convId int64, l chan<- events.Event) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -55,6 +125,8 @@ func (e *EventListener) PostUserTyping(cnvId, usrId int64) {
 }
 
 func (e *EventListener) PostMessageCreated(msg *chatEnts.Message) {
+	e.all.PostMessageCreated(msg)
+
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
@@ -66,6 +138,8 @@ func (e *EventListener) PostMessageCreated(msg *chatEnts.Message) {
 }
 
 func (e *EventListener) PostMessageUpdated(msg *chatEnts.Message) {
+	e.all.PostMessageUpdated(msg)
+
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
@@ -75,3 +149,181 @@ func (e *EventListener) PostMessageUpdated(msg *chatEnts.Message) {
 	}
 	ch.PostMessageUpdated(msg)
 }
+
+func (e *EventListener) PostMessageExpired(msg *chatEnts.ExpiredMessage) {
+	e.all.PostMessageExpired(msg)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ch, ok := e.ecs[msg.ConversationID]
+	if !ok {
+		return
+	}
+	ch.PostMessageExpired(msg)
+}
+
+func (e *EventListener) PostMessagePinned(p *chatEnts.Pin) {
+	e.all.PostMessagePinned(p)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ch, ok := e.ecs[p.ConversationID]
+	if !ok {
+		return
+	}
+	ch.PostMessagePinned(p)
+}
+
+func (e *EventListener) PostMessageUnpinned(p *chatEnts.Pin) {
+	e.all.PostMessageUnpinned(p)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ch, ok := e.ecs[p.ConversationID]
+	if !ok {
+		return
+	}
+	ch.PostMessageUnpinned(p)
+}
+
+func (e *EventListener) PostParticipantAdded(p *chatEnts.ConversationParticipant) {
+	e.all.PostParticipantAdded(p)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ch, ok := e.ecs[p.ConversationID]
+	if !ok {
+		return
+	}
+	ch.PostParticipantAdded(p)
+}
+
+func (e *EventListener) PostConversationUpdated(cnv *chatEnts.Conversation) {
+	e.all.PostConversationUpdated(cnv)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ch, ok := e.ecs[cnv.ID]
+	if !ok {
+		return
+	}
+	ch.PostConversationUpdated(cnv)
+}
+
+func (e *EventListener) PostUserUpdated(u *chatEnts.UserUpdated) {
+	e.all.PostUserUpdated(u)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ch, ok := e.ecs[u.ConversationID]
+	if !ok {
+		return
+	}
+	ch.PostUserUpdated(u)
+}
+
+// PostEphemeralMessage delivers the message only to the websockets of the recipient, it is not published to SubscribeAll.
+func (e *EventListener) PostEphemeralMessage(usrId int64, msg *chatEnts.Message) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ch, ok := e.ecs[msg.ConversationID]
+	if !ok {
+		return
+	}
+	ch.PostEphemeralMessage(usrId, msg)
+}
+
+// PostExportFinished notifies the websockets of the requester, it is not published to SubscribeAll.
+func (e *EventListener) PostExportFinished(exp *chatEnts.Export) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ch, ok := e.ecs[exp.ConversationID]
+	if !ok {
+		return
+	}
+	ch.PostExportFinished(exp.RequestedBy, exp)
+}
+
+func (e *EventListener) SubscribeUser(usrId int64, l chan<- events.Event) {
+	e.uMu.Lock()
+	defer e.uMu.Unlock()
+
+	ch, ok := e.ucs[usrId]
+	if !ok {
+		ch = events.StartEventChannel()
+		e.ucs[usrId] = ch
+	}
+	ch.Subscribe(l)
+}
+
+func (e *EventListener) UnsubscribeUser(usrId int64, l chan<- events.Event) {
+	e.uMu.Lock()
+	defer e.uMu.Unlock()
+
+	ch, ok := e.ucs[usrId]
+	if !ok {
+		return
+	}
+	ch.Unsubscribe(l)
+
+	if !ch.IsEmpty() {
+		return
+	}
+	ch.Stop()
+	delete(e.ucs, usrId)
+}
+
+// PostContactRequest notifies the user websockets of both the sender and the recipient,
+// it is not published to SubscribeAll.
+func (e *EventListener) PostContactRequest(req *users.ContactRequest) {
+	e.uMu.RLock()
+	defer e.uMu.RUnlock()
+
+	for _, usrId := range []int64{req.SenderID, req.RecipientID} {
+		if ch, ok := e.ucs[usrId]; ok {
+			ch.PostContactRequest(req)
+		}
+	}
+}
+
+// PostContactRemoved notifies the user websockets of both former contacts.
+func (e *EventListener) PostContactRemoved(usrId, contactId int64) {
+	e.uMu.RLock()
+	defer e.uMu.RUnlock()
+
+	if ch, ok := e.ucs[usrId]; ok {
+		ch.PostContactRemoved(contactId)
+	}
+	if ch, ok := e.ucs[contactId]; ok {
+		ch.PostContactRemoved(usrId)
+	}
+}
+
+// PostUserBlocked notifies the user websockets of the blocker, so open connections stop delivering
+// events of the blocked user. It is not published to SubscribeAll.
+func (e *EventListener) PostUserBlocked(usrId, blockedId int64) {
+	e.uMu.RLock()
+	defer e.uMu.RUnlock()
+
+	if ch, ok := e.ucs[usrId]; ok {
+		ch.PostUserBlocked(blockedId)
+	}
+}
+
+// PostUserUnblocked notifies the user websockets of the former blocker.
+func (e *EventListener) PostUserUnblocked(usrId, blockedId int64) {
+	e.uMu.RLock()
+	defer e.uMu.RUnlock()
+
+	if ch, ok := e.ucs[usrId]; ok {
+		ch.PostUserUnblocked(blockedId)
+	}
+}
@@ -0,0 +1,72 @@
+package message
+
+import (
+	"chatapp/internal/config"
+	"chatapp/internal/entities/jobs"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	chat_events "chatapp/internal/services/chat"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// JobKindExpireMessages is the periodic job deleting expired messages
+const JobKindExpireMessages = "expire_messages"
+
+// messageTTL returns the ttl of a new message, the shorter of the message and the conversation TTL applies.
+func (s *Service) messageTTL(ctx context.Context, cnvId int64, ttl *time.Duration) (*time.Duration, error) {
+	cnv, err := s.repos.ConversationRepository.GetConversationById(ctx, cnvId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get conversation: %w", err), slog.Int64("id", cnvId))
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if cnv != nil && cnv.MessageTTL != nil {
+		cnvTTL := time.Duration(*cnv.MessageTTL) * time.Second
+		if ttl == nil || cnvTTL < *ttl {
+			ttl = &cnvTTL
+		}
+	}
+	return ttl, nil
+}
+
+// Sweeper deletes expired messages and publishes message_expired events, so clients purge their copies.
+// Expired messages are already hidden from reads, the sweeper only reclaims them.
+type Sweeper struct {
+	cfg    *config.Config
+	logger logger.Logger
+	repos  *repositories.Repositories
+	evl    *chat_events.EventListener
+}
+
+func NewSweeper(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories, evl *chat_events.EventListener) *Sweeper {
+	return &Sweeper{
+		cfg:    cfg,
+		logger: logger,
+		repos:  repos,
+		evl:    evl,
+	}
+}
+
+// Sweep is the handler of the periodic job, it deletes batches until no expired message is left.
+func (s *Sweeper) Sweep(ctx context.Context, _ *jobs.Job) error {
+	total := 0
+	for {
+		expired, err := s.repos.MessageRepository.DeleteExpired(ctx, s.cfg.MessageExpiryBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to delete expired messages: %w", err)
+		}
+		for _, msg := range expired {
+			s.evl.PostMessageExpired(msg)
+		}
+		total += len(expired)
+		if len(expired) < s.cfg.MessageExpiryBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		s.logger.Info(ctx, "expired messages deleted", slog.Int("count", total))
+	}
+	return nil
+}
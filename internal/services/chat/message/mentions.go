@@ -0,0 +1,155 @@
+package message
+
+import (
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/services/chat/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrBroadcastMentionNotAllowed = errors.New("only conversation admins can mention @here and @all")
+
+// mentionPattern matches "@name" not preceded by a word character, so emails are not mentions
+var mentionPattern = regexp.MustCompile(`(^|[^\w@])@([A-Za-z0-9_-]{1,32})`)
+
+type mentionToken struct {
+	name   string
+	offset int
+	length int
+}
+
+func parseMentions(content string) []mentionToken {
+	var tokens []mentionToken
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		// the name group starts right after "@"
+		start, end := m[4]-1, m[5]
+		tokens = append(tokens, mentionToken{
+			name:   content[m[4]:m[5]],
+			offset: utf8.RuneCountInString(content[:start]),
+			length: utf8.RuneCountInString(content[start:end]),
+		})
+	}
+	return tokens
+}
+
+// resolveMentions returns mention ranges of the content and participants to notify, the sender is never notified.
+// Names not matching a participant are left as plain text.
+func (s *Service) resolveMentions(ctx context.Context, cnvId, senderId int64, content string) (chatEnts.Mentions, []int64, error) {
+	tokens := parseMentions(content)
+	if len(tokens) == 0 {
+		return nil, nil, nil
+	}
+	var (
+		names     []string
+		broadcast bool
+	)
+	for _, t := range tokens {
+		switch strings.ToLower(t.name) {
+		case chatEnts.MentionKindHere, chatEnts.MentionKindAll:
+			broadcast = true
+		default:
+			names = append(names, t.name)
+		}
+	}
+
+	var participants []*chatEnts.ConversationParticipant
+	if broadcast {
+		if err := s.aCh.CanManageConversation(ctx, cnvId, senderId); err != nil {
+			if errors.Is(err, utils.ErrIsNotConversationAdmin) {
+				return nil, nil, ErrBroadcastMentionNotAllowed
+			}
+			return nil, nil, err
+		}
+		var err error
+		if participants, err = s.repos.ConversationRepository.GetParticipants(ctx, cnvId); err != nil {
+			return nil, nil, fmt.Errorf("failed to get participants: %w", err)
+		}
+	}
+	byName := make(map[string][]*users.User)
+	if len(names) > 0 {
+		found, err := s.repos.ConversationRepository.GetParticipantsByUsernames(ctx, cnvId, names)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get mentioned users: %w", err)
+		}
+		for _, u := range found {
+			byName[u.Username] = append(byName[u.Username], u)
+		}
+	}
+
+	var mentions chatEnts.Mentions
+	notify := make(map[int64]struct{})
+	for _, t := range tokens {
+		switch kind := strings.ToLower(t.name); kind {
+		case chatEnts.MentionKindHere, chatEnts.MentionKindAll:
+			mentions = append(mentions, chatEnts.Mention{Kind: kind, Offset: t.offset, Length: t.length})
+			for _, p := range participants {
+				if kind == chatEnts.MentionKindAll || s.evl.IsOnline(cnvId, p.UserID) {
+					notify[p.UserID] = struct{}{}
+				}
+			}
+		default:
+			// user names are not unique, every participant with the name is mentioned
+			for _, u := range byName[t.name] {
+				mentions = append(mentions, chatEnts.Mention{Kind: chatEnts.MentionKindUser, UserID: u.ID, Offset: t.offset, Length: t.length})
+				notify[u.ID] = struct{}{}
+			}
+		}
+	}
+	delete(notify, senderId)
+
+	usrIds := make([]int64, 0, len(notify))
+	for id := range notify {
+		usrIds = append(usrIds, id)
+	}
+	slices.Sort(usrIds)
+	usrIds, err := s.withoutSuppressed(ctx, cnvId, senderId, usrIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mentions, usrIds, nil
+}
+
+// withoutSuppressed drops users who muted the conversation or blocked the sender,
+// their mentions are still rendered but they are not notified.
+func (s *Service) withoutSuppressed(ctx context.Context, cnvId, senderId int64, usrIds []int64) ([]int64, error) {
+	if len(usrIds) == 0 {
+		return usrIds, nil
+	}
+	participants, err := s.repos.ConversationRepository.GetParticipants(ctx, cnvId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get participants: %w", err)
+	}
+	suppressed := make(map[int64]struct{})
+	now := time.Now()
+	for _, p := range participants {
+		if p.IsMuted(now) {
+			suppressed[p.UserID] = struct{}{}
+		}
+	}
+	blockers, err := s.repos.BlockRepository.GetBlockersOf(ctx, senderId, usrIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blockers: %w", err)
+	}
+	for _, id := range blockers {
+		suppressed[id] = struct{}{}
+	}
+	return slices.DeleteFunc(usrIds, func(id int64) bool {
+		_, ok := suppressed[id]
+		return ok
+	}), nil
+}
+
+// saveMentions stores notified users, a failure is logged and does not fail the message.
+func (s *Service) saveMentions(ctx context.Context, message *chatEnts.Message, usrIds []int64) {
+	if err := s.repos.MentionRepository.Replace(ctx, message, usrIds); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to save mentions: %w", err), slog.Int64("messageId", message.ID))
+	}
+}
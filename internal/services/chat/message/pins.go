@@ -0,0 +1,85 @@
+package message
+
+import (
+	chatEnts "chatapp/internal/entities/chat"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+const maxPinsPerConversation = 100
+
+var (
+	ErrTooManyPins      = fmt.Errorf("conversation can't have more than %d pinned messages", maxPinsPerConversation)
+	ErrMessageNotPinned = errors.New("message is not pinned")
+)
+
+// PinMessage pins the message for all participants, pinning a pinned message does nothing.
+func (s *Service) PinMessage(ctx context.Context, cnvId, msgId, usrId int64) (*chatEnts.Pin, error) {
+	if err := s.aCh.CanManageConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	message, err := s.repos.MessageRepository.GetMessageById(ctx, cnvId, msgId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get message for pin: %w", err), slog.Int64("conversation", cnvId), slog.Int64("message", msgId))
+		return nil, fmt.Errorf("failed to pin message: %w", err)
+	}
+	if message == nil {
+		return nil, ErrMessageNotFound
+	}
+	count, err := s.repos.PinRepository.Count(ctx, cnvId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count pins: %w", err)
+	}
+	if count >= maxPinsPerConversation {
+		return nil, ErrTooManyPins
+	}
+
+	pin := &chatEnts.Pin{
+		ConversationID: cnvId,
+		MessageID:      msgId,
+		PinnedBy:       &usrId,
+	}
+	created, err := s.repos.PinRepository.Create(ctx, pin)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to pin message: %w", err), slog.Int64("conversation", cnvId), slog.Int64("message", msgId))
+		return nil, fmt.Errorf("failed to pin message: %w", err)
+	}
+	if !created {
+		return pin, nil
+	}
+	s.evl.PostMessagePinned(pin)
+	s.logger.Info(ctx, "message pinned", slog.Int64("conversation", cnvId), slog.Int64("message", msgId))
+	return pin, nil
+}
+
+func (s *Service) UnpinMessage(ctx context.Context, cnvId, msgId, usrId int64) error {
+	if err := s.aCh.CanManageConversation(ctx, cnvId, usrId); err != nil {
+		return err
+	}
+	pin, err := s.repos.PinRepository.Delete(ctx, cnvId, msgId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to unpin message: %w", err), slog.Int64("conversation", cnvId), slog.Int64("message", msgId))
+		return fmt.Errorf("failed to unpin message: %w", err)
+	}
+	if pin == nil {
+		return ErrMessageNotPinned
+	}
+	pin.UnpinnedBy = &usrId
+	s.evl.PostMessageUnpinned(pin)
+	s.logger.Info(ctx, "message unpinned", slog.Int64("conversation", cnvId), slog.Int64("message", msgId))
+	return nil
+}
+
+func (s *Service) GetPinnedMessages(ctx context.Context, cnvId, usrId int64) ([]*chatEnts.PinnedMessage, error) {
+	if err := s.aCh.CanAccessConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	pins, err := s.repos.PinRepository.GetPinnedMessages(ctx, cnvId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed get pinned messages: %w", err), slog.Int64("id", cnvId))
+		return nil, fmt.Errorf("failed get pinned messages: %w", err)
+	}
+	return pins, nil
+}
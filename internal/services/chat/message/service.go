@@ -6,51 +6,111 @@ import (
 	"chatapp/internal/logger"
 	"chatapp/internal/repositories"
 	chat_events "chatapp/internal/services/chat"
+	"chatapp/internal/services/chat/command"
 	"chatapp/internal/services/chat/utils"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 )
 
 var (
 	ErrMessageNotFound = errors.New("message not found")
 )
 
+type commandRunner interface {
+	Execute(ctx context.Context, inv *command.Invocation) (*command.Response, error)
+}
+
 type Service struct {
-	repos  *repositories.Repositories
-	aCh    *utils.AccessChecker
-	logger logger.Logger
+	repos    *repositories.Repositories
+	aCh      *utils.AccessChecker
+	logger   logger.Logger
+	commands commandRunner
 
 	evl *chat_events.EventListener
 }
 
-func NewService(logger logger.Logger, repos *repositories.Repositories, evl *chat_events.EventListener) *Service {
+func NewService(logger logger.Logger, repos *repositories.Repositories, evl *chat_events.EventListener, commands commandRunner) *Service {
 	return &Service{
-		repos:  repos,
-		aCh:    utils.NewAccesChecker(logger, repos),
-		logger: logger,
+		repos:    repos,
+		aCh:      utils.NewAccesChecker(logger, repos),
+		logger:   logger,
+		commands: commands,
 
 		evl: evl,
 	}
 }
 
-func (s *Service) SendMessage(ctx context.Context, cnvId, sederId int64, content string) (*chatEnts.Message, error) {
-	if err := s.aCh.CanAccessConversation(ctx, cnvId, sederId); err != nil {
-		s.logger.Error(ctx, fmt.Errorf("failed create message: %w", err), slog.Int64("id", cnvId))
+func (s *Service) SendMessage(ctx context.Context, dto *messages_dto.SendMessageDTO) (*chatEnts.Message, error) {
+	if err := s.aCh.CanAccessConversation(ctx, dto.ConversationID, dto.SenderID); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed create message: %w", err), slog.Int64("id", dto.ConversationID))
 		return nil, err
 	}
+	if !dto.Raw {
+		if name, args, ok := command.Parse(dto.Content); ok {
+			return s.runCommand(ctx, dto, name, args)
+		}
+	}
+	return s.createMessage(ctx, &chatEnts.Message{
+		ConversationID: dto.ConversationID,
+		SenderID:       dto.SenderID,
+		Content:        dto.Content,
+		DisplayName:    dto.DisplayName,
+		AvatarURL:      dto.AvatarURL,
+	}, dto.TTL)
+}
+
+// runCommand returns the response of the command, ephemeral responses are sent only to the invoker websockets.
+func (s *Service) runCommand(ctx context.Context, dto *messages_dto.SendMessageDTO, name, args string) (*chatEnts.Message, error) {
+	res, err := s.commands.Execute(ctx, &command.Invocation{
+		ConversationID: dto.ConversationID,
+		UserID:         dto.SenderID,
+		Name:           name,
+		Args:           args,
+	})
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to run command: %w", err), slog.Int64("id", dto.ConversationID), slog.String("command", name))
+		return nil, fmt.Errorf("failed to run command: %w", err)
+	}
 	message := &chatEnts.Message{
-		ConversationID: cnvId,
-		SenderID:       sederId,
-		Content:        content,
+		ConversationID: dto.ConversationID,
+		SenderID:       dto.SenderID,
+		Content:        res.Text,
+	}
+	if res.SenderID != 0 {
+		message.SenderID = res.SenderID
+	}
+	if !res.Ephemeral {
+		return s.createMessage(ctx, message, nil)
 	}
 
-	if err := s.repos.MessageRepository.Create(ctx, message); err != nil {
+	message.Ephemeral = true
+	message.CreatedAt = time.Now()
+	message.UpdatedAt = message.CreatedAt
+	s.evl.PostEphemeralMessage(dto.SenderID, message)
+	return message, nil
+}
+
+func (s *Service) createMessage(ctx context.Context, message *chatEnts.Message, ttl *time.Duration) (*chatEnts.Message, error) {
+	mentions, notify, err := s.resolveMentions(ctx, message.ConversationID, message.SenderID, message.Content)
+	if err != nil {
+		return nil, err
+	}
+	message.Mentions = mentions
+	if ttl, err = s.messageTTL(ctx, message.ConversationID, ttl); err != nil {
+		return nil, err
+	}
+
+	if err := s.repos.MessageRepository.Create(ctx, message, ttl); err != nil {
 		s.logger.Error(ctx, fmt.Errorf("failed create message: %w", err), slog.Any("message", message))
 		return nil, fmt.Errorf("failed create message: %w", err)
 	}
+	if len(notify) > 0 {
+		s.saveMentions(ctx, message, notify)
+	}
 	s.evl.PostMessageCreated(message)
 	s.logger.Info(ctx, "message created", slog.Any("message", message))
 	return message, nil
@@ -72,10 +132,15 @@ func (s *Service) UpdateMessage(ctx context.Context, cnvId, msgId, usrId int64,
 	if message.SenderID != usrId {
 		return nil, fmt.Errorf("have not access to update message content")
 	}
-	if err := s.repos.MessageRepository.Update(ctx, message, content); err != nil {
+	mentions, notify, err := s.resolveMentions(ctx, cnvId, usrId, content)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repos.MessageRepository.Update(ctx, message, content, mentions); err != nil {
 		s.logger.Error(ctx, fmt.Errorf("failed to update message: %w", err), slog.Any("message", message), slog.String("content", content))
 		return nil, fmt.Errorf("failed to update message: %w", err)
 	}
+	s.saveMentions(ctx, message, notify)
 
 	s.evl.PostMessageUpdated(message)
 	s.logger.Info(ctx, "message updated", slog.Any("message", message))
@@ -98,3 +163,41 @@ func (s *Service) GetMessages(ctx context.Context, params *messages_dto.GetMessa
 	}
 	return messages, nil
 }
+
+// GetMentions returns messages with unread mentions of the user, newest first.
+func (s *Service) GetMentions(ctx context.Context, params *messages_dto.GetMentionsQueryParams) ([]*chatEnts.Message, error) {
+	if params.ConvId != nil {
+		if err := s.aCh.CanAccessConversation(ctx, *params.ConvId, params.UserId); err != nil {
+			return nil, err
+		}
+	}
+	messages, err := s.repos.MentionRepository.GetUnreadMessages(ctx, params.UserId, params.ConvId, params.LastId, params.Limit)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed get mentions: %w", err), slog.Any("params", params))
+		return nil, fmt.Errorf("failed get mentions: %w", err)
+	}
+	return messages, nil
+}
+
+func (s *Service) GetMentionCounts(ctx context.Context, usrId int64) ([]*chatEnts.MentionCount, error) {
+	counts, err := s.repos.MentionRepository.GetUnreadCounts(ctx, usrId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed get mention counts: %w", err), slog.Int64("userId", usrId))
+		return nil, fmt.Errorf("failed get mention counts: %w", err)
+	}
+	return counts, nil
+}
+
+func (s *Service) MarkMentionsRead(ctx context.Context, usrId, cnvId int64) error {
+	if err := s.aCh.CanAccessConversation(ctx, cnvId, usrId); err != nil {
+		return err
+	}
+	if err := s.repos.MentionRepository.MarkRead(ctx, usrId, cnvId); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed mark mentions read: %w", err), slog.Int64("id", cnvId), slog.Int64("userId", usrId))
+		return fmt.Errorf("failed mark mentions read: %w", err)
+	}
+	if err := s.repos.ConversationRepository.MarkSeen(ctx, cnvId, usrId); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed mark conversation seen: %w", err), slog.Int64("id", cnvId), slog.Int64("userId", usrId))
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package scheduled
+
+import (
+	"chatapp/internal/clients"
+	messages_dto "chatapp/internal/dto/messages"
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/jobs"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"chatapp/internal/services/chat/command"
+	"chatapp/internal/services/chat/message"
+	"chatapp/internal/services/chat/utils"
+	"chatapp/internal/services/scheduler"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// JobKind is the scheduler job sending a scheduled message
+const JobKind = "scheduled_message"
+
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+var (
+	ErrScheduledMessageNotFound   = errors.New("scheduled message not found")
+	ErrScheduledMessageNotPending = errors.New("scheduled message was already sent or cancelled")
+	ErrInvalidSendTime            = errors.New("send time must be in the future and within a year")
+	ErrCommandNotSchedulable      = errors.New("slash commands can't be scheduled")
+)
+
+type messageSender interface {
+	SendMessage(ctx context.Context, dto *messages_dto.SendMessageDTO) (*chatEnts.Message, error)
+}
+
+type jobPayload struct {
+	ScheduledMessageID int64 `json:"scheduled_message_id"`
+	ConversationID     int64 `json:"conversation_id"`
+}
+
+// Service schedules messages, they are sent by a scheduler job which checks the access of the sender again.
+type Service struct {
+	logger    logger.Logger
+	repos     *repositories.Repositories
+	db        *sqlx.DB
+	aCh       *utils.AccessChecker
+	scheduler *scheduler.Scheduler
+	messages  messageSender
+}
+
+func NewService(cls *clients.Clients, logger logger.Logger, repos *repositories.Repositories, sch *scheduler.Scheduler, messages messageSender) *Service {
+	s := &Service{
+		logger:    logger,
+		repos:     repos,
+		db:        cls.Postgres,
+		aCh:       utils.NewAccesChecker(logger, repos),
+		scheduler: sch,
+		messages:  messages,
+	}
+	sch.Register(JobKind, s.deliver)
+	return s
+}
+
+func (s *Service) ScheduleMessage(ctx context.Context, dto *messages_dto.ScheduleMessageDTO) (m *chatEnts.ScheduledMessage, err error) {
+	if err := s.aCh.CanAccessConversation(ctx, dto.ConversationID, dto.SenderID); err != nil {
+		return nil, err
+	}
+	if err := validate(dto.Content, dto.SendAt); err != nil {
+		return nil, err
+	}
+	m = &chatEnts.ScheduledMessage{
+		ConversationID: dto.ConversationID,
+		SenderID:       dto.SenderID,
+		Content:        dto.Content,
+		SendAt:         dto.SendAt,
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				s.logger.Error(ctx, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+			}
+			return
+		}
+		if commitErr := tx.Commit(); commitErr != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", commitErr)
+		}
+	}()
+
+	if err = s.repos.ScheduledMessageRepository.Create(ctx, tx, m); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to create scheduled message: %w", err), slog.Int64("id", dto.ConversationID))
+		return nil, fmt.Errorf("failed to create scheduled message: %w", err)
+	}
+	job, err := s.scheduler.EnqueueTx(ctx, tx, JobKind, &jobPayload{ScheduledMessageID: m.ID, ConversationID: m.ConversationID}, m.SendAt)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.repos.ScheduledMessageRepository.SetJob(ctx, tx, m.ID, job.ID); err != nil {
+		return nil, fmt.Errorf("failed to create scheduled message: %w", err)
+	}
+	m.JobID = &job.ID
+	return m, nil
+}
+
+func (s *Service) GetScheduledMessages(ctx context.Context, cnvId, usrId int64) ([]*chatEnts.ScheduledMessage, error) {
+	if err := s.aCh.CanAccessConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	res, err := s.repos.ScheduledMessageRepository.GetPending(ctx, cnvId, usrId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed get scheduled messages: %w", err), slog.Int64("id", cnvId))
+		return nil, fmt.Errorf("failed get scheduled messages: %w", err)
+	}
+	return res, nil
+}
+
+// UpdateScheduledMessage edits a message waiting to be sent. A new send time replaces the job, the old one is cancelled.
+func (s *Service) UpdateScheduledMessage(ctx context.Context, dto *messages_dto.UpdateScheduledMessageDTO) (m *chatEnts.ScheduledMessage, err error) {
+	m, err = s.getOwnMessage(ctx, dto.ConversationID, dto.ID, dto.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	oldJobID := m.JobID
+	if dto.Content != nil {
+		m.Content = *dto.Content
+	}
+	if dto.SendAt != nil {
+		m.SendAt = *dto.SendAt
+	}
+	if err := validate(m.Content, m.SendAt); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				s.logger.Error(ctx, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+			}
+			return
+		}
+		if commitErr := tx.Commit(); commitErr != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", commitErr)
+			return
+		}
+		if oldJobID != nil && m.JobID != oldJobID {
+			s.cancelJob(ctx, *oldJobID)
+		}
+	}()
+
+	if dto.SendAt != nil {
+		job, err := s.scheduler.EnqueueTx(ctx, tx, JobKind, &jobPayload{ScheduledMessageID: m.ID, ConversationID: m.ConversationID}, m.SendAt)
+		if err != nil {
+			return nil, err
+		}
+		m.JobID = &job.ID
+	}
+	ok, err := s.repos.ScheduledMessageRepository.Update(ctx, tx, m)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to update scheduled message: %w", err), slog.Int64("scheduledMessageId", m.ID))
+		return nil, fmt.Errorf("failed to update scheduled message: %w", err)
+	}
+	if !ok {
+		return nil, ErrScheduledMessageNotPending
+	}
+	return m, nil
+}
+
+func (s *Service) CancelScheduledMessage(ctx context.Context, cnvId, id, usrId int64) error {
+	m, err := s.getOwnMessage(ctx, cnvId, id, usrId)
+	if err != nil {
+		return err
+	}
+	ok, err := s.repos.ScheduledMessageRepository.SetStatus(ctx, m.ID, chatEnts.ScheduledMessageStatusScheduled, chatEnts.ScheduledMessageStatusCancelled, nil)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to cancel scheduled message: %w", err), slog.Int64("scheduledMessageId", m.ID))
+		return fmt.Errorf("failed to cancel scheduled message: %w", err)
+	}
+	if !ok {
+		return ErrScheduledMessageNotPending
+	}
+	if m.JobID != nil {
+		s.cancelJob(ctx, *m.JobID)
+	}
+	return nil
+}
+
+func (s *Service) getOwnMessage(ctx context.Context, cnvId, id, usrId int64) (*chatEnts.ScheduledMessage, error) {
+	if err := s.aCh.CanAccessConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	m, err := s.repos.ScheduledMessageRepository.GetScheduledMessage(ctx, cnvId, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled message: %w", err)
+	}
+	if m == nil || m.SenderID != usrId {
+		return nil, ErrScheduledMessageNotFound
+	}
+	if m.Status != chatEnts.ScheduledMessageStatusScheduled {
+		return nil, ErrScheduledMessageNotPending
+	}
+	return m, nil
+}
+
+// cancelJob is best effort, a stale job finds the message claimed by another job and does nothing.
+func (s *Service) cancelJob(ctx context.Context, jobId int64) {
+	if _, err := s.scheduler.Cancel(ctx, jobId); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to cancel job: %w", err), slog.Int64("jobId", jobId))
+	}
+}
+
+// deliver sends the message at most once: it is claimed before sending and a replica stopping
+// in between leaves it in the sending status.
+func (s *Service) deliver(ctx context.Context, job *jobs.Job) error {
+	p := &jobPayload{}
+	if err := json.Unmarshal(job.Payload, p); err != nil {
+		return scheduler.Permanent(fmt.Errorf("invalid payload: %w", err))
+	}
+	m, err := s.repos.ScheduledMessageRepository.Claim(ctx, p.ScheduledMessageID, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to claim scheduled message: %w", err)
+	}
+	if m == nil {
+		return nil
+	}
+
+	// the sender could have left the conversation since scheduling
+	if err := s.aCh.CanAccessConversation(ctx, m.ConversationID, m.SenderID); err != nil {
+		if errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound) {
+			s.fail(ctx, m, err)
+			return nil
+		}
+		s.release(ctx, m)
+		return err
+	}
+	msg, err := s.messages.SendMessage(ctx, &messages_dto.SendMessageDTO{
+		ConversationID: m.ConversationID,
+		SenderID:       m.SenderID,
+		Content:        m.Content,
+		Raw:            true,
+	})
+	if err != nil {
+		if errors.Is(err, message.ErrBroadcastMentionNotAllowed) {
+			s.fail(ctx, m, err)
+			return nil
+		}
+		s.release(ctx, m)
+		return err
+	}
+	if err := s.repos.ScheduledMessageRepository.MarkSent(ctx, m.ID, msg.ID); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to mark scheduled message sent: %w", err), slog.Int64("scheduledMessageId", m.ID))
+	}
+	return nil
+}
+
+func (s *Service) fail(ctx context.Context, m *chatEnts.ScheduledMessage, reason error) {
+	msg := reason.Error()
+	if _, err := s.repos.ScheduledMessageRepository.SetStatus(ctx, m.ID, chatEnts.ScheduledMessageStatusSending, chatEnts.ScheduledMessageStatusFailed, &msg); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to mark scheduled message failed: %w", err), slog.Int64("scheduledMessageId", m.ID))
+	}
+	s.logger.Info(ctx, "scheduled message not sent", slog.Int64("scheduledMessageId", m.ID), slog.String("reason", msg))
+}
+
+// release returns the message to the scheduled status, so the retried job can claim it again.
+func (s *Service) release(ctx context.Context, m *chatEnts.ScheduledMessage) {
+	if _, err := s.repos.ScheduledMessageRepository.SetStatus(ctx, m.ID, chatEnts.ScheduledMessageStatusSending, chatEnts.ScheduledMessageStatusScheduled, nil); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to release scheduled message: %w", err), slog.Int64("scheduledMessageId", m.ID))
+	}
+}
+
+func validate(content string, sendAt time.Time) error {
+	if _, _, ok := command.Parse(content); ok {
+		return ErrCommandNotSchedulable
+	}
+	now := time.Now()
+	if !sendAt.After(now) || sendAt.After(now.Add(maxScheduleAhead)) {
+		return ErrInvalidSendTime
+	}
+	return nil
+}
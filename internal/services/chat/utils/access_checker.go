@@ -12,6 +12,7 @@ import (
 var (
 	ErrConversationNotFound         = errors.New("conversation not found")
 	ErrIsNotConversationParticipant = errors.New("user is not conversation participant")
+	ErrIsNotConversationAdmin       = errors.New("user is not conversation admin")
 )
 
 type AccessChecker struct {
@@ -46,3 +47,21 @@ func (a *AccessChecker) CanAccessConversation(ctx context.Context, cnvId, usrId
 	}
 	return nil
 }
+
+func (a *AccessChecker) CanManageConversation(ctx context.Context, cnvId, usrId int64) error {
+	if err := a.CanAccessConversation(ctx, cnvId, usrId); err != nil {
+		return err
+	}
+	p, err := a.repos.ConversationRepository.GetParticipant(ctx, cnvId, usrId)
+	if err != nil {
+		a.logger.Error(ctx, fmt.Errorf("failed get participant: %w", err), slog.Int64("id", cnvId), slog.Int64("userId", usrId))
+		return fmt.Errorf("faild to check participant role: %w", err)
+	}
+	if p == nil {
+		return ErrIsNotConversationParticipant
+	}
+	if !p.IsAdmin() {
+		return ErrIsNotConversationAdmin
+	}
+	return nil
+}
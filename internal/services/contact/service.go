@@ -0,0 +1,189 @@
+package contact
+
+import (
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"chatapp/internal/repositories/contact"
+	chat_events "chatapp/internal/services/chat"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+var (
+	ErrUserNotFound        = errors.New("user not found")
+	ErrCannotAddSelf       = errors.New("users can't add themselves to contacts")
+	ErrAlreadyContacts     = errors.New("users are already contacts")
+	ErrRequestPending      = errors.New("a contact request between the users is already pending")
+	ErrRequestNotFound     = errors.New("contact request not found")
+	ErrContactNotFound     = errors.New("contact not found")
+	ErrCannotSendRequest   = errors.New("contact request can't be sent to the user")
+	ErrNotRequestRecipient = errors.New("only the recipient can answer a contact request")
+	ErrNotRequestSender    = errors.New("only the sender can cancel a contact request")
+)
+
+// Service manages contact requests and contacts. Both sides of a request are notified
+// of every change over their user websockets.
+type Service struct {
+	logger logger.Logger
+	repos  *repositories.Repositories
+	evl    *chat_events.EventListener
+}
+
+func NewService(logger logger.Logger, repos *repositories.Repositories, evl *chat_events.EventListener) *Service {
+	return &Service{
+		logger: logger,
+		repos:  repos,
+		evl:    evl,
+	}
+}
+
+// SendRequest asks the recipient to become a contact. A pending request from the recipient
+// to the sender is accepted instead, as both want the same.
+func (s *Service) SendRequest(ctx context.Context, senderId, recipientId int64) (*users.ContactRequest, error) {
+	if senderId == recipientId {
+		return nil, ErrCannotAddSelf
+	}
+	recipient, err := s.repos.UserRepository.GetUserById(ctx, recipientId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get user: %w", err), slog.Int64("userId", recipientId))
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if recipient == nil || recipient.IsDeleted() {
+		return nil, ErrUserNotFound
+	}
+	if recipient.IsBot {
+		return nil, ErrCannotSendRequest
+	}
+	blocked, err := s.repos.BlockRepository.IsBlockedEither(ctx, senderId, recipientId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to check blocks: %w", err), slog.Int64("userId", senderId))
+		return nil, fmt.Errorf("failed to check blocks: %w", err)
+	}
+	if blocked {
+		return nil, ErrCannotSendRequest
+	}
+	isContact, err := s.repos.ContactRepository.IsContact(ctx, senderId, recipientId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to check contact: %w", err), slog.Int64("userId", senderId))
+		return nil, fmt.Errorf("failed to check contact: %w", err)
+	}
+	if isContact {
+		return nil, ErrAlreadyContacts
+	}
+
+	reverse, err := s.repos.ContactRepository.GetPendingRequest(ctx, recipientId, senderId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get contact request: %w", err), slog.Int64("userId", senderId))
+		return nil, fmt.Errorf("failed to get contact request: %w", err)
+	}
+	if reverse != nil {
+		return s.respond(ctx, reverse.ID, users.ContactRequestAccepted)
+	}
+
+	req := &users.ContactRequest{
+		SenderID:    senderId,
+		RecipientID: recipientId,
+	}
+	if err := s.repos.ContactRepository.CreateRequest(ctx, req); err != nil {
+		if errors.Is(err, contact.ErrRequestExists) {
+			return nil, ErrRequestPending
+		}
+		s.logger.Error(ctx, fmt.Errorf("failed to create contact request: %w", err), slog.Int64("userId", senderId))
+		return nil, fmt.Errorf("failed to create contact request: %w", err)
+	}
+	s.evl.PostContactRequest(req)
+	s.logger.Info(ctx, "contact request sent", slog.Int64("requestId", req.ID), slog.Int64("userId", senderId))
+	return req, nil
+}
+
+func (s *Service) AcceptRequest(ctx context.Context, usrId, reqId int64) (*users.ContactRequest, error) {
+	if _, err := s.getRequest(ctx, reqId, usrId, true); err != nil {
+		return nil, err
+	}
+	return s.respond(ctx, reqId, users.ContactRequestAccepted)
+}
+
+func (s *Service) DeclineRequest(ctx context.Context, usrId, reqId int64) (*users.ContactRequest, error) {
+	if _, err := s.getRequest(ctx, reqId, usrId, true); err != nil {
+		return nil, err
+	}
+	return s.respond(ctx, reqId, users.ContactRequestDeclined)
+}
+
+func (s *Service) CancelRequest(ctx context.Context, usrId, reqId int64) (*users.ContactRequest, error) {
+	if _, err := s.getRequest(ctx, reqId, usrId, false); err != nil {
+		return nil, err
+	}
+	return s.respond(ctx, reqId, users.ContactRequestCanceled)
+}
+
+// getRequest returns a request the user is the recipient of when incoming, otherwise the sender.
+func (s *Service) getRequest(ctx context.Context, reqId, usrId int64, incoming bool) (*users.ContactRequest, error) {
+	req, err := s.repos.ContactRepository.GetRequest(ctx, reqId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get contact request: %w", err), slog.Int64("requestId", reqId))
+		return nil, fmt.Errorf("failed to get contact request: %w", err)
+	}
+	if req == nil || (req.SenderID != usrId && req.RecipientID != usrId) {
+		return nil, ErrRequestNotFound
+	}
+	if incoming && req.RecipientID != usrId {
+		return nil, ErrNotRequestRecipient
+	}
+	if !incoming && req.SenderID != usrId {
+		return nil, ErrNotRequestSender
+	}
+	return req, nil
+}
+
+func (s *Service) respond(ctx context.Context, reqId int64, status string) (*users.ContactRequest, error) {
+	req, err := s.repos.ContactRepository.Respond(ctx, reqId, status)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to answer contact request: %w", err), slog.Int64("requestId", reqId), slog.String("status", status))
+		return nil, fmt.Errorf("failed to answer contact request: %w", err)
+	}
+	if req == nil {
+		// already answered or canceled
+		return nil, ErrRequestNotFound
+	}
+	s.evl.PostContactRequest(req)
+	s.logger.Info(ctx, "contact request answered", slog.Int64("requestId", req.ID), slog.String("status", status))
+	return req, nil
+}
+
+// GetRequests returns pending requests sent to the user when incoming, otherwise the ones the user sent.
+func (s *Service) GetRequests(ctx context.Context, usrId int64, incoming bool) ([]*users.ContactRequest, error) {
+	reqs, err := s.repos.ContactRepository.GetPendingRequests(ctx, usrId, incoming)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get contact requests: %w", err), slog.Int64("userId", usrId))
+		return nil, fmt.Errorf("failed to get contact requests: %w", err)
+	}
+	return reqs, nil
+}
+
+func (s *Service) GetContacts(ctx context.Context, usrId int64) ([]*users.Contact, error) {
+	contacts, err := s.repos.ContactRepository.GetContacts(ctx, usrId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get contacts: %w", err), slog.Int64("userId", usrId))
+		return nil, fmt.Errorf("failed to get contacts: %w", err)
+	}
+	return contacts, nil
+}
+
+// RemoveContact removes the users from the contacts of each other.
+func (s *Service) RemoveContact(ctx context.Context, usrId, contactId int64) error {
+	ok, err := s.repos.ContactRepository.RemoveContact(ctx, usrId, contactId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to remove contact: %w", err), slog.Int64("userId", usrId), slog.Int64("contactId", contactId))
+		return fmt.Errorf("failed to remove contact: %w", err)
+	}
+	if !ok {
+		return ErrContactNotFound
+	}
+	s.evl.PostContactRemoved(usrId, contactId)
+	s.logger.Info(ctx, "contact removed", slog.Int64("userId", usrId), slog.Int64("contactId", contactId))
+	return nil
+}
@@ -0,0 +1,171 @@
+package digest
+
+import (
+	"chatapp/internal/config"
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/jobs"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"chatapp/internal/services/mailer"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// JobKindSend is the periodic job emailing digests to users who are away
+	JobKindSend = "email_digests"
+
+	// maxContentLength is the number of characters quoted from a message
+	maxContentLength = 200
+	timeLayout       = "Jan 2 15:04"
+)
+
+// Service emails digests of unread messages to users who have been away for a while.
+// The last digested message is recorded per user, so messages are never digested twice.
+type Service struct {
+	cfg    *config.Config
+	logger logger.Logger
+	repos  *repositories.Repositories
+	mailer mailer.Mailer
+}
+
+func NewService(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories, mlr mailer.Mailer) *Service {
+	return &Service{
+		cfg:    cfg,
+		logger: logger,
+		repos:  repos,
+		mailer: mlr,
+	}
+}
+
+type conversation struct {
+	Name         string
+	UnreadCount  int
+	MentionCount int
+	Messages     []message
+	// More is the number of unread messages not quoted
+	More int
+}
+
+type message struct {
+	Sender    string
+	Content   string
+	Time      string
+	Mentioned bool
+}
+
+// Send is the handler of the periodic job. Failures are logged per user and don't stop the others,
+// users in their quiet hours are left for a later run.
+func (s *Service) Send(ctx context.Context, _ *jobs.Job) error {
+	now := time.Now()
+
+	var lastId int64
+	sent := 0
+	for {
+		due, err := s.repos.DigestRepository.GetDueUsers(ctx, s.cfg.DigestInactivity, lastId, s.cfg.DigestBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to get users due a digest: %w", err)
+		}
+		for _, u := range due {
+			lastId = u.ID
+			if u.InQuietHours(now) {
+				continue
+			}
+			ok, err := s.sendDigest(ctx, u)
+			if err != nil {
+				s.logger.Error(ctx, fmt.Errorf("failed to send digest: %w", err), slog.Int64("userId", u.ID))
+				continue
+			}
+			if ok {
+				sent++
+			}
+		}
+		if len(due) < s.cfg.DigestBatchSize {
+			break
+		}
+	}
+	if sent > 0 {
+		s.logger.Info(ctx, "email digests sent", slog.Int("count", sent))
+	}
+	return nil
+}
+
+// sendDigest emails the unread messages of the user, false is returned when there are none.
+func (s *Service) sendDigest(ctx context.Context, usr *users.User) (bool, error) {
+	msgs, err := s.repos.DigestRepository.GetUnreadMessages(ctx, usr.ID, s.cfg.DigestMessagesPerConversation)
+	if err != nil {
+		return false, fmt.Errorf("failed to get unread messages: %w", err)
+	}
+	if len(msgs) == 0 {
+		return false, nil
+	}
+
+	loc := time.UTC
+	if usr.Timezone != "" {
+		if l, err := time.LoadLocation(usr.Timezone); err == nil {
+			loc = l
+		}
+	}
+	var (
+		cnvs            []*conversation
+		total, mentions int
+		lastMessageId   int64
+	)
+	for i, m := range msgs {
+		if i == 0 || m.ConversationID != msgs[i-1].ConversationID {
+			cnvs = append(cnvs, &conversation{
+				Name:         conversationName(m),
+				UnreadCount:  m.UnreadCount,
+				MentionCount: m.MentionCount,
+				More:         m.UnreadCount - min(m.UnreadCount, s.cfg.DigestMessagesPerConversation),
+			})
+			total += m.UnreadCount
+			mentions += m.MentionCount
+		}
+		c := cnvs[len(cnvs)-1]
+		c.Messages = append(c.Messages, message{
+			Sender:    m.SenderName,
+			Content:   truncate(m.Content),
+			Time:      m.CreatedAt.In(loc).Format(timeLayout),
+			Mentioned: m.Mentioned,
+		})
+		lastMessageId = max(lastMessageId, m.ID)
+	}
+
+	mail, err := mailer.Render("digest", []string{usr.Email}, map[string]any{
+		"Username":      usr.Username,
+		"Total":         total,
+		"Mentions":      mentions,
+		"Conversations": cnvs,
+		"Link":          s.cfg.AppPublicURL,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := s.mailer.Send(ctx, mail); err != nil {
+		return false, err
+	}
+	if err := s.repos.DigestRepository.Record(ctx, usr.ID, lastMessageId, total); err != nil {
+		return false, fmt.Errorf("failed to record digest: %w", err)
+	}
+	return true, nil
+}
+
+// conversationName names direct conversations after the sender, the other participant.
+func conversationName(m *chatEnts.DigestMessage) string {
+	if !m.IsGroup || m.ConversationName == "" {
+		return m.SenderName
+	}
+	return m.ConversationName
+}
+
+func truncate(s string) string {
+	r := []rune(s)
+	if len(r) <= maxContentLength {
+		return s
+	}
+	return string(r[:maxContentLength-1]) + "…"
+}
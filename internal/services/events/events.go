@@ -2,6 +2,7 @@ package events
 
 import (
 	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
 	"sync"
 	"sync/atomic"
 )
@@ -11,18 +12,42 @@ type EventType string
 const (
 	EventTypeMessageCreated = "message_created"
 	EventTypeMessageUpdated = "message_updated"
+	EventTypeMessageExpired = "message_expired"
+
+	EventTypeMessagePinned   = "message_pinned"
+	EventTypeMessageUnpinned = "message_unpinned"
+
+	EventTypeParticipantAdded = "participant_added"
+
+	EventTypeConversationUpdated = "conversation_updated"
+
+	EventTypeUserUpdated = "user_updated"
+
+	EventTypeEphemeralMessage = "ephemeral_message"
+
+	EventTypeExportFinished = "export_finished"
 
 	EventTypeUserTyping = "user_typing"
+
+	EventTypeContactRequest = "contact_request"
+	EventTypeContactRemoved = "contact_removed"
+
+	EventTypeUserBlocked   = "user_blocked"
+	EventTypeUserUnblocked = "user_unblocked"
 )
 
 type Event struct {
 	Type EventType
 	Data any
+	// Recipient limits the event to a single user, everyone receives it when zero
+	Recipient int64 `json:"-"`
 }
 
 type EventChannel struct {
 	listeners map[chan<- Event]struct{}
 	msgsCh    chan Event
+	// dropped is set for lossy channels, it is called instead of waiting for a listener with a full buffer
+	dropped func(ev Event)
 
 	mu            sync.RWMutex
 	stopTriggered atomic.Bool
@@ -38,6 +63,20 @@ func StartEventChannel() *EventChannel {
 	return ch
 }
 
+// StartLossyEventChannel starts a channel which never waits for its listeners. An event is dropped
+// for a listener whose buffer is full and reported to dropped, so slow background consumers can't
+// hold up the posters.
+func StartLossyEventChannel(dropped func(ev Event)) *EventChannel {
+	ch := &EventChannel{
+		listeners: make(map[chan<- Event]struct{}),
+		msgsCh:    make(chan Event, 100),
+		dropped:   dropped,
+	}
+	go ch.run()
+
+	return ch
+}
+
 func (e *EventChannel) IsEmpty() bool {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -62,6 +101,64 @@ func (e *EventChannel) PostMessageUpdated(msg *chatEnts.Message) {
 	}
 }
 
+func (e *EventChannel) PostMessageExpired(msg *chatEnts.ExpiredMessage) {
+	e.msgsCh <- Event{
+		Type: EventTypeMessageExpired,
+		Data: msg,
+	}
+}
+
+func (e *EventChannel) PostMessagePinned(p *chatEnts.Pin) {
+	e.msgsCh <- Event{
+		Type: EventTypeMessagePinned,
+		Data: p,
+	}
+}
+
+func (e *EventChannel) PostMessageUnpinned(p *chatEnts.Pin) {
+	e.msgsCh <- Event{
+		Type: EventTypeMessageUnpinned,
+		Data: p,
+	}
+}
+
+func (e *EventChannel) PostParticipantAdded(p *chatEnts.ConversationParticipant) {
+	e.msgsCh <- Event{
+		Type: EventTypeParticipantAdded,
+		Data: p,
+	}
+}
+
+func (e *EventChannel) PostConversationUpdated(cnv *chatEnts.Conversation) {
+	e.msgsCh <- Event{
+		Type: EventTypeConversationUpdated,
+		Data: cnv,
+	}
+}
+
+func (e *EventChannel) PostUserUpdated(u *chatEnts.UserUpdated) {
+	e.msgsCh <- Event{
+		Type: EventTypeUserUpdated,
+		Data: u,
+	}
+}
+
+func (e *EventChannel) PostEphemeralMessage(usrId int64, msg *chatEnts.Message) {
+	e.msgsCh <- Event{
+		Type:      EventTypeEphemeralMessage,
+		Data:      msg,
+		Recipient: usrId,
+	}
+}
+
+func (e *EventChannel) PostExportFinished(usrId int64, exp *chatEnts.Export) {
+	e.msgsCh <- Event{
+		Type:      EventTypeExportFinished,
+		Data:      exp,
+		Recipient: usrId,
+	}
+}
+
 func (e *EventChannel) PostUserTyping(usrId int64) {
 	e.msgsCh <- Event{
 		Type: EventTypeUserTyping,
@@ -69,6 +166,34 @@ func (e *EventChannel) PostUserTyping(usrId int64) {
 	}
 }
 
+func (e *EventChannel) PostContactRequest(req *users.ContactRequest) {
+	e.msgsCh <- Event{
+		Type: EventTypeContactRequest,
+		Data: req,
+	}
+}
+
+func (e *EventChannel) PostContactRemoved(contactId int64) {
+	e.msgsCh <- Event{
+		Type: EventTypeContactRemoved,
+		Data: map[string]int64{"user_id": contactId},
+	}
+}
+
+func (e *EventChannel) PostUserBlocked(blockedId int64) {
+	e.msgsCh <- Event{
+		Type: EventTypeUserBlocked,
+		Data: map[string]int64{"user_id": blockedId},
+	}
+}
+
+func (e *EventChannel) PostUserUnblocked(blockedId int64) {
+	e.msgsCh <- Event{
+		Type: EventTypeUserUnblocked,
+		Data: map[string]int64{"user_id": blockedId},
+	}
+}
+
 func (e *EventChannel) Subscribe(l chan<- Event) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -91,7 +216,15 @@ func (e *EventChannel) run() {
 		}
 		e.mu.RLock()
 		for l := range e.listeners {
-			l <- msg
+			if e.dropped == nil {
+				l <- msg
+				continue
+			}
+			select {
+			case l <- msg:
+			default:
+				e.dropped(msg)
+			}
 		}
 		e.mu.RUnlock()
 	}
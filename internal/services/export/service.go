@@ -0,0 +1,296 @@
+package export
+
+import (
+	"bufio"
+	"chatapp/internal/config"
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/jobs"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	chat_events "chatapp/internal/services/chat"
+	"chatapp/internal/services/chat/utils"
+	"chatapp/internal/services/scheduler"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// JobKindExport writes an export to a file
+	JobKindExport = "conversation_export"
+	// JobKindCleanup deletes expired export files
+	JobKindCleanup = "export_cleanup"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported export format")
+	ErrExportNotFound    = errors.New("export not found")
+	ErrExportNotReady    = errors.New("export is not ready")
+)
+
+type jobPayload struct {
+	ExportID int64 `json:"export_id"`
+}
+
+// Service exports conversation histories. Small conversations are streamed to the requester,
+// larger ones are written to a file by a job and the requester is notified over the websocket.
+type Service struct {
+	cfg       *config.Config
+	logger    logger.Logger
+	repos     *repositories.Repositories
+	aCh       *utils.AccessChecker
+	scheduler *scheduler.Scheduler
+	evl       *chat_events.EventListener
+}
+
+func NewService(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories, sch *scheduler.Scheduler, evl *chat_events.EventListener) *Service {
+	s := &Service{
+		cfg:       cfg,
+		logger:    logger,
+		repos:     repos,
+		aCh:       utils.NewAccesChecker(logger, repos),
+		scheduler: sch,
+		evl:       evl,
+	}
+	sch.Register(JobKindExport, s.run)
+	return s
+}
+
+func ParseFormat(format string) (chatEnts.ExportFormat, error) {
+	switch f := chatEnts.ExportFormat(format); f {
+	case chatEnts.ExportFormatJSON, chatEnts.ExportFormatHTML, chatEnts.ExportFormatTXT:
+		return f, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+func ContentType(format chatEnts.ExportFormat) string {
+	switch format {
+	case chatEnts.ExportFormatHTML:
+		return "text/html; charset=utf-8"
+	case chatEnts.ExportFormatTXT:
+		return "text/plain; charset=utf-8"
+	}
+	return "application/json"
+}
+
+func FileName(cnvId int64, format chatEnts.ExportFormat) string {
+	return fmt.Sprintf("conversation-%d.%s", cnvId, format)
+}
+
+// RequestExport checks the access of the user. Conversations larger than the sync limit are queued and the export
+// is returned, nil means the history should be streamed with WriteExport.
+func (s *Service) RequestExport(ctx context.Context, usrId, cnvId int64, format chatEnts.ExportFormat) (*chatEnts.Export, error) {
+	if err := s.aCh.CanAccessConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	n, err := s.repos.MessageRepository.Count(ctx, cnvId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to count messages: %w", err), slog.Int64("conversation", cnvId))
+		return nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+	if n <= s.cfg.ExportSyncMaxMessages {
+		return nil, nil
+	}
+
+	e := &chatEnts.Export{
+		ConversationID: cnvId,
+		RequestedBy:    usrId,
+		Format:         format,
+		Status:         chatEnts.ExportStatusPending,
+	}
+	if err := s.repos.ExportRepository.Create(ctx, e); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to create export: %w", err), slog.Int64("conversation", cnvId))
+		return nil, fmt.Errorf("failed to create export: %w", err)
+	}
+	if _, err := s.scheduler.Enqueue(ctx, JobKindExport, &jobPayload{ExportID: e.ID}, time.Now()); err != nil {
+		return nil, err
+	}
+	s.logger.Info(ctx, "export queued", slog.Int64("exportId", e.ID), slog.Int64("conversation", cnvId), slog.Int64("messages", n))
+	return e, nil
+}
+
+// WriteExport writes the history in batches, the access must be checked by the caller.
+func (s *Service) WriteExport(ctx context.Context, cnvId int64, format chatEnts.ExportFormat, w io.Writer) error {
+	cnv, err := s.repos.ConversationRepository.GetConversationById(ctx, cnvId)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if cnv == nil {
+		return utils.ErrConversationNotFound
+	}
+	pts, err := s.repos.ConversationRepository.GetParticipantProfiles(ctx, cnvId)
+	if err != nil {
+		return fmt.Errorf("failed to get participants: %w", err)
+	}
+
+	wr := newWriter(format, w)
+	if err := wr.begin(&header{Conversation: cnv, Participants: pts, ExportedAt: time.Now().UTC()}); err != nil {
+		return err
+	}
+	var lastId int64
+	for {
+		messages, err := s.repos.MessageRepository.GetMessagesAfter(ctx, cnvId, lastId, s.cfg.ExportBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to get messages: %w", err)
+		}
+		for _, m := range messages {
+			if err := wr.message(m); err != nil {
+				return err
+			}
+			lastId = m.ID
+		}
+		if len(messages) < s.cfg.ExportBatchSize {
+			break
+		}
+	}
+	return wr.end()
+}
+
+// GetExport returns an export of the user, only the requester can download it.
+func (s *Service) GetExport(ctx context.Context, usrId, cnvId, id int64) (*chatEnts.Export, error) {
+	if err := s.aCh.CanAccessConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	e, err := s.repos.ExportRepository.GetExport(ctx, cnvId, id)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get export: %w", err), slog.Int64("exportId", id))
+		return nil, fmt.Errorf("failed to get export: %w", err)
+	}
+	if e == nil || e.RequestedBy != usrId {
+		return nil, ErrExportNotFound
+	}
+	return e, nil
+}
+
+// OpenExport returns a ready export of the user with its file path.
+func (s *Service) OpenExport(ctx context.Context, usrId, cnvId, id int64) (*chatEnts.Export, error) {
+	e, err := s.GetExport(ctx, usrId, cnvId, id)
+	if err != nil {
+		return nil, err
+	}
+	if e.Status != chatEnts.ExportStatusReady || e.FilePath == nil {
+		return nil, ErrExportNotReady
+	}
+	return e, nil
+}
+
+func (s *Service) run(ctx context.Context, job *jobs.Job) error {
+	p := &jobPayload{}
+	if err := json.Unmarshal(job.Payload, p); err != nil {
+		return scheduler.Permanent(fmt.Errorf("invalid payload: %w", err))
+	}
+	e, err := s.repos.ExportRepository.Start(ctx, p.ExportID)
+	if err != nil {
+		return fmt.Errorf("failed to start export: %w", err)
+	}
+	if e == nil {
+		return nil
+	}
+
+	// the requester could have left the conversation since the request
+	if err := s.aCh.CanAccessConversation(ctx, e.ConversationID, e.RequestedBy); err != nil {
+		if errors.Is(err, utils.ErrIsNotConversationParticipant) || errors.Is(err, utils.ErrConversationNotFound) {
+			s.fail(ctx, e, err)
+			return nil
+		}
+		return s.retry(ctx, job, e, err)
+	}
+	if err := s.writeFile(ctx, e); err != nil {
+		return s.retry(ctx, job, e, err)
+	}
+	if err := s.repos.ExportRepository.MarkReady(ctx, e, s.cfg.ExportTTL); err != nil {
+		return s.retry(ctx, job, e, fmt.Errorf("failed to mark export ready: %w", err))
+	}
+	s.evl.PostExportFinished(e)
+	s.logger.Info(ctx, "export finished", slog.Int64("exportId", e.ID), slog.Int64("conversation", e.ConversationID))
+	return nil
+}
+
+// writeFile writes the export to a temporary file renamed on success, so a failed attempt never leaves a partial file.
+func (s *Service) writeFile(ctx context.Context, e *chatEnts.Export) (err error) {
+	if err := os.MkdirAll(s.cfg.ExportDir, 0o750); err != nil {
+		return fmt.Errorf("failed to create export dir: %w", err)
+	}
+	path := filepath.Join(s.cfg.ExportDir, fmt.Sprintf("export-%d.%s", e.ID, e.Format))
+	tmp, err := os.CreateTemp(s.cfg.ExportDir, fmt.Sprintf("export-%d-*.tmp", e.ID))
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	bw := bufio.NewWriter(tmp)
+	if err = s.WriteExport(ctx, e.ConversationID, e.Format, bw); err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	size := info.Size()
+	e.FilePath = &path
+	e.Size = &size
+	return nil
+}
+
+// retry returns the export to pending for the next attempt, the last attempt fails it.
+func (s *Service) retry(ctx context.Context, job *jobs.Job, e *chatEnts.Export, err error) error {
+	if job.Attempts >= job.MaxAttempts {
+		s.fail(ctx, e, err)
+		return err
+	}
+	if releaseErr := s.repos.ExportRepository.Release(ctx, e.ID); releaseErr != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to release export: %w", releaseErr), slog.Int64("exportId", e.ID))
+	}
+	return err
+}
+
+func (s *Service) fail(ctx context.Context, e *chatEnts.Export, reason error) {
+	if err := s.repos.ExportRepository.MarkFailed(ctx, e, reason.Error()); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to mark export failed: %w", err), slog.Int64("exportId", e.ID))
+		return
+	}
+	s.evl.PostExportFinished(e)
+	s.logger.Info(ctx, "export failed", slog.Int64("exportId", e.ID), slog.String("reason", reason.Error()))
+}
+
+// Cleanup is the handler of the periodic job deleting expired exports with their files.
+func (s *Service) Cleanup(ctx context.Context, _ *jobs.Job) error {
+	for {
+		expired, err := s.repos.ExportRepository.DeleteExpired(ctx, 100)
+		if err != nil {
+			return fmt.Errorf("failed to delete expired exports: %w", err)
+		}
+		for _, e := range expired {
+			if e.FilePath == nil {
+				continue
+			}
+			if err := os.Remove(*e.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				s.logger.Error(ctx, fmt.Errorf("failed to remove export file: %w", err), slog.Int64("exportId", e.ID))
+			}
+		}
+		if len(expired) < 100 {
+			return nil
+		}
+	}
+}
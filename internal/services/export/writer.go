@@ -0,0 +1,170 @@
+package export
+
+import (
+	chatEnts "chatapp/internal/entities/chat"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+type header struct {
+	Conversation *chatEnts.Conversation         `json:"conversation"`
+	Participants []*chatEnts.ParticipantProfile `json:"participants"`
+	ExportedAt   time.Time                      `json:"exported_at"`
+}
+
+// writer writes an export in a format, messages are written one by one as they are read.
+type writer interface {
+	begin(h *header) error
+	message(m *chatEnts.ExportedMessage) error
+	end() error
+}
+
+func newWriter(format chatEnts.ExportFormat, w io.Writer) writer {
+	switch format {
+	case chatEnts.ExportFormatHTML:
+		return &htmlWriter{w: w}
+	case chatEnts.ExportFormatTXT:
+		return &txtWriter{w: w}
+	}
+	return &jsonWriter{w: w}
+}
+
+func senderName(m *chatEnts.ExportedMessage) string {
+	switch {
+	case m.DisplayName != nil:
+		return *m.DisplayName
+	case m.SenderName != nil:
+		return *m.SenderName
+	}
+	return fmt.Sprintf("user %d", m.SenderID)
+}
+
+func isEdited(m *chatEnts.ExportedMessage) bool {
+	return m.UpdatedAt.After(m.CreatedAt)
+}
+
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) begin(h *header) error {
+	body, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	// the header object is reopened to append the messages array
+	if _, err := j.w.Write(body[:len(body)-1]); err != nil {
+		return err
+	}
+	_, err = io.WriteString(j.w, `,"messages":[`)
+	return err
+}
+
+func (j *jsonWriter) message(m *chatEnts.ExportedMessage) error {
+	body, err := json.Marshal(&struct {
+		*chatEnts.ExportedMessage
+		Edited bool `json:"edited"`
+	}{m, isEdited(m)})
+	if err != nil {
+		return err
+	}
+	if j.count > 0 {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+	j.count++
+	_, err = j.w.Write(body)
+	return err
+}
+
+func (j *jsonWriter) end() error {
+	_, err := io.WriteString(j.w, "]}\n")
+	return err
+}
+
+var (
+	htmlHeader = template.Must(template.New("header").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Conversation.Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; }
+.message { margin: .5em 0; }
+.time, .edited { color: #888; font-size: .85em; }
+.sender { font-weight: bold; }
+.content { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Conversation.Name}}</h1>
+{{if .Conversation.Topic}}<p>{{.Conversation.Topic}}</p>
+{{end}}<p class="time">Exported at {{.ExportedAt.Format "2006-01-02 15:04:05"}} UTC</p>
+<h2>Participants</h2>
+<ul>
+{{range .Participants}}<li>{{.Username}} ({{.Role}})</li>
+{{end}}</ul>
+<h2>Messages</h2>
+`))
+	htmlMessage = template.Must(template.New("message").Parse(`<div class="message" id="m{{.ID}}"><span class="time">{{.Time}}</span> <span class="sender">{{.Sender}}</span>{{if .Edited}} <span class="edited">(edited {{.EditedAt}})</span>{{end}}<div class="content">{{.Content}}</div></div>
+`))
+)
+
+type htmlWriter struct {
+	w io.Writer
+}
+
+func (h *htmlWriter) begin(hd *header) error {
+	return htmlHeader.Execute(h.w, hd)
+}
+
+func (h *htmlWriter) message(m *chatEnts.ExportedMessage) error {
+	return htmlMessage.Execute(h.w, map[string]any{
+		"ID":       m.ID,
+		"Time":     m.CreatedAt.Format(timeLayout),
+		"Sender":   senderName(m),
+		"Edited":   isEdited(m),
+		"EditedAt": m.UpdatedAt.Format(timeLayout),
+		"Content":  m.Content,
+	})
+}
+
+func (h *htmlWriter) end() error {
+	_, err := io.WriteString(h.w, "</body>\n</html>\n")
+	return err
+}
+
+type txtWriter struct {
+	w io.Writer
+}
+
+func (t *txtWriter) begin(h *header) error {
+	names := make([]string, 0, len(h.Participants))
+	for _, p := range h.Participants {
+		names = append(names, p.Username)
+	}
+	_, err := fmt.Fprintf(t.w, "%s\nExported at %s UTC\nParticipants: %s\n\n",
+		h.Conversation.Name, h.ExportedAt.Format(timeLayout), strings.Join(names, ", "))
+	return err
+}
+
+func (t *txtWriter) message(m *chatEnts.ExportedMessage) error {
+	edited := ""
+	if isEdited(m) {
+		edited = " (edited)"
+	}
+	_, err := fmt.Fprintf(t.w, "[%s] %s%s: %s\n", m.CreatedAt.Format(timeLayout), senderName(m), edited, m.Content)
+	return err
+}
+
+func (t *txtWriter) end() error {
+	return nil
+}
@@ -0,0 +1,17 @@
+package hash
+
+import "sync"
+
+var (
+	dummyOnce sync.Once
+	dummyHash string
+)
+
+// CompareWithDummy spends the same time as a real password check.
+// It is used when there is no stored hash so response timing doesn't reveal whether an account exists.
+func (s *Service) CompareWithDummy(password string) {
+	dummyOnce.Do(func() {
+		dummyHash, _ = s.HashPassword("dummy-password-for-timing")
+	})
+	_, _ = s.CompareHashWithPassword(dummyHash, password)
+}
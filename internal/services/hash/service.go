@@ -1,33 +1,94 @@
 package hash
 
 import (
+	"chatapp/internal/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	ErrCannotHashPassword = errors.New("cannot hash password: %s")
+	ErrCannotHashPassword = errors.New("cannot hash password")
+	ErrUnknownHashFormat  = errors.New("unknown password hash format")
 )
 
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+
+	argon2SaltSize = 16
+	argon2KeySize  = 32
+
+	bcryptMaxPasswordSize = 72
+)
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
 type Service struct {
+	algorithm  string
+	argon2     argon2Params
+	bcryptCost int
 }
 
-func NewService() *Service {
-	return &Service{}
+func NewService(cfg *config.Config) *Service {
+	return &Service{
+		algorithm: cfg.PasswordHashAlgorithm,
+		argon2: argon2Params{
+			memory:      uint32(cfg.Argon2Memory),
+			iterations:  uint32(cfg.Argon2Iterations),
+			parallelism: uint8(cfg.Argon2Parallelism),
+		},
+		bcryptCost: cfg.BcryptCost,
+	}
 }
 
+// HashPassword hashes password with the configured algorithm.
+// Argon2id hashes are stored in PHC string format so parameters can change without breaking old hashes.
 func (s *Service) HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", errors.Join(fmt.Errorf(ErrCannotHashPassword.Error(), password), err)
+	if s.algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword(bcryptInput(password), s.bcryptCost)
+		if err != nil {
+			return "", errors.Join(ErrCannotHashPassword, err)
+		}
+		return string(hash), nil
 	}
-	return string(hash), nil
+
+	salt := make([]byte, argon2SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", errors.Join(ErrCannotHashPassword, err)
+	}
+	p := s.argon2
+	key := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, argon2KeySize)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.iterations, p.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
 func (s *Service) CompareHashWithPassword(hash, password string) (bool, error) {
-	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+	if isArgon2id(hash) {
+		p, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, fmt.Errorf("failed to process password hash: %w", err)
+		}
+		other := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), bcryptInput(password)); err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 			return false, nil
 		}
@@ -35,3 +96,60 @@ func (s *Service) CompareHashWithPassword(hash, password string) (bool, error) {
 	}
 	return true, nil
 }
+
+// NeedsRehash reports whether the hash was made with another algorithm or weaker parameters than configured.
+func (s *Service) NeedsRehash(hash string) bool {
+	if s.algorithm == AlgorithmBcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < s.bcryptCost
+	}
+	if !isArgon2id(hash) {
+		return true
+	}
+	p, _, _, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return p.memory < s.argon2.memory || p.iterations < s.argon2.iterations || p.parallelism < s.argon2.parallelism
+}
+
+// bcryptInput pre-hashes passwords longer than bcrypt accepts. Shorter ones are used as is,
+// hashes made before stay valid as bcrypt refused the long ones.
+func bcryptInput(password string) []byte {
+	if len(password) <= bcryptMaxPasswordSize {
+		return []byte(password)
+	}
+	sum := sha256.Sum256([]byte(password))
+	return []byte(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+func isArgon2id(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func decodeArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	p := argon2Params{}
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, errors.Join(ErrUnknownHashFormat, err)
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("%w: argon2 version %d", ErrUnknownHashFormat, version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return p, nil, nil, errors.Join(ErrUnknownHashFormat, err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, errors.Join(ErrUnknownHashFormat, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, errors.Join(ErrUnknownHashFormat, err)
+	}
+	return p, salt, key, nil
+}
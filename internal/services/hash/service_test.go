@@ -0,0 +1,37 @@
+package hash
+
+import (
+	"chatapp/internal/config"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBcryptLongPasswords(t *testing.T) {
+	s := NewService(&config.Config{PasswordHashAlgorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	long := strings.Repeat("a", 100)
+
+	hash, err := s.HashPassword(long)
+	if err != nil {
+		t.Fatalf("hash long password: %v", err)
+	}
+	if ok, err := s.CompareHashWithPassword(hash, long); err != nil || !ok {
+		t.Fatalf("compare long password: ok=%v err=%v", ok, err)
+	}
+	// bcrypt alone ignores everything after 72 bytes
+	if ok, _ := s.CompareHashWithPassword(hash, strings.Repeat("a", 72)+"b"); ok {
+		t.Fatal("password differing after 72 bytes matched")
+	}
+}
+
+func TestBcryptShortPasswordsStayCompatible(t *testing.T) {
+	s := NewService(&config.Config{PasswordHashAlgorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	old, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.CompareHashWithPassword(string(old), "correct horse"); err != nil || !ok {
+		t.Fatalf("compare existing hash: ok=%v err=%v", ok, err)
+	}
+}
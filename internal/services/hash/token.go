@@ -0,0 +1,27 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const defaultTokenSize = 32
+
+// GenerateToken returns a random url-safe token suitable for refresh tokens, links and keys.
+func (s *Service) GenerateToken() (string, error) {
+	b := make([]byte, defaultTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns hex encoded sha256 of a high entropy token.
+// It must not be used for passwords, use HashPassword instead.
+func (s *Service) HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"chatapp/internal/config"
+	"chatapp/internal/logger"
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	DriverSMTP   = "smtp"
+	DriverOutbox = "outbox"
+)
+
+var (
+	ErrNoRecipients = errors.New("message has no recipients")
+)
+
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers email messages.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+func NewMailer(cfg *config.Config, logger logger.Logger) (Mailer, error) {
+	switch cfg.MailerDriver {
+	case DriverSMTP:
+		return NewSMTPMailer(cfg), nil
+	case DriverOutbox:
+		return NewOutboxMailer(cfg.MailFrom, cfg.MailOutboxDir, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer driver: %s", cfg.MailerDriver)
+	}
+}
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"chatapp/internal/logger"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// OutboxMailer stores messages as .eml files instead of sending them, for local development and tests.
+// With an empty dir messages are only written to the log.
+type OutboxMailer struct {
+	from   string
+	dir    string
+	logger logger.Logger
+	seq    atomic.Int64
+}
+
+func NewOutboxMailer(from, dir string, logger logger.Logger) *OutboxMailer {
+	return &OutboxMailer{
+		from:   from,
+		dir:    dir,
+		logger: logger,
+	}
+}
+
+func (m *OutboxMailer) Send(ctx context.Context, msg *Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipients
+	}
+	if m.dir == "" {
+		m.logger.Info(ctx, "outbox mail", slog.String("to", strings.Join(msg.To, ", ")), slog.String("subject", msg.Subject), slog.String("text", msg.Text))
+		return nil
+	}
+
+	body, err := build(m.from, msg)
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create outbox dir: %w", err)
+	}
+	name := fmt.Sprintf("%d-%d.eml", time.Now().UnixNano(), m.seq.Add(1))
+	if err := os.WriteFile(filepath.Join(m.dir, name), body, 0o644); err != nil {
+		return fmt.Errorf("failed to write outbox mail: %w", err)
+	}
+	m.logger.Info(ctx, "mail stored in outbox", slog.String("file", name), slog.String("subject", msg.Subject))
+	return nil
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templatesFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templatesFS, "templates/*.txt.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templatesFS, "templates/*.html.tmpl"))
+)
+
+// Render builds a message from templates/<name>.txt.tmpl and templates/<name>.html.tmpl.
+// The text template defines the subject in a "<name>.subject" block.
+func Render(name string, to []string, data any) (*Message, error) {
+	msg := &Message{
+		To: to,
+	}
+	subject := &bytes.Buffer{}
+	if err := textTemplates.ExecuteTemplate(subject, name+".subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	msg.Subject = strings.TrimSpace(subject.String())
+
+	text := &bytes.Buffer{}
+	if err := textTemplates.ExecuteTemplate(text, name+".txt.tmpl", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text: %w", name, err)
+	}
+	msg.Text = text.String()
+
+	if htmlTemplates.Lookup(name+".html.tmpl") != nil {
+		html := &bytes.Buffer{}
+		if err := htmlTemplates.ExecuteTemplate(html, name+".html.tmpl", data); err != nil {
+			return nil, fmt.Errorf("failed to render %s html: %w", name, err)
+		}
+		msg.HTML = html.String()
+	}
+	return msg, nil
+}
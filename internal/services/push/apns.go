@@ -0,0 +1,151 @@
+package push
+
+import (
+	"bytes"
+	"chatapp/internal/config"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	apnsProductionURL = "https://api.push.apple.com"
+	apnsSandboxURL    = "https://api.sandbox.push.apple.com"
+
+	// apnsTokenTTL stays below the hour after which APNs rejects provider tokens,
+	// while not refreshing more often than APNs allows
+	apnsTokenTTL = 50 * time.Minute
+)
+
+// APNsProvider sends notifications through the Apple Push Notification service with token based authentication.
+type APNsProvider struct {
+	baseURL string
+	keyId   string
+	teamId  string
+	topic   string
+	key     *ecdsa.PrivateKey
+	client  *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func NewAPNsProvider(cfg *config.Config, client *http.Client) (*APNsProvider, error) {
+	b, err := os.ReadFile(cfg.APNsKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key: %w", err)
+	}
+	baseURL := apnsSandboxURL
+	if cfg.APNsProduction {
+		baseURL = apnsProductionURL
+	}
+	return &APNsProvider{
+		baseURL: baseURL,
+		keyId:   cfg.APNsKeyID,
+		teamId:  cfg.APNsTeamID,
+		topic:   cfg.APNsTopic,
+		key:     key,
+		client:  client,
+	}, nil
+}
+
+type apnsAlert struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type apnsAps struct {
+	Alert    apnsAlert `json:"alert"`
+	Badge    int       `json:"badge"`
+	Sound    string    `json:"sound"`
+	ThreadID string    `json:"thread-id,omitempty"`
+}
+
+func (p *APNsProvider) Send(ctx context.Context, n *Notification) error {
+	token, err := p.providerToken()
+	if err != nil {
+		return err
+	}
+	// custom data sits next to aps at the top level of the payload
+	payload := make(map[string]any, len(n.Data)+1)
+	for k, v := range n.Data {
+		payload[k] = v
+	}
+	payload["aps"] = apnsAps{
+		Alert:    apnsAlert{Title: n.Title, Body: n.Body},
+		Badge:    n.Badge,
+		Sound:    "default",
+		ThreadID: n.CollapseKey,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/3/device/"+n.Token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apns-topic", p.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	if n.CollapseKey != "" {
+		req.Header.Set("apns-collapse-id", n.CollapseKey)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+	var ae struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.Unmarshal(respBody, &ae)
+	if resp.StatusCode == http.StatusGone || ae.Reason == "BadDeviceToken" || ae.Reason == "Unregistered" {
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("apns responded with %d: %s", resp.StatusCode, ae.Reason)
+}
+
+// providerToken returns the signed provider token, reissued after apnsTokenTTL.
+func (p *APNsProvider) providerToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && time.Since(p.issuedAt) < apnsTokenTTL {
+		return p.token, nil
+	}
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": p.teamId,
+		"iat": now.Unix(),
+	})
+	t.Header["kid"] = p.keyId
+	signed, err := t.SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign provider token: %w", err)
+	}
+	p.token = signed
+	p.issuedAt = now
+	return p.token, nil
+}
@@ -0,0 +1,230 @@
+package push
+
+import (
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	chat_events "chatapp/internal/services/chat"
+	"chatapp/internal/services/events"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// maxBodyLength is the number of characters of the message content shown in a notification
+const maxBodyLength = 200
+
+// Dispatcher consumes created messages and pushes them to devices of recipients who are not
+// in the conversation right now. Sending is done outside the event loop, at most PushConcurrency
+// messages at a time, notifications of further messages are dropped until a sender is free.
+type Dispatcher struct {
+	logger    logger.Logger
+	repos     *repositories.Repositories
+	evl       *chat_events.EventListener
+	providers map[string]Provider
+	sem       chan struct{}
+}
+
+func NewDispatcher(logger logger.Logger, repos *repositories.Repositories, evl *chat_events.EventListener, providers map[string]Provider, concurrency int) *Dispatcher {
+	return &Dispatcher{
+		logger:    logger,
+		repos:     repos,
+		evl:       evl,
+		providers: providers,
+		sem:       make(chan struct{}, concurrency),
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ch := make(chan events.Event, 100)
+	d.evl.SubscribeAll(ch)
+	defer d.evl.UnsubscribeAll(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-ch:
+			msg, ok := ev.Data.(*chatEnts.Message)
+			if ev.Type != events.EventTypeMessageCreated || !ok || msg.Ephemeral {
+				continue
+			}
+			// waiting for a slot would stop draining the subscription while push services are slow
+			select {
+			case d.sem <- struct{}{}:
+			default:
+				d.logger.Error(ctx, fmt.Errorf("push notifications dropped, all senders are busy"),
+					slog.Int64("messageId", msg.ID), slog.Int64("conversationId", msg.ConversationID))
+				continue
+			}
+			go func() {
+				defer func() { <-d.sem }()
+				if err := d.dispatch(ctx, msg); err != nil {
+					d.logger.Error(ctx, fmt.Errorf("failed to dispatch push notifications: %w", err), slog.Int64("messageId", msg.ID))
+				}
+			}()
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, msg *chatEnts.Message) error {
+	recipients, err := d.recipients(ctx, msg)
+	if err != nil || len(recipients) == 0 {
+		return err
+	}
+	cnv, err := d.repos.ConversationRepository.GetConversationById(ctx, msg.ConversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if cnv == nil {
+		return nil
+	}
+	sender, err := d.repos.UserRepository.GetUserById(ctx, msg.SenderID)
+	if err != nil {
+		return fmt.Errorf("failed to get sender: %w", err)
+	}
+
+	ids := make([]int64, 0, len(recipients))
+	for _, u := range recipients {
+		if wantsPush(u, msg, cnv) {
+			ids = append(ids, u.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	devices, err := d.repos.DeviceRepository.GetDevicesOfUsers(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get devices: %w", err)
+	}
+
+	title, body := render(msg, cnv, sender)
+	badges := make(map[int64]int)
+	for _, dev := range devices {
+		p, ok := d.providers[dev.Platform]
+		if !ok {
+			continue
+		}
+		badge, ok := badges[dev.UserID]
+		if !ok {
+			if badge, err = d.repos.ConversationRepository.CountUnreadMessages(ctx, dev.UserID); err != nil {
+				d.logger.Error(ctx, fmt.Errorf("failed to count unread messages: %w", err), slog.Int64("userId", dev.UserID))
+			}
+			badges[dev.UserID] = badge
+		}
+		n := &Notification{
+			Platform:    dev.Platform,
+			Token:       dev.PushToken,
+			Title:       title,
+			Body:        body,
+			CollapseKey: "conversation-" + strconv.FormatInt(cnv.ID, 10),
+			Badge:       badge,
+			Data: map[string]string{
+				"conversation_id": strconv.FormatInt(cnv.ID, 10),
+				"message_id":      strconv.FormatInt(msg.ID, 10),
+			},
+		}
+		d.send(ctx, p, dev, n)
+	}
+	return nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, p Provider, dev *users.Device, n *Notification) {
+	err := p.Send(ctx, n)
+	if err == nil {
+		return
+	}
+	if errors.Is(err, ErrInvalidToken) {
+		if err := d.repos.DeviceRepository.DeleteByToken(ctx, dev.PushToken); err != nil {
+			d.logger.Error(ctx, fmt.Errorf("failed to delete unregistered device: %w", err), slog.Int64("deviceId", dev.ID))
+			return
+		}
+		d.logger.Info(ctx, "unregistered device removed", slog.Int64("deviceId", dev.ID), slog.Int64("userId", dev.UserID))
+		return
+	}
+	d.logger.Error(ctx, fmt.Errorf("failed to send push notification: %w", err),
+		slog.Int64("deviceId", dev.ID), slog.String("platform", dev.Platform))
+}
+
+// recipients returns participants other than the sender who are not connected to the conversation,
+// didn't mute it, didn't block the sender and are not in their quiet hours.
+func (d *Dispatcher) recipients(ctx context.Context, msg *chatEnts.Message) ([]*users.User, error) {
+	participants, err := d.repos.ConversationRepository.GetParticipants(ctx, msg.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get participants: %w", err)
+	}
+	now := time.Now()
+	ids := make([]int64, 0, len(participants))
+	for _, p := range participants {
+		if p.UserID == msg.SenderID || p.IsMuted(now) || d.evl.IsOnline(msg.ConversationID, p.UserID) {
+			continue
+		}
+		ids = append(ids, p.UserID)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	blockers, err := d.repos.BlockRepository.GetBlockersOf(ctx, msg.SenderID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blockers: %w", err)
+	}
+	ids = slices.DeleteFunc(ids, func(id int64) bool {
+		return slices.Contains(blockers, id)
+	})
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	usrs, err := d.repos.UserRepository.GetUsersByIds(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	return slices.DeleteFunc(usrs, func(u *users.User) bool {
+		return u.IsBot || u.IsDeleted() || u.InQuietHours(now)
+	}), nil
+}
+
+// wantsPush applies the user's push level. Direct messages count as mentions.
+func wantsPush(u *users.User, msg *chatEnts.Message, cnv *chatEnts.Conversation) bool {
+	switch u.PushLevel {
+	case users.PushLevelAll:
+		return true
+	case users.PushLevelMentions:
+		if !cnv.IsGroup {
+			return true
+		}
+		for _, m := range msg.Mentions {
+			if m.Kind == chatEnts.MentionKindAll || (m.Kind == chatEnts.MentionKindUser && m.UserID == u.ID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// render returns the title and body of a message notification, group messages are prefixed with the sender.
+func render(msg *chatEnts.Message, cnv *chatEnts.Conversation, sender *users.User) (string, string) {
+	name := users.DeletedUserName
+	switch {
+	case msg.DisplayName != nil:
+		name = *msg.DisplayName
+	case sender != nil && !sender.IsDeleted() && sender.DisplayName != nil && *sender.DisplayName != "":
+		name = *sender.DisplayName
+	case sender != nil && !sender.IsDeleted():
+		name = sender.Username
+	}
+
+	body := []rune(msg.Content)
+	if len(body) > maxBodyLength {
+		body = append(body[:maxBodyLength-1], '…')
+	}
+	if !cnv.IsGroup {
+		return name, string(body)
+	}
+	return cnv.Name, name + ": " + string(body)
+}
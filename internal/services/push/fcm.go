@@ -0,0 +1,214 @@
+package push
+
+import (
+	"bytes"
+	"chatapp/internal/entities/users"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	fcmScope       = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmSendURL     = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+	fcmGrantType   = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	maxErrorLength = 500
+)
+
+// FCMProvider sends notifications through the Firebase Cloud Messaging HTTP v1 API.
+// Access tokens are obtained with the service account key and reused until shortly before they expire.
+type FCMProvider struct {
+	projectId string
+	email     string
+	tokenURL  string
+	key       *rsa.PrivateKey
+	client    *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+func NewFCMProvider(projectId, credentialsFile string, client *http.Client) (*FCMProvider, error) {
+	b, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	var sa serviceAccount
+	if err := json.Unmarshal(b, &sa); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	if sa.ClientEmail == "" || sa.TokenURI == "" {
+		return nil, errors.New("credentials are not a service account key")
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(sa.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	return &FCMProvider{
+		projectId: projectId,
+		email:     sa.ClientEmail,
+		tokenURL:  sa.TokenURI,
+		key:       key,
+		client:    client,
+	}, nil
+}
+
+type fcmMessage struct {
+	Message fcmMessageBody `json:"message"`
+}
+
+type fcmMessageBody struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      *fcmAndroid       `json:"android,omitempty"`
+	WebPush      *fcmWebPush       `json:"webpush,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type fcmAndroid struct {
+	CollapseKey  string                 `json:"collapse_key,omitempty"`
+	Notification fcmAndroidNotification `json:"notification"`
+}
+
+type fcmAndroidNotification struct {
+	// Tag replaces a shown notification with the same tag
+	Tag               string `json:"tag,omitempty"`
+	NotificationCount int    `json:"notification_count,omitempty"`
+}
+
+type fcmWebPush struct {
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type fcmError struct {
+	Error struct {
+		Status  string `json:"status"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (p *FCMProvider) Send(ctx context.Context, n *Notification) error {
+	token, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+	msg := fcmMessage{Message: fcmMessageBody{
+		Token:        n.Token,
+		Notification: fcmNotification{Title: n.Title, Body: n.Body},
+		Data:         n.Data,
+	}}
+	if n.Platform == users.PlatformWeb {
+		// the Topic header collapses pending web push messages
+		msg.Message.WebPush = &fcmWebPush{Headers: map[string]string{"Topic": n.CollapseKey}}
+	} else {
+		msg.Message.Android = &fcmAndroid{
+			CollapseKey:  n.CollapseKey,
+			Notification: fcmAndroidNotification{Tag: n.CollapseKey, NotificationCount: n.Badge},
+		}
+	}
+	body, err := json.Marshal(&msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(fcmSendURL, p.projectId), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+	var fe fcmError
+	_ = json.Unmarshal(respBody, &fe)
+	if resp.StatusCode == http.StatusNotFound || fe.Error.Status == "NOT_FOUND" {
+		return ErrInvalidToken
+	}
+	for _, d := range fe.Error.Details {
+		if d.ErrorCode == "UNREGISTERED" {
+			return ErrInvalidToken
+		}
+	}
+	return fmt.Errorf("fcm responded with %d: %s", resp.StatusCode, respBody)
+}
+
+// token returns a cached access token, a new one is requested a minute before the old one expires.
+func (p *FCMProvider) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.accessToken != "" && time.Now().Before(p.expiresAt.Add(-time.Minute)) {
+		return p.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   p.email,
+		"scope": fcmScope,
+		"aud":   p.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign assertion: %w", err)
+	}
+	form := url.Values{"grant_type": {fcmGrantType}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return "", fmt.Errorf("token endpoint responded with %d: %s", resp.StatusCode, respBody)
+	}
+
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", fmt.Errorf("failed to decode access token: %w", err)
+	}
+	p.accessToken = tr.AccessToken
+	p.expiresAt = now.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	return p.accessToken, nil
+}
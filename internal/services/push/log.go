@@ -0,0 +1,29 @@
+package push
+
+import (
+	"chatapp/internal/logger"
+	"context"
+	"log/slog"
+)
+
+// LogProvider logs notifications instead of sending them, for development and tests.
+type LogProvider struct {
+	logger logger.Logger
+}
+
+func NewLogProvider(logger logger.Logger) *LogProvider {
+	return &LogProvider{
+		logger: logger,
+	}
+}
+
+func (p *LogProvider) Send(ctx context.Context, n *Notification) error {
+	p.logger.Info(ctx, "push notification",
+		slog.String("platform", n.Platform),
+		slog.String("title", n.Title),
+		slog.String("body", n.Body),
+		slog.String("collapseKey", n.CollapseKey),
+		slog.Int("badge", n.Badge),
+		slog.Any("data", n.Data))
+	return nil
+}
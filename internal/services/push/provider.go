@@ -0,0 +1,73 @@
+package push
+
+import (
+	"chatapp/internal/config"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	DriverLog    = "log"
+	DriverRemote = "remote"
+)
+
+var (
+	// ErrInvalidToken is returned by providers when the push token is no longer registered, the device is removed
+	ErrInvalidToken = errors.New("push token is not registered")
+)
+
+// Notification is a push notification for a single device.
+type Notification struct {
+	Platform string
+	Token    string
+	Title    string
+	Body     string
+	// CollapseKey makes a newer notification replace an older one with the same key
+	CollapseKey string
+	// Badge is the unread count shown on the app icon
+	Badge int
+	Data  map[string]string
+}
+
+// Provider delivers notifications to devices of a push service.
+type Provider interface {
+	Send(ctx context.Context, n *Notification) error
+}
+
+// NewProviders returns providers by device platform. Platforms without a provider are not pushed to.
+func NewProviders(cfg *config.Config, logger logger.Logger) (map[string]Provider, error) {
+	switch cfg.PushDriver {
+	case DriverLog:
+		p := NewLogProvider(logger)
+		return map[string]Provider{
+			users.PlatformAndroid: p,
+			users.PlatformIOS:     p,
+			users.PlatformWeb:     p,
+		}, nil
+	case DriverRemote:
+		client := &http.Client{Timeout: cfg.PushTimeout}
+		providers := make(map[string]Provider)
+		if cfg.FCMCredentialsFile != "" {
+			fcm, err := NewFCMProvider(cfg.FCMProjectID, cfg.FCMCredentialsFile, client)
+			if err != nil {
+				return nil, fmt.Errorf("failed to init fcm: %w", err)
+			}
+			providers[users.PlatformAndroid] = fcm
+			providers[users.PlatformWeb] = fcm
+		}
+		if cfg.APNsKeyFile != "" {
+			apns, err := NewAPNsProvider(cfg, client)
+			if err != nil {
+				return nil, fmt.Errorf("failed to init apns: %w", err)
+			}
+			providers[users.PlatformIOS] = apns
+		}
+		return providers, nil
+	default:
+		return nil, fmt.Errorf("unknown push driver: %s", cfg.PushDriver)
+	}
+}
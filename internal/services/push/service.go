@@ -0,0 +1,69 @@
+package push
+
+import (
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+var (
+	ErrInvalidPlatform = errors.New("platform must be android, ios or web")
+	ErrDeviceNotFound  = errors.New("device not found")
+)
+
+// Service manages the devices users receive push notifications on.
+type Service struct {
+	logger logger.Logger
+	repos  *repositories.Repositories
+}
+
+func NewService(logger logger.Logger, repos *repositories.Repositories) *Service {
+	return &Service{
+		logger: logger,
+		repos:  repos,
+	}
+}
+
+// RegisterDevice stores the push token of a device. Apps register on every start,
+// a token registered before is moved to the user and marked as seen.
+func (s *Service) RegisterDevice(ctx context.Context, usrId int64, platform, token string) (*users.Device, error) {
+	if !slices.Contains(users.DevicePlatforms, platform) {
+		return nil, ErrInvalidPlatform
+	}
+	d := &users.Device{
+		UserID:    usrId,
+		Platform:  platform,
+		PushToken: token,
+	}
+	if err := s.repos.DeviceRepository.Register(ctx, d); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to register device: %w", err), slog.Int64("userId", usrId), slog.String("platform", platform))
+		return nil, fmt.Errorf("failed to register device: %w", err)
+	}
+	return d, nil
+}
+
+func (s *Service) GetDevices(ctx context.Context, usrId int64) ([]*users.Device, error) {
+	devices, err := s.repos.DeviceRepository.GetUserDevices(ctx, usrId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get devices: %w", err), slog.Int64("userId", usrId))
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+	return devices, nil
+}
+
+func (s *Service) DeleteDevice(ctx context.Context, usrId, id int64) error {
+	ok, err := s.repos.DeviceRepository.Delete(ctx, usrId, id)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to delete device: %w", err), slog.Int64("userId", usrId), slog.Int64("deviceId", id))
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	if !ok {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package retention
+
+import (
+	"chatapp/internal/config"
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/jobs"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	chat_events "chatapp/internal/services/chat"
+	"chatapp/internal/services/chat/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// JobKindPurge is the periodic job purging messages older than the retention
+const JobKindPurge = "retention_purge"
+
+var (
+	ErrAdminRequired           = errors.New("only system administrators can do this")
+	ErrRetentionExceedsDefault = errors.New("retention can't be longer than the server default")
+)
+
+// Service manages retention policies. Messages older than the retention of their conversation are purged
+// in small batches by a periodic job, every run is recorded for audits.
+type Service struct {
+	cfg    *config.Config
+	logger logger.Logger
+	repos  *repositories.Repositories
+	aCh    *utils.AccessChecker
+	evl    *chat_events.EventListener
+}
+
+func NewService(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories, evl *chat_events.EventListener) *Service {
+	return &Service{
+		cfg:    cfg,
+		logger: logger,
+		repos:  repos,
+		aCh:    utils.NewAccesChecker(logger, repos),
+		evl:    evl,
+	}
+}
+
+// SetRetention overrides the default retention of the conversation, nil restores the default and 0 keeps messages forever.
+// Conversation admins can only shorten the server default, system administrators can set any retention.
+func (s *Service) SetRetention(ctx context.Context, usr *users.User, cnvId int64, days *int) (*chatEnts.Conversation, error) {
+	if !usr.IsAdmin {
+		if err := s.aCh.CanManageConversation(ctx, cnvId, usr.ID); err != nil {
+			return nil, err
+		}
+		if days != nil && s.cfg.RetentionDefaultDays > 0 && (*days == 0 || *days > s.cfg.RetentionDefaultDays) {
+			return nil, ErrRetentionExceedsDefault
+		}
+	}
+	cnv, err := s.getConversation(ctx, cnvId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repos.ConversationRepository.SetRetention(ctx, cnv, days); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to set retention: %w", err), slog.Int64("conversation", cnvId))
+		return nil, fmt.Errorf("failed to set retention: %w", err)
+	}
+	s.evl.PostConversationUpdated(cnv)
+	s.logger.Info(ctx, "conversation retention changed", slog.Int64("conversation", cnvId), slog.Int64("user", usr.ID), slog.Any("days", days))
+	return cnv, nil
+}
+
+// SetLegalHold suspends or resumes purging of the conversation. Participants are not notified.
+func (s *Service) SetLegalHold(ctx context.Context, usr *users.User, cnvId int64, hold bool) (*chatEnts.Conversation, error) {
+	if !usr.IsAdmin {
+		return nil, ErrAdminRequired
+	}
+	cnv, err := s.getConversation(ctx, cnvId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repos.ConversationRepository.SetLegalHold(ctx, cnv, hold); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to set legal hold: %w", err), slog.Int64("conversation", cnvId))
+		return nil, fmt.Errorf("failed to set legal hold: %w", err)
+	}
+	s.logger.Info(ctx, "conversation legal hold changed", slog.Int64("conversation", cnvId), slog.Int64("user", usr.ID), slog.Bool("hold", hold))
+	return cnv, nil
+}
+
+func (s *Service) GetRuns(ctx context.Context, usr *users.User, lastId *int64, limit int) ([]*chatEnts.RetentionRun, error) {
+	if !usr.IsAdmin {
+		return nil, ErrAdminRequired
+	}
+	runs, err := s.repos.RetentionRepository.GetRuns(ctx, lastId, limit)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get retention runs: %w", err))
+		return nil, fmt.Errorf("failed to get retention runs: %w", err)
+	}
+	return runs, nil
+}
+
+func (s *Service) getConversation(ctx context.Context, cnvId int64) (*chatEnts.Conversation, error) {
+	cnv, err := s.repos.ConversationRepository.GetConversationById(ctx, cnvId)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to get conversation: %w", err), slog.Int64("conversation", cnvId))
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if cnv == nil {
+		return nil, utils.ErrConversationNotFound
+	}
+	return cnv, nil
+}
+
+// Purge is the handler of the periodic job. Every batch is a short statement, so writers of the purged
+// conversations are not blocked for long. The run is recorded even when it fails.
+func (s *Service) Purge(ctx context.Context, _ *jobs.Job) (err error) {
+	run := &chatEnts.RetentionRun{
+		Status:      chatEnts.RetentionRunStatusRunning,
+		Archive:     s.cfg.RetentionArchive,
+		DefaultDays: s.cfg.RetentionDefaultDays,
+		Details:     chatEnts.PurgeCounts{},
+	}
+	if err := s.repos.RetentionRepository.CreateRun(ctx, run); err != nil {
+		return fmt.Errorf("failed to create retention run: %w", err)
+	}
+	defer func() {
+		run.Status = chatEnts.RetentionRunStatusSucceeded
+		if err != nil {
+			msg := err.Error()
+			run.Status = chatEnts.RetentionRunStatusFailed
+			run.Error = &msg
+		}
+		// the job context can be already cancelled, the audit entry must be written anyway
+		finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if finishErr := s.repos.RetentionRepository.FinishRun(finishCtx, run); finishErr != nil {
+			s.logger.Error(ctx, fmt.Errorf("failed to finish retention run: %w", finishErr), slog.Int64("runId", run.ID))
+		}
+		s.logger.Info(ctx, "retention run finished", slog.Int64("runId", run.ID), slog.String("status", string(run.Status)), slog.Int64("purged", run.PurgedMessages))
+	}()
+
+	if run.HeldConversations, err = s.repos.RetentionRepository.CountHeld(ctx); err != nil {
+		return fmt.Errorf("failed to count held conversations: %w", err)
+	}
+	targets, err := s.repos.RetentionRepository.GetTargets(ctx, s.cfg.RetentionDefaultDays)
+	if err != nil {
+		return fmt.Errorf("failed to get retention targets: %w", err)
+	}
+	run.Conversations = len(targets)
+
+	for _, t := range targets {
+		for {
+			n, err := s.repos.RetentionRepository.PurgeBatch(ctx, t.ConversationID, t.RetentionDays, s.cfg.RetentionBatchSize, s.cfg.RetentionArchive)
+			if err != nil {
+				return fmt.Errorf("failed to purge conversation %d: %w", t.ConversationID, err)
+			}
+			if n > 0 {
+				run.Details[t.ConversationID] += n
+				run.PurgedMessages += n
+			}
+			if n < int64(s.cfg.RetentionBatchSize) {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.cfg.RetentionBatchPause):
+			}
+		}
+	}
+	return nil
+}
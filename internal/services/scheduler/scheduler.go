@@ -0,0 +1,245 @@
+package scheduler
+
+import (
+	"chatapp/internal/config"
+	"chatapp/internal/entities/jobs"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const maxErrorLength = 500
+
+var ErrUnknownJobKind = errors.New("no handler registered for job kind")
+
+// Handler runs a job. Returned errors are retried with exponential backoff unless wrapped with Permanent.
+// Handlers must be idempotent: a job can run again if a replica stops before recording the result.
+type Handler func(ctx context.Context, job *jobs.Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks the job failed without retries.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Scheduler runs jobs stored in Postgres. Due jobs are leased with FOR UPDATE SKIP LOCKED,
+// so every job runs on one replica at a time.
+type Scheduler struct {
+	cfg    *config.Config
+	logger logger.Logger
+	repos  *repositories.Repositories
+
+	handlers map[string]Handler
+	periodic map[string]time.Duration
+	mu       sync.RWMutex
+}
+
+func New(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories) *Scheduler {
+	return &Scheduler{
+		cfg:      cfg,
+		logger:   logger,
+		repos:    repos,
+		handlers: make(map[string]Handler),
+		periodic: make(map[string]time.Duration),
+	}
+}
+
+// Register sets the handler of a job kind, it must be called before Run.
+func (s *Scheduler) Register(kind string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[kind] = h
+}
+
+// RegisterPeriodic runs the handler every interval on one of the replicas.
+// The next run is scheduled when the previous one finishes.
+func (s *Scheduler) RegisterPeriodic(kind string, every time.Duration, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[kind] = h
+	s.periodic[kind] = every
+}
+
+// Enqueue schedules a job to run at runAt.
+func (s *Scheduler) Enqueue(ctx context.Context, kind string, payload any, runAt time.Time) (*jobs.Job, error) {
+	return s.EnqueueTx(ctx, nil, kind, payload, runAt)
+}
+
+// EnqueueTx schedules a job within the transaction, so it runs only if the transaction is committed.
+func (s *Scheduler) EnqueueTx(ctx context.Context, tx *sqlx.Tx, kind string, payload any, runAt time.Time) (*jobs.Job, error) {
+	j, _, err := s.enqueue(ctx, tx, kind, nil, payload, runAt)
+	return j, err
+}
+
+// EnqueueUnique schedules a job unless a pending job of the kind has the same key, false is returned then.
+func (s *Scheduler) EnqueueUnique(ctx context.Context, kind, key string, payload any, runAt time.Time) (*jobs.Job, bool, error) {
+	return s.enqueue(ctx, nil, kind, &key, payload, runAt)
+}
+
+func (s *Scheduler) enqueue(ctx context.Context, tx *sqlx.Tx, kind string, key *string, payload any, runAt time.Time) (*jobs.Job, bool, error) {
+	body := []byte("{}")
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, false, fmt.Errorf("failed to marshal job payload: %w", err)
+		}
+	}
+	j := &jobs.Job{
+		Kind:        kind,
+		UniqueKey:   key,
+		Payload:     body,
+		RunAt:       runAt,
+		MaxAttempts: s.cfg.JobMaxAttempts,
+	}
+
+	var q sqlx.QueryerContext
+	if tx != nil {
+		q = tx
+	}
+	created, err := s.repos.JobRepository.Create(ctx, q, j)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to enqueue job: %w", err), slog.String("kind", kind))
+		return nil, false, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return j, created, nil
+}
+
+// Cancel cancels a pending job, false is returned when it already finished.
+func (s *Scheduler) Cancel(ctx context.Context, id int64) (bool, error) {
+	return s.repos.JobRepository.Cancel(ctx, id)
+}
+
+// Reschedule moves a pending job, false is returned when it already finished.
+func (s *Scheduler) Reschedule(ctx context.Context, id int64, runAt time.Time) (bool, error) {
+	return s.repos.JobRepository.Reschedule(ctx, id, runAt)
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.RLock()
+	for kind := range s.periodic {
+		s.schedulePeriodic(ctx, kind, time.Now())
+	}
+	s.mu.RUnlock()
+
+	ticker := time.NewTicker(s.cfg.JobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.processBatch(ctx)
+		}
+	}
+}
+
+func (s *Scheduler) processBatch(ctx context.Context) {
+	claimed, err := s.repos.JobRepository.ClaimDue(ctx, s.cfg.JobBatchSize, s.cfg.JobLease)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to claim jobs: %w", err))
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, j := range claimed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.process(ctx, j)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) process(ctx context.Context, j *jobs.Job) {
+	s.mu.RLock()
+	h, ok := s.handlers[j.Kind]
+	every, isPeriodic := s.periodic[j.Kind]
+	s.mu.RUnlock()
+
+	var runErr error
+	if !ok {
+		// another replica may run a newer version knowing the kind, so the job is retried
+		runErr = fmt.Errorf("%w: %s", ErrUnknownJobKind, j.Kind)
+	} else {
+		runErr = s.run(ctx, h, j)
+	}
+
+	if runErr == nil {
+		if err := s.repos.JobRepository.MarkSucceeded(ctx, j.ID); err != nil {
+			s.logger.Error(ctx, fmt.Errorf("failed to mark job succeeded: %w", err), slog.Int64("jobId", j.ID))
+		}
+		if isPeriodic {
+			s.schedulePeriodic(ctx, j.Kind, time.Now().Add(every))
+		}
+		return
+	}
+
+	msg := runErr.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	var permanent *permanentError
+	if errors.As(runErr, &permanent) || j.Attempts >= j.MaxAttempts {
+		s.logger.Warn(ctx, "job failed", slog.Int64("jobId", j.ID), slog.String("kind", j.Kind), slog.Int("attempts", j.Attempts), slog.String("error", msg))
+		if err := s.repos.JobRepository.MarkFailed(ctx, j.ID, msg); err != nil {
+			s.logger.Error(ctx, fmt.Errorf("failed to mark job failed: %w", err), slog.Int64("jobId", j.ID))
+		}
+		if isPeriodic {
+			s.schedulePeriodic(ctx, j.Kind, time.Now().Add(every))
+		}
+		return
+	}
+	if err := s.repos.JobRepository.ScheduleRetry(ctx, j.ID, time.Now().Add(s.backoff(j.Attempts)), msg); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to schedule job retry: %w", err), slog.Int64("jobId", j.ID))
+	}
+}
+
+// run calls the handler, a panic fails the job instead of the process.
+func (s *Scheduler) run(ctx context.Context, h Handler, j *jobs.Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.JobLease)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return h(ctx, j)
+}
+
+func (s *Scheduler) schedulePeriodic(ctx context.Context, kind string, runAt time.Time) {
+	if _, _, err := s.EnqueueUnique(ctx, kind, kind, nil, runAt); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to schedule periodic job: %w", err), slog.String("kind", kind))
+	}
+}
+
+// backoff doubles the delay after every failed attempt up to the configured maximum.
+func (s *Scheduler) backoff(attempts int) time.Duration {
+	delay := s.cfg.JobBackoffBase
+	for i := 1; i < attempts && delay < s.cfg.JobBackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, s.cfg.JobBackoffMax)
+}
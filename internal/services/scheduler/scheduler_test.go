@@ -0,0 +1,277 @@
+package scheduler
+
+import (
+	"chatapp/internal/config"
+	"chatapp/internal/entities/jobs"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// memJobs mimics the job repository: claiming leases a job by moving run_at past the lease.
+type memJobs struct {
+	mu     sync.Mutex
+	jobs   map[int64]*jobs.Job
+	nextId int64
+
+	claimLimit int
+	claimLease time.Duration
+}
+
+func newMemJobs() *memJobs {
+	return &memJobs{jobs: make(map[int64]*jobs.Job)}
+}
+
+func (m *memJobs) Create(_ context.Context, _ sqlx.QueryerContext, j *jobs.Job) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.jobs {
+		if j.UniqueKey != nil && other.UniqueKey != nil && other.Status == jobs.StatusPending &&
+			other.Kind == j.Kind && *other.UniqueKey == *j.UniqueKey {
+			return false, nil
+		}
+	}
+	m.nextId++
+	j.ID = m.nextId
+	j.Status = jobs.StatusPending
+	cp := *j
+	m.jobs[j.ID] = &cp
+	return true, nil
+}
+
+func (m *memJobs) ClaimDue(_ context.Context, limit int, lease time.Duration) ([]*jobs.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.claimLimit, m.claimLease = limit, lease
+	now := time.Now()
+	var res []*jobs.Job
+	for _, j := range m.jobs {
+		if len(res) == limit {
+			break
+		}
+		if j.Status == jobs.StatusPending && !j.RunAt.After(now) {
+			j.RunAt = now.Add(lease)
+			j.Attempts++
+			cp := *j
+			res = append(res, &cp)
+		}
+	}
+	return res, nil
+}
+
+func (m *memJobs) finish(id int64, status jobs.Status, lastError *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.jobs[id]
+	j.Status, j.LastError = status, lastError
+	return nil
+}
+
+func (m *memJobs) MarkSucceeded(_ context.Context, id int64) error {
+	return m.finish(id, jobs.StatusSucceeded, nil)
+}
+
+func (m *memJobs) MarkFailed(_ context.Context, id int64, lastError string) error {
+	return m.finish(id, jobs.StatusFailed, &lastError)
+}
+
+func (m *memJobs) ScheduleRetry(_ context.Context, id int64, runAt time.Time, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.jobs[id]
+	j.RunAt, j.LastError = runAt, &lastError
+	return nil
+}
+
+func (m *memJobs) Cancel(_ context.Context, id int64) (bool, error) {
+	return true, m.finish(id, jobs.StatusCancelled, nil)
+}
+
+func (m *memJobs) Reschedule(_ context.Context, id int64, runAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].RunAt = runAt
+	return true, nil
+}
+
+func (m *memJobs) get(id int64) jobs.Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.jobs[id]
+}
+
+func newTestScheduler(t *testing.T) (*Scheduler, *memJobs) {
+	t.Helper()
+	repo := newMemJobs()
+	cfg := &config.Config{
+		JobBatchSize:   10,
+		JobLease:       time.Minute,
+		JobMaxAttempts: 3,
+		JobBackoffBase: time.Second,
+		JobBackoffMax:  time.Hour,
+	}
+	return New(cfg, logger.NewSLogger(), &repositories.Repositories{JobRepository: repo}), repo
+}
+
+func enqueueDue(t *testing.T, s *Scheduler, kind string) *jobs.Job {
+	t.Helper()
+	j, err := s.Enqueue(context.Background(), kind, map[string]int{"n": 1}, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestLeaseHidesRunningJobs(t *testing.T) {
+	s, repo := newTestScheduler(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var runs int
+	s.Register("slow", func(ctx context.Context, _ *jobs.Job) error {
+		runs++
+		close(started)
+		<-release
+		return nil
+	})
+	j := enqueueDue(t, s, "slow")
+
+	done := make(chan struct{})
+	go func() {
+		s.processBatch(context.Background())
+		close(done)
+	}()
+	<-started
+
+	// another replica polling while the job runs must not get it
+	claimed, err := repo.ClaimDue(context.Background(), 10, time.Minute)
+	if err != nil || len(claimed) != 0 {
+		t.Fatalf("leased job claimed again: %v %v", claimed, err)
+	}
+	if repo.claimLease != time.Minute {
+		t.Errorf("claimed with lease %s, want the configured %s", repo.claimLease, time.Minute)
+	}
+	close(release)
+	<-done
+
+	if got := repo.get(j.ID); got.Status != jobs.StatusSucceeded || got.Attempts != 1 || runs != 1 {
+		t.Fatalf("job = %+v after %d runs", got, runs)
+	}
+}
+
+func TestExpiredLeaseIsClaimedAgain(t *testing.T) {
+	s, repo := newTestScheduler(t)
+	j := enqueueDue(t, s, "lost")
+	if _, err := repo.ClaimDue(context.Background(), 10, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// the replica holding the lease stopped, once it expires the job runs elsewhere
+	if _, err := repo.Reschedule(context.Background(), j.ID, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	var ran bool
+	s.Register("lost", func(context.Context, *jobs.Job) error {
+		ran = true
+		return nil
+	})
+	s.processBatch(context.Background())
+	if got := repo.get(j.ID); !ran || got.Status != jobs.StatusSucceeded || got.Attempts != 2 {
+		t.Fatalf("job = %+v, ran = %v", got, ran)
+	}
+}
+
+func TestHandlerContextEndsWithLease(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	var deadline time.Time
+	s.Register("deadline", func(ctx context.Context, _ *jobs.Job) error {
+		deadline, _ = ctx.Deadline()
+		return nil
+	})
+	enqueueDue(t, s, "deadline")
+	s.processBatch(context.Background())
+
+	if until := time.Until(deadline); until <= 0 || until > time.Minute {
+		t.Fatalf("handler deadline in %s, want within the %s lease", until, time.Minute)
+	}
+}
+
+func TestFailuresAreRetriedWithBackoff(t *testing.T) {
+	s, repo := newTestScheduler(t)
+	s.Register("flaky", func(context.Context, *jobs.Job) error {
+		return errors.New("temporary")
+	})
+	j := enqueueDue(t, s, "flaky")
+
+	for attempt := 1; attempt < 3; attempt++ {
+		if _, err := repo.Reschedule(context.Background(), j.ID, time.Now().Add(-time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		before := time.Now()
+		s.processBatch(context.Background())
+
+		got := repo.get(j.ID)
+		wantDelay := time.Second << (attempt - 1)
+		if got.Status != jobs.StatusPending || got.RunAt.Before(before.Add(wantDelay)) || got.RunAt.After(time.Now().Add(wantDelay)) {
+			t.Fatalf("attempt %d: job = %+v, want a retry in %s", attempt, got, wantDelay)
+		}
+	}
+
+	// the last attempt fails the job for good
+	if _, err := repo.Reschedule(context.Background(), j.ID, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	s.processBatch(context.Background())
+	if got := repo.get(j.ID); got.Status != jobs.StatusFailed || got.Attempts != 3 || got.LastError == nil || *got.LastError != "temporary" {
+		t.Fatalf("job = %+v after all attempts", got)
+	}
+}
+
+func TestPermanentErrorsAndPanicsFail(t *testing.T) {
+	s, repo := newTestScheduler(t)
+	s.Register("broken", func(context.Context, *jobs.Job) error {
+		return Permanent(errors.New("bad payload"))
+	})
+	s.Register("panics", func(context.Context, *jobs.Job) error {
+		panic("boom")
+	})
+	broken := enqueueDue(t, s, "broken")
+	panics := enqueueDue(t, s, "panics")
+	s.processBatch(context.Background())
+
+	if got := repo.get(broken.ID); got.Status != jobs.StatusFailed || got.Attempts != 1 {
+		t.Errorf("permanent error: job = %+v", got)
+	}
+	got := repo.get(panics.ID)
+	if got.Status != jobs.StatusPending || got.LastError == nil || !strings.Contains(*got.LastError, "boom") {
+		t.Errorf("panic: job = %+v", got)
+	}
+}
+
+func TestPeriodicJobsAreRescheduled(t *testing.T) {
+	s, repo := newTestScheduler(t)
+	s.RegisterPeriodic("cleanup", time.Hour, func(context.Context, *jobs.Job) error {
+		return nil
+	})
+	s.schedulePeriodic(context.Background(), "cleanup", time.Now().Add(-time.Second))
+	// a second replica starting up doesn't add a duplicate
+	s.schedulePeriodic(context.Background(), "cleanup", time.Now().Add(-time.Second))
+	if len(repo.jobs) != 1 {
+		t.Fatalf("%d periodic jobs scheduled, want 1", len(repo.jobs))
+	}
+
+	s.processBatch(context.Background())
+	if len(repo.jobs) != 2 {
+		t.Fatalf("%d jobs after the run, want the next one scheduled", len(repo.jobs))
+	}
+	next := repo.get(2)
+	if next.Status != jobs.StatusPending || time.Until(next.RunAt) < 59*time.Minute {
+		t.Fatalf("next run = %+v, want in an hour", next)
+	}
+}
@@ -0,0 +1,59 @@
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrMalformedCiphertext = errors.New("malformed ciphertext")
+)
+
+// Box encrypts small secrets stored in the database (TOTP seeds, webhook secrets) with AES-256-GCM.
+// The key is derived from the application secret.
+type Box struct {
+	aead cipher.AEAD
+}
+
+func New(secret string) (*Box, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to init cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init gcm: %w", err)
+	}
+	return &Box{aead: aead}, nil
+}
+
+func (b *Box) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *Box) Open(ciphertext string) (string, error) {
+	data, err := base64.RawStdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", errors.Join(ErrMalformedCiphertext, err)
+	}
+	if len(data) < b.aead.NonceSize() {
+		return "", ErrMalformedCiphertext
+	}
+	nonce, sealed := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	plain, err := b.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", errors.Join(ErrMalformedCiphertext, err)
+	}
+	return string(plain), nil
+}
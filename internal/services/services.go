@@ -3,49 +3,269 @@ package services
 import (
 	"chatapp/internal/clients"
 	"chatapp/internal/config"
+	command_dto "chatapp/internal/dto/command"
 	messages_dto "chatapp/internal/dto/messages"
+	session_dto "chatapp/internal/dto/session"
 	user_dto "chatapp/internal/dto/user"
+	webhook_dto "chatapp/internal/dto/webhook"
 	"chatapp/internal/entities/chat"
+	"chatapp/internal/entities/integrations"
 	"chatapp/internal/entities/users"
 	eventlisteners "chatapp/internal/eventListeners"
 	"chatapp/internal/logger"
 	"chatapp/internal/repositories"
+	"chatapp/internal/services/account"
 	user "chatapp/internal/services/auth"
+	"chatapp/internal/services/auth/apikey"
 	"chatapp/internal/services/auth/jwt"
+	"chatapp/internal/services/auth/oidc"
+	"chatapp/internal/services/auth/password"
+	"chatapp/internal/services/auth/session"
+	"chatapp/internal/services/block"
+	"chatapp/internal/services/chat/command"
 	"chatapp/internal/services/chat/conversation"
 	"chatapp/internal/services/chat/message"
+	"chatapp/internal/services/chat/scheduled"
+	"chatapp/internal/services/contact"
+	"chatapp/internal/services/digest"
+	"chatapp/internal/services/export"
+	"chatapp/internal/services/mailer"
+	"chatapp/internal/services/push"
+	"chatapp/internal/services/retention"
+	"chatapp/internal/services/scheduler"
+	"chatapp/internal/services/secretbox"
+	"chatapp/internal/services/webhook"
 	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
 type JWTServiceInterface interface {
-	CreateToken(ctx context.Context, userID int64) (string, error)
-	VerifyAuthToken(token string) (*int64, error)
+	CreateToken(ctx context.Context, userID, sessionID int64) (string, time.Time, error)
+	VerifyAuthToken(token string) (*jwt.TokenClaims, error)
+	GetJWKS() *jwt.JWKS
+}
+type SessionServiceInterface interface {
+	CreateSession(ctx context.Context, dto *session_dto.CreateSessionDTO) (*session_dto.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*session_dto.TokenPair, error)
+	IsSessionActive(ctx context.Context, usrId, sessionId int64) (bool, error)
+	GetSessions(ctx context.Context, usrId int64) ([]*users.Session, error)
+	RevokeSession(ctx context.Context, usrId, sessionId int64) error
+	RevokeAllSessions(ctx context.Context, usrId int64) error
 }
 type UserServiceInterface interface {
 	Register(ctx context.Context, user *user_dto.CreateUserDTO) (*users.User, error)
-	Login(ctx context.Context, email, password string) (*users.User, error)
+	Login(ctx context.Context, dto *user_dto.LoginDTO) (*user.LoginResult, error)
+	VerifyMFALogin(ctx context.Context, dto *user_dto.MFALoginDTO) (*users.User, error)
+	EnrollTOTP(ctx context.Context, usr *users.User) (*user.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, usr *users.User, code string) ([]string, error)
+	RegenerateRecoveryCodes(ctx context.Context, usr *users.User, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, usr *users.User, code string) error
+	RequiresMFAEnrollment(usr *users.User) bool
+	ResendVerificationEmail(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	LoginWithIdentity(ctx context.Context, dto *user_dto.ExternalIdentityDTO) (*user.LoginResult, error)
+	UpdateProfile(ctx context.Context, usr *users.User, dto *user_dto.UpdateProfileDTO) (*users.User, error)
+	SetAvatar(ctx context.Context, usr *users.User, r io.Reader) (*users.User, error)
+	RemoveAvatar(ctx context.Context, usr *users.User) (*users.User, error)
+	AvatarPath(name string) (string, error)
+	RequestEmailChange(ctx context.Context, usr *users.User, email, password string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	ChangePassword(ctx context.Context, usr *users.User, sessionId int64, current, password string) error
+	SearchUsers(ctx context.Context, prefix string, lastId *int64, limit int) ([]*users.PublicUser, error)
+	GetPublicProfile(ctx context.Context, viewer *users.User, id int64) (*users.PublicUser, error)
+	UpdatePrivacy(ctx context.Context, usr *users.User, dto *user_dto.UpdatePrivacyDTO) (*users.User, error)
+	UpdateNotificationSettings(ctx context.Context, usr *users.User, dto *user_dto.NotificationSettingsDTO) (*users.User, error)
+}
+type OIDCServiceInterface interface {
+	Providers() []oidc.ProviderInfo
+	BeginLogin(ctx context.Context, providerName string) (*oidc.AuthRequest, error)
+	FinishLogin(ctx context.Context, sealedState, state, code string) (*user_dto.ExternalIdentityDTO, error)
+	SuccessRedirectURL() string
+}
+type APIKeyServiceInterface interface {
+	CreateAPIKey(ctx context.Context, usr *users.User, dto *user_dto.CreateAPIKeyDTO) (*users.APIKey, string, error)
+	Authenticate(ctx context.Context, raw string) (*users.User, *users.APIKey, error)
+	GetAPIKeys(ctx context.Context, usrId int64) ([]*users.APIKey, error)
+	RevokeAPIKey(ctx context.Context, usrId, keyId int64) error
+	CreateBot(ctx context.Context, q sqlx.QueryerContext, owner *users.User, name string) (*users.User, error)
+	GetBots(ctx context.Context, ownerId int64) ([]*users.User, error)
+	DeleteBot(ctx context.Context, ownerId, botId int64) error
 }
 type ConversationServiceInterface interface {
-	CreateConversation(ctx context.Context, name string, isGroup bool, participantIDs []int64) (*chat.Conversation, error)
+	CreateConversation(ctx context.Context, creatorId int64, name string, isGroup bool, participantIDs []int64) (*chat.Conversation, error)
 	PostUserTyping(ctx context.Context, usrId, cnvId int64) error
+	SetMessageTTL(ctx context.Context, usrId, cnvId int64, ttl *time.Duration) (*chat.Conversation, error)
+	SetMuted(ctx context.Context, usrId, cnvId int64, muted bool, until *time.Time) (*chat.ConversationParticipant, error)
+	MarkSeen(ctx context.Context, cnvId, usrId int64)
+}
+
+type ContactServiceInterface interface {
+	SendRequest(ctx context.Context, senderId, recipientId int64) (*users.ContactRequest, error)
+	AcceptRequest(ctx context.Context, usrId, reqId int64) (*users.ContactRequest, error)
+	DeclineRequest(ctx context.Context, usrId, reqId int64) (*users.ContactRequest, error)
+	CancelRequest(ctx context.Context, usrId, reqId int64) (*users.ContactRequest, error)
+	GetRequests(ctx context.Context, usrId int64, incoming bool) ([]*users.ContactRequest, error)
+	GetContacts(ctx context.Context, usrId int64) ([]*users.Contact, error)
+	RemoveContact(ctx context.Context, usrId, contactId int64) error
+}
+
+type BlockServiceInterface interface {
+	Block(ctx context.Context, usrId, blockedId int64) error
+	Unblock(ctx context.Context, usrId, blockedId int64) error
+	GetBlocks(ctx context.Context, usrId int64) ([]*users.Block, error)
+	GetBlockedIds(ctx context.Context, usrId int64) ([]int64, error)
 }
 type MessageServiceInterface interface {
-	SendMessage(ctx context.Context, conversationID, senderID int64, content string) (*chat.Message, error)
+	SendMessage(ctx context.Context, dto *messages_dto.SendMessageDTO) (*chat.Message, error)
 	GetMessages(ctx context.Context, params *messages_dto.GetMessageQueryParams) ([]*chat.Message, error)
 	UpdateMessage(ctx context.Context, conversationID, messageID, userID int64, content string) (*chat.Message, error)
+	GetMentions(ctx context.Context, params *messages_dto.GetMentionsQueryParams) ([]*chat.Message, error)
+	GetMentionCounts(ctx context.Context, usrId int64) ([]*chat.MentionCount, error)
+	MarkMentionsRead(ctx context.Context, usrId, cnvId int64) error
+	PinMessage(ctx context.Context, cnvId, msgId, usrId int64) (*chat.Pin, error)
+	UnpinMessage(ctx context.Context, cnvId, msgId, usrId int64) error
+	GetPinnedMessages(ctx context.Context, cnvId, usrId int64) ([]*chat.PinnedMessage, error)
+}
+type ScheduledMessageServiceInterface interface {
+	ScheduleMessage(ctx context.Context, dto *messages_dto.ScheduleMessageDTO) (*chat.ScheduledMessage, error)
+	GetScheduledMessages(ctx context.Context, cnvId, usrId int64) ([]*chat.ScheduledMessage, error)
+	UpdateScheduledMessage(ctx context.Context, dto *messages_dto.UpdateScheduledMessageDTO) (*chat.ScheduledMessage, error)
+	CancelScheduledMessage(ctx context.Context, cnvId, id, usrId int64) error
+}
+type WebhookServiceInterface interface {
+	CreateWebhook(ctx context.Context, dto *webhook_dto.CreateWebhookDTO) (*integrations.Webhook, string, error)
+	GetWebhooks(ctx context.Context, usrId, cnvId int64) ([]*integrations.Webhook, error)
+	DeleteWebhook(ctx context.Context, usrId, cnvId, webhookId int64) error
+	GetDeliveries(ctx context.Context, params *webhook_dto.GetDeliveriesQueryParams) ([]*integrations.WebhookDelivery, error)
+	GetDeadLetters(ctx context.Context, usrId, cnvId, webhookId int64, limit int) ([]*integrations.WebhookDeadLetter, error)
+}
+
+type IncomingWebhookServiceInterface interface {
+	CreateIncomingWebhook(ctx context.Context, owner *users.User, dto *webhook_dto.CreateIncomingWebhookDTO) (*integrations.IncomingWebhook, string, error)
+	GetIncomingWebhooks(ctx context.Context, usrId, cnvId int64) ([]*integrations.IncomingWebhook, error)
+	DeleteIncomingWebhook(ctx context.Context, usrId, cnvId, webhookId int64) error
+	PostMessage(ctx context.Context, token string, dto *webhook_dto.IncomingMessageDTO) (*chat.Message, error)
+}
+type CommandServiceInterface interface {
+	CreateCommand(ctx context.Context, owner *users.User, dto *command_dto.CreateCommandDTO) (*integrations.BotCommand, string, error)
+	GetCommands(ctx context.Context, usrId, cnvId int64) ([]*integrations.BotCommand, error)
+	DeleteCommand(ctx context.Context, usrId, cnvId, cmdId int64) error
+}
+
+type RetentionServiceInterface interface {
+	SetRetention(ctx context.Context, usr *users.User, cnvId int64, days *int) (*chat.Conversation, error)
+	SetLegalHold(ctx context.Context, usr *users.User, cnvId int64, hold bool) (*chat.Conversation, error)
+	GetRuns(ctx context.Context, usr *users.User, lastId *int64, limit int) ([]*chat.RetentionRun, error)
+}
+
+type ExportServiceInterface interface {
+	RequestExport(ctx context.Context, usrId, cnvId int64, format chat.ExportFormat) (*chat.Export, error)
+	WriteExport(ctx context.Context, cnvId int64, format chat.ExportFormat, w io.Writer) error
+	GetExport(ctx context.Context, usrId, cnvId, id int64) (*chat.Export, error)
+	OpenExport(ctx context.Context, usrId, cnvId, id int64) (*chat.Export, error)
+}
+
+type AccountServiceInterface interface {
+	WriteExport(ctx context.Context, usrId int64, w io.Writer) error
+	DeleteAccount(ctx context.Context, usr *users.User, password string) error
+}
+
+type DeviceServiceInterface interface {
+	RegisterDevice(ctx context.Context, usrId int64, platform, token string) (*users.Device, error)
+	GetDevices(ctx context.Context, usrId int64) ([]*users.Device, error)
+	DeleteDevice(ctx context.Context, usrId, id int64) error
+}
+
+// Worker is a background process running until the context is cancelled.
+type Worker interface {
+	Run(ctx context.Context)
 }
 type Services struct {
 	UserService         UserServiceInterface
 	JwtService          JWTServiceInterface
+	SessionService      SessionServiceInterface
+	OIDCService         OIDCServiceInterface
+	APIKeyService       APIKeyServiceInterface
 	ConversationService ConversationServiceInterface
 	MessageService      MessageServiceInterface
+	ScheduledService    ScheduledMessageServiceInterface
+	WebhookService      WebhookServiceInterface
+	IncomingService     IncomingWebhookServiceInterface
+	CommandService      CommandServiceInterface
+	RetentionService    RetentionServiceInterface
+	ExportService       ExportServiceInterface
+	AccountService      AccountServiceInterface
+	BlockService        BlockServiceInterface
+	ContactService      ContactServiceInterface
+	DeviceService       DeviceServiceInterface
+
+	Mailer  mailer.Mailer
+	Workers []Worker
 }
 
-func NewServices(cfg *config.Config, cls *clients.Clients, logger logger.Logger, repos *repositories.Repositories, evls *eventlisteners.EventListeners) *Services {
+func NewServices(cfg *config.Config, cls *clients.Clients, logger logger.Logger, repos *repositories.Repositories, evls *eventlisteners.EventListeners) (*Services, error) {
+	jwtService, err := jwt.NewService(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init jwt service: %w", err)
+	}
+	passwordPolicy, err := password.NewPolicy(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init password policy: %w", err)
+	}
+	mlr, err := mailer.NewMailer(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init mailer: %w", err)
+	}
+	box, err := secretbox.New(cfg.AppSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init secret box: %w", err)
+	}
+	providers, err := push.NewProviders(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init push providers: %w", err)
+	}
+	apiKeyService := apikey.NewService(cfg, logger, repos)
+	commandService := command.NewService(cfg, cls, logger, repos, box, apiKeyService, evls.ChatEventListener)
+	messageService := message.NewService(logger, repos, evls.ChatEventListener, commandService)
+	sch := scheduler.New(cfg, logger, repos)
+	sch.RegisterPeriodic(message.JobKindExpireMessages, cfg.MessageExpiryInterval, message.NewSweeper(cfg, logger, repos, evls.ChatEventListener).Sweep)
+	retentionService := retention.NewService(cfg, logger, repos, evls.ChatEventListener)
+	sch.RegisterPeriodic(retention.JobKindPurge, cfg.RetentionInterval, retentionService.Purge)
+	exportService := export.NewService(cfg, logger, repos, sch, evls.ChatEventListener)
+	sch.RegisterPeriodic(export.JobKindCleanup, cfg.ExportCleanupInterval, exportService.Cleanup)
+	sch.RegisterPeriodic(digest.JobKindSend, cfg.DigestInterval, digest.NewService(cfg, logger, repos, mlr).Send)
+
 	return &Services{
-		UserService:         user.NewService(logger, repos),
-		JwtService:          jwt.NewService(cfg, logger),
+		UserService:         user.NewService(cfg, logger, repos, passwordPolicy, mlr, box, evls.ChatEventListener),
+		JwtService:          jwtService,
+		SessionService:      session.NewService(cfg, logger, repos, jwtService),
+		OIDCService:         oidc.NewService(cfg, logger, box),
+		APIKeyService:       apiKeyService,
 		ConversationService: conversation.NewService(cls, repos, logger, evls.ChatEventListener),
-		MessageService:      message.NewService(logger, repos, evls.ChatEventListener),
-	}
+		MessageService:      messageService,
+		ScheduledService:    scheduled.NewService(cls, logger, repos, sch, messageService),
+		WebhookService:      webhook.NewService(cfg, logger, repos, box),
+		IncomingService:     webhook.NewIncomingService(cfg, cls, logger, repos, apiKeyService, messageService, evls.ChatEventListener),
+		CommandService:      commandService,
+		RetentionService:    retentionService,
+		ExportService:       exportService,
+		AccountService:      account.NewService(cfg, logger, repos),
+		BlockService:        block.NewService(logger, repos, evls.ChatEventListener),
+		ContactService:      contact.NewService(logger, repos, evls.ChatEventListener),
+		DeviceService:       push.NewService(logger, repos),
+
+		Mailer: mlr,
+		Workers: []Worker{
+			webhook.NewDispatcher(logger, repos, evls.ChatEventListener),
+			webhook.NewWorker(cfg, logger, repos, box),
+			push.NewDispatcher(logger, repos, evls.ChatEventListener, providers, cfg.PushConcurrency),
+			sch,
+		},
+	}, nil
 }
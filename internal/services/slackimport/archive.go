@@ -0,0 +1,189 @@
+package slackimport
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidArchive = errors.New("invalid slack export archive")
+
+type slackUser struct {
+	ID      string `json:"id"`
+	TeamID  string `json:"team_id"`
+	Name    string `json:"name"`
+	Deleted bool   `json:"deleted"`
+	IsBot   bool   `json:"is_bot"`
+	Profile struct {
+		Email       string `json:"email"`
+		RealName    string `json:"real_name"`
+		DisplayName string `json:"display_name"`
+	} `json:"profile"`
+}
+
+type slackChannel struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Created int64    `json:"created"`
+	Creator string   `json:"creator"`
+	Members []string `json:"members"`
+	Topic   struct {
+		Value string `json:"value"`
+	} `json:"topic"`
+	Purpose struct {
+		Value string `json:"value"`
+	} `json:"purpose"`
+
+	// folder holds the daily message files, dms are stored by id and the rest by name
+	folder  string
+	isGroup bool
+}
+
+type slackMessage struct {
+	Type       string `json:"type"`
+	Subtype    string `json:"subtype"`
+	User       string `json:"user"`
+	BotID      string `json:"bot_id"`
+	Username   string `json:"username"`
+	Text       string `json:"text"`
+	TS         string `json:"ts"`
+	ThreadTS   string `json:"thread_ts"`
+	BotProfile *struct {
+		Name string `json:"name"`
+	} `json:"bot_profile"`
+	Edited *struct {
+		TS string `json:"ts"`
+	} `json:"edited"`
+	Reactions []struct {
+		Name  string   `json:"name"`
+		Users []string `json:"users"`
+	} `json:"reactions"`
+	Files []struct {
+		Name string `json:"name"`
+	} `json:"files"`
+}
+
+// archive is an opened Slack workspace export
+type archive struct {
+	zr    *zip.ReadCloser
+	files map[string]*zip.File
+	// root is the directory holding users.json, exports repacked by hand often nest it
+	root string
+}
+
+func openArchive(name string) (*archive, error) {
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	a := &archive{zr: zr, files: make(map[string]*zip.File, len(zr.File))}
+	root, found := "", false
+	for _, f := range zr.File {
+		a.files[f.Name] = f
+		if path.Base(f.Name) == "users.json" && (!found || len(f.Name) < len(root)) {
+			root, found = path.Dir(f.Name), true
+		}
+	}
+	if !found {
+		zr.Close()
+		return nil, fmt.Errorf("%w: users.json not found", ErrInvalidArchive)
+	}
+	if root != "." {
+		a.root = root
+	}
+	return a, nil
+}
+
+func (a *archive) Close() error {
+	return a.zr.Close()
+}
+
+// readJSON decodes a file of the archive, missing files leave v untouched.
+func (a *archive) readJSON(name string, v any) error {
+	f, ok := a.files[path.Join(a.root, name)]
+	if !ok {
+		return nil
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+	}
+	return nil
+}
+
+func (a *archive) users() ([]*slackUser, error) {
+	var usrs []*slackUser
+	return usrs, a.readJSON("users.json", &usrs)
+}
+
+// channels lists public and private channels, direct messages and group direct messages.
+func (a *archive) channels() ([]*slackChannel, error) {
+	var res []*slackChannel
+	for _, src := range []struct {
+		file    string
+		isGroup bool
+		byID    bool
+	}{
+		{"channels.json", true, false},
+		{"groups.json", true, false},
+		{"mpims.json", true, false},
+		{"dms.json", false, true},
+	} {
+		var chs []*slackChannel
+		if err := a.readJSON(src.file, &chs); err != nil {
+			return nil, err
+		}
+		for _, ch := range chs {
+			ch.isGroup = src.isGroup
+			ch.folder = ch.Name
+			if src.byID {
+				ch.folder = ch.ID
+			}
+			res = append(res, ch)
+		}
+	}
+	return res, nil
+}
+
+// messages reads every daily file of the channel, ordered by timestamp so thread roots precede their replies.
+func (a *archive) messages(ch *slackChannel) ([]*slackMessage, error) {
+	prefix := path.Join(a.root, ch.folder) + "/"
+
+	var res []*slackMessage
+	for name := range a.files {
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".json") || strings.Contains(name[len(prefix):], "/") {
+			continue
+		}
+		var msgs []*slackMessage
+		if err := a.readJSON(strings.TrimPrefix(strings.TrimPrefix(name, a.root), "/"), &msgs); err != nil {
+			return nil, err
+		}
+		res = append(res, msgs...)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return parseTS(res[i].TS).Before(parseTS(res[j].TS))
+	})
+	return res, nil
+}
+
+// parseTS converts a Slack timestamp like "1700000000.000200" to time, the fraction is in microseconds.
+func parseTS(ts string) time.Time {
+	sec, frac, _ := strings.Cut(ts, ".")
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	us, _ := strconv.ParseInt(frac, 10, 64)
+	return time.Unix(s, us*int64(time.Microsecond)).UTC()
+}
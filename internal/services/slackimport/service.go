@@ -0,0 +1,397 @@
+package slackimport
+
+import (
+	"chatapp/internal/config"
+	user_dto "chatapp/internal/dto/user"
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// externalIDPrefix namespaces ids of imported conversations
+	externalIDPrefix = "slack:"
+
+	maxUsernameLen     = 50
+	maxConversationLen = 255
+	maxTopicLen        = 250
+)
+
+// skippedSubtypes are channel notices Slack stores as messages, they carry no conversation content
+var skippedSubtypes = map[string]bool{
+	"channel_join":    true,
+	"channel_leave":   true,
+	"group_join":      true,
+	"group_leave":     true,
+	"channel_topic":   true,
+	"channel_purpose": true,
+	"channel_name":    true,
+	"channel_archive": true,
+	"group_topic":     true,
+	"group_purpose":   true,
+	"group_name":      true,
+	"group_archive":   true,
+	"bot_add":         true,
+	"bot_remove":      true,
+	"pinned_item":     true,
+	"tombstone":       true,
+}
+
+type passwordHasher interface {
+	GenerateToken() (string, error)
+	HashPassword(password string) (string, error)
+}
+
+// Report counts what an import did, rows that already existed are not counted as created.
+type Report struct {
+	UsersMatched         int   `json:"users_matched"`
+	UsersCreated         int   `json:"users_created"`
+	Conversations        int   `json:"conversations"`
+	ConversationsCreated int   `json:"conversations_created"`
+	Participants         int64 `json:"participants"`
+	Messages             int64 `json:"messages"`
+	MessagesSkipped      int   `json:"messages_skipped"`
+	Threads              int64 `json:"threads"`
+	Reactions            int64 `json:"reactions"`
+}
+
+// Service imports Slack workspace exports. Slack users are mapped to accounts by email and
+// placeholder accounts are created for the rest. Every row is keyed by its Slack id, so an
+// interrupted or repeated import resumes without duplicating data.
+type Service struct {
+	cfg    *config.Config
+	logger logger.Logger
+	repos  *repositories.Repositories
+	hasher passwordHasher
+}
+
+func NewService(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories, hasher passwordHasher) *Service {
+	return &Service{
+		cfg:    cfg,
+		logger: logger,
+		repos:  repos,
+		hasher: hasher,
+	}
+}
+
+// run holds the state of a single import
+type run struct {
+	team      string
+	userIds   map[string]int64
+	usernames map[string]string
+	report    *Report
+}
+
+func (s *Service) Import(ctx context.Context, file string) (*Report, error) {
+	a, err := openArchive(file)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	usrs, err := a.users()
+	if err != nil {
+		return nil, err
+	}
+	chs, err := a.channels()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &run{
+		userIds:   make(map[string]int64, len(usrs)),
+		usernames: make(map[string]string, len(usrs)),
+		report:    &Report{},
+	}
+	for _, u := range usrs {
+		if r.team == "" {
+			r.team = u.TeamID
+		}
+		if err := s.importUser(ctx, r, u); err != nil {
+			return r.report, err
+		}
+	}
+	s.logger.Info(ctx, "slack users imported",
+		slog.Int("matched", r.report.UsersMatched), slog.Int("created", r.report.UsersCreated))
+
+	for _, ch := range chs {
+		if err := ctx.Err(); err != nil {
+			return r.report, err
+		}
+		if err := s.importChannel(ctx, r, a, ch); err != nil {
+			return r.report, err
+		}
+	}
+	s.logger.Info(ctx, "slack import finished", slog.Any("report", r.report))
+	return r.report, nil
+}
+
+func (s *Service) importUser(ctx context.Context, r *run, u *slackUser) error {
+	name := firstNonEmpty(u.Profile.DisplayName, u.Profile.RealName, u.Name, u.ID)
+	email := strings.ToLower(strings.TrimSpace(u.Profile.Email))
+	if email == "" {
+		email = s.placeholderEmail(r, u.ID)
+	}
+
+	usr, err := s.ensureUser(ctx, r, email, name, u.IsBot)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to import slack user: %w", err), slog.String("slackId", u.ID))
+		return fmt.Errorf("failed to import slack user %s: %w", u.ID, err)
+	}
+	r.userIds[u.ID] = usr.ID
+	r.usernames[u.ID] = usr.Username
+	return nil
+}
+
+// ensureUser returns the account with the email, a placeholder is created when there is none.
+// Placeholders get an unusable password, people claim them with a password reset.
+func (s *Service) ensureUser(ctx context.Context, r *run, email, name string, isBot bool) (*users.User, error) {
+	usr, err := s.repos.UserRepository.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	created := false
+	if usr == nil {
+		secret, err := s.hasher.GenerateToken()
+		if err != nil {
+			return nil, err
+		}
+		pwdHash, err := s.hasher.HashPassword(secret)
+		if err != nil {
+			return nil, err
+		}
+		usr, created, err = s.repos.UserRepository.CreateImported(ctx, &user_dto.CreateImportedUserDTO{
+			Name:     truncate(name, maxUsernameLen),
+			Email:    email,
+			Password: pwdHash,
+			IsBot:    isBot,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if created {
+		r.report.UsersCreated++
+	} else {
+		r.report.UsersMatched++
+	}
+	return usr, nil
+}
+
+func (s *Service) placeholderEmail(r *run, slackId string) string {
+	local := "slack-" + slackId
+	if r.team != "" {
+		local = "slack-" + r.team + "-" + slackId
+	}
+	return strings.ToLower(local + "@" + s.cfg.ImportPlaceholderDomain)
+}
+
+// sender resolves the author of a message, integrations posting without a user get bot placeholders.
+func (s *Service) sender(ctx context.Context, r *run, m *slackMessage) (int64, error) {
+	if m.User != "" {
+		if id, ok := r.userIds[m.User]; ok {
+			return id, nil
+		}
+	}
+	key := firstNonEmpty(m.BotID, m.User)
+	if key == "" {
+		return 0, nil
+	}
+	if id, ok := r.userIds[key]; ok {
+		return id, nil
+	}
+
+	name := firstNonEmpty(m.Username, key)
+	if m.BotProfile != nil && m.BotProfile.Name != "" {
+		name = m.BotProfile.Name
+	}
+	usr, err := s.ensureUser(ctx, r, s.placeholderEmail(r, key), name, m.BotID != "")
+	if err != nil {
+		return 0, err
+	}
+	r.userIds[key] = usr.ID
+	r.usernames[key] = usr.Username
+	return usr.ID, nil
+}
+
+func (s *Service) importChannel(ctx context.Context, r *run, a *archive, ch *slackChannel) error {
+	externalId := externalIDPrefix + ch.ID
+	cnv := &chatEnts.Conversation{
+		Name:       truncate(s.conversationName(r, ch), maxConversationLen),
+		IsGroup:    ch.isGroup,
+		Topic:      truncate(firstNonEmpty(ch.Topic.Value, ch.Purpose.Value), maxTopicLen),
+		ExternalID: &externalId,
+		CreatedAt:  time.Unix(ch.Created, 0),
+	}
+	created, err := s.repos.ImportRepository.UpsertConversation(ctx, cnv)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to import slack channel: %w", err), slog.String("slackId", ch.ID))
+		return fmt.Errorf("failed to import slack channel %s: %w", ch.ID, err)
+	}
+	r.report.Conversations++
+	if created {
+		r.report.ConversationsCreated++
+	}
+
+	var pts []*chatEnts.ConversationParticipant
+	for _, m := range ch.Members {
+		id, ok := r.userIds[m]
+		if !ok {
+			continue
+		}
+		role := chatEnts.ParticipantRoleMember
+		if m == ch.Creator || !ch.isGroup {
+			role = chatEnts.ParticipantRoleAdmin
+		}
+		pts = append(pts, &chatEnts.ConversationParticipant{UserID: id, Role: role})
+	}
+	added, err := s.repos.ImportRepository.AddParticipants(ctx, cnv.ID, pts)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to import slack channel members: %w", err),
+			slog.String("slackId", ch.ID), slog.Int64("conversationId", cnv.ID))
+		return fmt.Errorf("failed to import slack channel members %s: %w", ch.ID, err)
+	}
+	r.report.Participants += added
+
+	msgs, err := a.messages(ch)
+	if err != nil {
+		return err
+	}
+	if err := s.importMessages(ctx, r, cnv.ID, msgs); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to import slack messages: %w", err),
+			slog.String("slackId", ch.ID), slog.Int64("conversationId", cnv.ID))
+		return fmt.Errorf("failed to import slack messages %s: %w", ch.ID, err)
+	}
+	s.logger.Info(ctx, "slack channel imported",
+		slog.String("slackId", ch.ID), slog.Int64("conversationId", cnv.ID), slog.Int("messages", len(msgs)))
+	return nil
+}
+
+// importMessages inserts messages first and then threads and reactions, which reference messages
+// by their Slack timestamp and so need them stored.
+func (s *Service) importMessages(ctx context.Context, r *run, cnvId int64, msgs []*slackMessage) error {
+	batchSize := s.cfg.ImportBatchSize
+
+	var (
+		batch     []*chatEnts.Message
+		links     []*chatEnts.ImportedThreadLink
+		reactions []*chatEnts.ImportedReaction
+	)
+	flush := func() error {
+		n, err := s.repos.ImportRepository.InsertMessages(ctx, cnvId, batch)
+		if err != nil {
+			return err
+		}
+		r.report.Messages += n
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, m := range msgs {
+		if m.Type != "message" || m.TS == "" || skippedSubtypes[m.Subtype] {
+			r.report.MessagesSkipped++
+			continue
+		}
+		senderId, err := s.sender(ctx, r, m)
+		if err != nil {
+			return err
+		}
+		content := messageContent(m, r.usernames)
+		if senderId == 0 || content == "" {
+			r.report.MessagesSkipped++
+			continue
+		}
+
+		ts := m.TS
+		createdAt := parseTS(ts)
+		updatedAt := createdAt
+		if m.Edited != nil {
+			updatedAt = parseTS(m.Edited.TS)
+		}
+		batch = append(batch, &chatEnts.Message{
+			SenderID:   senderId,
+			Content:    content,
+			ExternalID: &ts,
+			CreatedAt:  createdAt,
+			UpdatedAt:  updatedAt,
+		})
+		if m.ThreadTS != "" && m.ThreadTS != m.TS {
+			links = append(links, &chatEnts.ImportedThreadLink{MessageExternalID: ts, ParentExternalID: m.ThreadTS})
+		}
+		for _, re := range m.Reactions {
+			for _, u := range re.Users {
+				if id, ok := r.userIds[u]; ok {
+					reactions = append(reactions, &chatEnts.ImportedReaction{MessageExternalID: ts, UserID: id, Emoji: re.Name})
+				}
+			}
+		}
+
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(links); start += batchSize {
+		n, err := s.repos.ImportRepository.LinkThreads(ctx, cnvId, links[start:min(start+batchSize, len(links))])
+		if err != nil {
+			return err
+		}
+		r.report.Threads += n
+	}
+	for start := 0; start < len(reactions); start += batchSize {
+		n, err := s.repos.ImportRepository.InsertReactions(ctx, cnvId, reactions[start:min(start+batchSize, len(reactions))])
+		if err != nil {
+			return err
+		}
+		r.report.Reactions += n
+	}
+	return nil
+}
+
+// conversationName names direct messages after their members, Slack names them by id or generated handles.
+func (s *Service) conversationName(r *run, ch *slackChannel) string {
+	if ch.isGroup && !strings.HasPrefix(ch.Name, "mpdm-") {
+		return ch.Name
+	}
+	names := make([]string, 0, len(ch.Members))
+	for _, m := range ch.Members {
+		names = append(names, firstNonEmpty(r.usernames[m], m))
+	}
+	return strings.Join(names, ", ")
+}
+
+func messageContent(m *slackMessage, usernames map[string]string) string {
+	content := convertText(m.Text, usernames)
+	for _, f := range m.Files {
+		content = strings.TrimSpace(content + "\n[file: " + f.Name + "]")
+	}
+	return strings.TrimSpace(content)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
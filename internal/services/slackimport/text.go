@@ -0,0 +1,47 @@
+package slackimport
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// slackLinkRe matches Slack control sequences like <@U123>, <#C123|general>, <!here> and <https://x|label>
+var slackLinkRe = regexp.MustCompile(`<([^<>]+)>`)
+
+// convertText rewrites Slack markup to plain text, user references are replaced with usernames
+// so they read like mentions of this app.
+func convertText(text string, usernames map[string]string) string {
+	text = slackLinkRe.ReplaceAllStringFunc(text, func(m string) string {
+		ref, label, hasLabel := strings.Cut(m[1:len(m)-1], "|")
+		switch {
+		case strings.HasPrefix(ref, "@"):
+			if name, ok := usernames[ref[1:]]; ok {
+				return "@" + name
+			}
+			if hasLabel {
+				return "@" + label
+			}
+			return ref
+		case strings.HasPrefix(ref, "#"):
+			if hasLabel {
+				return "#" + label
+			}
+			return ref
+		case strings.HasPrefix(ref, "!subteam^"):
+			if hasLabel {
+				return label
+			}
+			return "@group"
+		case strings.HasPrefix(ref, "!"):
+			if hasLabel {
+				return label
+			}
+			return "@" + strings.TrimPrefix(ref, "!")
+		case hasLabel && label != ref && label != strings.TrimPrefix(ref, "mailto:"):
+			return label + " (" + ref + ")"
+		}
+		return strings.TrimPrefix(ref, "mailto:")
+	})
+	return html.UnescapeString(text)
+}
@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/integrations"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	chat_events "chatapp/internal/services/chat"
+	"chatapp/internal/services/events"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Payload is the JSON body sent to webhooks.
+type Payload struct {
+	Type           events.EventType `json:"type"`
+	ConversationID int64            `json:"conversation_id"`
+	OccurredAt     time.Time        `json:"occurred_at"`
+	Data           any              `json:"data"`
+}
+
+// Dispatcher consumes events published by the chat event listener and queues a delivery per subscribed webhook.
+// Deliveries are stored before sending, so they survive restarts and are sent by the Worker.
+type Dispatcher struct {
+	logger logger.Logger
+	repos  *repositories.Repositories
+	evl    *chat_events.EventListener
+}
+
+func NewDispatcher(logger logger.Logger, repos *repositories.Repositories, evl *chat_events.EventListener) *Dispatcher {
+	return &Dispatcher{
+		logger: logger,
+		repos:  repos,
+		evl:    evl,
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ch := make(chan events.Event, 100)
+	d.evl.SubscribeAll(ch)
+	defer d.evl.UnsubscribeAll(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-ch:
+			if err := d.dispatch(ctx, ev); err != nil {
+				d.logger.Error(ctx, fmt.Errorf("failed to dispatch webhook event: %w", err), slog.String("event", string(ev.Type)))
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, ev events.Event) error {
+	cnvId, ok := conversationOf(ev)
+	if !ok {
+		return nil
+	}
+	webhooks, err := d.repos.WebhookRepository.GetConversationWebhooks(ctx, cnvId)
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(&Payload{
+		Type:           ev.Type,
+		ConversationID: cnvId,
+		OccurredAt:     time.Now().UTC(),
+		Data:           ev.Data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	for _, w := range webhooks {
+		if !w.Subscribes(string(ev.Type)) {
+			continue
+		}
+		delivery := &integrations.WebhookDelivery{
+			WebhookID: w.ID,
+			EventType: string(ev.Type),
+			Payload:   body,
+		}
+		if err := d.repos.WebhookRepository.CreateDelivery(ctx, delivery); err != nil {
+			d.logger.Error(ctx, fmt.Errorf("failed to queue webhook delivery: %w", err), slog.Int64("webhookId", w.ID))
+		}
+	}
+	return nil
+}
+
+func conversationOf(ev events.Event) (int64, bool) {
+	switch data := ev.Data.(type) {
+	case *chatEnts.Message:
+		return data.ConversationID, true
+	case *chatEnts.ConversationParticipant:
+		return data.ConversationID, true
+	case *chatEnts.ExpiredMessage:
+		return data.ConversationID, true
+	case *chatEnts.Pin:
+		return data.ConversationID, true
+	case *chatEnts.Conversation:
+		return data.ID, true
+	case *chatEnts.UserUpdated:
+		return data.ConversationID, true
+	}
+	return 0, false
+}
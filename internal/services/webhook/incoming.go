@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"chatapp/internal/clients"
+	"chatapp/internal/config"
+	messages_dto "chatapp/internal/dto/messages"
+	webhook_dto "chatapp/internal/dto/webhook"
+	chatEnts "chatapp/internal/entities/chat"
+	"chatapp/internal/entities/integrations"
+	"chatapp/internal/entities/users"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	chat_events "chatapp/internal/services/chat"
+	"chatapp/internal/services/chat/utils"
+	"chatapp/internal/services/hash"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/time/rate"
+)
+
+const IncomingPath = "/api/v1/hooks/"
+
+var (
+	ErrInvalidHookToken = errors.New("invalid webhook token")
+	ErrRateLimited      = errors.New("webhook rate limit exceeded")
+)
+
+type botCreator interface {
+	CreateBot(ctx context.Context, q sqlx.QueryerContext, owner *users.User, name string) (*users.User, error)
+}
+
+type messageSender interface {
+	SendMessage(ctx context.Context, dto *messages_dto.SendMessageDTO) (*chatEnts.Message, error)
+}
+
+// IncomingService manages incoming webhooks: secret URLs posting messages as a bot of the conversation.
+type IncomingService struct {
+	cfg         *config.Config
+	logger      logger.Logger
+	repos       *repositories.Repositories
+	db          *sqlx.DB
+	aCh         *utils.AccessChecker
+	hashService *hash.Service
+	bots        botCreator
+	messages    messageSender
+	evl         *chat_events.EventListener
+
+	limiters map[int64]*rate.Limiter
+	mu       sync.Mutex
+}
+
+func NewIncomingService(
+	cfg *config.Config,
+	cls *clients.Clients,
+	logger logger.Logger,
+	repos *repositories.Repositories,
+	bots botCreator,
+	messages messageSender,
+	evl *chat_events.EventListener,
+) *IncomingService {
+	return &IncomingService{
+		cfg:         cfg,
+		logger:      logger,
+		repos:       repos,
+		db:          cls.Postgres,
+		aCh:         utils.NewAccesChecker(logger, repos),
+		hashService: hash.NewService(cfg),
+		bots:        bots,
+		messages:    messages,
+		evl:         evl,
+		limiters:    make(map[int64]*rate.Limiter),
+	}
+}
+
+// CreateIncomingWebhook creates a bot, adds it to the conversation and returns the secret URL, it is shown only once.
+func (s *IncomingService) CreateIncomingWebhook(ctx context.Context, owner *users.User, dto *webhook_dto.CreateIncomingWebhookDTO) (w *integrations.IncomingWebhook, hookURL string, err error) {
+	if err := s.aCh.CanManageConversation(ctx, dto.ConversationID, owner.ID); err != nil {
+		return nil, "", err
+	}
+	token, err := s.hashService.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	w = &integrations.IncomingWebhook{
+		ConversationID:     dto.ConversationID,
+		CreatedBy:          owner.ID,
+		Name:               dto.Name,
+		TokenHash:          s.hashService.HashToken(token),
+		RateLimitPerMinute: dto.RateLimitPerMinute,
+	}
+	if w.RateLimitPerMinute == 0 {
+		w.RateLimitPerMinute = s.cfg.IncomingWebhookRateLimit
+	}
+
+	// the bot is created in the transaction, so a failure doesn't leave a bot without its webhook
+	var bot *users.User
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				s.logger.Error(ctx, fmt.Errorf("failed to rollback transaction: %w", rollbackErr))
+			}
+			return
+		}
+		if commitErr := tx.Commit(); commitErr != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", commitErr)
+			return
+		}
+		s.evl.PostParticipantAdded(&chatEnts.ConversationParticipant{
+			ConversationID: w.ConversationID,
+			UserID:         bot.ID,
+			JoinedAt:       time.Now(),
+			Role:           chatEnts.ParticipantRoleMember,
+		})
+	}()
+
+	if bot, err = s.bots.CreateBot(ctx, tx, owner, dto.Name); err != nil {
+		return nil, "", err
+	}
+	w.BotUserID = bot.ID
+	if err = s.repos.ConversationRepository.AddParticipant(ctx, tx, dto.ConversationID, bot.ID, chatEnts.ParticipantRoleMember); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("faild to add webhook bot to conversation: %w", err), slog.Int64("conversationId", dto.ConversationID))
+		return nil, "", fmt.Errorf("faild to add webhook bot to conversation: %w", err)
+	}
+	if err = s.repos.IncomingWebhookRepository.Create(ctx, tx, w); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to create incoming webhook: %w", err), slog.Int64("conversationId", dto.ConversationID))
+		return nil, "", fmt.Errorf("failed to create incoming webhook: %w", err)
+	}
+	s.logger.Info(ctx, "incoming webhook created", slog.Int64("webhookId", w.ID), slog.Int64("conversationId", w.ConversationID))
+
+	return w, strings.TrimSuffix(s.cfg.AppPublicURL, "/") + IncomingPath + token, nil
+}
+
+func (s *IncomingService) GetIncomingWebhooks(ctx context.Context, usrId, cnvId int64) ([]*integrations.IncomingWebhook, error) {
+	if err := s.aCh.CanManageConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	return s.repos.IncomingWebhookRepository.GetConversationWebhooks(ctx, cnvId)
+}
+
+// DeleteIncomingWebhook disables the URL, the bot stays in the conversation as the author of past messages.
+func (s *IncomingService) DeleteIncomingWebhook(ctx context.Context, usrId, cnvId, webhookId int64) error {
+	if err := s.aCh.CanManageConversation(ctx, cnvId, usrId); err != nil {
+		return err
+	}
+	ok, err := s.repos.IncomingWebhookRepository.Delete(ctx, cnvId, webhookId)
+	if err != nil {
+		return fmt.Errorf("failed to delete incoming webhook: %w", err)
+	}
+	if !ok {
+		return ErrWebhookNotFound
+	}
+	s.mu.Lock()
+	delete(s.limiters, webhookId)
+	s.mu.Unlock()
+
+	s.logger.Info(ctx, "incoming webhook deleted", slog.Int64("webhookId", webhookId), slog.Int64("conversationId", cnvId))
+	return nil
+}
+
+// PostMessage sends a message on behalf of the webhook bot.
+func (s *IncomingService) PostMessage(ctx context.Context, token string, dto *webhook_dto.IncomingMessageDTO) (*chatEnts.Message, error) {
+	if token == "" {
+		return nil, ErrInvalidHookToken
+	}
+	w, err := s.repos.IncomingWebhookRepository.GetWebhookByTokenHash(ctx, s.hashService.HashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incoming webhook: %w", err)
+	}
+	if w == nil {
+		return nil, ErrInvalidHookToken
+	}
+	if !s.getLimiter(w).Allow() {
+		return nil, ErrRateLimited
+	}
+
+	return s.messages.SendMessage(ctx, &messages_dto.SendMessageDTO{
+		ConversationID: w.ConversationID,
+		SenderID:       w.BotUserID,
+		Content:        dto.Text,
+		DisplayName:    dto.Username,
+		AvatarURL:      dto.AvatarURL,
+		Raw:            true,
+	})
+}
+
+func (s *IncomingService) getLimiter(w *integrations.IncomingWebhook) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limiter, exists := s.limiters[w.ID]
+	if !exists {
+		limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(w.RateLimitPerMinute)), w.RateLimitPerMinute)
+		s.limiters[w.ID] = limiter
+	}
+	return limiter
+}
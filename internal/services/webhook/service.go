@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"chatapp/internal/config"
+	webhook_dto "chatapp/internal/dto/webhook"
+	"chatapp/internal/entities/integrations"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"chatapp/internal/services/chat/utils"
+	"chatapp/internal/services/events"
+	"chatapp/internal/services/hash"
+	"chatapp/internal/services/netguard"
+	"chatapp/internal/services/secretbox"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrUnknownEvent    = errors.New("unknown webhook event")
+)
+
+// SupportedEvents are the conversation events which can be delivered to webhooks.
+var SupportedEvents = []string{
+	events.EventTypeMessageCreated,
+	events.EventTypeMessageUpdated,
+	events.EventTypeMessageExpired,
+	events.EventTypeMessagePinned,
+	events.EventTypeMessageUnpinned,
+	events.EventTypeParticipantAdded,
+	events.EventTypeConversationUpdated,
+	events.EventTypeUserUpdated,
+}
+
+// Service manages outgoing webhooks of conversations. Participants can see them, only conversation
+// admins can create and delete them as webhooks receive the conversation content.
+type Service struct {
+	cfg         *config.Config
+	logger      logger.Logger
+	repos       *repositories.Repositories
+	aCh         *utils.AccessChecker
+	box         *secretbox.Box
+	hashService *hash.Service
+}
+
+func NewService(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories, box *secretbox.Box) *Service {
+	return &Service{
+		cfg:         cfg,
+		logger:      logger,
+		repos:       repos,
+		aCh:         utils.NewAccesChecker(logger, repos),
+		box:         box,
+		hashService: hash.NewService(cfg),
+	}
+}
+
+// CreateWebhook subscribes the URL to conversation events and returns the signing secret, it is shown only once.
+// The URL must point to a public address.
+func (s *Service) CreateWebhook(ctx context.Context, dto *webhook_dto.CreateWebhookDTO) (*integrations.Webhook, string, error) {
+	if err := s.aCh.CanManageConversation(ctx, dto.ConversationID, dto.UserID); err != nil {
+		return nil, "", err
+	}
+	if err := netguard.CheckURL(ctx, dto.URL, s.cfg.WebhookAllowPrivateNetworks); err != nil {
+		return nil, "", err
+	}
+	evs := dto.Events
+	if len(evs) == 0 {
+		evs = SupportedEvents
+	}
+	for _, ev := range evs {
+		if !slices.Contains(SupportedEvents, ev) {
+			return nil, "", fmt.Errorf("%w: %s", ErrUnknownEvent, ev)
+		}
+	}
+
+	secret, err := s.hashService.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	sealed, err := s.box.Seal(secret)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+	w := &integrations.Webhook{
+		ConversationID: dto.ConversationID,
+		CreatedBy:      dto.UserID,
+		URL:            dto.URL,
+		Secret:         sealed,
+		Events:         evs,
+	}
+	if err := s.repos.WebhookRepository.Create(ctx, w); err != nil {
+		s.logger.Error(ctx, fmt.Errorf("failed to create webhook: %w", err), slog.Int64("conversationId", dto.ConversationID))
+		return nil, "", fmt.Errorf("failed to create webhook: %w", err)
+	}
+	s.logger.Info(ctx, "webhook created", slog.Int64("webhookId", w.ID), slog.Int64("conversationId", w.ConversationID))
+	return w, secret, nil
+}
+
+func (s *Service) GetWebhooks(ctx context.Context, usrId, cnvId int64) ([]*integrations.Webhook, error) {
+	if err := s.aCh.CanAccessConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	return s.repos.WebhookRepository.GetConversationWebhooks(ctx, cnvId)
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, usrId, cnvId, webhookId int64) error {
+	if err := s.aCh.CanManageConversation(ctx, cnvId, usrId); err != nil {
+		return err
+	}
+	ok, err := s.repos.WebhookRepository.Delete(ctx, cnvId, webhookId)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if !ok {
+		return ErrWebhookNotFound
+	}
+	s.logger.Info(ctx, "webhook deleted", slog.Int64("webhookId", webhookId), slog.Int64("conversationId", cnvId))
+	return nil
+}
+
+func (s *Service) GetDeliveries(ctx context.Context, params *webhook_dto.GetDeliveriesQueryParams) ([]*integrations.WebhookDelivery, error) {
+	if _, err := s.getWebhook(ctx, params.UserId, params.ConvId, params.WebhookId); err != nil {
+		return nil, err
+	}
+	return s.repos.WebhookRepository.GetDeliveries(ctx, params.WebhookId, params.LastId, params.Limit)
+}
+
+func (s *Service) GetDeadLetters(ctx context.Context, usrId, cnvId, webhookId int64, limit int) ([]*integrations.WebhookDeadLetter, error) {
+	if _, err := s.getWebhook(ctx, usrId, cnvId, webhookId); err != nil {
+		return nil, err
+	}
+	return s.repos.WebhookRepository.GetDeadLetters(ctx, webhookId, limit)
+}
+
+func (s *Service) getWebhook(ctx context.Context, usrId, cnvId, webhookId int64) (*integrations.Webhook, error) {
+	if err := s.aCh.CanAccessConversation(ctx, cnvId, usrId); err != nil {
+		return nil, err
+	}
+	w, err := s.repos.WebhookRepository.GetWebhookById(ctx, webhookId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	if w == nil || w.ConversationID != cnvId {
+		return nil, ErrWebhookNotFound
+	}
+	return w, nil
+}
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"chatapp/internal/config"
+	"chatapp/internal/entities/integrations"
+	"chatapp/internal/logger"
+	"chatapp/internal/services/secretbox"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"message.created"}`)
+	// computed independently: HMAC-SHA256("whsec_test", "1700000000." + body)
+	want := "sha256=9884eb2fcc09ffc10f00127fff0a0c5686da2fef61d0363442271c6dfa1917eb"
+	if got := Sign("whsec_test", 1700000000, body); got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+
+	for name, other := range map[string]string{
+		"secret":    Sign("whsec_other", 1700000000, body),
+		"timestamp": Sign("whsec_test", 1700000001, body),
+		"body":      Sign("whsec_test", 1700000000, []byte(`{"event":"message.deleted"}`)),
+	} {
+		if other == want {
+			t.Errorf("signature does not depend on the %s", name)
+		}
+	}
+}
+
+// verify is what a receiver does with the delivery headers.
+func verify(secret string, r *http.Request, body []byte) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(r.Header.Get(TimestampHeader) + "."))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(r.Header.Get(SignatureHeader)))
+}
+
+func TestDeliveriesAreSigned(t *testing.T) {
+	const secret = "whsec_delivery"
+	type received struct {
+		header http.Header
+		ok     bool
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{header: r.Header, ok: verify(secret, r, body)}
+	}))
+	defer srv.Close()
+
+	box, err := secretbox.New(strings.Repeat("s", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := box.Seal(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{WebhookTimeout: time.Second, WebhookAllowPrivateNetworks: true}
+	w := NewWorker(cfg, logger.NewSLogger(), nil, box)
+
+	hook := &integrations.Webhook{ID: 1, URL: srv.URL, Secret: sealed}
+	d := &integrations.WebhookDelivery{ID: 42, WebhookID: 1, EventType: "message.created", Payload: []byte(`{"id":1}`)}
+	status, err := w.send(context.Background(), hook, d)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("send = (%d, %v)", status, err)
+	}
+
+	r := <-got
+	if !r.ok {
+		t.Fatal("receiver could not verify the signature")
+	}
+	ts, err := strconv.ParseInt(r.header.Get(TimestampHeader), 10, 64)
+	if err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		t.Errorf("timestamp header = %q", r.header.Get(TimestampHeader))
+	}
+	if r.header.Get(EventHeader) != "message.created" || r.header.Get(DeliveryHeader) != "42" {
+		t.Errorf("event headers = %v", r.header)
+	}
+	if verify("whsec_other", &http.Request{Header: r.header}, []byte(`{"id":1}`)) {
+		t.Error("signature verified with another secret")
+	}
+}
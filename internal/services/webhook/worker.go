@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"bytes"
+	"chatapp/internal/config"
+	"chatapp/internal/entities/integrations"
+	"chatapp/internal/logger"
+	"chatapp/internal/repositories"
+	"chatapp/internal/services/netguard"
+	"chatapp/internal/services/secretbox"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const maxErrorLength = 500
+
+// Worker sends queued deliveries, retrying failures with exponential backoff.
+// Deliveries which fail all attempts are moved to the dead-letter table.
+type Worker struct {
+	cfg    *config.Config
+	logger logger.Logger
+	repos  *repositories.Repositories
+	box    *secretbox.Box
+	client *http.Client
+}
+
+func NewWorker(cfg *config.Config, logger logger.Logger, repos *repositories.Repositories, box *secretbox.Box) *Worker {
+	return &Worker{
+		cfg:    cfg,
+		logger: logger,
+		repos:  repos,
+		box:    box,
+		client: netguard.NewClient(cfg.WebhookTimeout, cfg.WebhookAllowPrivateNetworks),
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.WebhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.processBatch(ctx)
+		}
+	}
+}
+
+func (w *Worker) processBatch(ctx context.Context) {
+	// the lease must outlive a request, otherwise another worker could send the delivery twice
+	lease := w.cfg.WebhookTimeout + 30*time.Second
+	deliveries, err := w.repos.WebhookRepository.ClaimDueDeliveries(ctx, w.cfg.WebhookBatchSize, lease)
+	if err != nil {
+		w.logger.Error(ctx, fmt.Errorf("failed to claim webhook deliveries: %w", err))
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.process(ctx, d)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) process(ctx context.Context, d *integrations.WebhookDelivery) {
+	hook, err := w.repos.WebhookRepository.GetWebhookById(ctx, d.WebhookID)
+	if err != nil {
+		w.logger.Error(ctx, fmt.Errorf("failed to get webhook: %w", err), slog.Int64("deliveryId", d.ID))
+		return
+	}
+	if hook == nil {
+		return
+	}
+
+	status, sendErr := w.send(ctx, hook, d)
+	d.Attempts++
+	if status != 0 {
+		d.ResponseStatus = &status
+	}
+	if sendErr == nil {
+		if err := w.repos.WebhookRepository.MarkDelivered(ctx, d); err != nil {
+			w.logger.Error(ctx, fmt.Errorf("failed to mark webhook delivered: %w", err), slog.Int64("deliveryId", d.ID))
+		}
+		return
+	}
+
+	msg := sendErr.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	d.LastError = &msg
+	if d.Attempts >= w.cfg.WebhookMaxAttempts {
+		w.logger.Warn(ctx, "webhook delivery moved to dead letters", slog.Int64("deliveryId", d.ID), slog.Int64("webhookId", hook.ID), slog.String("error", msg))
+		if err := w.repos.WebhookRepository.MoveToDeadLetter(ctx, d); err != nil {
+			w.logger.Error(ctx, fmt.Errorf("failed to move webhook delivery to dead letters: %w", err), slog.Int64("deliveryId", d.ID))
+		}
+		return
+	}
+	d.NextAttemptAt = time.Now().Add(w.backoff(d.Attempts))
+	if err := w.repos.WebhookRepository.ScheduleRetry(ctx, d); err != nil {
+		w.logger.Error(ctx, fmt.Errorf("failed to schedule webhook retry: %w", err), slog.Int64("deliveryId", d.ID))
+	}
+}
+
+func (w *Worker) send(ctx context.Context, hook *integrations.Webhook, d *integrations.WebhookDelivery) (int, error) {
+	secret, err := w.box.Open(hook.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt webhook secret: %w", err)
+	}
+	ts := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "chatapp-webhooks/1.0")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, ts, d.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff doubles the delay after every failed attempt up to the configured maximum.
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.cfg.WebhookBackoffBase
+	for i := 1; i < attempts && delay < w.cfg.WebhookBackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, w.cfg.WebhookBackoffMax)
+}
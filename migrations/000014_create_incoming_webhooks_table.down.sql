@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS incoming_webhooks;
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS avatar_url,
+    DROP COLUMN IF EXISTS display_name;
+
+ALTER TABLE conversation_participants
+    DROP COLUMN IF EXISTS role;
@@ -0,0 +1,36 @@
+ALTER TABLE conversation_participants
+    ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'member';
+
+-- creators were not recorded before, the earliest participant of a group is taken as its creator.
+-- Direct conversations have nobody to moderate, both sides are admins like in new ones.
+UPDATE conversation_participants cp SET role = 'admin'
+FROM conversations c
+WHERE c.id = cp.conversation_id AND (
+    NOT c.is_group
+    OR cp.user_id = (
+        SELECT earliest.user_id FROM conversation_participants earliest
+        WHERE earliest.conversation_id = cp.conversation_id
+        ORDER BY earliest.joined_at, earliest.user_id
+        LIMIT 1
+    )
+);
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS display_name VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS avatar_url VARCHAR(2048);
+
+CREATE TABLE IF NOT EXISTS incoming_webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    conversation_id BIGINT NOT NULL,
+    created_by BIGINT NOT NULL,
+    bot_user_id BIGINT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    rate_limit_per_minute INT NOT NULL DEFAULT 60,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (bot_user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS incoming_webhooks_conversation_id_idx ON incoming_webhooks (conversation_id);
@@ -0,0 +1,22 @@
+ALTER TABLE conversations
+    ADD COLUMN IF NOT EXISTS topic VARCHAR(250) NOT NULL DEFAULT '';
+
+ALTER TABLE conversation_participants
+    ADD COLUMN IF NOT EXISTS muted BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS muted_until TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS bot_commands (
+    id BIGSERIAL PRIMARY KEY,
+    conversation_id BIGINT NOT NULL,
+    created_by BIGINT NOT NULL,
+    bot_user_id BIGINT NOT NULL,
+    name VARCHAR(32) NOT NULL,
+    description VARCHAR(250) NOT NULL DEFAULT '',
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (conversation_id, name),
+    FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (bot_user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS message_mentions;
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS mentions;
@@ -0,0 +1,16 @@
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS mentions JSONB;
+
+CREATE TABLE IF NOT EXISTS message_mentions (
+    message_id BIGINT NOT NULL,
+    conversation_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    read_at TIMESTAMP,
+    PRIMARY KEY (message_id, user_id),
+    FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE,
+    FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_mentions_unread ON message_mentions (user_id, conversation_id) WHERE read_at IS NULL;
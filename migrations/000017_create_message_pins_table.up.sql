@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS message_pins (
+    message_id BIGINT PRIMARY KEY,
+    conversation_id BIGINT NOT NULL,
+    pinned_by BIGINT,
+    pinned_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE,
+    FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+    FOREIGN KEY (pinned_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_pins_conversation ON message_pins (conversation_id, pinned_at);
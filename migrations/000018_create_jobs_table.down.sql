@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS scheduled_messages;
+DROP TABLE IF EXISTS jobs;
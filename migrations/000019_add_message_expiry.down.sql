@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_messages_expires_at;
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS expires_at;
+
+ALTER TABLE conversations
+    DROP COLUMN IF EXISTS message_ttl;
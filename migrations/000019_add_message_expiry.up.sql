@@ -0,0 +1,7 @@
+ALTER TABLE conversations
+    ADD COLUMN IF NOT EXISTS message_ttl INTEGER;
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages (expires_at) WHERE expires_at IS NOT NULL;
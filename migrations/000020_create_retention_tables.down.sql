@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS retention_runs;
+
+DROP TABLE IF EXISTS messages_archive;
+
+DROP INDEX IF EXISTS idx_messages_conversation_created_at;
+
+ALTER TABLE conversations
+    DROP COLUMN IF EXISTS legal_hold,
+    DROP COLUMN IF EXISTS retention_days;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS is_admin;
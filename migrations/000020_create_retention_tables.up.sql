@@ -0,0 +1,40 @@
+-- system administrators are granted directly in the database
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- retention_days overrides the global default, 0 keeps messages forever
+ALTER TABLE conversations
+    ADD COLUMN IF NOT EXISTS retention_days INTEGER,
+    ADD COLUMN IF NOT EXISTS legal_hold BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_created_at ON messages (conversation_id, created_at);
+
+-- purged messages are moved here when archiving is enabled, rows outlive their conversations and senders
+CREATE TABLE IF NOT EXISTS messages_archive (
+    id BIGINT PRIMARY KEY,
+    conversation_id BIGINT NOT NULL,
+    sender_id BIGINT NOT NULL,
+    content TEXT NOT NULL,
+    display_name VARCHAR(50),
+    avatar_url VARCHAR(2048),
+    mentions JSONB,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    archived_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_archive_conversation_id ON messages_archive (conversation_id, created_at);
+
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id BIGSERIAL PRIMARY KEY,
+    status VARCHAR(16) NOT NULL DEFAULT 'running',
+    archive BOOLEAN NOT NULL,
+    default_days INTEGER NOT NULL,
+    conversations INTEGER NOT NULL DEFAULT 0,
+    held_conversations INTEGER NOT NULL DEFAULT 0,
+    purged_messages BIGINT NOT NULL DEFAULT 0,
+    details JSONB,
+    error TEXT,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP
+);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS exports (
+    id BIGSERIAL PRIMARY KEY,
+    conversation_id BIGINT NOT NULL,
+    requested_by BIGINT NOT NULL,
+    format VARCHAR(8) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    file_path TEXT,
+    size BIGINT,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+    FOREIGN KEY (requested_by) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_exports_expires_at ON exports (expires_at) WHERE expires_at IS NOT NULL;
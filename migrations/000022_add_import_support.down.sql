@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS message_reactions;
+
+DROP INDEX IF EXISTS idx_messages_external_id;
+DROP INDEX IF EXISTS idx_messages_parent_id;
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS external_id,
+    DROP COLUMN IF EXISTS parent_id;
+
+DROP INDEX IF EXISTS idx_conversations_external_id;
+
+ALTER TABLE conversations
+    DROP COLUMN IF EXISTS external_id;
@@ -0,0 +1,23 @@
+-- external_id keys rows created by imports, re-running an import skips rows that already exist
+ALTER TABLE conversations
+    ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_external_id ON conversations (external_id) WHERE external_id IS NOT NULL;
+
+-- parent_id links thread replies to their root message
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES messages (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_messages_parent_id ON messages (parent_id) WHERE parent_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_external_id ON messages (conversation_id, external_id) WHERE external_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS message_reactions (
+    message_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    emoji VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, user_id, emoji),
+    FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
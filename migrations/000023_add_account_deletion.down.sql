@@ -0,0 +1,13 @@
+ALTER TABLE messages
+DROP CONSTRAINT IF EXISTS messages_sender_id_fkey;
+
+ALTER TABLE messages
+ADD CONSTRAINT messages_sender_id_fkey
+    FOREIGN KEY (sender_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE;
+
+DELETE FROM users WHERE email = 'deleted-user@deleted.invalid';
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,18 @@
+-- deleted_at marks accounts anonymized on request, their rows stay so message history keeps its senders
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- messages of deleted accounts are moved to this placeholder, it can't sign in
+INSERT INTO users (user_name, email, password, created_at, is_bot, deleted_at)
+VALUES ('Deleted user', 'deleted-user@deleted.invalid', '!', NOW(), TRUE, NOW())
+ON CONFLICT (email) DO NOTHING;
+
+-- deleting a user must not delete other people's conversation history
+ALTER TABLE messages
+DROP CONSTRAINT IF EXISTS messages_sender_id_fkey;
+
+ALTER TABLE messages
+ADD CONSTRAINT messages_sender_id_fkey
+    FOREIGN KEY (sender_id)
+    REFERENCES users (id)
+    ON DELETE RESTRICT;
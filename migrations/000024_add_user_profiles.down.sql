@@ -0,0 +1,7 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS pending_email,
+    DROP COLUMN IF EXISTS avatar_url,
+    DROP COLUMN IF EXISTS timezone,
+    DROP COLUMN IF EXISTS status_text,
+    DROP COLUMN IF EXISTS bio,
+    DROP COLUMN IF EXISTS display_name;
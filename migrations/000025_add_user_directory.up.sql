@@ -0,0 +1,10 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE users
+    -- discoverability is 'everyone' to be listed in the user directory or 'conversations'
+    -- to be visible only to people sharing a conversation
+    ADD COLUMN IF NOT EXISTS discoverability VARCHAR(20) NOT NULL DEFAULT 'everyone';
+
+-- trigram indexes serve the prefix LIKE queries of the directory search
+CREATE INDEX IF NOT EXISTS users_user_name_trgm_idx ON users USING gin (lower(user_name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_display_name_trgm_idx ON users USING gin (lower(display_name) gin_trgm_ops);
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS dm_policy;
+
+DROP TABLE IF EXISTS contacts;
+DROP TABLE IF EXISTS contact_requests;
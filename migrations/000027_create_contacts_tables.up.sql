@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS contact_requests (
+    id BIGSERIAL PRIMARY KEY,
+    sender_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    recipient_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    -- status is pending until the request is accepted, declined or canceled
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    responded_at TIMESTAMP
+);
+
+-- a pair of users has at most one pending request, whoever sent it
+CREATE UNIQUE INDEX IF NOT EXISTS contact_requests_pending_pair_idx
+    ON contact_requests (LEAST(sender_id, recipient_id), GREATEST(sender_id, recipient_id))
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS contact_requests_recipient_id_idx ON contact_requests (recipient_id) WHERE status = 'pending';
+
+-- contacts are stored in both directions
+CREATE TABLE IF NOT EXISTS contacts (
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    contact_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, contact_id)
+);
+
+ALTER TABLE users
+    -- dm_policy is 'everyone' or 'contacts' when only contacts can start direct conversations
+    ADD COLUMN IF NOT EXISTS dm_policy VARCHAR(20) NOT NULL DEFAULT 'everyone';
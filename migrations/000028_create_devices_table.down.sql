@@ -0,0 +1,4 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS push_level;
+
+DROP TABLE IF EXISTS devices;
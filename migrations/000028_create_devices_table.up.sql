@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS devices (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    platform VARCHAR(20) NOT NULL,
+    -- push_token moves to the user who registered it last, like after signing in with another account
+    push_token VARCHAR(512) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS devices_user_id_idx ON devices (user_id);
+
+ALTER TABLE users
+    -- push_level is 'all', 'mentions' for mentions and direct messages only, or 'none'
+    ADD COLUMN IF NOT EXISTS push_level VARCHAR(20) NOT NULL DEFAULT 'all';
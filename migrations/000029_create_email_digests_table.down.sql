@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS email_digests;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS digest_frequency,
+    DROP COLUMN IF EXISTS quiet_hours_start,
+    DROP COLUMN IF EXISTS quiet_hours_end;
+
+ALTER TABLE conversation_participants
+    DROP COLUMN IF EXISTS last_seen_at;
@@ -0,0 +1,18 @@
+ALTER TABLE conversation_participants
+    -- last_seen_at is when the user last had the conversation open, later messages are unread
+    ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+ALTER TABLE users
+    -- digest_frequency is 'daily', 'weekly' or 'off'
+    ADD COLUMN IF NOT EXISTS digest_frequency VARCHAR(20) NOT NULL DEFAULT 'daily',
+    -- quiet hours are hours of the day in the user's timezone, both are set or both are NULL
+    ADD COLUMN IF NOT EXISTS quiet_hours_start SMALLINT,
+    ADD COLUMN IF NOT EXISTS quiet_hours_end SMALLINT;
+
+-- email_digests keeps the last digest of each user, messages up to last_message_id were digested
+CREATE TABLE IF NOT EXISTS email_digests (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    last_message_id BIGINT NOT NULL,
+    message_count INT NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT NOW()
+);